@@ -0,0 +1,18 @@
+# AOI ACL policy, applied alongside acl.rules and reloaded when this file changes.
+# Point acl.policy_file at it and check edits first with aoi.acl.validate.
+version = "2026-10-16.1"
+
+# Everyone may read projects
+rule {
+  agent_id   = "*"
+  resource   = "projects/**"
+  permission = "read"
+}
+
+# QA never sees secret projects
+rule {
+  agent_id   = "role:qa"
+  resource   = "projects/secret/**"
+  permission = "read"
+  effect     = "deny"
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"crypto/ed25519"
+	"crypto/tls"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -15,13 +17,21 @@ import (
 	"github.com/aoi-protocol/aoi/internal/acl"
 	"github.com/aoi-protocol/aoi/internal/config"
 	aoicontext "github.com/aoi-protocol/aoi/internal/context"
+	"github.com/aoi-protocol/aoi/internal/disclosure"
+	"github.com/aoi-protocol/aoi/internal/focus"
+	"github.com/aoi-protocol/aoi/internal/gossip"
 	"github.com/aoi-protocol/aoi/internal/h2a"
 	agentidentity "github.com/aoi-protocol/aoi/internal/identity"
+	"github.com/aoi-protocol/aoi/internal/llm"
 	"github.com/aoi-protocol/aoi/internal/mcp"
 	"github.com/aoi-protocol/aoi/internal/notify"
+	"github.com/aoi-protocol/aoi/internal/pki"
 	"github.com/aoi-protocol/aoi/internal/protocol"
+	"github.com/aoi-protocol/aoi/internal/redact"
 	"github.com/aoi-protocol/aoi/internal/secretary"
+	"github.com/aoi-protocol/aoi/internal/signing"
 	"github.com/aoi-protocol/aoi/internal/tailscale"
+	"github.com/aoi-protocol/aoi/internal/task"
 	"github.com/aoi-protocol/aoi/pkg/aoi"
 )
 
@@ -62,41 +72,137 @@ func main() {
 		cfg.Agent.Owner = *owner
 	}
 
-	// Map role string to AgentRole
-	var agentRole aoi.AgentRole
-	switch cfg.Agent.Role {
-	case "pm":
-		agentRole = aoi.RolePM
-	case "engineer":
-		agentRole = aoi.RoleEngineer
-	case "qa":
-		agentRole = aoi.RoleQA
-	case "design":
-		agentRole = aoi.RoleDesign
-	default:
-		agentRole = aoi.RoleEngineer
+	// Roles are defined in config; an unknown role is an error rather than an engineer
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid config: %v", err)
 	}
+	roleDef, _ := cfg.Role(cfg.Agent.Role)
+	agentRole := aoi.AgentRole(roleDef.Name)
 
 	// Create agent identity
+	scheme := "http"
+	if cfg.Network.TLSEnabled {
+		scheme = "https"
+	}
+	endpoint := cfg.Network.AdvertiseURL
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("%s://%s", scheme, cfg.Network.ListenAddr)
+	}
 	identity := &aoi.AgentIdentity{
-		ID:       cfg.Agent.ID,
-		Role:     agentRole,
-		Owner:    cfg.Agent.Owner,
-		Status:   "online",
-		Endpoint: fmt.Sprintf("http://%s", cfg.Network.ListenAddr),
+		ID:              cfg.Agent.ID,
+		Role:            agentRole,
+		RoleDisplayName: roleDef.DisplayName,
+		Owner:           cfg.Agent.Owner,
+		Capabilities:    mergeCapabilities(roleDef.Capabilities, cfg.Agent.Capabilities),
+		Status:          "online",
+		Endpoint:        endpoint,
+		Manifest:        manifestFromConfig(cfg.Agent.Manifest),
 	}
 
-	// Create secretary
+	// Create secretary; the role decides how it answers queries
 	sec := secretary.NewSecretary(identity)
+	if err := sec.SetHandler(roleDef.Handler); err != nil {
+		log.Fatalf("Invalid config: role %q: %v", roleDef.Name, err)
+	}
+
+	// A language model, if configured, answers with the role's system prompt
+	provider, err := llm.New(cfg.LLM)
+	if err != nil {
+		log.Fatalf("Invalid config: %v", err)
+	}
+	if provider != nil {
+		sec.SetProvider(provider)
+		sec.SetSystemPrompt(roleDef.SystemPrompt)
+		log.Printf("Secretary: answering with %s model %s", cfg.LLM.Provider, cfg.LLM.Model)
+	}
+
+	// Create registry; file storage keeps registered agents across restarts
+	registryStore, err := agentidentity.OpenStorage(cfg.Registry.Storage, cfg.Registry.Path)
+	if err != nil {
+		log.Fatalf("Registry storage error: %v", err)
+	}
+	registry, err := agentidentity.NewAgentRegistryWithStorage(registryStore)
+	if err != nil {
+		log.Fatalf("Registry storage error: %v", err)
+	}
+	// Changes made here are stamped with this agent's ID when registries gossip
+	registry.SetNodeID(identity.ID)
+
+	// Signed requests prove which agent sent them; the public key is published
+	// in the registry so that peers can verify them
+	var signingKey ed25519.PrivateKey
+	switch cfg.Signing.Mode {
+	case "", signing.ModeOff:
+	case signing.ModeOptional, signing.ModeRequired:
+		signingKey, err = signing.LoadOrGenerateKey(cfg.Signing.KeyFile)
+		if err != nil {
+			log.Fatalf("Signing key error: %v", err)
+		}
+		identity.PublicKey = aoi.EncodePublicKey(signingKey.Public().(ed25519.PublicKey))
+		// Peers only accept this agent's entry from a relay when it is signed
+		registry.SetNodeKey(signingKey)
+	default:
+		log.Fatalf("Unknown signing mode %q", cfg.Signing.Mode)
+	}
+
+	if cfg.Registry.Storage == agentidentity.StorageFile {
+		log.Printf("Registry: file storage in %s (%d agents restored)", cfg.Registry.Path, len(registry.Discover()))
+	}
+	if err := registry.Register(identity); err != nil {
+		log.Printf("Failed to register self: %v", err)
+	}
 
-	// Create registry
-	registry := agentidentity.NewAgentRegistry()
-	_ = registry.Register(identity)
+	// Agents that stop sending aoi.heartbeat are marked offline when their lease runs out
+	leaseTTL := parseDuration(cfg.Registry.LeaseTTL, agentidentity.DefaultLeaseTTL)
+	sweepInterval := parseDuration(cfg.Registry.SweepInterval, agentidentity.DefaultSweepInterval)
+	registry.SetLeaseTTL(leaseTTL)
+	registry.StartLeaseSweeper(sweepInterval)
+	log.Printf("Registry: heartbeat lease %s (sweep every %s)", leaseTTL, sweepInterval)
 
-	// Create ACL manager and configure rules
+	// Create ACL manager and configure rules. Subjects may be agent IDs, globs
+	// such as "*" or "eng-*", or roles ("role:qa").
 	aclMgr := acl.NewAclManager()
 	for _, rule := range cfg.ACL.Rules {
-		log.Printf("ACL Rule: %s -> %s: %s", rule.AgentID, rule.Resource, rule.Permission)
+		r := acl.NewRule(rule.AgentID, rule.Resource, acl.PermissionLevel(config.ParsePermission(rule.Permission)))
+		r.Effect = acl.Effect(rule.Effect)
+		aclMgr.AddRule(r)
+		if r.Effect == acl.EffectDeny {
+			log.Printf("ACL Rule: %s -> %s: deny %s", rule.AgentID, rule.Resource, rule.Permission)
+		} else {
+			log.Printf("ACL Rule: %s -> %s: %s", rule.AgentID, rule.Resource, rule.Permission)
+		}
+	}
+
+	// Temporary grants issued through approvals are revoked when they expire
+	aclMgr.StartGrantSweeper(acl.DefaultGrantSweepInterval)
+
+	// Role grants apply to every agent registered with the role
+	aclMgr.SetRoleResolver(func(agentID string) (string, bool) {
+		agent, err := registry.GetAgent(agentID)
+		if err != nil {
+			return "", false
+		}
+		return string(agent.Role), true
+	})
+	for _, name := range cfg.RoleNames() {
+		role, _ := cfg.Role(name)
+		for _, grant := range role.Grants {
+			aclMgr.AddRule(&acl.AccessRule{
+				Role:       role.Name,
+				Resource:   grant.Resource,
+				Permission: acl.PermissionLevel(config.ParsePermission(grant.Permission)),
+			})
+			log.Printf("ACL Role Grant: role:%s -> %s: %s", role.Name, grant.Resource, grant.Permission)
+		}
+	}
+
+	// The policy file is reloaded when it changes, so access can be changed
+	// without restarting the agent; an invalid policy at startup is fatal
+	aclMgr.SetKnownRoles(cfg.RoleNames())
+	if cfg.ACL.PolicyFile != "" {
+		if err := aclMgr.WatchPolicy(cfg.ACL.PolicyFile, acl.DefaultPolicyPollInterval); err != nil {
+			log.Fatalf("Failed to load ACL policy: %v", err)
+		}
 	}
 
 	// Create notification manager
@@ -129,7 +235,7 @@ func main() {
 						log.Printf("   Tailscale IPs: %v", selfNode.IPs)
 					}
 					// Update identity with Tailscale node ID
-					identity.TailscaleNodeID = selfNode.ID
+					_ = registry.UpdateTailscaleNodeID(identity.ID, selfNode.ID)
 				}
 			} else {
 				log.Printf("   Tailscale: not connected (running in fallback mode)")
@@ -147,6 +253,9 @@ func main() {
 	contextMonitor.SetPollInterval(pollInterval)
 	contextAPI := aoicontext.NewContextAPI(contextMonitor, contextStore)
 
+	// The secretary answers queries from the context it mirrors
+	sec.SetContextStore(contextStore)
+
 	// Add configured watch paths
 	for _, watchPath := range cfg.Context.WatchPaths {
 		if watchPath != "" && watchPath != "." {
@@ -199,6 +308,25 @@ func main() {
 		}
 	}
 
+	// Advertise the tools of auto-connected MCP servers in the capability manifest
+	if cfg.MCP.Enabled && len(mcpBridge.ListClients()) > 0 {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			tools := mcpBridge.ToolNames(ctx)
+			if len(tools) == 0 {
+				return
+			}
+			manifest := manifestFromConfig(cfg.Agent.Manifest)
+			manifest.MCPTools = append(manifest.MCPTools, tools...)
+			if err := registry.UpdateManifest(identity.ID, manifest); err != nil {
+				log.Printf("Failed to update manifest: %v", err)
+				return
+			}
+			log.Printf("Manifest: %d MCP tools advertised", len(tools))
+		}()
+	}
+
 	// Initialize H2A manager
 	h2aMgr := h2a.NewH2AManager()
 	if cfg.H2A.Enabled {
@@ -207,8 +335,161 @@ func main() {
 		h2aMgr.SetPMUsers(cfg.H2A.PMUsers)
 	}
 
+	// Delegated queries are handed to the worker AI and answered with its reply
+	delegationTimeout := parseDuration(cfg.Delegation.Timeout, secretary.DefaultDelegationTimeout)
+	if cfg.Delegation.Mode != "" {
+		var worker secretary.Worker
+		switch cfg.Delegation.Worker {
+		case "h2a":
+			if cfg.Delegation.TmuxSession != "" {
+				if err := h2aMgr.RegisterSession(identity.ID, cfg.Delegation.TmuxSession, cfg.Delegation.TmuxPane); err != nil {
+					log.Fatalf("Invalid config: delegation: %v", err)
+				}
+			}
+			h2aWorker := secretary.NewH2AWorker(h2aMgr, identity.ID)
+			h2aWorker.SetPollInterval(parseDuration(cfg.Delegation.PollInterval, secretary.DefaultWorkerPollInterval))
+			worker = h2aWorker
+		case "mcp":
+			worker = secretary.NewMCPWorker(mcpBridge, cfg.Delegation.MCPServer, cfg.Delegation.MCPTool)
+		}
+		sec.SetWorker(worker, cfg.Delegation.Mode, delegationTimeout)
+		log.Printf("Delegation: %s to %s (timeout %s)", cfg.Delegation.Mode, worker.Name(), delegationTimeout)
+	}
+
 	// Create protocol server with JSON-RPC support
 	server := protocol.NewServerFull(registry, aclMgr, contextAPI, mcpBridge, h2aMgr)
+	server.SetSecretary(sec)
+	// Queries forwarded to peers wait out their delegation, assumed to be as long as ours
+	server.SetForwardTimeout(delegationTimeout + protocol.ForwardTimeoutMargin)
+	if len(cfg.ACL.Tokens) > 0 {
+		tokens := make(map[string]string)
+		for _, token := range cfg.ACL.Tokens {
+			tokens[token.Token] = token.AgentID
+		}
+		server.SetAuthTokens(tokens)
+	}
+	if tsIntegration != nil {
+		server.SetTailscale(tsIntegration)
+	}
+	if cfg.ACL.Enforce {
+		server.SetACLEnforced(true)
+		log.Printf("ACL: enforced on every JSON-RPC method and REST route (%d tokens)", len(cfg.ACL.Tokens))
+	}
+	if cfg.Disclosure.DefaultLevel != "" {
+		level, err := disclosure.ParseLevel(cfg.Disclosure.DefaultLevel)
+		if err != nil {
+			log.Fatalf("Invalid config: disclosure: %v", err)
+		}
+		server.SetDisclosure(level)
+		log.Printf("Disclosure: %s by default, more with read on %s or %s", level, disclosure.ResourceAnalysis, disclosure.ResourceFull)
+	}
+	// Secrets and personal data are redacted from answers, context history,
+	// MCP output and terminal captures before they leave the agent
+	if !cfg.Redaction.Disabled {
+		var detectors []redact.Detector
+		if len(cfg.Redaction.Detectors) == 0 {
+			detectors = redact.Builtins()
+		}
+		for _, name := range cfg.Redaction.Detectors {
+			detector, _ := redact.Builtin(name)
+			detectors = append(detectors, detector)
+		}
+		for _, pattern := range cfg.Redaction.Patterns {
+			detector, err := redact.NewDetector(pattern.Name, pattern.Pattern)
+			if err != nil {
+				log.Fatalf("Invalid config: redaction: %v", err)
+			}
+			detectors = append(detectors, detector)
+		}
+		redactor := redact.New(detectors)
+		server.SetRedactor(redactor)
+		log.Printf("Redaction: %v", redactor.Detectors())
+	}
+	if signingKey != nil {
+		maxSkew := parseDuration(cfg.Signing.MaxSkew, signing.DefaultMaxSkew)
+		server.SetSigning(signing.NewVerifier(registry, cfg.Signing.Mode, maxSkew), identity.ID, signingKey)
+		log.Printf("Signing: %s (key %s, max skew %s)", cfg.Signing.Mode, cfg.Signing.KeyFile, maxSkew)
+	}
+
+	// Focus mode: while the human is focused, queries are answered from
+	// context, queued for a digest or escalated according to the focus rules
+	focusOpts := focus.Options{
+		AutoDetect:     cfg.Focus.AutoDetect,
+		BurstWindow:    parseDuration(cfg.Focus.BurstWindow, focus.DefaultBurstWindow),
+		BurstThreshold: cfg.Focus.BurstThreshold,
+		IdleTimeout:    parseDuration(cfg.Focus.IdleTimeout, focus.DefaultIdleTimeout),
+		MinConfidence:  cfg.Focus.MinConfidence,
+	}
+	var focusRules []focus.Rule
+	for _, rule := range cfg.Focus.Rules {
+		focusRules = append(focusRules, focus.Rule{
+			SenderRole: rule.SenderRole,
+			Priority:   rule.Priority,
+			Topic:      rule.Topic,
+			Action:     focus.Action(rule.Action),
+		})
+	}
+	server.Focus().Configure(focusOpts, focusRules)
+	server.Focus().Start(focus.DefaultCheckInterval)
+	if cfg.Focus.AutoDetect {
+		// Bursts of file changes in the watched paths mean the human is at work
+		contextMonitor.OnChange(func(aoicontext.FileChangeEvent) {
+			server.Focus().RecordActivity(identity.ID, time.Now())
+		})
+		log.Printf("Focus: automatic detection on (%d rules)", len(server.Focus().Rules()))
+	}
+
+	// TLS with optional mutual authentication by agent certificate
+	var tlsConfig, forwardTLS *tls.Config
+	if cfg.Network.TLSEnabled {
+		var err error
+		tlsConfig, err = pki.NewServerTLSConfig(pki.ServerConfig{
+			CertFile:   cfg.Network.TLSCertFile,
+			KeyFile:    cfg.Network.TLSKeyFile,
+			CAFile:     cfg.Network.TLSCAFile,
+			ClientAuth: cfg.Network.TLSClientAuth,
+		})
+		if err != nil {
+			log.Fatalf("TLS configuration error: %v", err)
+		}
+		forwardTLS, err = pki.NewClientTLSConfig(cfg.Network.TLSCertFile, cfg.Network.TLSKeyFile, cfg.Network.TLSCAFile)
+		if err != nil {
+			log.Fatalf("TLS configuration error: %v", err)
+		}
+		server.SetForwardTLS(forwardTLS)
+	}
+
+	// Registry gossip lets the team's agents find each other without a central server
+	var gossiper *gossip.Gossiper
+	if cfg.Gossip.Enabled {
+		hc := &http.Client{Timeout: gossip.DefaultTimeout}
+		if forwardTLS != nil {
+			hc.Transport = &http.Transport{TLSClientConfig: forwardTLS}
+		}
+		gossiper = gossip.New(registry, gossip.Config{
+			Seeds:        cfg.Gossip.Seeds,
+			Interval:     parseDuration(cfg.Gossip.Interval, gossip.DefaultInterval),
+			Fanout:       cfg.Gossip.Fanout,
+			TombstoneTTL: parseDuration(cfg.Gossip.TombstoneTTL, agentidentity.DefaultTombstoneTTL),
+			HTTPClient:   hc,
+			SigningKey:   signingKey,
+			Caller:       protocol.AuthenticatedAgent,
+		})
+		gossiper.RegisterMethods(server.Methods())
+		gossiper.Start()
+		log.Printf("Gossip: enabled (seeds=%v, interval=%s)", cfg.Gossip.Seeds, cfg.Gossip.Interval)
+	}
+
+	// Tasks typed into tmux sessions need H2A, and the same permission as aoi.h2a.send
+	if cfg.H2A.Enabled {
+		server.TaskExecutors().Register(task.TypeH2A, task.NewH2AExecutor(h2aMgr))
+	}
+
+	// Local commands are only runnable when explicitly allow-listed
+	if len(cfg.Tasks.ShellAllowlist) > 0 {
+		server.TaskExecutors().Register(task.TypeShell, task.NewShellExecutor(cfg.Tasks.ShellAllowlist, cfg.Tasks.WorkDir))
+		log.Printf("Tasks: shell executor enabled (allowlist=%v)", cfg.Tasks.ShellAllowlist)
+	}
 
 	// Create HTTP mux for handlers
 	mux := http.NewServeMux()
@@ -253,6 +534,9 @@ func main() {
 	log.Printf("   Owner: %s", identity.Owner)
 	log.Printf("   Listening on: %s", cfg.Network.ListenAddr)
 	log.Printf("   TLS Enabled: %v", cfg.Network.TLSEnabled)
+	if cfg.Network.TLSEnabled {
+		log.Printf("   TLS Client Auth: %s", cfg.Network.TLSClientAuth)
+	}
 	log.Printf("   JSON-RPC 2.0: /api/v1/rpc")
 	if cfg.Tailscale.Enabled {
 		log.Printf("   Tailscale: enabled (require_auth=%v)", cfg.Tailscale.RequireAuth)
@@ -261,6 +545,10 @@ func main() {
 	// Start protocol server (includes JSON-RPC endpoint)
 	serverErrors := make(chan error, 1)
 	go func() {
+		if tlsConfig != nil {
+			serverErrors <- server.StartTLS(cfg.Network.ListenAddr, tlsConfig)
+			return
+		}
 		serverErrors <- server.Start(cfg.Network.ListenAddr)
 	}()
 
@@ -277,10 +565,47 @@ func main() {
 			log.Printf("Context monitor shutdown error: %v", err)
 		}
 		contextStore.Stop()
+		if gossiper != nil {
+			gossiper.Stop()
+		}
+		aclMgr.Close()
+		server.Focus().Close()
+		if err := registry.Close(); err != nil {
+			log.Printf("Registry shutdown error: %v", err)
+		}
 		log.Println("Shutdown complete")
 	}
 }
 
+// manifestFromConfig builds the capability manifest declared in config
+func manifestFromConfig(mc config.ManifestConfig) *aoi.CapabilityManifest {
+	manifest := &aoi.CapabilityManifest{
+		Actions:  append([]string(nil), mc.Actions...),
+		MCPTools: append([]string(nil), mc.MCPTools...),
+	}
+	for _, repo := range mc.Repositories {
+		manifest.Repositories = append(manifest.Repositories, aoi.RepositoryContext{
+			Name:   repo.Name,
+			Issues: repo.Issues,
+		})
+	}
+	return manifest
+}
+
+// mergeCapabilities returns the role's default capabilities followed by the
+// agent's own, without duplicates
+func mergeCapabilities(role, agent []string) []string {
+	seen := make(map[string]bool)
+	var merged []string
+	for _, c := range append(append([]string(nil), role...), agent...) {
+		if !seen[c] {
+			seen[c] = true
+			merged = append(merged, c)
+		}
+	}
+	return merged
+}
+
 // parseDuration parses a duration string, returning default if empty or invalid
 func parseDuration(s string, defaultVal time.Duration) time.Duration {
 	if s == "" {
@@ -376,7 +701,7 @@ func queryHandler(sec *secretary.Secretary) http.HandlerFunc {
 			return
 		}
 
-		resp, err := sec.HandleQuery(req)
+		resp, err := sec.HandleQueryContext(r.Context(), req)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -0,0 +1,106 @@
+// Command aoi-ca is a small local certificate authority for AOI agents that
+// authenticate with mutual TLS instead of Tailscale.
+//
+//	aoi-ca init  -dir pki [-cn "AOI Local CA"] [-days 3650]
+//	aoi-ca issue -dir pki -id eng-suzuki [-hosts eng-suzuki.local,10.0.0.5] [-days 365]
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aoi-protocol/aoi/internal/pki"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "init":
+		err = runInit(os.Args[2:])
+	case "issue":
+		err = runIssue(os.Args[2:])
+	case "-h", "--help", "help":
+		usage()
+		return
+	default:
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatalf("aoi-ca %s: %v", os.Args[1], err)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `Usage:
+  aoi-ca init  -dir <dir> [-cn <name>] [-days <n>]    create a CA (ca.crt, ca.key)
+  aoi-ca issue -dir <dir> -id <agent-id> [-hosts <h1,h2>] [-days <n>]
+                                                       issue <agent-id>.crt/.key
+`)
+}
+
+// runInit creates a new CA in -dir
+func runInit(args []string) error {
+	fs := flag.NewFlagSet("init", flag.ExitOnError)
+	dir := fs.String("dir", "pki", "Directory for ca.crt and ca.key")
+	cn := fs.String("cn", "AOI Local CA", "CA common name")
+	days := fs.Int("days", 3650, "Validity in days")
+	fs.Parse(args)
+
+	ca, err := pki.NewCA(*cn, time.Duration(*days)*24*time.Hour)
+	if err != nil {
+		return err
+	}
+	if err := ca.Save(*dir); err != nil {
+		return err
+	}
+
+	log.Printf("Created CA %q in %s", *cn, *dir)
+	log.Printf("   Distribute %s/%s to agents as network.tls_ca_file; keep %s private", *dir, pki.CACertFile, pki.CAKeyFile)
+	return nil
+}
+
+// runIssue issues an agent certificate signed by the CA in -dir
+func runIssue(args []string) error {
+	fs := flag.NewFlagSet("issue", flag.ExitOnError)
+	dir := fs.String("dir", "pki", "CA directory; the certificate is written here too")
+	agentID := fs.String("id", "", "Agent ID the certificate is issued for (required)")
+	hosts := fs.String("hosts", "localhost,127.0.0.1", "Comma-separated DNS names and IPs the agent serves on")
+	days := fs.Int("days", 365, "Validity in days")
+	fs.Parse(args)
+
+	if *agentID == "" {
+		return fmt.Errorf("-id is required")
+	}
+
+	ca, err := pki.LoadCADir(*dir)
+	if err != nil {
+		return err
+	}
+
+	var hostList []string
+	for _, h := range strings.Split(*hosts, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			hostList = append(hostList, h)
+		}
+	}
+
+	certFile, keyFile, err := ca.IssueAgentFiles(*dir, *agentID, hostList, time.Duration(*days)*24*time.Hour)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Issued certificate for agent %s", *agentID)
+	log.Printf("   network.tls_cert_file: %s", certFile)
+	log.Printf("   network.tls_key_file:  %s", keyFile)
+	return nil
+}
@@ -1,7 +1,14 @@
 package acl
 
 import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
 	"sync"
+	"time"
+
+	"github.com/aoi-protocol/aoi/internal/rpc"
 )
 
 // PermissionLevel represents the level of access
@@ -14,11 +21,192 @@ const (
 	PermissionAdmin
 )
 
+// String returns the level's name as used in configuration
+func (p PermissionLevel) String() string {
+	switch p {
+	case PermissionNone:
+		return "none"
+	case PermissionRead:
+		return "read"
+	case PermissionWrite:
+		return "write"
+	case PermissionAdmin:
+		return "admin"
+	}
+	return fmt.Sprintf("PermissionLevel(%d)", int(p))
+}
+
+// Effect is whether a rule allows or denies access
+type Effect string
+
+const (
+	EffectAllow Effect = "allow"
+	// EffectDeny denies the rule's permission level and everything above it:
+	// a read deny blocks every action, a write deny blocks write and execute
+	EffectDeny Effect = "deny"
+)
+
+// RolePrefix marks a rule subject that names a role, e.g. "role:qa"
+const RolePrefix = "role:"
+
 // AccessRule defines an access control rule
 type AccessRule struct {
-	AgentID    string
+	// AgentID is an agent ID or a glob over agent IDs ("*", "eng-*")
+	AgentID string
+	// Role, when set, applies the rule to every agent with this role instead of AgentID
+	Role string
+	// Resource is a resource or a pattern, see MatchResource
 	Resource   string
 	Permission PermissionLevel
+	// Effect defaults to EffectAllow
+	Effect Effect
+	// ID identifies a temporary grant, e.g. by the approval that issued it
+	ID string
+	// ExpiresAt ends a temporary grant; the zero time never expires
+	ExpiresAt time.Time
+
+	// fromPolicy marks rules replaced when a new policy is applied
+	fromPolicy bool
+}
+
+// NewRule creates a rule for subject, which is an agent ID pattern or
+// RolePrefix followed by a role name
+func NewRule(subject, resource string, permission PermissionLevel) *AccessRule {
+	rule := &AccessRule{Resource: resource, Permission: permission}
+	if role, ok := strings.CutPrefix(subject, RolePrefix); ok {
+		rule.Role = role
+	} else {
+		rule.AgentID = subject
+	}
+	return rule
+}
+
+// Subject returns the rule's subject in the form accepted by NewRule
+func (r AccessRule) Subject() string {
+	if r.Role != "" {
+		return RolePrefix + r.Role
+	}
+	return r.AgentID
+}
+
+func (r AccessRule) effect() string {
+	if r.Effect == "" {
+		return string(EffectAllow)
+	}
+	return string(r.Effect)
+}
+
+// Specificity ranks how narrowly a rule applies; higher is more specific.
+// The resource pattern counts first: more literal characters, then an exact
+// resource over a pattern, then "/*" over "/**". The subject breaks ties: an
+// agent ID, then a role, then an agent glob, then "*".
+func (r AccessRule) Specificity() int {
+	literals, wildcards := 0, false
+	inClass := false
+	for _, c := range r.Resource {
+		switch {
+		case inClass:
+			inClass = c != ']'
+		case c == '[':
+			inClass, wildcards = true, true
+			literals++ // a character class matches exactly one character
+		case c == '*' || c == '?':
+			wildcards = true
+			if c == '?' {
+				literals++
+			}
+		default:
+			literals++
+		}
+	}
+	spec := literals * 4
+	if !wildcards {
+		spec += 2
+	} else if !strings.HasSuffix(r.Resource, "/**") {
+		spec++
+	}
+
+	subject := 0
+	switch {
+	case r.Role != "":
+		subject = 2
+	case r.AgentID == "*":
+		subject = 0
+	case strings.ContainsAny(r.AgentID, "*?["):
+		subject = 1
+	default:
+		subject = 3
+	}
+	return spec*4 + subject
+}
+
+// Explanation is the outcome of a permission check with the evaluation of every rule
+type Explanation struct {
+	AgentID  string `json:"agent_id"`
+	Role     string `json:"role,omitempty"`
+	Resource string `json:"resource"`
+	Action   string `json:"action"`
+	Allowed  bool   `json:"allowed"`
+	Reason   string `json:"reason"`
+	// Decision is the rule that decided the outcome, nil if none applied
+	Decision *RuleTrace  `json:"decision,omitempty"`
+	Rules    []RuleTrace `json:"rules"`
+}
+
+// RuleTrace is how one rule was evaluated for an Explanation
+type RuleTrace struct {
+	Index       int    `json:"index"`
+	Subject     string `json:"subject"`
+	Resource    string `json:"resource"`
+	Permission  string `json:"permission"`
+	Effect      string `json:"effect"`
+	Specificity int    `json:"specificity"`
+	// ExpiresAt is set for temporary grants
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// Matched is set when the subject and resource match the request
+	Matched bool `json:"matched"`
+	// Applies is set when the rule matched and covers the action
+	Applies bool   `json:"applies"`
+	Note    string `json:"note"`
+}
+
+// MatchAgent reports whether an agent ID pattern matches agentID. "*" matches
+// every agent and other patterns are globs, e.g. "eng-*". An anonymous caller
+// (empty agentID) only matches a rule for the empty agent ID, never a wildcard.
+func MatchAgent(pattern, agentID string) bool {
+	if pattern == agentID {
+		return true
+	}
+	if agentID == "" {
+		return false
+	}
+	if pattern == "*" {
+		return true
+	}
+	matched, err := path.Match(pattern, agentID)
+	return err == nil && matched
+}
+
+// MatchResource reports whether a resource pattern matches resource:
+//   - "*" matches every resource
+//   - "docs/*" matches resources one level below docs ("docs/a", not "docs/a/b")
+//   - "docs/**" matches every resource below docs, at any depth
+//   - other patterns are globs matched segment by segment, e.g. "projects/aoi-*/issues"
+func MatchResource(pattern, resource string) bool {
+	if pattern == resource || pattern == "*" {
+		return true
+	}
+	if prefix, ok := strings.CutSuffix(pattern, "/**"); ok {
+		// The prefix must match the leading segments and something must follow them
+		n := strings.Count(prefix, "/") + 1
+		segments := strings.SplitN(resource, "/", n+1)
+		if len(segments) <= n {
+			return false
+		}
+		return MatchResource(prefix, strings.Join(segments[:n], "/"))
+	}
+	matched, err := path.Match(pattern, resource)
+	return err == nil && matched
 }
 
 // PermissionCheckResult contains the result of a permission check
@@ -27,16 +215,27 @@ type PermissionCheckResult struct {
 	Reason  string
 }
 
+// RoleResolver returns the role of an agent, or false if the agent is unknown
+type RoleResolver func(agentID string) (string, bool)
+
 // AclManager manages access control lists
 type AclManager struct {
-	rules []AccessRule
-	mu    sync.RWMutex
+	rules        []AccessRule
+	roleResolver RoleResolver
+	onRevoke     []func(AccessRule)
+	// knownRoles are the roles policy rules may name
+	knownRoles    []string
+	policyVersion string
+	mu            sync.RWMutex
+	stopOnce      sync.Once
+	stop          chan struct{}
 }
 
 // NewAclManager creates a new ACL manager
 func NewAclManager() *AclManager {
 	return &AclManager{
 		rules: make([]AccessRule, 0),
+		stop:  make(chan struct{}),
 	}
 }
 
@@ -48,34 +247,219 @@ func (m *AclManager) AddRule(rule *AccessRule) {
 	m.rules = append(m.rules, *rule)
 }
 
-// CheckPermission checks if an agent has permission for an action
+// SetRoleResolver sets how the roles of agents are looked up for role rules
+func (m *AclManager) SetRoleResolver(resolver RoleResolver) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.roleResolver = resolver
+}
+
+// CheckPermission checks if an agent has permission for an action. Of the
+// rules that match the agent and resource and cover the action, the most
+// specific one decides; at equal specificity a deny rule wins over an allow.
+// Without any such rule the action is denied.
 func (m *AclManager) CheckPermission(agentID string, resource string, action string) PermissionCheckResult {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	// Find matching rules
-	for _, rule := range m.rules {
-		if rule.AgentID == agentID && rule.Resource == resource {
-			// Check if permission level is sufficient
-			allowed := false
-
-			switch action {
-			case "read":
-				allowed = rule.Permission >= PermissionRead
-			case "write":
-				allowed = rule.Permission >= PermissionWrite
-			case "execute":
-				allowed = rule.Permission >= PermissionWrite
-			default:
-				allowed = false
+	return m.evaluate(agentID, resource, action, nil)
+}
+
+// Explain evaluates a permission check like CheckPermission and reports how
+// every rule was evaluated and which one decided the outcome
+func (m *AclManager) Explain(agentID string, resource string, action string) *Explanation {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	exp := &Explanation{
+		AgentID:  agentID,
+		Resource: resource,
+		Action:   action,
+		Rules:    make([]RuleTrace, 0, len(m.rules)),
+	}
+	if m.roleResolver != nil {
+		exp.Role, _ = m.roleResolver(agentID)
+	}
+	result := m.evaluate(agentID, resource, action, exp)
+	exp.Allowed = result.Allowed
+	return exp
+}
+
+// evaluate decides a permission check, recording every rule in exp if it is
+// not nil. The caller holds m.mu.
+func (m *AclManager) evaluate(agentID, resource, action string, exp *Explanation) PermissionCheckResult {
+	// The agent's role is looked up at most once, and only if a role rule needs it
+	role, resolved := "", false
+	roleOf := func() string {
+		if !resolved && m.roleResolver != nil {
+			role, _ = m.roleResolver(agentID)
+		}
+		resolved = true
+		return role
+	}
+
+	required, known := requiredPermission(action)
+	now := time.Now()
+
+	decision, decidingSpec := -1, 0
+	for i, rule := range m.rules {
+		trace := RuleTrace{
+			Index:       i,
+			Subject:     rule.Subject(),
+			Resource:    rule.Resource,
+			Permission:  rule.Permission.String(),
+			Effect:      rule.effect(),
+			Specificity: rule.Specificity(),
+		}
+		if !rule.ExpiresAt.IsZero() {
+			expiresAt := rule.ExpiresAt
+			trace.ExpiresAt = &expiresAt
+		}
+
+		subjectMatches := MatchAgent(rule.AgentID, agentID)
+		if rule.Role != "" {
+			subjectMatches = roleOf() == rule.Role
+		}
+		switch {
+		case rule.expired(now):
+			trace.Note = "grant expired"
+		case !subjectMatches:
+			trace.Note = "subject does not match"
+		case !MatchResource(rule.Resource, resource):
+			trace.Note = "resource does not match"
+		case !known:
+			trace.Matched = true
+			trace.Note = "unknown action"
+		case rule.Effect == EffectDeny:
+			trace.Matched = true
+			// A deny rule denies its permission level and everything above it
+			trace.Applies = required >= max(rule.Permission, PermissionRead)
+			if trace.Applies {
+				trace.Note = "denies " + action
+			} else {
+				trace.Note = "denies only " + max(rule.Permission, PermissionRead).String() + " and above"
 			}
+		default:
+			trace.Matched = true
+			trace.Applies = rule.Permission >= required
+			if trace.Applies {
+				trace.Note = "allows " + action
+			} else {
+				trace.Note = "grants only " + rule.Permission.String()
+			}
+		}
 
-			if allowed {
-				return PermissionCheckResult{Allowed: true, Reason: "permission granted"}
+		if trace.Applies {
+			spec := trace.Specificity
+			if decision < 0 || spec > decidingSpec ||
+				(spec == decidingSpec && rule.Effect == EffectDeny && m.rules[decision].Effect != EffectDeny) {
+				decision, decidingSpec = i, spec
 			}
 		}
+		if exp != nil {
+			exp.Rules = append(exp.Rules, trace)
+		}
+	}
+
+	if decision < 0 {
+		if exp != nil {
+			exp.Reason = "no rule allows " + action + " on " + resource
+		}
+		// No matching rule or insufficient permission
+		return PermissionCheckResult{Allowed: false, Reason: "permission denied"}
+	}
+
+	rule := m.rules[decision]
+	if exp != nil {
+		exp.Decision = &exp.Rules[decision]
+		verb := "allowed"
+		if rule.Effect == EffectDeny {
+			verb = "denied"
+		}
+		exp.Reason = fmt.Sprintf("%s by rule %d (%s %s %s %s)", verb, decision, rule.effect(), rule.Subject(), rule.Resource, rule.Permission)
+	}
+	if rule.Effect == EffectDeny {
+		return PermissionCheckResult{Allowed: false, Reason: "permission denied by rule"}
+	}
+	return PermissionCheckResult{Allowed: true, Reason: "permission granted"}
+}
+
+// requiredPermission returns the permission level an action needs
+func requiredPermission(action string) (PermissionLevel, bool) {
+	switch action {
+	case "read":
+		return PermissionRead, true
+	case "write", "execute":
+		return PermissionWrite, true
+	case "admin":
+		return PermissionAdmin, true
 	}
+	return PermissionNone, false
+}
+
+// RegisterMethods registers the aoi.acl.* methods
+func (m *AclManager) RegisterMethods(reg *rpc.Registry) {
+	reg.MustRegister(
+		rpc.Method{
+			Name:    "aoi.acl.explain",
+			Summary: "Explain a permission check: every rule evaluated and the one that decided",
+			Params: []rpc.ContentDescriptor{
+				rpc.RequiredParam("agent_id", "string", "Agent requesting access"),
+				rpc.RequiredParam("resource", "string", "Resource accessed"),
+				rpc.RequiredParam("action", "string", "read, write or execute"),
+			},
+			Result:   rpc.Result("explanation", rpc.Type("object")),
+			Resource: "acl/explain",
+			Action:   rpc.ActionRead,
+			Handler:  rpc.WithoutContext(m.handleExplain),
+		},
+		rpc.Method{
+			Name:     "aoi.acl.grants",
+			Summary:  "List the temporary grants that have not expired",
+			Result:   rpc.Result("grants", rpc.ArrayOf(rpc.Type("object"))),
+			Resource: "acl/grants",
+			Action:   rpc.ActionRead,
+			Handler: rpc.WithoutContext(func(json.RawMessage) (interface{}, error) {
+				return m.Grants(), nil
+			}),
+		},
+		rpc.Method{
+			Name:     "aoi.acl.validate",
+			Summary:  "Validate an ACL policy without applying it",
+			Params:   []rpc.ContentDescriptor{rpc.RequiredParam("policy", "string", "Policy file content, JSON or HCL-like")},
+			Result:   rpc.Result("validation", rpc.Type("object")),
+			Resource: "acl/validate",
+			Action:   rpc.ActionRead,
+			Handler:  rpc.WithoutContext(m.handleValidate),
+		},
+	)
+}
 
-	// No matching rule or insufficient permission
-	return PermissionCheckResult{Allowed: false, Reason: "permission denied"}
+func (m *AclManager) handleExplain(params json.RawMessage) (interface{}, error) {
+	var p struct {
+		AgentID  string `json:"agent_id"`
+		Resource string `json:"resource"`
+		Action   string `json:"action"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, rpc.InvalidParams(err.Error())
+	}
+	if p.AgentID == "" || p.Resource == "" || p.Action == "" {
+		return nil, rpc.InvalidParams("agent_id, resource and action are required")
+	}
+	return m.Explain(p.AgentID, p.Resource, p.Action), nil
+}
+
+func (m *AclManager) handleValidate(params json.RawMessage) (interface{}, error) {
+	var p struct {
+		Policy string `json:"policy"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, rpc.InvalidParams(err.Error())
+	}
+	if strings.TrimSpace(p.Policy) == "" {
+		return nil, rpc.InvalidParams("policy is required")
+	}
+	return m.ValidatePolicy([]byte(p.Policy)), nil
 }
@@ -383,3 +383,235 @@ func TestAclManager_ConcurrentReadWrite(t *testing.T) {
 
 	wg.Wait()
 }
+
+func TestAclManager_CheckPermission_RoleRule(t *testing.T) {
+	acl := NewAclManager()
+	roles := map[string]string{"sre-1": "sre", "eng-1": "engineer"}
+	lookups := 0
+	acl.SetRoleResolver(func(agentID string) (string, bool) {
+		lookups++
+		role, ok := roles[agentID]
+		return role, ok
+	})
+
+	acl.AddRule(&AccessRule{Role: "sre", Resource: "tasks/shell", Permission: PermissionWrite})
+	acl.AddRule(&AccessRule{Role: "sre", Resource: "agents/status", Permission: PermissionRead})
+
+	if !acl.CheckPermission("sre-1", "tasks/shell", "execute").Allowed {
+		t.Error("Expected the sre role grant to allow sre-1")
+	}
+	if acl.CheckPermission("eng-1", "tasks/shell", "execute").Allowed {
+		t.Error("Expected the sre role grant not to apply to an engineer")
+	}
+	if acl.CheckPermission("unknown", "tasks/shell", "execute").Allowed {
+		t.Error("Expected an unknown agent to be denied")
+	}
+
+	// The role is resolved once per check, however many role rules there are
+	lookups = 0
+	acl.CheckPermission("sre-1", "agents/status", "write")
+	if lookups != 1 {
+		t.Errorf("Expected 1 role lookup, got %d", lookups)
+	}
+}
+
+func TestAclManager_CheckPermission_WildcardAgent(t *testing.T) {
+	acl := NewAclManager()
+
+	acl.AddRule(&AccessRule{AgentID: "*", Resource: "agents/status", Permission: PermissionRead})
+	acl.AddRule(&AccessRule{AgentID: "eng-*", Resource: "repo-1", Permission: PermissionWrite})
+
+	if !acl.CheckPermission("anyone", "agents/status", "read").Allowed {
+		t.Error("Expected \"*\" to match every agent")
+	}
+	if !acl.CheckPermission("eng-backend", "repo-1", "write").Allowed {
+		t.Error("Expected \"eng-*\" to match eng-backend")
+	}
+	if acl.CheckPermission("qa-1", "repo-1", "write").Allowed {
+		t.Error("Expected \"eng-*\" not to match qa-1")
+	}
+	if acl.CheckPermission("", "agents/status", "read").Allowed {
+		t.Error("Expected \"*\" not to match an anonymous caller")
+	}
+}
+
+func TestAclManager_CheckPermission_GlobResource(t *testing.T) {
+	acl := NewAclManager()
+
+	acl.AddRule(&AccessRule{AgentID: "agent-1", Resource: "docs/*", Permission: PermissionRead})
+	acl.AddRule(&AccessRule{AgentID: "agent-1", Resource: "projects/**", Permission: PermissionWrite})
+
+	if !acl.CheckPermission("agent-1", "docs/spec", "read").Allowed {
+		t.Error("Expected docs/* to match docs/spec")
+	}
+	if acl.CheckPermission("agent-1", "docs/spec/v2", "read").Allowed {
+		t.Error("Expected docs/* not to match below one level")
+	}
+	if !acl.CheckPermission("agent-1", "projects/aoi/issues/12", "write").Allowed {
+		t.Error("Expected projects/** to match at any depth")
+	}
+	if acl.CheckPermission("agent-1", "projects", "write").Allowed {
+		t.Error("Expected projects/** not to match projects itself")
+	}
+}
+
+func TestNewRule_RoleSubject(t *testing.T) {
+	rule := NewRule("role:qa", "tests/**", PermissionWrite)
+	if rule.Role != "qa" || rule.AgentID != "" {
+		t.Errorf("Expected a qa role rule, got %+v", rule)
+	}
+	if rule.Subject() != "role:qa" {
+		t.Errorf("Expected subject role:qa, got %s", rule.Subject())
+	}
+
+	rule = NewRule("eng-*", "repo-1", PermissionRead)
+	if rule.Role != "" || rule.AgentID != "eng-*" {
+		t.Errorf("Expected an agent rule, got %+v", rule)
+	}
+}
+
+func TestMatchResource(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		resource string
+		expected bool
+	}{
+		{"*", "anything/at/all", true},
+		{"repo-1", "repo-1", true},
+		{"repo-1", "repo-2", false},
+		{"agents/*", "agents/agent-1", true},
+		{"agents/*", "agents/agent-1/tasks", false},
+		{"agents/**", "agents/agent-1/tasks", true},
+		{"agents/**", "agents", false},
+		{"agents/**", "agentsx/agent-1", false},
+		{"projects/aoi-*/issues", "projects/aoi-web/issues", true},
+		{"projects/*/**", "projects/aoi/issues/1", true},
+		{"projects/*/**", "projects/aoi", false},
+	}
+
+	for _, tt := range tests {
+		if got := MatchResource(tt.pattern, tt.resource); got != tt.expected {
+			t.Errorf("MatchResource(%s, %s) = %v, expected %v", tt.pattern, tt.resource, got, tt.expected)
+		}
+	}
+}
+
+func TestMatchAgent(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		agentID  string
+		expected bool
+	}{
+		{"*", "eng-1", true},
+		{"eng-1", "eng-1", true},
+		{"eng-*", "eng-1", true},
+		{"eng-*", "qa-1", false},
+		{"*", "", false},
+		{"*-*", "", false},
+		{"", "", true},
+	}
+
+	for _, tt := range tests {
+		if got := MatchAgent(tt.pattern, tt.agentID); got != tt.expected {
+			t.Errorf("MatchAgent(%q, %q) = %v, expected %v", tt.pattern, tt.agentID, got, tt.expected)
+		}
+	}
+}
+
+func TestAclManager_CheckPermission_DenyRule(t *testing.T) {
+	acl := NewAclManager()
+
+	acl.AddRule(&AccessRule{AgentID: "*", Resource: "projects/**", Permission: PermissionWrite})
+	acl.AddRule(&AccessRule{AgentID: "qa-1", Resource: "projects/**", Permission: PermissionWrite, Effect: EffectDeny})
+
+	if !acl.CheckPermission("qa-1", "projects/aoi", "read").Allowed {
+		t.Error("Expected a write deny to leave read allowed")
+	}
+	result := acl.CheckPermission("qa-1", "projects/aoi", "write")
+	if result.Allowed {
+		t.Error("Expected the write deny to block write")
+	}
+	if result.Reason != "permission denied by rule" {
+		t.Errorf("Expected reason 'permission denied by rule', got '%s'", result.Reason)
+	}
+	if !acl.CheckPermission("eng-1", "projects/aoi", "write").Allowed {
+		t.Error("Expected the deny not to apply to other agents")
+	}
+}
+
+func TestAclManager_CheckPermission_Precedence(t *testing.T) {
+	acl := NewAclManager()
+
+	// A broad deny is overridden by a more specific allow
+	acl.AddRule(&AccessRule{Role: "qa", Resource: "projects/**", Permission: PermissionRead, Effect: EffectDeny})
+	acl.AddRule(&AccessRule{Role: "qa", Resource: "projects/aoi/*", Permission: PermissionRead})
+	// At equal specificity the deny wins, whatever the order
+	acl.AddRule(&AccessRule{AgentID: "eng-1", Resource: "repo-1", Permission: PermissionAdmin})
+	acl.AddRule(&AccessRule{AgentID: "eng-1", Resource: "repo-1", Permission: PermissionWrite, Effect: EffectDeny})
+	acl.SetRoleResolver(func(agentID string) (string, bool) {
+		if agentID == "qa-1" {
+			return "qa", true
+		}
+		return "engineer", true
+	})
+
+	if !acl.CheckPermission("qa-1", "projects/aoi/spec", "read").Allowed {
+		t.Error("Expected the more specific allow to win")
+	}
+	if acl.CheckPermission("qa-1", "projects/other/spec", "read").Allowed {
+		t.Error("Expected the deny to apply outside projects/aoi")
+	}
+	if acl.CheckPermission("eng-1", "repo-1", "write").Allowed {
+		t.Error("Expected deny to beat allow at equal specificity")
+	}
+	if !acl.CheckPermission("eng-1", "repo-1", "read").Allowed {
+		t.Error("Expected read to stay allowed")
+	}
+}
+
+func TestAccessRule_Specificity(t *testing.T) {
+	ordered := []AccessRule{
+		{AgentID: "*", Resource: "*"},
+		{AgentID: "*", Resource: "docs/**"},
+		{AgentID: "*", Resource: "docs/*"},
+		{AgentID: "eng-*", Resource: "docs/spec"},
+		{Role: "engineer", Resource: "docs/spec"},
+		{AgentID: "eng-1", Resource: "docs/spec"},
+	}
+	for i := 1; i < len(ordered); i++ {
+		if ordered[i-1].Specificity() >= ordered[i].Specificity() {
+			t.Errorf("Expected %s %s to be less specific than %s %s",
+				ordered[i-1].Subject(), ordered[i-1].Resource, ordered[i].Subject(), ordered[i].Resource)
+		}
+	}
+}
+
+func TestAclManager_Explain(t *testing.T) {
+	acl := NewAclManager()
+
+	acl.AddRule(&AccessRule{AgentID: "eng-1", Resource: "repo-1", Permission: PermissionRead})
+	acl.AddRule(&AccessRule{AgentID: "qa-1", Resource: "repo-1", Permission: PermissionAdmin})
+	acl.AddRule(&AccessRule{AgentID: "eng-1", Resource: "repo-2", Permission: PermissionAdmin})
+
+	exp := acl.Explain("eng-1", "repo-1", "write")
+	if exp.Allowed || exp.Decision != nil {
+		t.Errorf("Expected no deciding rule, got %+v", exp.Decision)
+	}
+	if len(exp.Rules) != 3 {
+		t.Fatalf("Expected 3 evaluated rules, got %d", len(exp.Rules))
+	}
+	notes := []string{"grants only read", "subject does not match", "resource does not match"}
+	for i, note := range notes {
+		if exp.Rules[i].Note != note {
+			t.Errorf("Rule %d: expected note %q, got %q", i, note, exp.Rules[i].Note)
+		}
+	}
+	if !exp.Rules[0].Matched || exp.Rules[0].Applies {
+		t.Errorf("Expected rule 0 to match without applying, got %+v", exp.Rules[0])
+	}
+
+	exp = acl.Explain("eng-1", "repo-1", "read")
+	if !exp.Allowed || exp.Decision == nil || exp.Decision.Index != 0 {
+		t.Errorf("Expected rule 0 to allow read, got %+v", exp)
+	}
+}
@@ -0,0 +1,101 @@
+package acl
+
+import (
+	"time"
+)
+
+// DefaultGrantSweepInterval is how often expired grants are revoked
+const DefaultGrantSweepInterval = 30 * time.Second
+
+// Grant describes an active temporary grant
+type Grant struct {
+	ID         string    `json:"id"`
+	Subject    string    `json:"subject"`
+	Resource   string    `json:"resource"`
+	Permission string    `json:"permission"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// expired reports whether a temporary grant has ended at now
+func (r AccessRule) expired(now time.Time) bool {
+	return !r.ExpiresAt.IsZero() && !now.Before(r.ExpiresAt)
+}
+
+// OnRevoke registers a callback invoked for every grant revoked on expiry
+func (m *AclManager) OnRevoke(fn func(AccessRule)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onRevoke = append(m.onRevoke, fn)
+}
+
+// Grants returns the temporary grants that have not expired
+func (m *AclManager) Grants() []Grant {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now()
+	grants := make([]Grant, 0)
+	for _, rule := range m.rules {
+		if rule.ExpiresAt.IsZero() || rule.expired(now) {
+			continue
+		}
+		grants = append(grants, Grant{
+			ID:         rule.ID,
+			Subject:    rule.Subject(),
+			Resource:   rule.Resource,
+			Permission: rule.Permission.String(),
+			ExpiresAt:  rule.ExpiresAt,
+		})
+	}
+	return grants
+}
+
+// SweepExpired removes the grants that have expired at now and returns them.
+// Expired grants never allow access, even before they are swept.
+func (m *AclManager) SweepExpired(now time.Time) []AccessRule {
+	m.mu.Lock()
+	var revoked []AccessRule
+	kept := m.rules[:0]
+	for _, rule := range m.rules {
+		if rule.expired(now) {
+			revoked = append(revoked, rule)
+			continue
+		}
+		kept = append(kept, rule)
+	}
+	m.rules = kept
+	callbacks := m.onRevoke
+	m.mu.Unlock()
+
+	for _, rule := range revoked {
+		for _, fn := range callbacks {
+			fn(rule)
+		}
+	}
+	return revoked
+}
+
+// StartGrantSweeper revokes expired grants every interval until Close
+func (m *AclManager) StartGrantSweeper(interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultGrantSweepInterval
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				m.SweepExpired(now)
+			case <-m.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops the grant sweeper
+func (m *AclManager) Close() {
+	m.stopOnce.Do(func() { close(m.stop) })
+}
@@ -0,0 +1,52 @@
+package acl
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAclManager_GrantExpires(t *testing.T) {
+	acl := NewAclManager()
+	acl.AddRule(&AccessRule{AgentID: "pm-agent", Resource: "context/billing", Permission: PermissionRead,
+		ID: "grant-1", ExpiresAt: time.Now().Add(time.Hour)})
+	acl.AddRule(&AccessRule{AgentID: "pm-agent", Resource: "context/old", Permission: PermissionRead,
+		ID: "grant-0", ExpiresAt: time.Now().Add(-time.Minute)})
+
+	if !acl.CheckPermission("pm-agent", "context/billing", "read").Allowed {
+		t.Error("Expected an active grant to allow access")
+	}
+	if acl.CheckPermission("pm-agent", "context/old", "read").Allowed {
+		t.Error("Expected an expired grant to deny access before it is swept")
+	}
+	if exp := acl.Explain("pm-agent", "context/old", "read"); exp.Rules[1].Note != "grant expired" {
+		t.Errorf("Expected the explanation to show the grant expired, got %q", exp.Rules[1].Note)
+	}
+
+	grants := acl.Grants()
+	if len(grants) != 1 || grants[0].ID != "grant-1" || grants[0].Subject != "pm-agent" {
+		t.Errorf("Expected only grant-1 to be listed, got %+v", grants)
+	}
+}
+
+func TestAclManager_SweepExpired(t *testing.T) {
+	acl := NewAclManager()
+	acl.AddRule(&AccessRule{AgentID: "eng-agent", Resource: "repo-1", Permission: PermissionRead})
+	acl.AddRule(&AccessRule{AgentID: "pm-agent", Resource: "context/billing", Permission: PermissionRead,
+		ID: "grant-1", ExpiresAt: time.Now().Add(time.Hour)})
+
+	var revoked []string
+	acl.OnRevoke(func(rule AccessRule) { revoked = append(revoked, rule.ID) })
+
+	if swept := acl.SweepExpired(time.Now()); len(swept) != 0 {
+		t.Errorf("Expected nothing to expire yet, swept %d", len(swept))
+	}
+	if swept := acl.SweepExpired(time.Now().Add(2 * time.Hour)); len(swept) != 1 {
+		t.Fatalf("Expected the grant to be swept, swept %d", len(swept))
+	}
+	if len(revoked) != 1 || revoked[0] != "grant-1" {
+		t.Errorf("Expected a revoke callback for grant-1, got %v", revoked)
+	}
+	if !acl.CheckPermission("eng-agent", "repo-1", "read").Allowed {
+		t.Error("Expected permanent rules to survive the sweep")
+	}
+}
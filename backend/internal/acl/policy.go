@@ -0,0 +1,340 @@
+package acl
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultPolicyPollInterval is how often a watched policy file is checked for changes
+const DefaultPolicyPollInterval = 5 * time.Second
+
+// Policy is a set of ACL rules loaded from a policy file. Applying a policy
+// replaces the rules of the previous policy at once; rules added otherwise,
+// such as config rules, role grants and temporary grants, are kept.
+//
+// A policy is written in JSON:
+//
+//	{"version": "2026-10-16", "rules": [{"agent_id": "role:qa", "resource": "projects/secret/**", "permission": "read", "effect": "deny"}]}
+//
+// or in an HCL-like format with one rule block per rule:
+//
+//	version = "2026-10-16"
+//
+//	# QA never sees secret projects
+//	rule {
+//	  agent_id   = "role:qa"
+//	  resource   = "projects/secret/**"
+//	  permission = "read"
+//	  effect     = "deny"
+//	}
+type Policy struct {
+	// Version is the declared version; if empty it is derived from the content
+	Version string       `json:"version,omitempty"`
+	Rules   []PolicyRule `json:"rules"`
+}
+
+// PolicyRule is a rule of a policy, in the same form as a config ACL rule
+type PolicyRule struct {
+	// AgentID is an agent ID, a glob such as "*" or "eng-*", or "role:<name>"
+	AgentID    string `json:"agent_id"`
+	Resource   string `json:"resource"`
+	Permission string `json:"permission"` // "none", "read", "write", "admin"
+	// Effect is "allow" (default) or "deny"
+	Effect string `json:"effect,omitempty"`
+}
+
+// PolicyValidation is the outcome of validating a policy without applying it
+type PolicyValidation struct {
+	Valid   bool     `json:"valid"`
+	Version string   `json:"version,omitempty"`
+	Rules   int      `json:"rules"`
+	Errors  []string `json:"errors,omitempty"`
+}
+
+// ParsePolicy parses a policy in JSON, if it starts with "{", or else in the
+// HCL-like format. A policy without a version gets one derived from data.
+func ParsePolicy(data []byte) (*Policy, error) {
+	var p Policy
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		dec := json.NewDecoder(bytes.NewReader(trimmed))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&p); err != nil {
+			return nil, fmt.Errorf("policy: %w", err)
+		}
+	} else if err := parseHCLPolicy(data, &p); err != nil {
+		return nil, err
+	}
+
+	if p.Version == "" {
+		sum := sha256.Sum256(data)
+		p.Version = fmt.Sprintf("sha256:%x", sum[:6])
+	}
+	return &p, nil
+}
+
+// parseHCLPolicy parses the HCL-like policy format: "key = "value"" lines
+// at the top level and in "rule { ... }" blocks, and "#" or "//" comments
+func parseHCLPolicy(data []byte, p *Policy) error {
+	var rule *PolicyRule
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//"):
+			continue
+		case line == "rule {":
+			if rule != nil {
+				return fmt.Errorf("policy line %d: rule blocks cannot be nested", n)
+			}
+			rule = &PolicyRule{}
+			continue
+		case line == "}":
+			if rule == nil {
+				return fmt.Errorf("policy line %d: unexpected }", n)
+			}
+			p.Rules = append(p.Rules, *rule)
+			rule = nil
+			continue
+		}
+
+		key, raw, ok := strings.Cut(line, "=")
+		if !ok {
+			return fmt.Errorf("policy line %d: expected key = \"value\", rule { or }", n)
+		}
+		key = strings.TrimSpace(key)
+		value, err := strconv.Unquote(strings.TrimSpace(raw))
+		if err != nil {
+			return fmt.Errorf("policy line %d: value of %s must be a quoted string", n, key)
+		}
+
+		var field *string
+		if rule == nil {
+			if key == "version" {
+				field = &p.Version
+			}
+		} else {
+			switch key {
+			case "agent_id":
+				field = &rule.AgentID
+			case "resource":
+				field = &rule.Resource
+			case "permission":
+				field = &rule.Permission
+			case "effect":
+				field = &rule.Effect
+			}
+		}
+		if field == nil {
+			return fmt.Errorf("policy line %d: unknown key %q", n, key)
+		}
+		*field = value
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("policy: %w", err)
+	}
+	if rule != nil {
+		return errors.New("policy: rule block is not closed")
+	}
+	return nil
+}
+
+// problems lists what is wrong with the policy's rules. Role subjects must
+// name one of roles unless roles is nil.
+func (p *Policy) problems(roles []string) []string {
+	known := make(map[string]bool, len(roles))
+	for _, role := range roles {
+		known[role] = true
+	}
+
+	var problems []string
+	for i, rule := range p.Rules {
+		if rule.AgentID == "" {
+			problems = append(problems, fmt.Sprintf("rules[%d]: agent_id is required", i))
+		} else if role, ok := strings.CutPrefix(rule.AgentID, RolePrefix); ok && roles != nil && !known[role] {
+			problems = append(problems, fmt.Sprintf("rules[%d]: unknown role %q", i, role))
+		}
+		if rule.Resource == "" {
+			problems = append(problems, fmt.Sprintf("rules[%d]: resource is required", i))
+		}
+		if _, ok := parsePermission(rule.Permission); !ok {
+			problems = append(problems, fmt.Sprintf("rules[%d]: unknown permission %q", i, rule.Permission))
+		}
+		switch Effect(rule.Effect) {
+		case "", EffectAllow, EffectDeny:
+		default:
+			problems = append(problems, fmt.Sprintf("rules[%d]: unknown effect %q", i, rule.Effect))
+		}
+	}
+	return problems
+}
+
+// Validate checks the policy's rules; role subjects must name one of roles
+// unless roles is nil
+func (p *Policy) Validate(roles []string) error {
+	problems := p.problems(roles)
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("invalid policy %s: %s", p.Version, strings.Join(problems, "; "))
+}
+
+// accessRules converts the policy's rules, which must be valid
+func (p *Policy) accessRules() []AccessRule {
+	rules := make([]AccessRule, 0, len(p.Rules))
+	for _, r := range p.Rules {
+		perm, _ := parsePermission(r.Permission)
+		rule := NewRule(r.AgentID, r.Resource, perm)
+		rule.Effect = Effect(r.Effect)
+		rule.fromPolicy = true
+		rules = append(rules, *rule)
+	}
+	return rules
+}
+
+// parsePermission converts a permission name to its level
+func parsePermission(perm string) (PermissionLevel, bool) {
+	for _, level := range []PermissionLevel{PermissionNone, PermissionRead, PermissionWrite, PermissionAdmin} {
+		if level.String() == perm {
+			return level, true
+		}
+	}
+	return PermissionNone, false
+}
+
+// SetKnownRoles sets the roles that role subjects of a policy must name
+func (m *AclManager) SetKnownRoles(roles []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.knownRoles = roles
+}
+
+// ValidatePolicy parses and validates a policy without applying it
+func (m *AclManager) ValidatePolicy(data []byte) *PolicyValidation {
+	p, err := ParsePolicy(data)
+	if err != nil {
+		return &PolicyValidation{Errors: []string{err.Error()}}
+	}
+
+	m.mu.RLock()
+	problems := p.problems(m.knownRoles)
+	m.mu.RUnlock()
+	return &PolicyValidation{
+		Valid:   len(problems) == 0,
+		Version: p.Version,
+		Rules:   len(p.Rules),
+		Errors:  problems,
+	}
+}
+
+// ApplyPolicy validates p and swaps its rules in for those of the previous
+// policy. An invalid policy is rejected and the previous one stays in effect.
+func (m *AclManager) ApplyPolicy(p *Policy) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := p.Validate(m.knownRoles); err != nil {
+		return err
+	}
+	rules := make([]AccessRule, 0, len(m.rules)+len(p.Rules))
+	for _, rule := range m.rules {
+		if !rule.fromPolicy {
+			rules = append(rules, rule)
+		}
+	}
+	m.rules = append(rules, p.accessRules()...)
+	m.policyVersion = p.Version
+	return nil
+}
+
+// PolicyVersion returns the version of the applied policy, or "" if none
+func (m *AclManager) PolicyVersion() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.policyVersion
+}
+
+// LoadPolicyFile reads, validates and applies the policy in path
+func (m *AclManager) LoadPolicyFile(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ACL policy: %w", err)
+	}
+	p, err := ParsePolicy(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.ApplyPolicy(p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// WatchPolicy applies the policy in path and then checks the file every
+// interval, applying it again whenever it changes, until Close. The initial
+// policy must be valid; later invalid versions are logged and ignored.
+func (m *AclManager) WatchPolicy(path string, interval time.Duration) error {
+	if interval <= 0 {
+		interval = DefaultPolicyPollInterval
+	}
+
+	p, err := m.LoadPolicyFile(path)
+	if err != nil {
+		return err
+	}
+	log.Printf("[ACL] Policy %s applied from %s (%d rules)", p.Version, path, len(p.Rules))
+	lastMod := policyFileStamp(path)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				stamp := policyFileStamp(path)
+				if stamp == lastMod {
+					continue
+				}
+				lastMod = stamp
+				m.reloadPolicy(path)
+			case <-m.stop:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// reloadPolicy applies the changed policy file, keeping the current policy
+// if the file cannot be read or is invalid
+func (m *AclManager) reloadPolicy(path string) {
+	previous := m.PolicyVersion()
+	p, err := m.LoadPolicyFile(path)
+	if err != nil {
+		log.Printf("[ACL] Policy reload rejected, keeping %s: %v", previous, err)
+		return
+	}
+	if p.Version != previous {
+		log.Printf("[ACL] Policy %s applied from %s (%d rules, was %s)", p.Version, path, len(p.Rules), previous)
+	}
+}
+
+// policyFileStamp identifies a version of the file by its size and
+// modification time, or "" if it cannot be read
+func policyFileStamp(path string) string {
+	info, err := os.Stat(path)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%d-%d", info.Size(), info.ModTime().UnixNano())
+}
@@ -0,0 +1,172 @@
+package acl
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const hclPolicy = `
+version = "2026-10-16"
+
+# QA never sees secret projects
+rule {
+  agent_id   = "role:qa"
+  resource   = "projects/secret/**"
+  permission = "read"
+  effect     = "deny"
+}
+
+// Everyone reads projects
+rule {
+  agent_id   = "*"
+  resource   = "projects/**"
+  permission = "read"
+}
+`
+
+func TestParsePolicy_Formats(t *testing.T) {
+	hcl, err := ParsePolicy([]byte(hclPolicy))
+	if err != nil {
+		t.Fatalf("ParsePolicy(hcl): %v", err)
+	}
+	if hcl.Version != "2026-10-16" || len(hcl.Rules) != 2 {
+		t.Fatalf("Expected version 2026-10-16 with 2 rules, got %+v", hcl)
+	}
+	if r := hcl.Rules[0]; r.AgentID != "role:qa" || r.Resource != "projects/secret/**" || r.Effect != "deny" {
+		t.Errorf("Unexpected first rule: %+v", r)
+	}
+
+	json, err := ParsePolicy([]byte(`{"rules":[{"agent_id":"*","resource":"projects/**","permission":"read"}]}`))
+	if err != nil {
+		t.Fatalf("ParsePolicy(json): %v", err)
+	}
+	if len(json.Rules) != 1 || !strings.HasPrefix(json.Version, "sha256:") {
+		t.Errorf("Expected one rule and a derived version, got %+v", json)
+	}
+}
+
+func TestParsePolicy_Errors(t *testing.T) {
+	tests := map[string]string{
+		"unknown json field": `{"rules":[{"agent":"*"}]}`,
+		"unquoted value":     "rule {\n  agent_id = eng\n}",
+		"unknown key":        "rule {\n  subject = \"eng\"\n}",
+		"unclosed block":     "rule {\n  agent_id = \"eng\"",
+		"nested block":       "rule {\nrule {",
+		"stray brace":        "}",
+	}
+	for name, data := range tests {
+		if _, err := ParsePolicy([]byte(data)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestAclManager_ApplyPolicy(t *testing.T) {
+	acl := NewAclManager()
+	acl.SetKnownRoles([]string{"qa"})
+	acl.AddRule(&AccessRule{AgentID: "eng-agent", Resource: "repo-1", Permission: PermissionWrite})
+
+	first, _ := ParsePolicy([]byte(`{"version":"1","rules":[{"agent_id":"*","resource":"docs/*","permission":"read"}]}`))
+	if err := acl.ApplyPolicy(first); err != nil {
+		t.Fatalf("ApplyPolicy: %v", err)
+	}
+	second, _ := ParsePolicy([]byte(`{"version":"2","rules":[{"agent_id":"*","resource":"wiki/*","permission":"read"}]}`))
+	if err := acl.ApplyPolicy(second); err != nil {
+		t.Fatalf("ApplyPolicy: %v", err)
+	}
+
+	if acl.CheckPermission("anyone", "docs/readme", "read").Allowed {
+		t.Error("Expected the previous policy's rules to be replaced")
+	}
+	if !acl.CheckPermission("anyone", "wiki/home", "read").Allowed {
+		t.Error("Expected the new policy's rules to apply")
+	}
+	if !acl.CheckPermission("eng-agent", "repo-1", "write").Allowed {
+		t.Error("Expected rules outside the policy to be kept")
+	}
+
+	invalid, _ := ParsePolicy([]byte(`{"version":"3","rules":[{"agent_id":"role:sre","resource":"*","permission":"admin"}]}`))
+	if err := acl.ApplyPolicy(invalid); err == nil {
+		t.Error("Expected a policy naming an unknown role to be rejected")
+	}
+	if acl.PolicyVersion() != "2" || !acl.CheckPermission("anyone", "wiki/home", "read").Allowed {
+		t.Errorf("Expected policy 2 to stay in effect, have %s", acl.PolicyVersion())
+	}
+}
+
+func TestAclManager_ValidatePolicy(t *testing.T) {
+	acl := NewAclManager()
+	acl.SetKnownRoles([]string{"qa"})
+
+	if v := acl.ValidatePolicy([]byte(hclPolicy)); !v.Valid || v.Rules != 2 || v.Version != "2026-10-16" {
+		t.Errorf("Expected the policy to be valid, got %+v", v)
+	}
+	v := acl.ValidatePolicy([]byte(`{"rules":[{"agent_id":"role:sre","resource":"","permission":"all"}]}`))
+	if v.Valid || len(v.Errors) != 3 {
+		t.Errorf("Expected 3 problems, got %+v", v)
+	}
+	if v := acl.ValidatePolicy([]byte("rule {")); v.Valid || len(v.Errors) != 1 {
+		t.Errorf("Expected a parse error, got %+v", v)
+	}
+	if acl.PolicyVersion() != "" {
+		t.Error("Expected validation not to apply the policy")
+	}
+}
+
+func TestAclManager_WatchPolicy(t *testing.T) {
+	acl := NewAclManager()
+	defer acl.Close()
+	path := filepath.Join(t.TempDir(), "acl.hcl")
+	write := func(version, resource string) {
+		data := "version = \"" + version + "\"\nrule {\n  agent_id = \"*\"\n  resource = \"" + resource + "\"\n  permission = \"read\"\n}\n"
+		if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitFor := func(version string) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for acl.PolicyVersion() != version {
+			if time.Now().After(deadline) {
+				t.Fatalf("Expected policy %s, have %s", version, acl.PolicyVersion())
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	write("1", "docs/*")
+	if err := acl.WatchPolicy(path, 10*time.Millisecond); err != nil {
+		t.Fatalf("WatchPolicy: %v", err)
+	}
+	waitFor("1")
+
+	write("2", "wiki/pages/*")
+	waitFor("2")
+	if !acl.CheckPermission("anyone", "wiki/pages/home", "read").Allowed {
+		t.Error("Expected the reloaded policy to apply")
+	}
+
+	// An invalid edit is ignored
+	if err := os.WriteFile(path, []byte("rule {\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if acl.PolicyVersion() != "2" {
+		t.Errorf("Expected policy 2 to stay in effect, have %s", acl.PolicyVersion())
+	}
+}
+
+func TestAclManager_WatchPolicyInvalidAtStart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "acl.json")
+	if err := os.WriteFile(path, []byte(`{"rules":[{"agent_id":"*"}]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	acl := NewAclManager()
+	defer acl.Close()
+	if err := acl.WatchPolicy(path, time.Second); err == nil {
+		t.Error("Expected an invalid initial policy to be an error")
+	}
+}
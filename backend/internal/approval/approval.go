@@ -1,12 +1,16 @@
 package approval
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
+
+	"github.com/aoi-protocol/aoi/internal/rpc"
 )
 
 // ApprovalStatus represents the status of an approval request
@@ -19,6 +23,25 @@ const (
 	StatusExpired  ApprovalStatus = "expired"
 )
 
+// TaskTypeACLGrant is the task type of requests for temporary access. When such
+// a request is approved, the ACL grant described by its params is issued.
+const TaskTypeACLGrant = "acl_grant"
+
+// ErrInvalidParams is returned when a validator rejects a request's params
+var ErrInvalidParams = errors.New("invalid params")
+
+// ErrSelfApproval is returned when a request is approved by its requester
+var ErrSelfApproval = errors.New("a request cannot be approved by its requester")
+
+// ErrApproverDenied is returned when an approver check rejects the approver
+var ErrApproverDenied = errors.New("approver is not allowed to approve this request")
+
+// Validator checks the params of a new request of one task type
+type Validator func(params map[string]interface{}) error
+
+// ApproverCheck checks that approver may approve a pending request of one task type
+type ApproverCheck func(req *ApprovalRequest, approver string) error
+
 // ApprovalRequest represents a Human-in-the-Loop approval request
 type ApprovalRequest struct {
 	ID          string                 `json:"id"`
@@ -41,6 +64,11 @@ type ApprovalManager struct {
 	mu            sync.RWMutex
 	defaultExpiry time.Duration
 	callbacks     map[string]func(*ApprovalRequest)
+	validators    map[string]Validator
+	approvers     map[string]ApproverCheck
+	onApprove     []func(*ApprovalRequest)
+	// caller returns the authenticated caller of a JSON-RPC request
+	caller func(ctx context.Context) string
 }
 
 // NewApprovalManager creates a new approval manager
@@ -49,6 +77,8 @@ func NewApprovalManager() *ApprovalManager {
 		requests:      make(map[string]*ApprovalRequest),
 		defaultExpiry: 24 * time.Hour, // Default 24 hour expiry
 		callbacks:     make(map[string]func(*ApprovalRequest)),
+		validators:    make(map[string]Validator),
+		approvers:     make(map[string]ApproverCheck),
 	}
 	// Start background cleanup
 	go am.cleanupExpired()
@@ -60,6 +90,12 @@ func (am *ApprovalManager) CreateRequest(requester, taskType, description string
 	am.mu.Lock()
 	defer am.mu.Unlock()
 
+	if validate, ok := am.validators[taskType]; ok {
+		if err := validate(params); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidParams, err)
+		}
+	}
+
 	now := time.Now()
 	req := &ApprovalRequest{
 		ID:          uuid.New().String(),
@@ -130,8 +166,23 @@ func (am *ApprovalManager) ListAll(statusFilter ApprovalStatus) []*ApprovalReque
 	return result
 }
 
-// Approve approves a request
+// Approve approves a request and runs the OnApprove callbacks
 func (am *ApprovalManager) Approve(id, approvedBy string) (*ApprovalRequest, error) {
+	req, err := am.approve(id, approvedBy)
+	if err != nil {
+		return nil, err
+	}
+
+	am.mu.RLock()
+	callbacks := am.onApprove
+	am.mu.RUnlock()
+	for _, fn := range callbacks {
+		fn(req)
+	}
+	return req, nil
+}
+
+func (am *ApprovalManager) approve(id, approvedBy string) (*ApprovalRequest, error) {
 	am.mu.Lock()
 	defer am.mu.Unlock()
 
@@ -144,6 +195,15 @@ func (am *ApprovalManager) Approve(id, approvedBy string) (*ApprovalRequest, err
 		return nil, fmt.Errorf("request is not pending: current status is %s", req.Status)
 	}
 
+	if approvedBy != "" && approvedBy == req.Requester {
+		return nil, ErrSelfApproval
+	}
+	if check, ok := am.approvers[req.TaskType]; ok {
+		if err := check(req, approvedBy); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrApproverDenied, err)
+		}
+	}
+
 	if time.Now().After(req.ExpiresAt) {
 		req.Status = StatusExpired
 		req.UpdatedAt = time.Now()
@@ -191,6 +251,52 @@ func (am *ApprovalManager) Deny(id, deniedBy, reason string) (*ApprovalRequest,
 	return req, nil
 }
 
+// SetValidator makes new requests of taskType fail unless validate accepts their params
+func (am *ApprovalManager) SetValidator(taskType string, validate Validator) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	am.validators[taskType] = validate
+}
+
+// SetApproverCheck makes approvals of taskType fail unless check accepts the approver
+func (am *ApprovalManager) SetApproverCheck(taskType string, check ApproverCheck) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	am.approvers[taskType] = check
+}
+
+// SetCaller makes the JSON-RPC methods take the requester, approver and
+// reviewer from the authenticated caller that caller returns rather than
+// from params. A name given in params must match the caller.
+func (am *ApprovalManager) SetCaller(caller func(ctx context.Context) string) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	am.caller = caller
+}
+
+// actor returns who is acting in a JSON-RPC request: the authenticated caller
+// when a caller func is set, else the name given in params
+func (am *ApprovalManager) actor(ctx context.Context, claimed string) (string, error) {
+	am.mu.RLock()
+	caller := am.caller
+	am.mu.RUnlock()
+	if caller == nil {
+		return claimed, nil
+	}
+	proven := caller(ctx)
+	if claimed != "" && claimed != proven {
+		return "", rpc.NewError(rpc.CodeACLDenied, fmt.Sprintf("caller '%s' cannot act as '%s'", proven, claimed), nil)
+	}
+	return proven, nil
+}
+
+// OnApprove registers a callback invoked synchronously whenever a request is approved
+func (am *ApprovalManager) OnApprove(fn func(*ApprovalRequest)) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	am.onApprove = append(am.onApprove, fn)
+}
+
 // RegisterCallback registers a callback function to be called when a request is approved/denied
 func (am *ApprovalManager) RegisterCallback(requestID string, callback func(*ApprovalRequest)) {
 	am.mu.Lock()
@@ -218,21 +324,85 @@ func (am *ApprovalManager) cleanupExpired() {
 func (am *ApprovalManager) HandleJSONRPC(method string, params json.RawMessage) (interface{}, error) {
 	switch method {
 	case "aoi.approval.create":
-		return am.handleCreate(params)
+		return am.handleCreate(context.Background(), params)
 	case "aoi.approval.get":
 		return am.handleGet(params)
 	case "aoi.approval.list":
 		return am.handleList(params)
 	case "aoi.approval.approve":
-		return am.handleApprove(params)
+		return am.handleApprove(context.Background(), params)
 	case "aoi.approval.deny":
-		return am.handleDeny(params)
+		return am.handleDeny(context.Background(), params)
 	default:
 		return nil, fmt.Errorf("unknown method: %s", method)
 	}
 }
 
-func (am *ApprovalManager) handleCreate(params json.RawMessage) (interface{}, error) {
+// RegisterMethods registers the aoi.approval.* methods
+func (am *ApprovalManager) RegisterMethods(reg *rpc.Registry) {
+	request := rpc.Result("request", rpc.Type("object"))
+	reg.MustRegister(
+		rpc.Method{
+			Name:    "aoi.approval.create",
+			Summary: "Create a Human-in-the-Loop approval request",
+			Params: []rpc.ContentDescriptor{
+				rpc.Param("requester", "string", "Agent asking for approval; defaults to the caller"),
+				rpc.RequiredParam("taskType", "string", "Kind of task to approve"),
+				rpc.Param("description", "string", "Human readable description"),
+				rpc.Param("params", "object", "Task parameters"),
+			},
+			Result:   request,
+			Resource: "approvals/create",
+			Action:   rpc.ActionWrite,
+			Handler:  am.handleCreate,
+		},
+		rpc.Method{
+			Name:     "aoi.approval.get",
+			Summary:  "Get an approval request by ID",
+			Params:   []rpc.ContentDescriptor{rpc.RequiredParam("id", "string", "Approval request ID")},
+			Result:   request,
+			Resource: "approvals/get",
+			Action:   rpc.ActionRead,
+			Handler:  rpc.WithoutContext(am.handleGet),
+		},
+		rpc.Method{
+			Name:     "aoi.approval.list",
+			Summary:  "List approval requests by status (pending by default)",
+			Params:   []rpc.ContentDescriptor{rpc.Param("status", "string", "pending, approved, denied or expired")},
+			Result:   rpc.Result("requests", rpc.ArrayOf(rpc.Type("object"))),
+			Resource: "approvals/list",
+			Action:   rpc.ActionRead,
+			Handler:  rpc.WithoutContext(am.handleList),
+		},
+		rpc.Method{
+			Name:    "aoi.approval.approve",
+			Summary: "Approve a pending request",
+			Params: []rpc.ContentDescriptor{
+				rpc.RequiredParam("id", "string", "Approval request ID"),
+				rpc.Param("approvedBy", "string", "Approver; defaults to the caller, who cannot be the requester"),
+			},
+			Result:   request,
+			Resource: "approvals/approve",
+			Action:   rpc.ActionWrite,
+			Handler:  am.handleApprove,
+		},
+		rpc.Method{
+			Name:    "aoi.approval.deny",
+			Summary: "Deny a pending request",
+			Params: []rpc.ContentDescriptor{
+				rpc.RequiredParam("id", "string", "Approval request ID"),
+				rpc.Param("deniedBy", "string", "Reviewer; defaults to the caller"),
+				rpc.Param("reason", "string", "Reason for denial"),
+			},
+			Result:   request,
+			Resource: "approvals/deny",
+			Action:   rpc.ActionWrite,
+			Handler:  am.handleDeny,
+		},
+	)
+}
+
+func (am *ApprovalManager) handleCreate(ctx context.Context, params json.RawMessage) (interface{}, error) {
 	var p struct {
 		Requester   string                 `json:"requester"`
 		TaskType    string                 `json:"taskType"`
@@ -242,7 +412,15 @@ func (am *ApprovalManager) handleCreate(params json.RawMessage) (interface{}, er
 	if err := json.Unmarshal(params, &p); err != nil {
 		return nil, fmt.Errorf("invalid params: %w", err)
 	}
-	return am.CreateRequest(p.Requester, p.TaskType, p.Description, p.Params)
+	requester, err := am.actor(ctx, p.Requester)
+	if err != nil {
+		return nil, err
+	}
+	req, err := am.CreateRequest(requester, p.TaskType, p.Description, p.Params)
+	if errors.Is(err, ErrInvalidParams) {
+		return nil, rpc.InvalidParams(err.Error())
+	}
+	return req, err
 }
 
 func (am *ApprovalManager) handleGet(params json.RawMessage) (interface{}, error) {
@@ -271,7 +449,7 @@ func (am *ApprovalManager) handleList(params json.RawMessage) (interface{}, erro
 	return am.ListAll(ApprovalStatus(p.Status)), nil
 }
 
-func (am *ApprovalManager) handleApprove(params json.RawMessage) (interface{}, error) {
+func (am *ApprovalManager) handleApprove(ctx context.Context, params json.RawMessage) (interface{}, error) {
 	var p struct {
 		ID         string `json:"id"`
 		ApprovedBy string `json:"approvedBy"`
@@ -279,10 +457,18 @@ func (am *ApprovalManager) handleApprove(params json.RawMessage) (interface{}, e
 	if err := json.Unmarshal(params, &p); err != nil {
 		return nil, fmt.Errorf("invalid params: %w", err)
 	}
-	return am.Approve(p.ID, p.ApprovedBy)
+	approver, err := am.actor(ctx, p.ApprovedBy)
+	if err != nil {
+		return nil, err
+	}
+	req, err := am.Approve(p.ID, approver)
+	if errors.Is(err, ErrSelfApproval) || errors.Is(err, ErrApproverDenied) {
+		return nil, rpc.NewError(rpc.CodeACLDenied, err.Error(), nil)
+	}
+	return req, err
 }
 
-func (am *ApprovalManager) handleDeny(params json.RawMessage) (interface{}, error) {
+func (am *ApprovalManager) handleDeny(ctx context.Context, params json.RawMessage) (interface{}, error) {
 	var p struct {
 		ID       string `json:"id"`
 		DeniedBy string `json:"deniedBy"`
@@ -291,5 +477,9 @@ func (am *ApprovalManager) handleDeny(params json.RawMessage) (interface{}, erro
 	if err := json.Unmarshal(params, &p); err != nil {
 		return nil, fmt.Errorf("invalid params: %w", err)
 	}
-	return am.Deny(p.ID, p.DeniedBy, p.Reason)
+	reviewer, err := am.actor(ctx, p.DeniedBy)
+	if err != nil {
+		return nil, err
+	}
+	return am.Deny(p.ID, reviewer, p.Reason)
 }
@@ -2,6 +2,7 @@ package approval
 
 import (
 	"encoding/json"
+	"errors"
 	"testing"
 	"time"
 )
@@ -229,3 +230,66 @@ func TestApprovalManager_ListAll(t *testing.T) {
 		t.Errorf("Expected 1 denied request, got %d", len(denied))
 	}
 }
+
+func TestApprovalManager_Validator(t *testing.T) {
+	am := NewApprovalManager()
+	am.SetValidator(TaskTypeACLGrant, func(params map[string]interface{}) error {
+		if params["resource"] == nil {
+			return errors.New("resource is required")
+		}
+		return nil
+	})
+
+	if _, err := am.CreateRequest("pm-agent", TaskTypeACLGrant, "read billing", map[string]interface{}{}); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("Expected ErrInvalidParams, got %v", err)
+	}
+	if _, err := am.CreateRequest("pm-agent", TaskTypeACLGrant, "read billing", map[string]interface{}{"resource": "x"}); err != nil {
+		t.Errorf("Expected valid params to be accepted, got %v", err)
+	}
+	// Other task types are not validated
+	if _, err := am.CreateRequest("pm-agent", "deploy", "", nil); err != nil {
+		t.Errorf("Expected an unvalidated task type to be accepted, got %v", err)
+	}
+}
+
+func TestApprovalManager_OnApprove(t *testing.T) {
+	am := NewApprovalManager()
+	var approved []string
+	am.OnApprove(func(req *ApprovalRequest) { approved = append(approved, req.ID) })
+
+	req, _ := am.CreateRequest("pm-agent", "deploy", "", nil)
+	other, _ := am.CreateRequest("pm-agent", "deploy", "", nil)
+	am.Deny(other.ID, "lead", "no")
+	if _, err := am.Approve(req.ID, "lead"); err != nil {
+		t.Fatalf("Approve: %v", err)
+	}
+
+	// Called synchronously, and only for approvals
+	if len(approved) != 1 || approved[0] != req.ID {
+		t.Errorf("Expected one callback for %s, got %v", req.ID, approved)
+	}
+}
+
+func TestApprovalManager_ApproverChecks(t *testing.T) {
+	am := NewApprovalManager()
+	am.SetApproverCheck("deploy", func(req *ApprovalRequest, approver string) error {
+		if approver != "lead" {
+			return errors.New("only lead deploys")
+		}
+		return nil
+	})
+
+	req, _ := am.CreateRequest("pm-agent", "deploy", "", nil)
+	if _, err := am.Approve(req.ID, "pm-agent"); !errors.Is(err, ErrSelfApproval) {
+		t.Errorf("Expected ErrSelfApproval, got %v", err)
+	}
+	if _, err := am.Approve(req.ID, "eng-agent"); !errors.Is(err, ErrApproverDenied) {
+		t.Errorf("Expected ErrApproverDenied, got %v", err)
+	}
+	if got, _ := am.GetRequest(req.ID); got.Status != StatusPending {
+		t.Errorf("Expected the request to stay pending, got %s", got.Status)
+	}
+	if _, err := am.Approve(req.ID, "lead"); err != nil {
+		t.Errorf("Expected lead to approve, got %v", err)
+	}
+}
@@ -9,6 +9,8 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+
+	"github.com/aoi-protocol/aoi/internal/rpc"
 )
 
 // AuditEventType represents the type of audit event
@@ -23,6 +25,12 @@ const (
 	EventMCPCall     AuditEventType = "mcp_call"
 	EventAgentJoin   AuditEventType = "agent_join"
 	EventAgentLeave  AuditEventType = "agent_leave"
+	EventACLGrant    AuditEventType = "acl_grant"
+	EventACLRevoke   AuditEventType = "acl_revoke"
+	EventFocus       AuditEventType = "focus"
+	EventDelegate    AuditEventType = "delegate"
+	EventDisclosure  AuditEventType = "disclosure"
+	EventRedaction   AuditEventType = "redaction"
 )
 
 // AuditEntry represents an audit log entry
@@ -253,6 +261,78 @@ func (al *AuditLogger) HandleJSONRPC(method string, params json.RawMessage) (int
 	}
 }
 
+// RegisterMethods registers the aoi.audit.* methods
+func (al *AuditLogger) RegisterMethods(reg *rpc.Registry) {
+	entry := rpc.Result("entry", rpc.Type("object"))
+	reg.MustRegister(
+		rpc.Method{
+			Name:    "aoi.audit.log",
+			Summary: "Record an audit entry",
+			Params: []rpc.ContentDescriptor{
+				rpc.RequiredParam("eventType", "string", "Event type, e.g. query or execute"),
+				rpc.Param("fromAgent", "string", "Originating agent"),
+				rpc.Param("toAgent", "string", "Target agent"),
+				rpc.Param("summary", "string", "Short description"),
+				rpc.Param("details", "object", "Structured details"),
+				rpc.Param("success", "boolean", "Whether the event succeeded"),
+				rpc.Param("errorMsg", "string", "Error message on failure"),
+			},
+			Result:   entry,
+			Resource: "audit/log",
+			Action:   rpc.ActionWrite,
+			Handler:  rpc.WithoutContext(al.handleLog),
+		},
+		rpc.Method{
+			Name:     "aoi.audit.get",
+			Summary:  "Get an audit entry by ID",
+			Params:   []rpc.ContentDescriptor{rpc.RequiredParam("id", "string", "Audit entry ID")},
+			Result:   entry,
+			Resource: "audit/get",
+			Action:   rpc.ActionRead,
+			Handler:  rpc.WithoutContext(al.handleGet),
+		},
+		rpc.Method{
+			Name:    "aoi.audit.search",
+			Summary: "Search audit entries",
+			Params: []rpc.ContentDescriptor{
+				rpc.Param("fromAgent", "string", "Filter by originating agent"),
+				rpc.Param("toAgent", "string", "Filter by target agent"),
+				rpc.Param("eventType", "string", "Filter by event type"),
+				rpc.Param("searchTerm", "string", "Substring of summary or agents"),
+				rpc.Param("startTime", "string", "RFC 3339 lower bound"),
+				rpc.Param("endTime", "string", "RFC 3339 upper bound"),
+				rpc.Param("successOnly", "boolean", "Only successful (true) or failed (false) events"),
+				rpc.Param("limit", "integer", "Page size"),
+				rpc.Param("offset", "integer", "Page offset"),
+				rpc.Param("sortDescending", "boolean", "Newest first"),
+			},
+			Result:   rpc.Result("result", rpc.Type("object")),
+			Resource: "audit/search",
+			Action:   rpc.ActionRead,
+			Handler:  rpc.WithoutContext(al.handleSearch),
+		},
+		rpc.Method{
+			Name:     "aoi.audit.recent",
+			Summary:  "Get the most recent audit entries",
+			Params:   []rpc.ContentDescriptor{rpc.Param("count", "integer", "Number of entries")},
+			Result:   rpc.Result("entries", rpc.ArrayOf(rpc.Type("object"))),
+			Resource: "audit/recent",
+			Action:   rpc.ActionRead,
+			Handler:  rpc.WithoutContext(al.handleRecent),
+		},
+		rpc.Method{
+			Name:     "aoi.audit.stats",
+			Summary:  "Get audit log statistics",
+			Result:   rpc.Result("stats", rpc.Type("object")),
+			Resource: "audit/stats",
+			Action:   rpc.ActionRead,
+			Handler: rpc.WithoutContext(func(params json.RawMessage) (interface{}, error) {
+				return al.GetStats(), nil
+			}),
+		},
+	)
+}
+
 func (al *AuditLogger) handleLog(params json.RawMessage) (interface{}, error) {
 	var p struct {
 		EventType string                 `json:"eventType"`
@@ -8,13 +8,24 @@ import (
 
 // Config represents the complete configuration for an AOI agent
 type Config struct {
-	Agent     AgentConfig     `json:"agent"`
-	Network   NetworkConfig   `json:"network"`
-	ACL       ACLConfig       `json:"acl"`
-	Context   ContextConfig   `json:"context"`
-	MCP       MCPConfig       `json:"mcp"`
-	Tailscale TailscaleConfig `json:"tailscale"`
-	H2A       H2AConfig       `json:"h2a"`
+	Agent      AgentConfig      `json:"agent"`
+	Network    NetworkConfig    `json:"network"`
+	ACL        ACLConfig        `json:"acl"`
+	Context    ContextConfig    `json:"context"`
+	MCP        MCPConfig        `json:"mcp"`
+	Tailscale  TailscaleConfig  `json:"tailscale"`
+	H2A        H2AConfig        `json:"h2a"`
+	Tasks      TaskConfig       `json:"tasks"`
+	Registry   RegistryConfig   `json:"registry"`
+	Gossip     GossipConfig     `json:"gossip"`
+	Signing    SigningConfig    `json:"signing"`
+	LLM        LLMConfig        `json:"llm"`
+	Focus      FocusConfig      `json:"focus"`
+	Delegation DelegationConfig `json:"delegation"`
+	Disclosure DisclosureConfig `json:"disclosure"`
+	Redaction  RedactionConfig  `json:"redaction"`
+	// Roles defines agent roles beyond (or replacing) the built-in ones
+	Roles []RoleConfig `json:"roles,omitempty"`
 }
 
 // AgentConfig contains agent identity configuration
@@ -22,24 +33,72 @@ type AgentConfig struct {
 	ID    string `json:"id"`
 	Role  string `json:"role"`
 	Owner string `json:"owner"`
+	// Capabilities are free-form capability tags advertised in aoi.discover
+	Capabilities []string `json:"capabilities,omitempty"`
+	// Manifest is this agent's capability declaration
+	Manifest ManifestConfig `json:"manifest"`
+}
+
+// ManifestConfig declares the context an agent holds and the actions it exposes.
+// Tools of MCP servers with auto_connect are added to MCPTools at startup.
+type ManifestConfig struct {
+	Repositories []RepositoryConfig `json:"repositories,omitempty"`
+	// Actions is a subset of "read", "write" and "execute"
+	Actions  []string `json:"actions,omitempty"`
+	MCPTools []string `json:"mcp_tools,omitempty"`
+}
+
+// RepositoryConfig is a repository the agent holds context for
+type RepositoryConfig struct {
+	Name   string `json:"name"`
+	Issues []int  `json:"issues,omitempty"`
 }
 
 // NetworkConfig contains network and transport configuration
 type NetworkConfig struct {
 	ListenAddr string `json:"listen_addr"`
-	TLSEnabled bool   `json:"tls_enabled"`
+	// AdvertiseURL is the URL other agents reach this agent at (e.g.,
+	// "https://eng-suzuki.tailnet.ts.net:8080"); defaults to the listen address
+	AdvertiseURL string `json:"advertise_url,omitempty"`
+	TLSEnabled   bool   `json:"tls_enabled"`
+	// TLSCertFile and TLSKeyFile are this agent's certificate and key (PEM)
+	TLSCertFile string `json:"tls_cert_file,omitempty"`
+	TLSKeyFile  string `json:"tls_key_file,omitempty"`
+	// TLSCAFile is the CA that issues agent certificates. It verifies client
+	// certificates and the certificates of agents this agent calls.
+	TLSCAFile string `json:"tls_ca_file,omitempty"`
+	// TLSClientAuth is "none", "request" (verify if presented) or "require" (mutual TLS)
+	TLSClientAuth string `json:"tls_client_auth,omitempty"`
 }
 
 // ACLConfig contains access control list configuration
 type ACLConfig struct {
 	Rules []ACLRuleConfig `json:"rules"`
+	// Enforce checks every JSON-RPC method and REST route against the rules
+	Enforce bool `json:"enforce"`
+	// Tokens are bearer tokens proving an agent ID, for callers that can
+	// neither present a client certificate nor sign requests
+	Tokens []ACLTokenConfig `json:"tokens,omitempty"`
+	// PolicyFile is an ACL policy file, JSON or HCL-like, whose rules are
+	// applied alongside Rules and reloaded whenever the file changes
+	PolicyFile string `json:"policy_file,omitempty"`
+}
+
+// ACLTokenConfig is a bearer token and the agent it authenticates
+type ACLTokenConfig struct {
+	AgentID string `json:"agent_id"`
+	Token   string `json:"token"`
 }
 
 // ACLRuleConfig represents a single ACL rule
 type ACLRuleConfig struct {
-	AgentID    string `json:"agent_id"`
+	// AgentID is an agent ID, a glob such as "*" or "eng-*", or "role:<name>"
+	AgentID string `json:"agent_id"`
+	// Resource is a resource or a pattern such as "docs/*" or "projects/**"
 	Resource   string `json:"resource"`
-	Permission string `json:"permission"`
+	Permission string `json:"permission"` // "none", "read", "write", "admin"
+	// Effect is "allow" (default) or "deny"
+	Effect string `json:"effect,omitempty"`
 }
 
 // ContextConfig contains context management configuration
@@ -103,6 +162,148 @@ type H2AConfig struct {
 	StreamIntervalMs int `json:"stream_interval_ms"`
 }
 
+// TaskConfig contains configuration for aoi.execute task executors.
+type TaskConfig struct {
+	// ShellAllowlist lists the commands the "shell" task type may run. Empty disables it.
+	ShellAllowlist []string `json:"shell_allowlist"`
+	// WorkDir is the working directory for shell tasks (defaults to the agent's cwd).
+	WorkDir string `json:"work_dir,omitempty"`
+}
+
+// RegistryConfig contains configuration for storing registered agents.
+type RegistryConfig struct {
+	// Storage is "memory" (agents are forgotten on restart) or "file".
+	Storage string `json:"storage"`
+	// Path is the directory file storage keeps its snapshot and journal in.
+	Path string `json:"path,omitempty"`
+	// LeaseTTL is how long an aoi.heartbeat keeps an agent online (e.g., "30s").
+	LeaseTTL string `json:"lease_ttl,omitempty"`
+	// SweepInterval is how often expired leases are marked offline (e.g., "5s").
+	SweepInterval string `json:"sweep_interval,omitempty"`
+}
+
+// GossipConfig contains configuration for syncing registries between agents.
+type GossipConfig struct {
+	// Enabled turns registry gossip on.
+	Enabled bool `json:"enabled"`
+	// Seeds are URLs of agents to gossip with before any are known.
+	Seeds []string `json:"seeds,omitempty"`
+	// Interval is the time between gossip rounds (e.g., "10s").
+	Interval string `json:"interval,omitempty"`
+	// Fanout is how many peers are synced with per round.
+	Fanout int `json:"fanout,omitempty"`
+	// TombstoneTTL is how long removed agents are remembered (e.g., "24h").
+	TombstoneTTL string `json:"tombstone_ttl,omitempty"`
+}
+
+// SigningConfig contains configuration for Ed25519 request signatures.
+type SigningConfig struct {
+	// Mode is "off", "optional" (sign requests and verify signed ones) or
+	// "required" (also reject unsigned requests).
+	Mode string `json:"mode"`
+	// KeyFile is this agent's private key (PEM), generated on first start.
+	KeyFile string `json:"key_file,omitempty"`
+	// MaxSkew is how far a request's timestamp may be from the local clock (e.g., "5m").
+	MaxSkew string `json:"max_skew,omitempty"`
+}
+
+// LLMConfig selects the language model the secretary answers queries with
+type LLMConfig struct {
+	// Provider is "openai" for an OpenAI-compatible chat completions API,
+	// "stub" for deterministic answers, or "" for the role's built-in templates
+	Provider string `json:"provider"`
+	// BaseURL is the API root, e.g. "https://api.openai.com/v1" or a local
+	// server such as "http://localhost:11434/v1"
+	BaseURL string `json:"base_url,omitempty"`
+	Model   string `json:"model,omitempty"`
+	// APIKeyEnv names the environment variable holding the API key, if any
+	APIKeyEnv string `json:"api_key_env,omitempty"`
+	// Timeout bounds a single completion (e.g., "60s")
+	Timeout     string   `json:"timeout,omitempty"`
+	MaxTokens   int      `json:"max_tokens,omitempty"`
+	Temperature *float64 `json:"temperature,omitempty"`
+	// Logprobs asks for token log probabilities, from which the confidence
+	// of an answer is estimated; not every server supports them
+	Logprobs bool `json:"logprobs,omitempty"`
+}
+
+// FocusConfig contains configuration for focus mode, in which the secretary
+// shields a focused human from interruptions
+type FocusConfig struct {
+	// AutoDetect turns focus on when burst_threshold file changes happen
+	// within burst_window, and off after idle_timeout without changes
+	AutoDetect     bool   `json:"auto_detect"`
+	BurstWindow    string `json:"burst_window,omitempty"`
+	BurstThreshold int    `json:"burst_threshold,omitempty"`
+	IdleTimeout    string `json:"idle_timeout,omitempty"`
+	// MinConfidence is the confidence an answer needs to be given while the
+	// human is focused; less confident answers are queued instead
+	MinConfidence float64 `json:"min_confidence,omitempty"`
+	// Rules decide, in order, what happens to queries while the human is
+	// focused; urgent and critical queries are escalated after them
+	Rules []FocusRuleConfig `json:"rules,omitempty"`
+}
+
+// FocusRuleConfig matches queries by sender role, priority and topic, where
+// empty fields match anything, and applies an action to them
+type FocusRuleConfig struct {
+	SenderRole string `json:"sender_role,omitempty"`
+	Priority   string `json:"priority,omitempty"`
+	Topic      string `json:"topic,omitempty"`
+	// Action is "answer", "queue" or "escalate"
+	Action string `json:"action"`
+}
+
+// DelegationConfig selects the local worker AI, such as Claude Code or Cursor,
+// that the secretary delegates queries to
+type DelegationConfig struct {
+	// Mode is "explicit" to delegate queries sent with "delegate": true,
+	// "fallback" to also delegate those the context has no answer for, or ""
+	// to turn delegation off
+	Mode string `json:"mode"`
+	// Worker is "h2a" for a worker in a tmux session or "mcp" for an MCP tool
+	Worker string `json:"worker,omitempty"`
+	// TmuxSession and TmuxPane name the worker's tmux session, registered
+	// with H2A for this agent at startup
+	TmuxSession string `json:"tmux_session,omitempty"`
+	TmuxPane    string `json:"tmux_pane,omitempty"`
+	// MCPServer and MCPTool name the tool that takes the prompt as its
+	// "prompt" argument
+	MCPServer string `json:"mcp_server,omitempty"`
+	MCPTool   string `json:"mcp_tool,omitempty"`
+	// Timeout bounds how long the worker may take to answer (e.g., "2m")
+	Timeout string `json:"timeout,omitempty"`
+	// PollInterval is how often the tmux session is read for the answer (e.g., "2s")
+	PollInterval string `json:"poll_interval,omitempty"`
+}
+
+// DisclosureConfig limits how much of an answer or MCP output requesters see
+type DisclosureConfig struct {
+	// DefaultLevel is "full", "analysis" (no code blocks or file contents) or
+	// "boolean" (yes or no only); read permission on disclosure/analysis or
+	// disclosure/full raises a requester's level. Empty means "full".
+	DefaultLevel string `json:"default_level,omitempty"`
+}
+
+// RedactionConfig selects the detectors whose matches are replaced with
+// placeholders in everything that leaves the agent. Redaction is on unless
+// disabled.
+type RedactionConfig struct {
+	Disabled bool `json:"disabled,omitempty"`
+	// Detectors names the built-in detectors to run ("private_key", "api_key",
+	// "token", "env_secret", "email"); empty runs them all
+	Detectors []string `json:"detectors,omitempty"`
+	// Patterns are custom detectors, run after the built-in ones
+	Patterns []RedactionPatternConfig `json:"patterns,omitempty"`
+}
+
+// RedactionPatternConfig is a custom detector. A group named "secret" in the
+// pattern limits the replacement to that group.
+type RedactionPatternConfig struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+}
+
 // TagMappingConfig represents a mapping from Tailscale tag to AOI permission
 type TagMappingConfig struct {
 	Tag        string   `json:"tag"`
@@ -132,10 +333,14 @@ func LoadDefault() *Config {
 			ID:    "default-agent",
 			Role:  "engineer",
 			Owner: "system",
+			Manifest: ManifestConfig{
+				Actions: []string{"read"},
+			},
 		},
 		Network: NetworkConfig{
-			ListenAddr: "0.0.0.0:8080",
-			TLSEnabled: false,
+			ListenAddr:    "0.0.0.0:8080",
+			TLSEnabled:    false,
+			TLSClientAuth: "none",
 		},
 		ACL: ACLConfig{
 			Rules: []ACLRuleConfig{},
@@ -167,6 +372,26 @@ func LoadDefault() *Config {
 			DefaultCaptureLines: 50,
 			StreamIntervalMs:    500,
 		},
+		Tasks: TaskConfig{
+			ShellAllowlist: []string{},
+		},
+		Registry: RegistryConfig{
+			Storage:       "memory",
+			LeaseTTL:      "30s",
+			SweepInterval: "5s",
+		},
+		Gossip: GossipConfig{
+			Enabled:      false,
+			Seeds:        []string{},
+			Interval:     "10s",
+			Fanout:       3,
+			TombstoneTTL: "24h",
+		},
+		Signing: SigningConfig{
+			Mode:    "off",
+			KeyFile: "data/signing.key",
+			MaxSkew: "5m",
+		},
 	}
 }
 
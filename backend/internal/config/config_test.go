@@ -227,3 +227,217 @@ func TestACLRuleConfig_Fields(t *testing.T) {
 		t.Errorf("Expected permission 'allow', got %s", rule.Permission)
 	}
 }
+
+func TestRoleSet_BuiltinsAndCustom(t *testing.T) {
+	cfg := LoadDefault()
+	cfg.Roles = []RoleConfig{
+		{Name: "sre", DisplayName: "Site Reliability Engineer", Capabilities: []string{"oncall"}, Handler: "engineer",
+			Grants: []RoleGrantConfig{{Resource: "tasks/shell", Permission: "write"}}},
+		{Name: "tech-writer"},
+		{Name: "qa", DisplayName: "Quality Assurance"},
+	}
+
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+
+	roles := cfg.RoleSet()
+	if len(roles) != 6 {
+		t.Errorf("Expected 4 built-in and 2 custom roles, got %d", len(roles))
+	}
+	if sre := roles["sre"]; sre.Handler != "engineer" || len(sre.Grants) != 1 {
+		t.Errorf("Unexpected sre role: %+v", sre)
+	}
+	if writer := roles["tech-writer"]; writer.DisplayName != "tech-writer" || writer.Handler != "generic" {
+		t.Errorf("Expected defaults for tech-writer, got %+v", writer)
+	}
+	// Overriding a built-in keeps its handler
+	if qa := roles["qa"]; qa.DisplayName != "Quality Assurance" || qa.Handler != "qa" {
+		t.Errorf("Expected qa override with the qa handler, got %+v", qa)
+	}
+}
+
+func TestValidate_Roles(t *testing.T) {
+	tests := []struct {
+		name  string
+		role  string
+		roles []RoleConfig
+		valid bool
+	}{
+		{"built-in role", "pm", nil, true},
+		{"custom role", "sre", []RoleConfig{{Name: "sre"}}, true},
+		{"unknown role", "sre", nil, false},
+		{"empty role", "", nil, false},
+		{"unnamed role", "pm", []RoleConfig{{DisplayName: "Nameless"}}, false},
+		{"duplicate role", "sre", []RoleConfig{{Name: "sre"}, {Name: "sre"}}, false},
+		{"bad grant permission", "sre", []RoleConfig{{Name: "sre", Grants: []RoleGrantConfig{{Resource: "tasks/*", Permission: "allow"}}}}, false},
+		{"grant without resource", "sre", []RoleConfig{{Name: "sre", Grants: []RoleGrantConfig{{Permission: "read"}}}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := LoadDefault()
+			cfg.Agent.Role = tt.role
+			cfg.Roles = tt.roles
+			if err := cfg.Validate(); (err == nil) != tt.valid {
+				t.Errorf("Expected valid=%v, got %v", tt.valid, err)
+			}
+		})
+	}
+}
+
+func TestValidate_ACLRules(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  ACLRuleConfig
+		valid bool
+	}{
+		{"agent rule", ACLRuleConfig{AgentID: "eng-agent", Resource: "repo-1", Permission: "write"}, true},
+		{"wildcard rule", ACLRuleConfig{AgentID: "*", Resource: "docs/**", Permission: "read"}, true},
+		{"role rule", ACLRuleConfig{AgentID: "role:qa", Resource: "tests/*", Permission: "write"}, true},
+		{"unknown role", ACLRuleConfig{AgentID: "role:sre", Resource: "tests/*", Permission: "write"}, false},
+		{"no subject", ACLRuleConfig{Resource: "repo-1", Permission: "read"}, false},
+		{"no resource", ACLRuleConfig{AgentID: "eng-agent", Permission: "read"}, false},
+		{"bad permission", ACLRuleConfig{AgentID: "eng-agent", Resource: "repo-1", Permission: "allow"}, false},
+		{"deny rule", ACLRuleConfig{AgentID: "role:qa", Resource: "projects/secret/**", Permission: "read", Effect: "deny"}, true},
+		{"bad effect", ACLRuleConfig{AgentID: "eng-agent", Resource: "repo-1", Permission: "read", Effect: "block"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := LoadDefault()
+			cfg.ACL.Rules = []ACLRuleConfig{tt.rule}
+			if err := cfg.Validate(); (err == nil) != tt.valid {
+				t.Errorf("Expected valid=%v, got %v", tt.valid, err)
+			}
+		})
+	}
+}
+
+func TestValidate_ACLTokens(t *testing.T) {
+	cfg := LoadDefault()
+	cfg.ACL.Tokens = []ACLTokenConfig{{AgentID: "dashboard", Token: "s3cret"}}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected valid tokens, got %v", err)
+	}
+
+	cfg.ACL.Tokens = append(cfg.ACL.Tokens, ACLTokenConfig{AgentID: "other", Token: "s3cret"})
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected a duplicate token to be rejected")
+	}
+
+	cfg.ACL.Tokens = []ACLTokenConfig{{AgentID: "dashboard"}}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected an empty token to be rejected")
+	}
+}
+
+func TestValidate_LLM(t *testing.T) {
+	tests := []struct {
+		name  string
+		llm   LLMConfig
+		valid bool
+	}{
+		{"none", LLMConfig{}, true},
+		{"stub", LLMConfig{Provider: "stub"}, true},
+		{"openai", LLMConfig{Provider: "openai", BaseURL: "http://localhost:11434/v1", Model: "llama3"}, true},
+		{"openai without model", LLMConfig{Provider: "openai", BaseURL: "http://localhost:11434/v1"}, false},
+		{"unknown provider", LLMConfig{Provider: "gpt"}, false},
+	}
+	for _, tt := range tests {
+		cfg := LoadDefault()
+		cfg.LLM = tt.llm
+		if err := cfg.Validate(); (err == nil) != tt.valid {
+			t.Errorf("%s: valid = %v, got error %v", tt.name, tt.valid, err)
+		}
+	}
+}
+
+func TestValidate_Focus(t *testing.T) {
+	tests := []struct {
+		name  string
+		focus FocusConfig
+		valid bool
+	}{
+		{"none", FocusConfig{}, true},
+		{"rules", FocusConfig{Rules: []FocusRuleConfig{
+			{SenderRole: "pm", Action: "answer"},
+			{Topic: "outage", Action: "escalate"},
+			{Action: "queue"},
+		}}, true},
+		{"unknown action", FocusConfig{Rules: []FocusRuleConfig{{Action: "ignore"}}}, false},
+		{"unknown sender role", FocusConfig{Rules: []FocusRuleConfig{{SenderRole: "ceo", Action: "queue"}}}, false},
+		{"confidence above 1", FocusConfig{MinConfidence: 1.5}, false},
+	}
+	for _, tt := range tests {
+		cfg := LoadDefault()
+		cfg.Focus = tt.focus
+		if err := cfg.Validate(); (err == nil) != tt.valid {
+			t.Errorf("%s: valid = %v, got error %v", tt.name, tt.valid, err)
+		}
+	}
+}
+
+func TestValidate_Delegation(t *testing.T) {
+	tests := []struct {
+		name       string
+		delegation DelegationConfig
+		valid      bool
+	}{
+		{"off", DelegationConfig{}, true},
+		{"h2a", DelegationConfig{Mode: "explicit", Worker: "h2a", TmuxSession: "work"}, true},
+		{"mcp", DelegationConfig{Mode: "fallback", Worker: "mcp", MCPServer: "claude", MCPTool: "ask"}, true},
+		{"mcp without tool", DelegationConfig{Mode: "fallback", Worker: "mcp", MCPServer: "claude"}, false},
+		{"unknown worker", DelegationConfig{Mode: "explicit", Worker: "ssh"}, false},
+		{"unknown mode", DelegationConfig{Mode: "always", Worker: "h2a"}, false},
+	}
+	for _, tt := range tests {
+		cfg := LoadDefault()
+		cfg.Delegation = tt.delegation
+		if err := cfg.Validate(); (err == nil) != tt.valid {
+			t.Errorf("%s: valid = %v, got error %v", tt.name, tt.valid, err)
+		}
+	}
+}
+
+func TestValidate_Disclosure(t *testing.T) {
+	tests := []struct {
+		name  string
+		level string
+		valid bool
+	}{
+		{"default", "", true},
+		{"full", "full", true},
+		{"analysis", "analysis", true},
+		{"boolean", "boolean", true},
+		{"unknown", "summary", false},
+	}
+	for _, tt := range tests {
+		cfg := LoadDefault()
+		cfg.Disclosure.DefaultLevel = tt.level
+		if err := cfg.Validate(); (err == nil) != tt.valid {
+			t.Errorf("%s: valid = %v, got error %v", tt.name, tt.valid, err)
+		}
+	}
+}
+
+func TestValidate_Redaction(t *testing.T) {
+	tests := []struct {
+		name      string
+		redaction RedactionConfig
+		valid     bool
+	}{
+		{"default", RedactionConfig{}, true},
+		{"disabled", RedactionConfig{Disabled: true}, true},
+		{"detectors", RedactionConfig{Detectors: []string{"api_key", "env_secret"}}, true},
+		{"unknown detector", RedactionConfig{Detectors: []string{"ssn"}}, false},
+		{"pattern", RedactionConfig{Patterns: []RedactionPatternConfig{{Name: "employee_id", Pattern: `EMP-\d{6}`}}}, true},
+		{"invalid pattern", RedactionConfig{Patterns: []RedactionPatternConfig{{Name: "employee_id", Pattern: `EMP-(`}}}, false},
+		{"unnamed pattern", RedactionConfig{Patterns: []RedactionPatternConfig{{Pattern: `EMP-\d{6}`}}}, false},
+	}
+	for _, tt := range tests {
+		cfg := LoadDefault()
+		cfg.Redaction = tt.redaction
+		if err := cfg.Validate(); (err == nil) != tt.valid {
+			t.Errorf("%s: valid = %v, got error %v", tt.name, tt.valid, err)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aoi-protocol/aoi/internal/redact"
+	"github.com/aoi-protocol/aoi/pkg/aoi"
+)
+
+// RoleConfig defines an agent role. Roles named like a built-in role replace it.
+type RoleConfig struct {
+	Name string `json:"name"`
+	// DisplayName is shown to people, e.g. "Site Reliability Engineer"
+	DisplayName string `json:"display_name,omitempty"`
+	// Capabilities are advertised by every agent with the role, in addition to its own
+	Capabilities []string `json:"capabilities,omitempty"`
+	// Grants are ACL permissions given to every agent with the role
+	Grants []RoleGrantConfig `json:"grants,omitempty"`
+	// Handler is the secretary handler that answers queries ("pm", "engineer",
+	// "qa", "design" or "generic"); defaults to the role name if that is a
+	// handler, else "generic"
+	Handler string `json:"handler,omitempty"`
+	// SystemPrompt replaces the handler's default system prompt when queries
+	// are answered by a language model
+	SystemPrompt string `json:"system_prompt,omitempty"`
+}
+
+// RoleGrantConfig is a permission on a resource granted to a role
+type RoleGrantConfig struct {
+	Resource   string `json:"resource"`
+	Permission string `json:"permission"` // "read", "write", "admin"
+}
+
+// BuiltinRoles returns the roles available without configuration
+func BuiltinRoles() []RoleConfig {
+	return []RoleConfig{
+		{Name: string(aoi.RolePM), DisplayName: "Project Manager", Handler: "pm"},
+		{Name: string(aoi.RoleEngineer), DisplayName: "Engineer", Handler: "engineer"},
+		{Name: string(aoi.RoleQA), DisplayName: "QA", Handler: "qa"},
+		{Name: string(aoi.RoleDesign), DisplayName: "Designer", Handler: "design"},
+	}
+}
+
+// RoleSet returns the built-in roles overridden and extended by the configured
+// ones, by name. Empty display names and handlers are filled in.
+func (c *Config) RoleSet() map[string]RoleConfig {
+	roles := make(map[string]RoleConfig)
+	builtinHandlers := make(map[string]bool)
+	for _, role := range BuiltinRoles() {
+		roles[role.Name] = role
+		builtinHandlers[role.Handler] = true
+	}
+
+	for _, role := range c.Roles {
+		if role.DisplayName == "" {
+			role.DisplayName = role.Name
+		}
+		if role.Handler == "" {
+			role.Handler = "generic"
+			if builtin, ok := roles[role.Name]; ok {
+				role.Handler = builtin.Handler
+			} else if builtinHandlers[role.Name] {
+				role.Handler = role.Name
+			}
+		}
+		roles[role.Name] = role
+	}
+	return roles
+}
+
+// Role returns the definition of the named role
+func (c *Config) Role(name string) (RoleConfig, bool) {
+	role, ok := c.RoleSet()[name]
+	return role, ok
+}
+
+// RoleNames returns the names of all known roles, sorted
+func (c *Config) RoleNames() []string {
+	var names []string
+	for name := range c.RoleSet() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Validate checks the role definitions, the ACL rules, the LLM provider, the
+// focus rules, the delegation worker, the disclosure level, the redaction
+// detectors and that the agent's role is one of the roles
+func (c *Config) Validate() error {
+	seen := make(map[string]bool)
+	for i, role := range c.Roles {
+		if role.Name == "" {
+			return fmt.Errorf("roles[%d]: name is required", i)
+		}
+		if seen[role.Name] {
+			return fmt.Errorf("roles[%d]: duplicate role %q", i, role.Name)
+		}
+		seen[role.Name] = true
+
+		for j, grant := range role.Grants {
+			if grant.Resource == "" {
+				return fmt.Errorf("role %q grants[%d]: resource is required", role.Name, j)
+			}
+			switch grant.Permission {
+			case "read", "write", "admin":
+			default:
+				return fmt.Errorf("role %q grants[%d]: unknown permission %q", role.Name, j, grant.Permission)
+			}
+		}
+	}
+
+	for i, rule := range c.ACL.Rules {
+		if rule.AgentID == "" {
+			return fmt.Errorf("acl.rules[%d]: agent_id is required", i)
+		}
+		if role, ok := strings.CutPrefix(rule.AgentID, "role:"); ok {
+			if _, known := c.Role(role); !known {
+				return fmt.Errorf("acl.rules[%d]: unknown role %q", i, role)
+			}
+		}
+		if rule.Resource == "" {
+			return fmt.Errorf("acl.rules[%d]: resource is required", i)
+		}
+		switch rule.Permission {
+		case "none", "read", "write", "admin":
+		default:
+			return fmt.Errorf("acl.rules[%d]: unknown permission %q", i, rule.Permission)
+		}
+		switch rule.Effect {
+		case "", "allow", "deny":
+		default:
+			return fmt.Errorf("acl.rules[%d]: unknown effect %q", i, rule.Effect)
+		}
+	}
+
+	tokens := make(map[string]bool)
+	for i, token := range c.ACL.Tokens {
+		if token.AgentID == "" || token.Token == "" {
+			return fmt.Errorf("acl.tokens[%d]: agent_id and token are required", i)
+		}
+		if tokens[token.Token] {
+			return fmt.Errorf("acl.tokens[%d]: duplicate token", i)
+		}
+		tokens[token.Token] = true
+	}
+
+	switch c.LLM.Provider {
+	case "", "stub":
+	case "openai":
+		if c.LLM.BaseURL == "" || c.LLM.Model == "" {
+			return fmt.Errorf("llm: base_url and model are required for provider %q", c.LLM.Provider)
+		}
+	default:
+		return fmt.Errorf("llm.provider: unknown provider %q", c.LLM.Provider)
+	}
+
+	if c.Focus.MinConfidence < 0 || c.Focus.MinConfidence > 1 {
+		return fmt.Errorf("focus.min_confidence: %v is not between 0 and 1", c.Focus.MinConfidence)
+	}
+	for i, rule := range c.Focus.Rules {
+		switch rule.Action {
+		case "answer", "queue", "escalate":
+		default:
+			return fmt.Errorf("focus.rules[%d]: unknown action %q", i, rule.Action)
+		}
+		if rule.SenderRole != "" {
+			if _, known := c.Role(rule.SenderRole); !known {
+				return fmt.Errorf("focus.rules[%d]: unknown role %q", i, rule.SenderRole)
+			}
+		}
+	}
+
+	switch c.Delegation.Mode {
+	case "":
+	case "explicit", "fallback":
+		switch c.Delegation.Worker {
+		case "h2a":
+		case "mcp":
+			if c.Delegation.MCPServer == "" || c.Delegation.MCPTool == "" {
+				return fmt.Errorf("delegation: mcp_server and mcp_tool are required for worker %q", c.Delegation.Worker)
+			}
+		default:
+			return fmt.Errorf("delegation.worker: unknown worker %q", c.Delegation.Worker)
+		}
+	default:
+		return fmt.Errorf("delegation.mode: unknown mode %q", c.Delegation.Mode)
+	}
+
+	switch c.Disclosure.DefaultLevel {
+	case "", "full", "analysis", "boolean":
+	default:
+		return fmt.Errorf("disclosure.default_level: unknown level %q", c.Disclosure.DefaultLevel)
+	}
+
+	for i, name := range c.Redaction.Detectors {
+		if _, ok := redact.Builtin(name); !ok {
+			return fmt.Errorf("redaction.detectors[%d]: unknown detector %q (known: %v)", i, name, redact.BuiltinNames())
+		}
+	}
+	for i, pattern := range c.Redaction.Patterns {
+		if _, err := redact.NewDetector(pattern.Name, pattern.Pattern); err != nil {
+			return fmt.Errorf("redaction.patterns[%d]: %w", i, err)
+		}
+	}
+
+	if _, ok := c.Role(c.Agent.Role); !ok {
+		return fmt.Errorf("agent.role: unknown role %q (known: %v)", c.Agent.Role, c.RoleNames())
+	}
+	return nil
+}
@@ -1,17 +1,21 @@
 package context
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"strconv"
 	"time"
+
+	"github.com/aoi-protocol/aoi/internal/rpc"
 )
 
 // ContextAPI provides REST and JSON-RPC endpoints for context management
 type ContextAPI struct {
 	monitor *ContextMonitor
 	store   *ContextStore
+	filter  func(context.Context, *ContextHistory)
 }
 
 // NewContextAPI creates a new context API handler
@@ -22,6 +26,22 @@ func NewContextAPI(monitor *ContextMonitor, store *ContextStore) *ContextAPI {
 	}
 }
 
+// SetHistoryFilter sets a function that rewrites context history before it
+// is served to the caller in ctx, e.g. to redact secrets in entry contents
+func (api *ContextAPI) SetHistoryFilter(filter func(context.Context, *ContextHistory)) {
+	api.filter = filter
+}
+
+// queryHistory queries the store and applies the history filter
+func (api *ContextAPI) queryHistory(ctx context.Context, query ContextQuery) (*ContextHistory, error) {
+	history, err := api.store.Query(query)
+	if err != nil || api.filter == nil {
+		return history, err
+	}
+	api.filter(ctx, history)
+	return history, nil
+}
+
 // RegisterRoutes registers the context API routes with an HTTP mux
 func (api *ContextAPI) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("/api/v1/context", api.handleContext)
@@ -89,7 +109,7 @@ func (api *ContextAPI) handleContextHistory(w http.ResponseWriter, r *http.Reque
 		}
 	}
 
-	history, err := api.store.Query(query)
+	history, err := api.queryHistory(r.Context(), query)
 	if err != nil {
 		api.sendError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to query context: %v", err))
 		return
@@ -208,21 +228,63 @@ func (api *ContextAPI) sendError(w http.ResponseWriter, code int, message string
 	})
 }
 
-// HandleJSONRPC handles JSON-RPC method calls for context
-// This method is designed to be called from the main JSON-RPC handler
-func (api *ContextAPI) HandleJSONRPC(method string, params json.RawMessage) (any, error) {
-	switch method {
-	case "aoi.context":
-		return api.handleRPCContext(params)
-	case "aoi.context.history":
-		return api.handleRPCContextHistory(params)
-	case "aoi.context.watch":
-		return api.handleRPCContextWatch(params)
-	case "aoi.context.activity":
-		return api.handleRPCContextActivity(params)
-	default:
-		return nil, fmt.Errorf("unknown method: %s", method)
-	}
+// RegisterMethods registers the aoi.context.* methods
+func (api *ContextAPI) RegisterMethods(reg *rpc.Registry) {
+	reg.MustRegister(
+		rpc.Method{
+			Name:     "aoi.context",
+			Summary:  "Get the agent's context summary",
+			Result:   rpc.Result("summary", rpc.Type("object")),
+			Resource: "context/summary",
+			Action:   rpc.ActionRead,
+			Handler:  rpc.WithoutContext(api.handleRPCContext),
+		},
+		rpc.Method{
+			Name:    "aoi.context.history",
+			Summary: "Query recorded context entries",
+			Params: []rpc.ContentDescriptor{
+				rpc.Param("project", "string", "Filter by project"),
+				rpc.Param("file", "string", "Filter by file"),
+				rpc.Param("topic", "string", "Filter by topic"),
+				rpc.Param("type", "string", "Filter by entry type"),
+				rpc.Param("since", "string", "RFC 3339 lower bound"),
+				rpc.Param("until", "string", "RFC 3339 upper bound"),
+				rpc.Param("limit", "integer", "Page size"),
+				rpc.Param("offset", "integer", "Page offset"),
+			},
+			Result:   rpc.Result("history", rpc.Type("object")),
+			Resource: "context/history",
+			Action:   rpc.ActionRead,
+			Handler:  api.handleRPCContextHistory,
+		},
+		rpc.Method{
+			Name:    "aoi.context.watch",
+			Summary: "Add a directory to the file watcher",
+			Params: []rpc.ContentDescriptor{
+				rpc.RequiredParam("path", "string", "Directory to watch"),
+				rpc.Param("recursive", "boolean", "Watch subdirectories"),
+				rpc.ContentDescriptor{Name: "patterns", Description: "File patterns, e.g. *.go", Schema: rpc.ArrayOf(rpc.Type("string"))},
+				rpc.Param("ignore_hidden", "boolean", "Skip hidden files"),
+			},
+			Result:   rpc.Result("watch", rpc.Type("object")),
+			Resource: "context/watch",
+			Action:   rpc.ActionWrite,
+			Handler:  rpc.WithoutContext(api.handleRPCContextWatch),
+		},
+		rpc.Method{
+			Name:    "aoi.context.activity",
+			Summary: "Record an activity in the agent's context",
+			Params: []rpc.ContentDescriptor{
+				rpc.RequiredParam("type", "string", "Activity type"),
+				rpc.RequiredParam("description", "string", "What happened"),
+				rpc.Param("metadata", "object", "Additional metadata"),
+			},
+			Result:   rpc.Result("status", rpc.Type("object")),
+			Resource: "context/activity",
+			Action:   rpc.ActionWrite,
+			Handler:  rpc.WithoutContext(api.handleRPCContextActivity),
+		},
+	)
 }
 
 // handleRPCContext handles the aoi.context JSON-RPC method
@@ -232,7 +294,7 @@ func (api *ContextAPI) handleRPCContext(params json.RawMessage) (any, error) {
 }
 
 // handleRPCContextHistory handles the aoi.context.history JSON-RPC method
-func (api *ContextAPI) handleRPCContextHistory(params json.RawMessage) (any, error) {
+func (api *ContextAPI) handleRPCContextHistory(ctx context.Context, params json.RawMessage) (any, error) {
 	var query ContextQuery
 	if params != nil && len(params) > 0 {
 		if err := json.Unmarshal(params, &query); err != nil {
@@ -240,7 +302,7 @@ func (api *ContextAPI) handleRPCContextHistory(params json.RawMessage) (any, err
 		}
 	}
 
-	return api.store.Query(query)
+	return api.queryHistory(ctx, query)
 }
 
 // handleRPCContextWatch handles the aoi.context.watch JSON-RPC method
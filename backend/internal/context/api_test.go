@@ -2,12 +2,15 @@ package context
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"testing"
 	"time"
+
+	"github.com/aoi-protocol/aoi/internal/rpc"
 )
 
 func setupTestAPI() (*ContextAPI, *ContextMonitor, *ContextStore, func()) {
@@ -276,15 +279,22 @@ func TestContextAPI_HandleContextActivity_MissingDescription(t *testing.T) {
 	}
 }
 
-func TestContextAPI_HandleJSONRPC_Context(t *testing.T) {
+// callRPC calls a method registered by api
+func callRPC(api *ContextAPI, method string, params json.RawMessage) (any, error) {
+	reg := rpc.NewRegistry()
+	api.RegisterMethods(reg)
+	return reg.Call(context.Background(), method, params)
+}
+
+func TestContextAPI_RPCContext(t *testing.T) {
 	api, monitor, _, cleanup := setupTestAPI()
 	defer cleanup()
 
 	monitor.SetActiveProject("rpc-project")
 
-	result, err := api.HandleJSONRPC("aoi.context", nil)
+	result, err := callRPC(api, "aoi.context", nil)
 	if err != nil {
-		t.Fatalf("HandleJSONRPC failed: %v", err)
+		t.Fatalf("aoi.context failed: %v", err)
 	}
 
 	summary, ok := result.(*ContextSummary)
@@ -297,16 +307,16 @@ func TestContextAPI_HandleJSONRPC_Context(t *testing.T) {
 	}
 }
 
-func TestContextAPI_HandleJSONRPC_History(t *testing.T) {
+func TestContextAPI_RPCHistory(t *testing.T) {
 	api, _, store, cleanup := setupTestAPI()
 	defer cleanup()
 
 	store.Store(&ContextEntry{Type: ContextTypeFile, Project: "p1"})
 
 	params, _ := json.Marshal(ContextQuery{Project: "p1"})
-	result, err := api.HandleJSONRPC("aoi.context.history", params)
+	result, err := callRPC(api, "aoi.context.history", params)
 	if err != nil {
-		t.Fatalf("HandleJSONRPC failed: %v", err)
+		t.Fatalf("aoi.context.history failed: %v", err)
 	}
 
 	history, ok := result.(*ContextHistory)
@@ -319,11 +329,11 @@ func TestContextAPI_HandleJSONRPC_History(t *testing.T) {
 	}
 }
 
-func TestContextAPI_HandleJSONRPC_UnknownMethod(t *testing.T) {
+func TestContextAPI_RPCUnknownMethod(t *testing.T) {
 	api, _, _, cleanup := setupTestAPI()
 	defer cleanup()
 
-	_, err := api.HandleJSONRPC("aoi.unknown", nil)
+	_, err := callRPC(api, "aoi.unknown", nil)
 	if err == nil {
 		t.Error("Expected error for unknown method")
 	}
@@ -25,6 +25,7 @@ type ContextMonitor struct {
 	stopChan      chan struct{}
 	eventChan     chan FileChangeEvent
 	running       bool
+	onChange      []func(FileChangeEvent)
 }
 
 // WatchConfig holds configuration for a watched directory
@@ -55,6 +56,14 @@ func (cm *ContextMonitor) SetPollInterval(interval time.Duration) {
 	cm.pollInterval = interval
 }
 
+// OnChange registers a callback invoked for every recorded file change,
+// e.g. to detect bursts of activity
+func (cm *ContextMonitor) OnChange(fn func(FileChangeEvent)) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	cm.onChange = append(cm.onChange, fn)
+}
+
 // Start begins monitoring watched directories
 func (cm *ContextMonitor) Start() error {
 	cm.mu.Lock()
@@ -376,6 +385,13 @@ func (cm *ContextMonitor) handleFileChange(event FileChangeEvent) {
 	} else {
 		log.Printf("[ContextMonitor] Recorded file change: %s (%s)", event.Path, event.Operation)
 	}
+
+	cm.mu.RLock()
+	callbacks := cm.onChange
+	cm.mu.RUnlock()
+	for _, fn := range callbacks {
+		fn(event)
+	}
 }
 
 // matchesPatterns checks if a filename matches any of the given patterns
@@ -0,0 +1,204 @@
+// Package disclosure restricts what an answer reveals, so that source code
+// need not leave the machine: a requester sees full text, an analysis with
+// code and file contents removed, or only yes or no.
+package disclosure
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aoi-protocol/aoi/internal/llm"
+)
+
+// Level is how much of an answer a requester may see
+type Level int
+
+const (
+	// Boolean answers only yes, no or unknown
+	Boolean Level = iota
+	// Analysis answers in prose, without code blocks or file contents
+	Analysis
+	// Full answers with everything
+	Full
+)
+
+// ACL resources whose read permission lets a requester see more than the
+// default level
+const (
+	ResourceFull     = "disclosure/full"
+	ResourceAnalysis = "disclosure/analysis"
+)
+
+// Answers at the Boolean level
+const (
+	Yes     = "yes"
+	No      = "no"
+	Unknown = "unknown"
+)
+
+// Placeholders for what was removed
+const (
+	CodeRemoved     = "[code removed]"
+	ContentWithheld = "[content withheld]"
+)
+
+var levelNames = map[Level]string{Boolean: "boolean", Analysis: "analysis", Full: "full"}
+
+// String returns the level's name
+func (l Level) String() string {
+	if name, ok := levelNames[l]; ok {
+		return name
+	}
+	return fmt.Sprintf("level(%d)", int(l))
+}
+
+// ParseLevel parses "boolean", "analysis" or "full"
+func ParseLevel(name string) (Level, error) {
+	for level, n := range levelNames {
+		if n == name {
+			return level, nil
+		}
+	}
+	return Boolean, fmt.Errorf("unknown disclosure level %q", name)
+}
+
+// Report describes what a restriction removed, for the audit log
+type Report struct {
+	Level Level
+	// Changed is whether the output differs from the full one
+	Changed bool
+	// CodeBlocks and CodeLines count the fenced blocks and the code-like
+	// lines outside them that were removed
+	CodeBlocks int
+	CodeLines  int
+	// Withheld counts resource contents and non-text blocks left out
+	Withheld int
+}
+
+// Details returns the report as audit entry details
+func (r Report) Details() map[string]interface{} {
+	return map[string]interface{}{
+		"level":       r.Level.String(),
+		"changed":     r.Changed,
+		"code_blocks": r.CodeBlocks,
+		"code_lines":  r.CodeLines,
+		"withheld":    r.Withheld,
+	}
+}
+
+// Classifier picks the label that best describes a text; llm.Provider is one
+type Classifier interface {
+	Classify(ctx context.Context, text string, labels []string) (*llm.Classification, error)
+}
+
+// Restrict reduces the answer to question to what level allows. The
+// classifier, when not nil, decides yes or no at the Boolean level.
+func Restrict(ctx context.Context, level Level, c Classifier, question, answer string) (string, Report) {
+	switch level {
+	case Full:
+		return answer, Report{Level: Full}
+	case Analysis:
+		return StripCode(answer)
+	}
+	verdict := ToBoolean(ctx, c, question, answer)
+	return verdict, Report{Level: Boolean, Changed: verdict != answer}
+}
+
+// StripCode removes fenced code blocks and runs of code-like lines from text,
+// leaving a placeholder for each
+func StripCode(text string) (string, Report) {
+	report := Report{Level: Analysis}
+	var out []string
+	fence := ""
+	placeholder := func() {
+		if len(out) == 0 || out[len(out)-1] != CodeRemoved {
+			out = append(out, CodeRemoved)
+		}
+	}
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case fence != "":
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+		case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+			fence = trimmed[:3]
+			report.CodeBlocks++
+			placeholder()
+		case looksLikeCode(trimmed):
+			report.CodeLines++
+			placeholder()
+		default:
+			out = append(out, line)
+		}
+	}
+	report.Changed = report.CodeBlocks > 0 || report.CodeLines > 0
+	if !report.Changed {
+		return text, report
+	}
+	return strings.Join(out, "\n"), report
+}
+
+// codePrefixes start lines of source code in common languages
+var codePrefixes = []string{
+	"func ", "package ", "import ", "#include", "#!/", "def ", "class ", "return ", "const ", "var ",
+	"let ", "fn ", "pub ", "public ", "private ", "protected ", "static ", "if (", "for (", "while (",
+	"} else", "//", "/*", "SELECT ", "INSERT ", "UPDATE ", "DELETE ",
+}
+
+// looksLikeCode reports whether a trimmed line is probably source code
+// rather than prose
+func looksLikeCode(line string) bool {
+	if line == "" {
+		return false
+	}
+	for _, suffix := range []string{"{", "}", ";", "};", ");", "})", "=>"} {
+		if strings.HasSuffix(line, suffix) {
+			return true
+		}
+	}
+	for _, prefix := range codePrefixes {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// negations suggest a "no" in an answer that does not start with yes or no
+var negations = []string{"not ", "n't", "no ", "fail", "error", "broken", "missing", "unresolved", "open issue"}
+
+// ToBoolean reduces an answer to question to Yes, No or Unknown. An answer
+// that starts with yes or no decides; otherwise the classifier does, or
+// else the answer counts as No if it contains a negation. Anything else is
+// Unknown rather than a guessed Yes.
+func ToBoolean(ctx context.Context, c Classifier, question, answer string) string {
+	lower := strings.ToLower(strings.TrimSpace(answer))
+	if lower == "" || strings.HasPrefix(lower, "no context found") {
+		return Unknown
+	}
+	first := strings.TrimFunc(strings.Fields(lower)[0], func(r rune) bool {
+		return r < 'a' || r > 'z'
+	})
+	switch first {
+	case "yes", "true", "correct":
+		return Yes
+	case "no", "false":
+		return No
+	}
+
+	if c != nil {
+		text := "Question: " + question + "\nAnswer: " + answer
+		if label, err := c.Classify(ctx, text, []string{Yes, No, Unknown}); err == nil && label.Label != "" {
+			return label.Label
+		}
+	}
+	for _, negation := range negations {
+		if strings.Contains(lower, negation) {
+			return No
+		}
+	}
+	return Unknown
+}
@@ -0,0 +1,90 @@
+package disclosure
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aoi-protocol/aoi/internal/llm"
+)
+
+func TestParseLevel(t *testing.T) {
+	for _, level := range []Level{Boolean, Analysis, Full} {
+		parsed, err := ParseLevel(level.String())
+		if err != nil || parsed != level {
+			t.Errorf("ParseLevel(%q) = %v, %v", level, parsed, err)
+		}
+	}
+	if _, err := ParseLevel("summary"); err == nil {
+		t.Error("Expected an unknown level to be rejected")
+	}
+}
+
+func TestStripCode(t *testing.T) {
+	tests := []struct {
+		name   string
+		text   string
+		want   string
+		blocks int
+		lines  int
+	}{
+		{"prose", "The handler validates input.\nSee docs/api.md.", "The handler validates input.\nSee docs/api.md.", 0, 0},
+		{"fenced", "It does:\n```go\nif err != nil {\n\treturn err\n}\n```\nDone.", "It does:\n" + CodeRemoved + "\nDone.", 1, 0},
+		{"tilde fence", "~~~\nSELECT * FROM users\n~~~", CodeRemoved, 1, 0},
+		{"unclosed fence", "It does:\n```\nsecret()", "It does:\n" + CodeRemoved, 1, 0},
+		{"bare code", "Found it:\nfunc validate(r *Request) error {\n\treturn nil\n}\nThat is all.",
+			"Found it:\n" + CodeRemoved + "\nThat is all.", 0, 3},
+	}
+	for _, tt := range tests {
+		got, report := StripCode(tt.text)
+		if got != tt.want || report.CodeBlocks != tt.blocks || report.CodeLines != tt.lines {
+			t.Errorf("%s: got %q with %+v", tt.name, got, report)
+		}
+		if report.Changed != (tt.blocks+tt.lines > 0) {
+			t.Errorf("%s: changed = %v", tt.name, report.Changed)
+		}
+	}
+}
+
+func TestToBoolean(t *testing.T) {
+	tests := []struct {
+		answer string
+		want   string
+	}{
+		{"Yes, the API spec is up to date.", Yes},
+		{"No. The migration is still running.", No},
+		{"No context found for \"roadmap\".", Unknown},
+		{"", Unknown},
+		{"The tests pass on main.", Unknown},
+		{"It depends on the deployment window.", Unknown},
+		{"The build is broken since Monday.", No},
+		{"It hasn't been reviewed.", No},
+	}
+	for _, tt := range tests {
+		if got := ToBoolean(context.Background(), nil, "Is it done?", tt.answer); got != tt.want {
+			t.Errorf("ToBoolean(%q) = %q, want %q", tt.answer, got, tt.want)
+		}
+	}
+}
+
+func TestToBoolean_Classifier(t *testing.T) {
+	stub := llm.NewStubProvider()
+	// The stub picks the first label in the text, here "no" in the answer
+	got := ToBoolean(context.Background(), stub, "Is it done?", "Deployment: no rollout yet")
+	if got != No {
+		t.Errorf("Expected the classifier's label, got %q", got)
+	}
+}
+
+func TestRestrict(t *testing.T) {
+	answer := "Yes.\n```\nrm -rf /tmp/cache\n```"
+	if got, report := Restrict(context.Background(), Full, nil, "q", answer); got != answer || report.Changed {
+		t.Errorf("Expected full answers unchanged, got %q", got)
+	}
+	if got, report := Restrict(context.Background(), Analysis, nil, "q", answer); strings.Contains(got, "rm -rf") || report.CodeBlocks != 1 {
+		t.Errorf("Expected the code block removed, got %q", got)
+	}
+	if got, report := Restrict(context.Background(), Boolean, nil, "q", answer); got != Yes || !report.Changed {
+		t.Errorf("Expected yes, got %q with %+v", got, report)
+	}
+}
@@ -0,0 +1,515 @@
+// Package focus implements interrupt control: while a human is focused, the
+// secretary decides per query whether to answer on their behalf, queue the
+// query for a digest delivered when focus ends, or escalate it at once.
+package focus
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aoi-protocol/aoi/internal/rpc"
+	"github.com/google/uuid"
+)
+
+// Action is what the secretary does with a query
+type Action string
+
+const (
+	// ActionAnswer answers the query from context on the human's behalf
+	ActionAnswer Action = "answer"
+	// ActionQueue buffers the query for the human's digest
+	ActionQueue Action = "queue"
+	// ActionEscalate interrupts the human at once
+	ActionEscalate Action = "escalate"
+)
+
+// Sources of a focus state
+const (
+	SourceManual = "manual"
+	SourceAuto   = "auto"
+)
+
+// Defaults for automatic focus detection and auto-answers
+const (
+	DefaultBurstWindow    = 5 * time.Minute
+	DefaultBurstThreshold = 10
+	DefaultIdleTimeout    = 15 * time.Minute
+	DefaultMinConfidence  = 0.5
+	DefaultCheckInterval  = 30 * time.Second
+)
+
+// Rule decides the action for the queries it matches while the human is
+// focused. Empty fields match anything.
+type Rule struct {
+	SenderRole string `json:"sender_role,omitempty"`
+	Priority   string `json:"priority,omitempty"`
+	// Topic matches queries whose text or context scope mention it
+	Topic  string `json:"topic,omitempty"`
+	Action Action `json:"action"`
+}
+
+func (r Rule) matches(q Query) bool {
+	if r.SenderRole != "" && !strings.EqualFold(r.SenderRole, q.SenderRole) {
+		return false
+	}
+	if r.Priority != "" && !strings.EqualFold(r.Priority, q.Priority) {
+		return false
+	}
+	if r.Topic != "" {
+		text := strings.ToLower(q.Query + " " + q.ContextScope)
+		if !strings.Contains(text, strings.ToLower(r.Topic)) {
+			return false
+		}
+	}
+	return true
+}
+
+// DefaultRules escalate urgent and critical queries; they apply after the
+// configured rules
+func DefaultRules() []Rule {
+	return []Rule{
+		{Priority: "critical", Action: ActionEscalate},
+		{Priority: "urgent", Action: ActionEscalate},
+	}
+}
+
+// Query is what the policy knows about an incoming query
+type Query struct {
+	FromAgent string `json:"from_agent"`
+	// SenderRole is the role the sender is registered with, if known
+	SenderRole   string `json:"sender_role,omitempty"`
+	Priority     string `json:"priority,omitempty"`
+	Query        string `json:"query"`
+	ContextScope string `json:"context_scope,omitempty"`
+}
+
+// Decision is the action chosen for a query and why
+type Decision struct {
+	Action  Action `json:"action"`
+	Focused bool   `json:"focused"`
+	// Rule is the index of the deciding rule among Rules(), or -1
+	Rule   int    `json:"rule"`
+	Reason string `json:"reason"`
+}
+
+// QueuedQuery is a query buffered for the human
+type QueuedQuery struct {
+	ID         string    `json:"id"`
+	Query      Query     `json:"query"`
+	ReceivedAt time.Time `json:"received_at"`
+	Reason     string    `json:"reason"`
+}
+
+// Digest is what was queued while the human was focused
+type Digest struct {
+	AgentID      string        `json:"agent_id"`
+	FocusedSince time.Time     `json:"focused_since"`
+	EndedAt      time.Time     `json:"ended_at"`
+	Queries      []QueuedQuery `json:"queries"`
+}
+
+// State is an agent's focus state
+type State struct {
+	AgentID string `json:"agent_id"`
+	Focused bool   `json:"focused"`
+	// Source is SourceManual or SourceAuto while focused
+	Source string    `json:"source,omitempty"`
+	Since  time.Time `json:"since,omitempty"`
+	// Until is when focus ends by itself: the end of a manual focus period,
+	// or when automatic focus times out without activity
+	Until  time.Time `json:"until,omitempty"`
+	Queued int       `json:"queued"`
+}
+
+// Options configure focus detection and auto-answers
+type Options struct {
+	// AutoDetect turns focus on when BurstThreshold activity events happen
+	// within BurstWindow; it ends after IdleTimeout without activity
+	AutoDetect     bool
+	BurstWindow    time.Duration
+	BurstThreshold int
+	IdleTimeout    time.Duration
+	// MinConfidence is the confidence an auto-answer needs while focused;
+	// queries answered with less are queued instead
+	MinConfidence float64
+}
+
+// DefaultOptions returns the default options, without automatic detection
+func DefaultOptions() Options {
+	return Options{
+		BurstWindow:    DefaultBurstWindow,
+		BurstThreshold: DefaultBurstThreshold,
+		IdleTimeout:    DefaultIdleTimeout,
+		MinConfidence:  DefaultMinConfidence,
+	}
+}
+
+type agentState struct {
+	focused  bool
+	source   string
+	since    time.Time
+	until    time.Time
+	activity []time.Time
+	queue    []QueuedQuery
+}
+
+// Manager tracks the focus state of agents and queues their queries
+type Manager struct {
+	mu         sync.Mutex
+	opts       Options
+	rules      []Rule
+	localAgent string
+	agents     map[string]*agentState
+	onDigest   []func(Digest)
+	onEscalate []func(agentID string, q QueuedQuery)
+	now        func() time.Time
+	stopOnce   sync.Once
+	stop       chan struct{}
+}
+
+// NewManager creates a manager applying rules, then DefaultRules. Zero
+// options take their defaults.
+func NewManager(opts Options, rules []Rule) *Manager {
+	m := &Manager{
+		agents: make(map[string]*agentState),
+		now:    time.Now,
+		stop:   make(chan struct{}),
+	}
+	m.Configure(opts, rules)
+	return m
+}
+
+// Configure replaces the options and rules, e.g. with those from config
+func (m *Manager) Configure(opts Options, rules []Rule) {
+	defaults := DefaultOptions()
+	if opts.BurstWindow <= 0 {
+		opts.BurstWindow = defaults.BurstWindow
+	}
+	if opts.BurstThreshold <= 0 {
+		opts.BurstThreshold = defaults.BurstThreshold
+	}
+	if opts.IdleTimeout <= 0 {
+		opts.IdleTimeout = defaults.IdleTimeout
+	}
+	if opts.MinConfidence <= 0 {
+		opts.MinConfidence = defaults.MinConfidence
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.opts = opts
+	m.rules = append(append([]Rule(nil), rules...), DefaultRules()...)
+}
+
+// SetLocalAgent sets the agent that focus methods default to
+func (m *Manager) SetLocalAgent(agentID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.localAgent = agentID
+}
+
+// LocalAgent returns the agent that focus methods default to
+func (m *Manager) LocalAgent() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.localAgent
+}
+
+// Rules returns the rules in the order they are applied
+func (m *Manager) Rules() []Rule {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Rule(nil), m.rules...)
+}
+
+// MinConfidence is the confidence an auto-answer needs while focused
+func (m *Manager) MinConfidence() float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.opts.MinConfidence
+}
+
+// OnDigest registers a callback invoked with the queued queries when an
+// agent's focus ends
+func (m *Manager) OnDigest(fn func(Digest)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onDigest = append(m.onDigest, fn)
+}
+
+// OnEscalate registers a callback invoked for every escalated query
+func (m *Manager) OnEscalate(fn func(agentID string, q QueuedQuery)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onEscalate = append(m.onEscalate, fn)
+}
+
+func (m *Manager) agent(agentID string) *agentState {
+	st, ok := m.agents[agentID]
+	if !ok {
+		st = &agentState{}
+		m.agents[agentID] = st
+	}
+	return st
+}
+
+func (st *agentState) state(agentID string) State {
+	s := State{AgentID: agentID, Focused: st.focused, Queued: len(st.queue)}
+	if st.focused {
+		s.Source, s.Since, s.Until = st.source, st.since, st.until
+	}
+	return s
+}
+
+// end ends an agent's focus and returns its digest. The caller holds m.mu.
+func (m *Manager) end(agentID string, st *agentState, at time.Time) Digest {
+	digest := Digest{AgentID: agentID, FocusedSince: st.since, EndedAt: at, Queries: st.queue}
+	if digest.Queries == nil {
+		digest.Queries = []QueuedQuery{}
+	}
+	st.focused, st.source, st.since, st.until, st.queue = false, "", time.Time{}, time.Time{}, nil
+	return digest
+}
+
+// deliver runs the digest callbacks outside the lock
+func (m *Manager) deliver(digests []Digest) {
+	if len(digests) == 0 {
+		return
+	}
+	m.mu.Lock()
+	callbacks := m.onDigest
+	m.mu.Unlock()
+	for _, digest := range digests {
+		for _, fn := range callbacks {
+			fn(digest)
+		}
+	}
+}
+
+// Set turns an agent's focus on or off by hand. A positive duration ends the
+// focus by itself after that long. Turning focus off delivers the digest.
+func (m *Manager) Set(agentID string, focused bool, duration time.Duration) State {
+	now := m.now()
+	m.mu.Lock()
+	st := m.agent(agentID)
+	var digests []Digest
+	if focused {
+		if !st.focused {
+			st.since = now
+		}
+		st.focused, st.source, st.until = true, SourceManual, time.Time{}
+		if duration > 0 {
+			st.until = now.Add(duration)
+		}
+	} else if st.focused {
+		digests = append(digests, m.end(agentID, st, now))
+	}
+	// Activity before the change does not count toward the next burst
+	st.activity = nil
+	state := st.state(agentID)
+	m.mu.Unlock()
+
+	m.deliver(digests)
+	return state
+}
+
+// Get returns an agent's focus state
+func (m *Manager) Get(agentID string) State {
+	m.Tick(m.now())
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.agent(agentID).state(agentID)
+}
+
+// RecordActivity notes activity of an agent's human, such as a file change.
+// With automatic detection a burst of activity turns focus on, and further
+// activity keeps it on.
+func (m *Manager) RecordActivity(agentID string, at time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.opts.AutoDetect {
+		return
+	}
+
+	st := m.agent(agentID)
+	cutoff := at.Add(-m.opts.BurstWindow)
+	kept := st.activity[:0]
+	for _, t := range st.activity {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	st.activity = append(kept, at)
+
+	switch {
+	case st.focused && st.source == SourceAuto:
+		st.until = at.Add(m.opts.IdleTimeout)
+	case !st.focused && len(st.activity) >= m.opts.BurstThreshold:
+		st.focused, st.source, st.since, st.until = true, SourceAuto, at, at.Add(m.opts.IdleTimeout)
+	}
+}
+
+// Tick ends the focus periods that are over at now and delivers their digests
+func (m *Manager) Tick(now time.Time) {
+	var digests []Digest
+	m.mu.Lock()
+	for agentID, st := range m.agents {
+		if st.focused && !st.until.IsZero() && !now.Before(st.until) {
+			digests = append(digests, m.end(agentID, st, now))
+		}
+	}
+	m.mu.Unlock()
+
+	sort.Slice(digests, func(i, j int) bool { return digests[i].AgentID < digests[j].AgentID })
+	m.deliver(digests)
+}
+
+// Start ends expired focus periods every interval until Close
+func (m *Manager) Start(interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultCheckInterval
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				m.Tick(now)
+			case <-m.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops the expiry checks
+func (m *Manager) Close() {
+	m.stopOnce.Do(func() { close(m.stop) })
+}
+
+// Decide chooses the action for a query to agentID. Queries are answered
+// while the human is not focused; while focused the first matching rule
+// decides, and queries no rule matches are answered from context.
+func (m *Manager) Decide(agentID string, q Query) Decision {
+	state := m.Get(agentID)
+	if !state.Focused {
+		return Decision{Action: ActionAnswer, Rule: -1, Reason: "not focused"}
+	}
+	for i, rule := range m.Rules() {
+		if rule.matches(q) {
+			return Decision{Action: rule.Action, Focused: true, Rule: i, Reason: "matched " + describeRule(rule)}
+		}
+	}
+	return Decision{Action: ActionAnswer, Focused: true, Rule: -1, Reason: "no rule matched"}
+}
+
+func describeRule(r Rule) string {
+	var parts []string
+	if r.SenderRole != "" {
+		parts = append(parts, "sender_role="+r.SenderRole)
+	}
+	if r.Priority != "" {
+		parts = append(parts, "priority="+r.Priority)
+	}
+	if r.Topic != "" {
+		parts = append(parts, "topic="+r.Topic)
+	}
+	if len(parts) == 0 {
+		return "catch-all rule"
+	}
+	return "rule " + strings.Join(parts, " ")
+}
+
+// Enqueue buffers a query for agentID's digest
+func (m *Manager) Enqueue(agentID string, q Query, reason string) QueuedQuery {
+	queued := QueuedQuery{ID: uuid.New().String(), Query: q, ReceivedAt: m.now(), Reason: reason}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	st := m.agent(agentID)
+	st.queue = append(st.queue, queued)
+	return queued
+}
+
+// Escalate alerts agentID's human to a query at once
+func (m *Manager) Escalate(agentID string, q Query, reason string) QueuedQuery {
+	escalated := QueuedQuery{ID: uuid.New().String(), Query: q, ReceivedAt: m.now(), Reason: reason}
+	m.mu.Lock()
+	callbacks := m.onEscalate
+	m.mu.Unlock()
+	for _, fn := range callbacks {
+		fn(agentID, escalated)
+	}
+	return escalated
+}
+
+// Pending returns the queries queued for agentID so far
+func (m *Manager) Pending(agentID string) []QueuedQuery {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]QueuedQuery{}, m.agent(agentID).queue...)
+}
+
+// RegisterMethods registers the aoi.focus.* methods, which act on the local agent
+func (m *Manager) RegisterMethods(reg *rpc.Registry) {
+	state := rpc.Result("state", rpc.Type("object"))
+	reg.MustRegister(
+		rpc.Method{
+			Name:     "aoi.focus.get",
+			Summary:  "Report whether this agent's human is focused",
+			Result:   state,
+			Resource: "focus/get",
+			Action:   rpc.ActionRead,
+			Handler: rpc.WithoutContext(func(json.RawMessage) (interface{}, error) {
+				return m.Get(m.LocalAgent()), nil
+			}),
+		},
+		rpc.Method{
+			Name:    "aoi.focus.set",
+			Summary: "Turn focus on or off; turning it off delivers the digest of queued queries",
+			Params: []rpc.ContentDescriptor{
+				rpc.RequiredParam("focused", "boolean", "Whether the human is focused"),
+				rpc.Param("duration", "string", "End focus by itself after this long, e.g. \"90m\""),
+			},
+			Result:   state,
+			Resource: "focus/set",
+			Action:   rpc.ActionWrite,
+			Handler:  rpc.WithoutContext(m.handleSet),
+		},
+		rpc.Method{
+			Name:     "aoi.focus.digest",
+			Summary:  "List the queries queued for the human so far",
+			Result:   rpc.Result("queries", rpc.ArrayOf(rpc.Type("object"))),
+			Resource: "focus/digest",
+			Action:   rpc.ActionRead,
+			Handler: rpc.WithoutContext(func(json.RawMessage) (interface{}, error) {
+				return m.Pending(m.LocalAgent()), nil
+			}),
+		},
+	)
+}
+
+func (m *Manager) handleSet(params json.RawMessage) (interface{}, error) {
+	var p struct {
+		Focused  *bool  `json:"focused"`
+		Duration string `json:"duration"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, rpc.InvalidParams(err.Error())
+	}
+	if p.Focused == nil {
+		return nil, rpc.InvalidParams("focused is required")
+	}
+	var duration time.Duration
+	if p.Duration != "" {
+		d, err := time.ParseDuration(p.Duration)
+		if err != nil || d <= 0 {
+			return nil, rpc.InvalidParams("duration must be a positive duration such as \"90m\"")
+		}
+		duration = d
+	}
+	return m.Set(m.LocalAgent(), *p.Focused, duration), nil
+}
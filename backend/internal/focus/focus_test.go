@@ -0,0 +1,223 @@
+package focus
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/aoi-protocol/aoi/internal/rpc"
+)
+
+// newTestManager returns a manager whose clock is moved by hand
+func newTestManager(opts Options, rules []Rule) (*Manager, *time.Time) {
+	now := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+	m := NewManager(opts, rules)
+	m.now = func() time.Time { return now }
+	return m, &now
+}
+
+func TestManager_SetAndDigest(t *testing.T) {
+	m, now := newTestManager(Options{}, nil)
+	var digests []Digest
+	m.OnDigest(func(d Digest) { digests = append(digests, d) })
+
+	state := m.Set("eng-agent", true, 0)
+	if !state.Focused || state.Source != SourceManual || !state.Until.IsZero() {
+		t.Fatalf("Expected open-ended manual focus, got %+v", state)
+	}
+	m.Enqueue("eng-agent", Query{FromAgent: "pm-agent", Query: "Is the API done?"}, "matched catch-all rule")
+	if got := m.Get("eng-agent").Queued; got != 1 {
+		t.Errorf("Expected 1 queued query, got %d", got)
+	}
+
+	*now = now.Add(time.Hour)
+	if state := m.Set("eng-agent", false, 0); state.Focused || state.Queued != 0 {
+		t.Errorf("Expected focus off with an empty queue, got %+v", state)
+	}
+	if len(digests) != 1 {
+		t.Fatalf("Expected 1 digest, got %d", len(digests))
+	}
+	d := digests[0]
+	if d.AgentID != "eng-agent" || len(d.Queries) != 1 || d.Queries[0].Query.FromAgent != "pm-agent" {
+		t.Errorf("Unexpected digest %+v", d)
+	}
+	if d.EndedAt.Sub(d.FocusedSince) != time.Hour {
+		t.Errorf("Expected the digest to cover an hour, got %s", d.EndedAt.Sub(d.FocusedSince))
+	}
+
+	// Turning focus off again delivers nothing
+	m.Set("eng-agent", false, 0)
+	if len(digests) != 1 {
+		t.Errorf("Expected no second digest, got %d", len(digests))
+	}
+}
+
+func TestManager_SetDurationExpires(t *testing.T) {
+	m, now := newTestManager(Options{}, nil)
+	var digests []Digest
+	m.OnDigest(func(d Digest) { digests = append(digests, d) })
+
+	m.Set("eng-agent", true, 30*time.Minute)
+	*now = now.Add(29 * time.Minute)
+	if !m.Get("eng-agent").Focused {
+		t.Fatal("Expected focus to last 30 minutes")
+	}
+	*now = now.Add(time.Minute)
+	if m.Get("eng-agent").Focused {
+		t.Error("Expected focus to end after 30 minutes")
+	}
+	if len(digests) != 1 {
+		t.Errorf("Expected a digest when focus expires, got %d", len(digests))
+	}
+}
+
+func TestManager_AutoDetect(t *testing.T) {
+	m, now := newTestManager(Options{AutoDetect: true, BurstWindow: time.Minute, BurstThreshold: 3, IdleTimeout: 10 * time.Minute}, nil)
+	var digests []Digest
+	m.OnDigest(func(d Digest) { digests = append(digests, d) })
+
+	// Activity spread wider than the window is not a burst
+	for i := 0; i < 3; i++ {
+		m.RecordActivity("eng-agent", *now)
+		*now = now.Add(2 * time.Minute)
+	}
+	if m.Get("eng-agent").Focused {
+		t.Fatal("Expected sparse activity not to turn focus on")
+	}
+
+	for i := 0; i < 3; i++ {
+		m.RecordActivity("eng-agent", *now)
+		*now = now.Add(10 * time.Second)
+	}
+	state := m.Get("eng-agent")
+	if !state.Focused || state.Source != SourceAuto {
+		t.Fatalf("Expected a burst to turn focus on automatically, got %+v", state)
+	}
+
+	// Further activity keeps focus on past the idle timeout
+	*now = now.Add(8 * time.Minute)
+	m.RecordActivity("eng-agent", *now)
+	*now = now.Add(8 * time.Minute)
+	if !m.Get("eng-agent").Focused {
+		t.Fatal("Expected activity to extend automatic focus")
+	}
+	*now = now.Add(2 * time.Minute)
+	if m.Get("eng-agent").Focused {
+		t.Error("Expected automatic focus to end after the idle timeout")
+	}
+	if len(digests) != 1 {
+		t.Errorf("Expected a digest when automatic focus ends, got %d", len(digests))
+	}
+}
+
+func TestManager_AutoDetectOff(t *testing.T) {
+	m, now := newTestManager(Options{BurstThreshold: 2}, nil)
+	for i := 0; i < 5; i++ {
+		m.RecordActivity("eng-agent", *now)
+	}
+	if m.Get("eng-agent").Focused {
+		t.Error("Expected no automatic focus without auto detection")
+	}
+}
+
+func TestManager_Decide(t *testing.T) {
+	m, _ := newTestManager(Options{}, []Rule{
+		{SenderRole: "pm", Action: ActionAnswer},
+		{Topic: "outage", Action: ActionEscalate},
+		{Action: ActionQueue},
+	})
+
+	if d := m.Decide("eng-agent", Query{Query: "anything", Priority: "urgent"}); d.Action != ActionAnswer || d.Focused {
+		t.Errorf("Expected queries to be answered while not focused, got %+v", d)
+	}
+
+	m.Set("eng-agent", true, 0)
+	tests := []struct {
+		name  string
+		query Query
+		want  Action
+		rule  int
+	}{
+		{"sender role", Query{SenderRole: "PM", Query: "How is the sprint going?"}, ActionAnswer, 0},
+		{"topic in text", Query{SenderRole: "qa", Query: "Is the outage fixed?"}, ActionEscalate, 1},
+		{"topic in scope", Query{Query: "Any news?", ContextScope: "topic:outage"}, ActionEscalate, 1},
+		{"catch-all", Query{SenderRole: "qa", Query: "Which tests are flaky?", Priority: "urgent"}, ActionQueue, 2},
+	}
+	for _, tt := range tests {
+		d := m.Decide("eng-agent", tt.query)
+		if d.Action != tt.want || d.Rule != tt.rule || !d.Focused {
+			t.Errorf("%s: expected %s by rule %d, got %+v", tt.name, tt.want, tt.rule, d)
+		}
+	}
+}
+
+func TestManager_DefaultRules(t *testing.T) {
+	m, _ := newTestManager(Options{}, nil)
+	var escalated []QueuedQuery
+	m.OnEscalate(func(agentID string, q QueuedQuery) { escalated = append(escalated, q) })
+	m.Set("eng-agent", true, 0)
+
+	if d := m.Decide("eng-agent", Query{Query: "Prod is down", Priority: "critical"}); d.Action != ActionEscalate {
+		t.Errorf("Expected critical queries to be escalated, got %+v", d)
+	}
+	if d := m.Decide("eng-agent", Query{Query: "Hello", Priority: "normal"}); d.Action != ActionAnswer || d.Rule != -1 {
+		t.Errorf("Expected unmatched queries to be answered, got %+v", d)
+	}
+
+	q := m.Escalate("eng-agent", Query{Query: "Prod is down"}, "matched rule priority=critical")
+	if len(escalated) != 1 || escalated[0].ID != q.ID {
+		t.Errorf("Expected the escalation callback to run, got %+v", escalated)
+	}
+	if len(m.Pending("eng-agent")) != 0 {
+		t.Error("Expected escalated queries not to be queued")
+	}
+}
+
+func TestManager_Configure(t *testing.T) {
+	m, _ := newTestManager(Options{}, nil)
+	m.Configure(Options{MinConfidence: 0.8}, []Rule{{Priority: "low", Action: ActionQueue}})
+
+	if got := m.MinConfidence(); got != 0.8 {
+		t.Errorf("Expected min confidence 0.8, got %v", got)
+	}
+	rules := m.Rules()
+	if len(rules) != 1+len(DefaultRules()) || rules[0].Priority != "low" {
+		t.Errorf("Expected the configured rule before the defaults, got %+v", rules)
+	}
+}
+
+func TestManager_Methods(t *testing.T) {
+	m, _ := newTestManager(Options{}, nil)
+	m.SetLocalAgent("eng-agent")
+	reg := rpc.NewRegistry()
+	m.RegisterMethods(reg)
+
+	call := func(method, params string) (interface{}, error) {
+		return reg.Call(context.Background(), method, json.RawMessage(params))
+	}
+
+	if _, err := call("aoi.focus.set", `{}`); err == nil {
+		t.Error("Expected focused to be required")
+	}
+	if _, err := call("aoi.focus.set", `{"focused": true, "duration": "soon"}`); err == nil {
+		t.Error("Expected an invalid duration to be rejected")
+	}
+
+	result, err := call("aoi.focus.set", `{"focused": true, "duration": "90m"}`)
+	if err != nil {
+		t.Fatalf("aoi.focus.set failed: %v", err)
+	}
+	if state := result.(State); !state.Focused || state.AgentID != "eng-agent" || state.Until.IsZero() {
+		t.Errorf("Unexpected state %+v", state)
+	}
+
+	m.Enqueue("eng-agent", Query{Query: "ping"}, "test")
+	result, err = call("aoi.focus.digest", `{}`)
+	if err != nil {
+		t.Fatalf("aoi.focus.digest failed: %v", err)
+	}
+	if queued := result.([]QueuedQuery); len(queued) != 1 {
+		t.Errorf("Expected 1 pending query, got %d", len(queued))
+	}
+}
@@ -0,0 +1,276 @@
+// Package gossip keeps the agent registries of a team in sync without a central
+// server. Every interval an agent exchanges registry digests with a few peers
+// and each side pulls the entries the other holds a newer version of.
+package gossip
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aoi-protocol/aoi/internal/identity"
+	"github.com/aoi-protocol/aoi/internal/rpc"
+	"github.com/aoi-protocol/aoi/pkg/aoi/client"
+)
+
+// Defaults for Config
+const (
+	DefaultInterval = 10 * time.Second
+	DefaultFanout   = 3
+	DefaultTimeout  = 10 * time.Second
+)
+
+// Config configures a Gossiper
+type Config struct {
+	// Seeds are endpoints synced with in addition to the agents in the registry
+	Seeds []string
+	// Interval is the time between gossip rounds
+	Interval time.Duration
+	// Fanout is how many peers are synced with per round
+	Fanout int
+	// TombstoneTTL is how long removed agents are remembered
+	TombstoneTTL time.Duration
+	// HTTPClient reaches peers, e.g. with mutual TLS configured
+	HTTPClient *http.Client
+	// SigningKey signs gossip requests as the registry's node when set
+	SigningKey ed25519.PrivateKey
+	// Caller returns the agent that authenticated a gossip request, such as
+	// protocol.AuthenticatedAgent. Without it every caller is anonymous.
+	Caller func(ctx context.Context) string
+}
+
+// SyncParams is the params of aoi.gossip.sync. The callee takes the sender
+// from the authenticated caller, so From is informational.
+type SyncParams struct {
+	From   string          `json:"from"`
+	Digest identity.Digest `json:"digest"`
+}
+
+// SyncResult is the result of aoi.gossip.sync: the entries the caller lacks
+// and the IDs of those the callee wants pushed back
+type SyncResult struct {
+	identity.Delta
+	Want []string `json:"want,omitempty"`
+}
+
+// PushParams is the params of aoi.gossip.push
+type PushParams struct {
+	From string `json:"from"`
+	identity.Delta
+}
+
+// Gossiper periodically syncs a registry with its peers
+type Gossiper struct {
+	registry *identity.AgentRegistry
+	cfg      Config
+	stopOnce sync.Once
+	stop     chan struct{}
+}
+
+// New creates a Gossiper for registry. Call Start to begin gossiping.
+func New(registry *identity.AgentRegistry, cfg Config) *Gossiper {
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultInterval
+	}
+	if cfg.Fanout <= 0 {
+		cfg.Fanout = DefaultFanout
+	}
+	if cfg.TombstoneTTL <= 0 {
+		cfg.TombstoneTTL = identity.DefaultTombstoneTTL
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: DefaultTimeout}
+	}
+	return &Gossiper{
+		registry: registry,
+		cfg:      cfg,
+		stop:     make(chan struct{}),
+	}
+}
+
+// Start runs a gossip round every interval until Stop
+func (g *Gossiper) Start() {
+	go func() {
+		ticker := time.NewTicker(g.cfg.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), g.cfg.Interval)
+				g.Round(ctx)
+				cancel()
+			case <-g.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends gossiping
+func (g *Gossiper) Stop() {
+	g.stopOnce.Do(func() { close(g.stop) })
+}
+
+// Peers returns the endpoints to gossip with: the seeds plus every agent in
+// the registry that advertises an endpoint and is not known to be offline
+func (g *Gossiper) Peers() []string {
+	self := ""
+	if agent, err := g.registry.GetAgent(g.registry.NodeID()); err == nil {
+		self = normalize(agent.Endpoint)
+	}
+
+	seen := make(map[string]bool)
+	var peers []string
+	add := func(endpoint string) {
+		endpoint = normalize(endpoint)
+		if endpoint == "" || endpoint == self || seen[endpoint] {
+			return
+		}
+		seen[endpoint] = true
+		peers = append(peers, endpoint)
+	}
+
+	for _, seed := range g.cfg.Seeds {
+		add(seed)
+	}
+	for _, agent := range g.registry.Discover() {
+		if agent.ID == g.registry.NodeID() || agent.Status == identity.StatusOffline {
+			continue
+		}
+		add(agent.Endpoint)
+	}
+	return peers
+}
+
+// Round syncs with up to Fanout random peers and forgets old tombstones
+func (g *Gossiper) Round(ctx context.Context) {
+	peers := g.Peers()
+	rand.Shuffle(len(peers), func(i, j int) { peers[i], peers[j] = peers[j], peers[i] })
+	if len(peers) > g.cfg.Fanout {
+		peers = peers[:g.cfg.Fanout]
+	}
+
+	for _, peer := range peers {
+		if _, err := g.SyncWith(ctx, peer); err != nil {
+			log.Printf("[Gossip] Sync with %s failed: %v", peer, err)
+		}
+	}
+
+	g.registry.PruneTombstones(time.Now().Add(-g.cfg.TombstoneTTL))
+}
+
+// SyncWith exchanges digests with the agent at endpoint. Entries the peer holds
+// newer versions of are merged here, and entries the peer wants are pushed to it.
+// It returns how many entries were applied locally.
+func (g *Gossiper) SyncWith(ctx context.Context, endpoint string) (int, error) {
+	from := g.registry.NodeID()
+	opts := []client.Option{client.WithHTTPClient(g.cfg.HTTPClient), client.WithAgentID(from)}
+	if g.cfg.SigningKey != nil {
+		opts = append(opts, client.WithSigningKey(g.cfg.SigningKey))
+	}
+	c := client.New(endpoint, opts...)
+
+	var result SyncResult
+	if err := c.Call(ctx, "aoi.gossip.sync", SyncParams{From: from, Digest: g.registry.Digest()}, &result); err != nil {
+		return 0, err
+	}
+	// The callee is not authenticated here, so only its signed entries apply
+	applied := g.registry.Merge(result.Delta, "")
+
+	if len(result.Want) > 0 {
+		push := PushParams{From: from, Delta: g.registry.DeltaFor(result.Want)}
+		if !push.Empty() {
+			if err := c.Call(ctx, "aoi.gossip.push", push, nil); err != nil {
+				return applied, fmt.Errorf("push: %w", err)
+			}
+		}
+	}
+	return applied, nil
+}
+
+// RegisterMethods registers the aoi.gossip.* methods peers call
+func (g *Gossiper) RegisterMethods(reg *rpc.Registry) {
+	reg.MustRegister(
+		rpc.Method{
+			Name:    "aoi.gossip.sync",
+			Summary: "Compare registry digests; returns the entries the caller lacks and the IDs this agent wants",
+			Params: []rpc.ContentDescriptor{
+				rpc.Param("from", "string", "Calling agent; must match the authenticated caller"),
+				rpc.RequiredParam("digest", "object", "Version of every entry the caller holds"),
+			},
+			Result:   rpc.Result("delta", rpc.Type("object")),
+			Resource: "registry/gossip",
+			Action:   rpc.ActionRead,
+			Handler:  g.handleSync,
+		},
+		rpc.Method{
+			Name:    "aoi.gossip.push",
+			Summary: "Merge registry entries requested by a previous aoi.gossip.sync",
+			Params: []rpc.ContentDescriptor{
+				rpc.Param("from", "string", "Calling agent; must match the authenticated caller"),
+				rpc.Param("agents", "array", "Agent entries"),
+				rpc.Param("tombstones", "array", "Removed agents"),
+			},
+			Result:   rpc.Result("applied", rpc.Type("object")),
+			Resource: "registry/gossip",
+			Action:   rpc.ActionWrite,
+			Handler:  g.handlePush,
+		},
+	)
+}
+
+// sender returns the authenticated caller of a gossip request, refusing a
+// request that claims to come from another agent
+func (g *Gossiper) sender(ctx context.Context, claimed string) (string, error) {
+	from := ""
+	if g.cfg.Caller != nil {
+		from = g.cfg.Caller(ctx)
+	}
+	if from != "" && claimed != "" && claimed != from {
+		return "", rpc.NewError(rpc.CodeACLDenied, fmt.Sprintf("caller '%s' cannot gossip as '%s'", from, claimed), nil)
+	}
+	return from, nil
+}
+
+func (g *Gossiper) handleSync(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p SyncParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, rpc.InvalidParams(err.Error())
+	}
+	if _, err := g.sender(ctx, p.From); err != nil {
+		return nil, err
+	}
+	if p.Digest == nil {
+		return nil, rpc.InvalidParams("digest is required")
+	}
+
+	return SyncResult{
+		Delta: g.registry.DeltaSince(p.Digest),
+		Want:  g.registry.Wanted(p.Digest),
+	}, nil
+}
+
+func (g *Gossiper) handlePush(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p PushParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, rpc.InvalidParams(err.Error())
+	}
+	from, err := g.sender(ctx, p.From)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]int{"applied": g.registry.Merge(p.Delta, from)}, nil
+}
+
+// normalize trims the trailing slash so the same endpoint is only synced once
+func normalize(endpoint string) string {
+	return strings.TrimRight(strings.TrimSpace(endpoint), "/")
+}
@@ -0,0 +1,168 @@
+package gossip
+
+import (
+	"context"
+	"crypto/ed25519"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aoi-protocol/aoi/internal/identity"
+	"github.com/aoi-protocol/aoi/internal/protocol"
+	"github.com/aoi-protocol/aoi/pkg/aoi"
+	"github.com/aoi-protocol/aoi/pkg/aoi/client"
+)
+
+type peer struct {
+	registry *identity.AgentRegistry
+	gossiper *Gossiper
+	server   *protocol.Server
+	url      string
+}
+
+// signed signs agent's identity with a new key of its own, so that peers
+// accept the entry from whoever relays it
+func signed(t *testing.T, agent *aoi.AgentIdentity) *aoi.AgentIdentity {
+	t.Helper()
+	_, key, _ := ed25519.GenerateKey(nil)
+	agent.PublicKey = aoi.EncodePublicKey(key.Public().(ed25519.PublicKey))
+	if err := aoi.SignIdentity(key, agent); err != nil {
+		t.Fatalf("SignIdentity: %v", err)
+	}
+	return agent
+}
+
+// newPeer starts an AOI server for id that serves the gossip methods
+func newPeer(t *testing.T, id string, seeds ...string) *peer {
+	t.Helper()
+	registry := identity.NewAgentRegistry()
+	registry.SetNodeID(id)
+	_, key, _ := ed25519.GenerateKey(nil)
+	registry.SetNodeKey(key)
+
+	server := protocol.NewServer(registry, nil)
+	gossiper := New(registry, Config{Seeds: seeds, Caller: protocol.AuthenticatedAgent})
+	gossiper.RegisterMethods(server.Methods())
+
+	ts := httptest.NewServer(server.Handler())
+	t.Cleanup(ts.Close)
+	t.Cleanup(gossiper.Stop)
+
+	registry.Register(&aoi.AgentIdentity{ID: id, Endpoint: ts.URL, Status: identity.StatusOnline,
+		PublicKey: aoi.EncodePublicKey(key.Public().(ed25519.PublicKey))})
+	return &peer{registry: registry, gossiper: gossiper, server: server, url: ts.URL}
+}
+
+func TestGossiper_SyncWith(t *testing.T) {
+	pm := newPeer(t, "pm-agent")
+	eng := newPeer(t, "eng-agent")
+	eng.registry.Register(signed(t, &aoi.AgentIdentity{ID: "qa-agent", Role: aoi.RoleQA, Status: identity.StatusOnline}))
+
+	applied, err := pm.gossiper.SyncWith(context.Background(), eng.url)
+	if err != nil {
+		t.Fatalf("SyncWith failed: %v", err)
+	}
+	if applied != 2 {
+		t.Errorf("Expected 2 entries applied on pm-agent, got %d", applied)
+	}
+
+	// One sync pulls and pushes, so both sides now know all three agents
+	for _, p := range []*peer{pm, eng} {
+		if n := len(p.registry.Discover()); n != 3 {
+			t.Errorf("%s: expected 3 agents, got %d", p.registry.NodeID(), n)
+		}
+	}
+
+	applied, err = pm.gossiper.SyncWith(context.Background(), eng.url)
+	if err != nil || applied != 0 {
+		t.Errorf("Expected nothing left to sync, applied %d (err %v)", applied, err)
+	}
+}
+
+func TestGossiper_RoundConverges(t *testing.T) {
+	// a only knows b, c only knows b; rounds must spread everything
+	b := newPeer(t, "b-agent")
+	a := newPeer(t, "a-agent", b.url)
+	c := newPeer(t, "c-agent", b.url+"/")
+	a.registry.Register(signed(t, &aoi.AgentIdentity{ID: "only-on-a"}))
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		a.gossiper.Round(ctx)
+		c.gossiper.Round(ctx)
+	}
+
+	for _, p := range []*peer{a, b, c} {
+		if _, err := p.registry.GetAgent("only-on-a"); err != nil {
+			t.Errorf("%s: expected only-on-a to have spread", p.registry.NodeID())
+		}
+		if n := len(p.registry.Discover()); n != 4 {
+			t.Errorf("%s: expected 4 agents, got %d", p.registry.NodeID(), n)
+		}
+	}
+
+	// An agent's removal of itself spreads the same way
+	a.registry.Unregister("a-agent")
+	for i := 0; i < 2; i++ {
+		a.gossiper.Round(ctx)
+		c.gossiper.Round(ctx)
+	}
+	for _, p := range []*peer{b, c} {
+		if _, err := p.registry.GetAgent("a-agent"); err == nil {
+			t.Errorf("%s: expected a-agent to be removed", p.registry.NodeID())
+		}
+	}
+}
+
+func TestGossiper_PushTakesSenderFromCaller(t *testing.T) {
+	pm := newPeer(t, "pm-agent")
+	pm.registry.Register(&aoi.AgentIdentity{ID: "eng-agent", Role: aoi.RoleEngineer, Endpoint: "http://eng:8080"})
+	pm.server.SetAuthTokens(map[string]string{"qa-token": "qa-agent"})
+	ctx := context.Background()
+
+	anonymous := client.New(pm.url)
+	qa := client.New(pm.url, client.WithToken("qa-token"))
+
+	// Unsigned entries are only taken from the peer they describe
+	forged := PushParams{From: "eng-agent", Delta: identity.Delta{Agents: []*aoi.AgentIdentity{
+		{ID: "eng-agent", Role: aoi.RolePM, Endpoint: "http://evil:8080", Version: 100, Origin: "eng-agent"},
+	}}}
+	var result map[string]int
+	if err := anonymous.Call(ctx, "aoi.gossip.push", forged, &result); err != nil || result["applied"] != 0 {
+		t.Errorf("Expected an anonymous push claiming eng-agent to apply nothing, got %v (err %v)", result, err)
+	}
+	if err := qa.Call(ctx, "aoi.gossip.push", forged, nil); !client.IsCode(err, client.CodeACLDenied) {
+		t.Errorf("Expected qa-agent gossiping as eng-agent to be denied, got %v", err)
+	}
+	if agent, _ := pm.registry.GetAgent("eng-agent"); agent.Endpoint != "http://eng:8080" || agent.Role != aoi.RoleEngineer {
+		t.Errorf("Expected eng-agent to be unchanged, got %+v", agent)
+	}
+
+	own := PushParams{Delta: identity.Delta{Agents: []*aoi.AgentIdentity{
+		{ID: "qa-agent", Role: aoi.RoleQA, Endpoint: "http://qa:8080", Version: 1, Origin: "qa-agent"},
+	}}}
+	if err := qa.Call(ctx, "aoi.gossip.push", own, &result); err != nil || result["applied"] != 1 {
+		t.Errorf("Expected qa-agent's own entry to apply, got %v (err %v)", result, err)
+	}
+}
+
+func TestGossiper_Peers(t *testing.T) {
+	registry := identity.NewAgentRegistry()
+	registry.SetNodeID("pm-agent")
+	registry.Register(&aoi.AgentIdentity{ID: "pm-agent", Endpoint: "http://pm:8080"})
+	registry.Register(&aoi.AgentIdentity{ID: "eng-agent", Endpoint: "http://eng:8080/", Status: identity.StatusOnline})
+	registry.Register(&aoi.AgentIdentity{ID: "qa-agent", Endpoint: "http://qa:8080", Status: identity.StatusOffline})
+	registry.Register(&aoi.AgentIdentity{ID: "no-endpoint", Status: identity.StatusOnline})
+
+	g := New(registry, Config{Seeds: []string{"http://seed:8080", "http://eng:8080", "http://pm:8080/"}})
+	peers := g.Peers()
+
+	want := map[string]bool{"http://seed:8080": true, "http://eng:8080": true}
+	if len(peers) != len(want) {
+		t.Fatalf("Expected peers %v, got %v", want, peers)
+	}
+	for _, p := range peers {
+		if !want[p] {
+			t.Errorf("Unexpected peer %s", p)
+		}
+	}
+}
@@ -46,9 +46,14 @@ type H2AManager struct {
 	streams  map[string]*streamSession // streamID -> stream
 	pmUsers  []string                  // user IDs that hold PM (admin) permission
 	wsHub    WSHubInterface
+	filter   OutputFilter
 	mu       sync.RWMutex
 }
 
+// OutputFilter rewrites captured terminal output before it is returned or
+// broadcast, e.g. to redact secrets shown on screen.
+type OutputFilter func(agentID, output string) string
+
 // NewH2AManager creates a new H2AManager.
 func NewH2AManager() *H2AManager {
 	return &H2AManager{
@@ -65,6 +70,24 @@ func (m *H2AManager) SetWSHub(hub WSHubInterface) {
 	m.wsHub = hub
 }
 
+// SetOutputFilter sets the filter applied to captured output; nil turns it off.
+func (m *H2AManager) SetOutputFilter(filter OutputFilter) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.filter = filter
+}
+
+// filterOutput applies the output filter, if any.
+func (m *H2AManager) filterOutput(agentID, output string) string {
+	m.mu.RLock()
+	filter := m.filter
+	m.mu.RUnlock()
+	if filter == nil {
+		return output
+	}
+	return filter(agentID, output)
+}
+
 // SetPMUsers configures which user IDs are treated as PM (can send to any agent).
 func (m *H2AManager) SetPMUsers(users []string) {
 	m.mu.Lock()
@@ -176,8 +199,18 @@ func (m *H2AManager) SendCommand(agentID, command string, captureOutput bool) (*
 	return result, nil
 }
 
-// CaptureOutput runs tmux capture-pane and returns the last `lines` lines of output.
+// CaptureOutput runs tmux capture-pane and returns the last `lines` lines of
+// output, passed through the output filter.
 func (m *H2AManager) CaptureOutput(agentID string, lines int) (string, error) {
+	out, err := m.capturePane(agentID, lines)
+	if err != nil {
+		return "", err
+	}
+	return m.filterOutput(agentID, out), nil
+}
+
+// capturePane returns the last `lines` lines of the agent's pane as is.
+func (m *H2AManager) capturePane(agentID string, lines int) (string, error) {
 	session, ok := m.GetSession(agentID)
 	if !ok {
 		return "", fmt.Errorf("no tmux session registered for agent: %s", agentID)
@@ -240,12 +273,14 @@ func (m *H2AManager) StartStream(agentID string, intervalMs int) (string, error)
 				m.broadcastH2AOutput(streamID, agentID, "", true)
 				return
 			case <-ticker.C:
-				output, err := m.CaptureOutput(agentID, 50)
+				// Only changed output is filtered, so that the filter sees
+				// each screen once rather than every tick
+				output, err := m.capturePane(agentID, 50)
 				if err != nil || output == lastOutput {
 					continue
 				}
 				lastOutput = output
-				m.broadcastH2AOutput(streamID, agentID, output, false)
+				m.broadcastH2AOutput(streamID, agentID, m.filterOutput(agentID, output), false)
 			}
 		}
 	}()
@@ -178,3 +178,17 @@ func TestH2AManager_tmuxTarget(t *testing.T) {
 		}
 	}
 }
+
+func TestH2AManager_filterOutput(t *testing.T) {
+	m := NewH2AManager()
+	if got := m.filterOutput("eng-suzuki", "TOKEN=abc"); got != "TOKEN=abc" {
+		t.Errorf("filterOutput() without a filter = %q", got)
+	}
+
+	m.SetOutputFilter(func(agentID, output string) string {
+		return agentID + ": " + strings.ReplaceAll(output, "abc", "***")
+	})
+	if got := m.filterOutput("eng-suzuki", "TOKEN=abc"); got != "eng-suzuki: TOKEN=***" {
+		t.Errorf("filterOutput() = %q", got)
+	}
+}
@@ -0,0 +1,125 @@
+package identity
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/aoi-protocol/aoi/pkg/aoi"
+)
+
+// DiscoverFilter selects agents in DiscoverMatching. Zero fields match every agent.
+type DiscoverFilter struct {
+	// Role matches the agent's role
+	Role aoi.AgentRole `json:"role,omitempty"`
+	// Capability matches a capability tag, a manifest action or an MCP tool
+	// (by "server/tool" or bare tool name)
+	Capability string `json:"capability,omitempty"`
+	// Project matches a repository in the manifest, either by full name
+	// ("aoi-protocol/aoi") or by its last segment ("aoi")
+	Project string `json:"project,omitempty"`
+	// Issue matches an issue number held for Project (or any repository if Project is empty)
+	Issue int `json:"issue,omitempty"`
+	// Online selects agents that are (true) or are not (false) online
+	Online *bool `json:"online,omitempty"`
+}
+
+// Matches reports whether agent satisfies every set field of the filter
+func (f DiscoverFilter) Matches(agent *aoi.AgentIdentity) bool {
+	if f.Role != "" && agent.Role != f.Role {
+		return false
+	}
+	if f.Online != nil && (agent.Status == StatusOnline) != *f.Online {
+		return false
+	}
+	if f.Capability != "" && !hasCapability(agent, f.Capability) {
+		return false
+	}
+	if f.Project != "" || f.Issue != 0 {
+		return holdsContext(agent.Manifest, f.Project, f.Issue)
+	}
+	return true
+}
+
+// DiscoverMatching returns copies of the agents matching filter, ordered by ID
+func (r *AgentRegistry) DiscoverMatching(filter DiscoverFilter) []*aoi.AgentIdentity {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	agents := make([]*aoi.AgentIdentity, 0)
+	for _, agent := range r.agents {
+		if filter.Matches(agent) {
+			agents = append(agents, snapshot(agent))
+		}
+	}
+	sort.Slice(agents, func(i, j int) bool { return agents[i].ID < agents[j].ID })
+	return agents
+}
+
+// UpdateManifest replaces an agent's capability manifest
+func (r *AgentRegistry) UpdateManifest(id string, manifest *aoi.CapabilityManifest) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	agent, exists := r.agents[id]
+	if !exists {
+		return ErrAgentNotFound
+	}
+
+	agent.Manifest = manifest
+	r.stamp(agent)
+	return r.persist(agent)
+}
+
+func hasCapability(agent *aoi.AgentIdentity, capability string) bool {
+	if containsFold(agent.Capabilities, capability) {
+		return true
+	}
+	if agent.Manifest == nil {
+		return false
+	}
+	if containsFold(agent.Manifest.Actions, capability) {
+		return true
+	}
+	for _, tool := range agent.Manifest.MCPTools {
+		if nameMatches(tool, capability) {
+			return true
+		}
+	}
+	return false
+}
+
+func holdsContext(manifest *aoi.CapabilityManifest, project string, issue int) bool {
+	if manifest == nil {
+		return false
+	}
+	for _, repo := range manifest.Repositories {
+		if project != "" && !nameMatches(repo.Name, project) {
+			continue
+		}
+		if issue == 0 {
+			return true
+		}
+		for _, n := range repo.Issues {
+			if n == issue {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// nameMatches compares case-insensitively, accepting a full slash-separated
+// name ("aoi-protocol/aoi", "filesystem/read_file") or its last segment
+func nameMatches(name, want string) bool {
+	name, want = strings.ToLower(name), strings.ToLower(want)
+	return name == want || strings.HasSuffix(name, "/"+want)
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+package identity
+
+import (
+	"testing"
+
+	"github.com/aoi-protocol/aoi/pkg/aoi"
+)
+
+func newManifestRegistry() *AgentRegistry {
+	registry := NewAgentRegistry()
+	registry.Register(&aoi.AgentIdentity{
+		ID:     "eng-suzuki",
+		Role:   aoi.RoleEngineer,
+		Status: StatusOnline,
+		Manifest: &aoi.CapabilityManifest{
+			Repositories: []aoi.RepositoryContext{{Name: "aoi-protocol/aoi", Issues: []int{12, 27}}},
+			Actions:      []string{aoi.ActionRead, aoi.ActionExecute},
+			MCPTools:     []string{"filesystem/read_file"},
+		},
+	})
+	registry.Register(&aoi.AgentIdentity{
+		ID:           "eng-tanaka",
+		Role:         aoi.RoleEngineer,
+		Status:       StatusOffline,
+		Capabilities: []string{"go"},
+		Manifest: &aoi.CapabilityManifest{
+			Repositories: []aoi.RepositoryContext{{Name: "aoi-protocol/frontend"}},
+			Actions:      []string{aoi.ActionRead},
+		},
+	})
+	registry.Register(&aoi.AgentIdentity{ID: "qa-sato", Role: aoi.RoleQA, Status: StatusOnline})
+	return registry
+}
+
+func agentIDs(agents []*aoi.AgentIdentity) []string {
+	ids := make([]string, len(agents))
+	for i, agent := range agents {
+		ids[i] = agent.ID
+	}
+	return ids
+}
+
+func TestAgentRegistry_DiscoverMatching(t *testing.T) {
+	registry := newManifestRegistry()
+	online, offline := true, false
+
+	tests := []struct {
+		name   string
+		filter DiscoverFilter
+		want   []string
+	}{
+		{"no filter", DiscoverFilter{}, []string{"eng-suzuki", "eng-tanaka", "qa-sato"}},
+		{"role", DiscoverFilter{Role: aoi.RoleEngineer}, []string{"eng-suzuki", "eng-tanaka"}},
+		{"online", DiscoverFilter{Online: &online}, []string{"eng-suzuki", "qa-sato"}},
+		{"offline", DiscoverFilter{Online: &offline}, []string{"eng-tanaka"}},
+		{"project by full name", DiscoverFilter{Project: "aoi-protocol/aoi"}, []string{"eng-suzuki"}},
+		{"project by last segment", DiscoverFilter{Project: "FRONTEND"}, []string{"eng-tanaka"}},
+		{"project and issue", DiscoverFilter{Project: "aoi", Issue: 27}, []string{"eng-suzuki"}},
+		{"issue not held", DiscoverFilter{Project: "aoi", Issue: 99}, []string{}},
+		{"action", DiscoverFilter{Capability: "execute"}, []string{"eng-suzuki"}},
+		{"capability tag", DiscoverFilter{Capability: "go"}, []string{"eng-tanaka"}},
+		{"mcp tool", DiscoverFilter{Capability: "read_file"}, []string{"eng-suzuki"}},
+		{"combined", DiscoverFilter{Role: aoi.RoleEngineer, Project: "aoi", Online: &online}, []string{"eng-suzuki"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := agentIDs(registry.DiscoverMatching(tt.filter))
+			if len(got) != len(tt.want) {
+				t.Fatalf("Expected %v, got %v", tt.want, got)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("Expected %v, got %v", tt.want, got)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestAgentRegistry_UpdateManifest(t *testing.T) {
+	registry := newManifestRegistry()
+
+	manifest := &aoi.CapabilityManifest{Repositories: []aoi.RepositoryContext{{Name: "aoi-protocol/docs"}}}
+	if err := registry.UpdateManifest("qa-sato", manifest); err != nil {
+		t.Fatalf("UpdateManifest failed: %v", err)
+	}
+	if got := agentIDs(registry.DiscoverMatching(DiscoverFilter{Project: "docs"})); len(got) != 1 || got[0] != "qa-sato" {
+		t.Errorf("Expected qa-sato to hold docs, got %v", got)
+	}
+
+	if err := registry.UpdateManifest("ghost", manifest); err != ErrAgentNotFound {
+		t.Errorf("Expected ErrAgentNotFound, got %v", err)
+	}
+}
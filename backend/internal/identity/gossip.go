@@ -0,0 +1,311 @@
+package identity
+
+import (
+	"crypto/ed25519"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/aoi-protocol/aoi/pkg/aoi"
+)
+
+// DefaultTombstoneTTL is how long a removed agent's tombstone is kept so that
+// every peer hears of the removal
+const DefaultTombstoneTTL = 24 * time.Hour
+
+// MaxVersion is the highest version accepted from a peer. Entries above it are
+// dropped, so a forged version cannot wind the clock up to where stamp wraps.
+const MaxVersion = math.MaxUint64 >> 1
+
+// Tombstone records that an agent was unregistered, so peers that still hold
+// the agent drop it instead of gossiping it back
+type Tombstone struct {
+	ID        string    `json:"id"`
+	Version   uint64    `json:"version"`
+	Origin    string    `json:"origin,omitempty"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// DigestEntry is the version of one entry in a registry digest
+type DigestEntry struct {
+	Version uint64 `json:"version"`
+	Origin  string `json:"origin,omitempty"`
+	Deleted bool   `json:"deleted,omitempty"`
+}
+
+// Digest summarizes a registry as the version of every agent and tombstone
+type Digest map[string]DigestEntry
+
+// Delta carries registry entries from one peer to another
+type Delta struct {
+	Agents     []*aoi.AgentIdentity `json:"agents,omitempty"`
+	Tombstones []Tombstone          `json:"tombstones,omitempty"`
+}
+
+// Empty reports whether the delta carries nothing
+func (d Delta) Empty() bool {
+	return len(d.Agents) == 0 && len(d.Tombstones) == 0
+}
+
+// newer reports whether version a (with origin ao) supersedes b (with origin bo)
+func newer(a uint64, ao string, b uint64, bo string) bool {
+	return a > b || (a == b && ao > bo)
+}
+
+// SetNodeID sets the ID this registry stamps on its own changes and leases,
+// normally the local agent's ID
+func (r *AgentRegistry) SetNodeID(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nodeID = id
+}
+
+// SetNodeKey makes the registry sign this node's own entry with key whenever
+// it changes, so peers accept the entry from whoever relays it
+func (r *AgentRegistry) SetNodeKey(key ed25519.PrivateKey) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nodeKey = key
+}
+
+// NodeID returns the ID set by SetNodeID
+func (r *AgentRegistry) NodeID() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.nodeID
+}
+
+// stamp gives an entry the next version of this node. Caller must hold r.mu.
+func (r *AgentRegistry) stamp(agent *aoi.AgentIdentity) {
+	if agent.Version > r.clock && agent.Version <= MaxVersion {
+		r.clock = agent.Version
+	}
+	r.clock++
+	agent.Version = r.clock
+	agent.Origin = r.nodeID
+	if r.nodeKey != nil && agent.ID == r.nodeID {
+		_ = aoi.SignIdentity(r.nodeKey, agent)
+	}
+}
+
+// Digest returns the version of every agent and tombstone
+func (r *AgentRegistry) Digest() Digest {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	digest := make(Digest, len(r.agents)+len(r.tombstones))
+	for id, agent := range r.agents {
+		digest[id] = DigestEntry{Version: agent.Version, Origin: agent.Origin}
+	}
+	for id, ts := range r.tombstones {
+		digest[id] = DigestEntry{Version: ts.Version, Origin: ts.Origin, Deleted: true}
+	}
+	return digest
+}
+
+// DeltaSince returns the entries this registry holds that are newer than, or
+// missing from, a peer's digest
+func (r *AgentRegistry) DeltaSince(peer Digest) Delta {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var delta Delta
+	for id, agent := range r.agents {
+		if theirs, ok := peer[id]; !ok || newer(agent.Version, agent.Origin, theirs.Version, theirs.Origin) {
+			delta.Agents = append(delta.Agents, snapshot(agent))
+		}
+	}
+	for id, ts := range r.tombstones {
+		if theirs, ok := peer[id]; ok && newer(ts.Version, ts.Origin, theirs.Version, theirs.Origin) {
+			delta.Tombstones = append(delta.Tombstones, ts)
+		}
+	}
+	sort.Slice(delta.Agents, func(i, j int) bool { return delta.Agents[i].ID < delta.Agents[j].ID })
+	return delta
+}
+
+// Wanted returns the IDs for which a peer's digest is newer than this registry
+func (r *AgentRegistry) Wanted(peer Digest) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var ids []string
+	for id, theirs := range peer {
+		var version uint64
+		var origin string
+		if agent, ok := r.agents[id]; ok {
+			version, origin = agent.Version, agent.Origin
+		} else if ts, ok := r.tombstones[id]; ok {
+			version, origin = ts.Version, ts.Origin
+		} else {
+			// Unknown here: wanted unless it is only a tombstone
+			if !theirs.Deleted {
+				ids = append(ids, id)
+			}
+			continue
+		}
+		if newer(theirs.Version, theirs.Origin, version, origin) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// DeltaFor returns the current entries for ids, as requested by a peer
+func (r *AgentRegistry) DeltaFor(ids []string) Delta {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var delta Delta
+	for _, id := range ids {
+		if agent, ok := r.agents[id]; ok {
+			delta.Agents = append(delta.Agents, snapshot(agent))
+		} else if ts, ok := r.tombstones[id]; ok {
+			delta.Tombstones = append(delta.Tombstones, ts)
+		}
+	}
+	return delta
+}
+
+// selfSigned reports whether remote carries the agent's own signature, made
+// with its pinned key if local has one, else with the key remote declares
+func selfSigned(remote, local *aoi.AgentIdentity) bool {
+	key := remote.PublicKey
+	if local != nil && local.PublicKey != "" {
+		key = local.PublicKey
+	}
+	if remote.Signature == nil || key == "" {
+		return false
+	}
+	pub, err := aoi.ParsePublicKey(key)
+	return err == nil && aoi.VerifyIdentity(pub, remote) == nil
+}
+
+// Merge applies the entries that from, the authenticated peer that sent them
+// ("" when unknown), holds newer versions of and returns how many were applied.
+// An agent entry is only taken when the agent signed it or when from describes
+// itself; from may otherwise only report the liveness of agents whose lease it
+// holds. Gossip never changes the role of a known agent.
+//
+// This node's own entry is never overwritten: a peer holding a newer copy
+// (say, from before a restart) makes it re-stamp its own instead. An agent
+// with a pinned public key is only removed by its own tombstone.
+func (r *AgentRegistry) Merge(delta Delta, from string) int {
+	r.mu.Lock()
+	applied := 0
+	var changes []StatusChange
+
+	for _, remote := range delta.Agents {
+		if remote == nil || remote.ID == "" || remote.Version > MaxVersion {
+			continue
+		}
+		local, exists := r.agents[remote.ID]
+		if r.nodeID != "" && remote.ID == r.nodeID {
+			if exists && newer(remote.Version, remote.Origin, local.Version, local.Origin) {
+				r.clock = max(r.clock, remote.Version)
+				r.stamp(local)
+				_ = r.persist(local)
+			}
+			continue
+		}
+		if remote.Version > r.clock {
+			r.clock = remote.Version
+		}
+
+		if exists && !newer(remote.Version, remote.Origin, local.Version, local.Origin) {
+			continue
+		}
+		if ts, deleted := r.tombstones[remote.ID]; deleted && !newer(remote.Version, remote.Origin, ts.Version, ts.Origin) {
+			continue
+		}
+
+		// A pinned public key is never replaced by gossip
+		pinned := exists && local.PublicKey != "" && remote.PublicKey != local.PublicKey
+		trusted := selfSigned(remote, local) || (from != "" && remote.ID == from && remote.Origin == from)
+		var agent *aoi.AgentIdentity
+		switch {
+		case trusted && !pinned:
+			agent = snapshot(remote)
+			if exists {
+				agent.Role = local.Role
+			}
+		case exists && from != "" && remote.Origin == from && remote.LeaseHolder == from:
+			agent = snapshot(local)
+			agent.Status, agent.LastSeen, agent.LeaseExpiresAt = remote.Status, remote.LastSeen, remote.LeaseExpiresAt
+			agent.LeaseHolder, agent.Version, agent.Origin = remote.LeaseHolder, remote.Version, remote.Origin
+		default:
+			continue
+		}
+		previous := ""
+		if exists {
+			previous = local.Status
+		}
+		if err := r.persist(agent); err != nil {
+			continue
+		}
+		r.agents[agent.ID] = agent
+		delete(r.tombstones, agent.ID)
+		applied++
+		if previous != agent.Status {
+			changes = append(changes, StatusChange{Agent: snapshot(agent), Previous: previous})
+		}
+	}
+
+	for _, ts := range delta.Tombstones {
+		if ts.Version > MaxVersion {
+			continue
+		}
+		if ts.Version > r.clock {
+			r.clock = ts.Version
+		}
+		if r.nodeID != "" && ts.ID == r.nodeID {
+			if local, exists := r.agents[ts.ID]; exists && newer(ts.Version, ts.Origin, local.Version, local.Origin) {
+				r.stamp(local)
+				_ = r.persist(local)
+			}
+			continue
+		}
+		if existing, ok := r.tombstones[ts.ID]; ok && !newer(ts.Version, ts.Origin, existing.Version, existing.Origin) {
+			continue
+		}
+		if local, exists := r.agents[ts.ID]; exists {
+			if !newer(ts.Version, ts.Origin, local.Version, local.Origin) {
+				continue
+			}
+			// Any peer could forge a tombstone for a pinned agent
+			if local.PublicKey != "" && ts.Origin != ts.ID {
+				continue
+			}
+			if r.store != nil {
+				if err := r.store.Delete(ts.ID); err != nil {
+					continue
+				}
+			}
+			delete(r.agents, ts.ID)
+		}
+		r.tombstones[ts.ID] = ts
+		applied++
+	}
+	r.mu.Unlock()
+
+	for _, change := range changes {
+		r.notify(change)
+	}
+	return applied
+}
+
+// PruneTombstones forgets tombstones of agents removed before cutoff
+func (r *AgentRegistry) PruneTombstones(cutoff time.Time) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	pruned := 0
+	for id, ts := range r.tombstones {
+		if ts.DeletedAt.Before(cutoff) {
+			delete(r.tombstones, id)
+			pruned++
+		}
+	}
+	return pruned
+}
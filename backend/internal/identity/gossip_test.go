@@ -0,0 +1,293 @@
+package identity
+
+import (
+	"crypto/ed25519"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/aoi-protocol/aoi/pkg/aoi"
+)
+
+func newNode(id string) *AgentRegistry {
+	r := NewAgentRegistry()
+	r.SetNodeID(id)
+	return r
+}
+
+// exchange runs one sync in both directions between a and b, as the gossip
+// protocol does between peers that authenticate each other
+func exchange(a, b *AgentRegistry) {
+	digest := a.Digest()
+	a.Merge(b.DeltaSince(digest), b.NodeID())
+	b.Merge(a.DeltaFor(b.Wanted(digest)), a.NodeID())
+}
+
+// signed gives agent a key and signs its identity with it, as the agent itself
+// would; key reuses an earlier key when set
+func signed(t *testing.T, agent *aoi.AgentIdentity, key ed25519.PrivateKey) *aoi.AgentIdentity {
+	t.Helper()
+	if key == nil {
+		_, key, _ = ed25519.GenerateKey(nil)
+	}
+	agent.PublicKey = aoi.EncodePublicKey(key.Public().(ed25519.PublicKey))
+	if err := aoi.SignIdentity(key, agent); err != nil {
+		t.Fatalf("SignIdentity: %v", err)
+	}
+	return agent
+}
+
+func TestAgentRegistry_StampsVersions(t *testing.T) {
+	r := newNode("pm-agent")
+
+	agent := &aoi.AgentIdentity{ID: "eng-agent", Status: StatusOnline}
+	r.Register(agent)
+	if agent.Version != 1 || agent.Origin != "pm-agent" {
+		t.Errorf("Expected version 1 from pm-agent, got %d from %q", agent.Version, agent.Origin)
+	}
+
+	r.UpdateStatus("eng-agent", StatusOnline) // unchanged, no new version
+	r.UpdateStatus("eng-agent", "busy")
+	if agent.Version != 2 {
+		t.Errorf("Expected version 2 after a status change, got %d", agent.Version)
+	}
+
+	// Lease renewals are local and do not bump the version
+	r.Heartbeat("eng-agent", time.Minute)
+	v := agent.Version
+	r.Heartbeat("eng-agent", time.Minute)
+	if agent.Version != v {
+		t.Errorf("Expected renewal to keep version %d, got %d", v, agent.Version)
+	}
+}
+
+func TestAgentRegistry_MergeConverges(t *testing.T) {
+	pm, eng := newNode("pm-agent"), newNode("eng-agent")
+	pm.Register(&aoi.AgentIdentity{ID: "pm-agent", Role: aoi.RolePM, Status: StatusOnline})
+	eng.Register(&aoi.AgentIdentity{ID: "eng-agent", Role: aoi.RoleEngineer, Status: StatusOnline})
+	eng.Register(signed(t, &aoi.AgentIdentity{ID: "qa-agent", Role: aoi.RoleQA, Status: StatusOnline}, nil))
+
+	exchange(pm, eng)
+
+	for _, r := range []*AgentRegistry{pm, eng} {
+		if n := len(r.Discover()); n != 3 {
+			t.Errorf("%s: expected 3 agents after exchange, got %d", r.NodeID(), n)
+		}
+	}
+
+	// A second exchange has nothing left to transfer
+	if delta := eng.DeltaSince(pm.Digest()); !delta.Empty() {
+		t.Errorf("Expected empty delta after convergence, got %+v", delta)
+	}
+	if want := eng.Wanted(pm.Digest()); len(want) != 0 {
+		t.Errorf("Expected nothing wanted after convergence, got %v", want)
+	}
+}
+
+func TestAgentRegistry_MergeNewerWins(t *testing.T) {
+	pm, eng := newNode("pm-agent"), newNode("eng-agent")
+	_, qaKey, _ := ed25519.GenerateKey(nil)
+	eng.Register(signed(t, &aoi.AgentIdentity{ID: "qa-agent", Status: StatusOnline, Endpoint: "http://old:8080"}, qaKey))
+	exchange(pm, eng)
+
+	// eng updates the entry; pm must take the newer version
+	eng.Register(signed(t, &aoi.AgentIdentity{ID: "qa-agent", Status: StatusOnline, Endpoint: "http://new:8080"}, qaKey))
+	exchange(pm, eng)
+
+	agent, _ := pm.GetAgent("qa-agent")
+	if agent.Endpoint != "http://new:8080" {
+		t.Errorf("Expected the newer endpoint, got %s", agent.Endpoint)
+	}
+
+	// A stale copy is ignored
+	stale := *agent
+	stale.Version = 1
+	if applied := pm.Merge(Delta{Agents: []*aoi.AgentIdentity{&stale}}, "eng-agent"); applied != 0 {
+		t.Errorf("Expected stale entry to be ignored, applied %d", applied)
+	}
+}
+
+func TestAgentRegistry_TombstonePropagates(t *testing.T) {
+	qa, pm, eng := newNode("qa-agent"), newNode("pm-agent"), newNode("eng-agent")
+	_, qaKey, _ := ed25519.GenerateKey(nil)
+	qa.Register(signed(t, &aoi.AgentIdentity{ID: "qa-agent", Status: StatusOnline}, qaKey))
+	exchange(qa, pm)
+	exchange(pm, eng)
+	if _, err := eng.GetAgent("qa-agent"); err != nil {
+		t.Fatalf("Expected the signed entry to be relayed to eng-agent: %v", err)
+	}
+
+	// The agent's own removal is relayed like its entry
+	qa.Unregister("qa-agent")
+	exchange(qa, pm)
+	exchange(pm, eng)
+
+	if _, err := eng.GetAgent("qa-agent"); err == nil {
+		t.Error("Expected the removal to reach eng-agent")
+	}
+
+	// eng's older copy must not bring the agent back
+	exchange(eng, pm)
+	if _, err := pm.GetAgent("qa-agent"); err == nil {
+		t.Error("Expected the agent to stay removed")
+	}
+
+	// Registering again after removal wins over the tombstone
+	eng.Register(signed(t, &aoi.AgentIdentity{ID: "qa-agent", Status: StatusOnline}, qaKey))
+	exchange(pm, eng)
+	if _, err := pm.GetAgent("qa-agent"); err != nil {
+		t.Errorf("Expected re-registration to propagate: %v", err)
+	}
+}
+
+func TestAgentRegistry_TombstoneKeepsPinnedAgent(t *testing.T) {
+	pm := newNode("pm-agent")
+	pm.Register(&aoi.AgentIdentity{ID: "eng-agent", PublicKey: "eng-key", Status: StatusOnline})
+
+	// A peer cannot remove an agent whose key is pinned
+	forged := Tombstone{ID: "eng-agent", Version: 100, Origin: "qa-agent", DeletedAt: time.Now()}
+	if applied := pm.Merge(Delta{Tombstones: []Tombstone{forged}}, "qa-agent"); applied != 0 {
+		t.Errorf("Expected forged tombstone to be ignored, applied %d", applied)
+	}
+	if _, err := pm.GetAgent("eng-agent"); err != nil {
+		t.Fatalf("Expected pinned agent to be kept: %v", err)
+	}
+
+	// The agent itself can
+	own := Tombstone{ID: "eng-agent", Version: 101, Origin: "eng-agent", DeletedAt: time.Now()}
+	if applied := pm.Merge(Delta{Tombstones: []Tombstone{own}}, "eng-agent"); applied != 1 {
+		t.Errorf("Expected the agent's own tombstone to apply, applied %d", applied)
+	}
+	if _, err := pm.GetAgent("eng-agent"); err == nil {
+		t.Error("Expected the agent to be removed by its own tombstone")
+	}
+}
+
+func TestAgentRegistry_MergeCapsVersions(t *testing.T) {
+	pm := newNode("pm-agent")
+	huge := &aoi.AgentIdentity{ID: "qa-agent", Version: math.MaxUint64, Origin: "qa-agent"}
+	tomb := Tombstone{ID: "ops-agent", Version: math.MaxUint64, Origin: "ops-agent", DeletedAt: time.Now()}
+	if applied := pm.Merge(Delta{Agents: []*aoi.AgentIdentity{huge}, Tombstones: []Tombstone{tomb}}, "qa-agent"); applied != 0 {
+		t.Errorf("Expected out-of-range versions to be ignored, applied %d", applied)
+	}
+
+	// The clock was not wound up, so new versions still increase
+	agent := &aoi.AgentIdentity{ID: "eng-agent"}
+	pm.Register(agent)
+	pm.UpdateStatus("eng-agent", "busy")
+	if agent.Version != 2 {
+		t.Errorf("Expected version 2, got %d", agent.Version)
+	}
+}
+
+func TestAgentRegistry_MergeOnlyTrustedEntries(t *testing.T) {
+	pm := newNode("pm-agent")
+	pm.Register(&aoi.AgentIdentity{ID: "eng-agent", Role: aoi.RoleEngineer, Endpoint: "http://eng:8080", Status: StatusOnline})
+	merge := func(from string, agent *aoi.AgentIdentity) int {
+		return pm.Merge(Delta{Agents: []*aoi.AgentIdentity{agent}}, from)
+	}
+
+	// A peer cannot rewrite another agent or invent one
+	forged := &aoi.AgentIdentity{ID: "eng-agent", Role: aoi.RolePM, Endpoint: "http://qa:8080", TailscaleNodeID: "qa-node", Version: 100, Origin: "qa-agent"}
+	if applied := merge("qa-agent", forged); applied != 0 {
+		t.Errorf("Expected a rewrite by another peer to be ignored, applied %d", applied)
+	}
+	if applied := merge("qa-agent", &aoi.AgentIdentity{ID: "rogue", Role: aoi.RolePM, Version: 100, Origin: "qa-agent"}); applied != 0 {
+		t.Errorf("Expected an unsigned third-party agent to be ignored, applied %d", applied)
+	}
+
+	// A peer can describe itself, but only when authenticated
+	self := &aoi.AgentIdentity{ID: "qa-agent", Role: aoi.RoleQA, Version: 5, Origin: "qa-agent"}
+	if applied := merge("", self); applied != 0 {
+		t.Errorf("Expected an unauthenticated self-description to be ignored, applied %d", applied)
+	}
+	if applied := merge("qa-agent", self); applied != 1 {
+		t.Errorf("Expected the peer's own entry to apply, applied %d", applied)
+	}
+
+	// A signed entry is taken from any relay, but keeps the known role
+	_, key, _ := ed25519.GenerateKey(nil)
+	relayed := signed(t, &aoi.AgentIdentity{ID: "eng-agent", Role: aoi.RolePM, Endpoint: "http://eng2:8080", Version: 101, Origin: "eng-agent"}, key)
+	tampered := *relayed
+	tampered.Endpoint = "http://qa:8080"
+	if applied := merge("qa-agent", &tampered); applied != 0 {
+		t.Errorf("Expected a tampered signed entry to be ignored, applied %d", applied)
+	}
+	if applied := merge("qa-agent", relayed); applied != 1 {
+		t.Fatalf("Expected the signed entry to apply, applied %d", applied)
+	}
+	agent, _ := pm.GetAgent("eng-agent")
+	if agent.Endpoint != "http://eng2:8080" || agent.Role != aoi.RoleEngineer {
+		t.Errorf("Expected the new endpoint and the old role, got %+v", agent)
+	}
+
+	// The lease holder reports liveness, and nothing else
+	offline := &aoi.AgentIdentity{ID: "eng-agent", Endpoint: "http://qa:8080", Status: StatusOffline, LeaseHolder: "qa-agent", Version: 102, Origin: "qa-agent"}
+	if applied := merge("qa-agent", offline); applied != 1 {
+		t.Fatalf("Expected the lease holder's status to apply, applied %d", applied)
+	}
+	agent, _ = pm.GetAgent("eng-agent")
+	if agent.Status != StatusOffline || agent.Endpoint != "http://eng2:8080" {
+		t.Errorf("Expected only the status to change, got %+v", agent)
+	}
+}
+
+func TestAgentRegistry_MergeNeverOverwritesSelf(t *testing.T) {
+	pm := newNode("pm-agent")
+	pm.Register(&aoi.AgentIdentity{ID: "pm-agent", Endpoint: "http://pm:8080"})
+
+	// A peer holds a higher version from before pm-agent restarted
+	old := &aoi.AgentIdentity{ID: "pm-agent", Endpoint: "http://pm-old:8080", Version: 50, Origin: "pm-agent"}
+	pm.Merge(Delta{Agents: []*aoi.AgentIdentity{old}}, "pm-agent")
+
+	self, _ := pm.GetAgent("pm-agent")
+	if self.Endpoint != "http://pm:8080" {
+		t.Errorf("Expected own entry to be kept, got %s", self.Endpoint)
+	}
+	if self.Version <= 50 {
+		t.Errorf("Expected own entry re-stamped above 50, got %d", self.Version)
+	}
+}
+
+func TestAgentRegistry_SweepOnlyOwnLeases(t *testing.T) {
+	pm, eng := newNode("pm-agent"), newNode("eng-agent")
+	eng.Register(&aoi.AgentIdentity{ID: "qa-agent", Status: StatusOffline})
+	pm.Register(&aoi.AgentIdentity{ID: "qa-agent", Status: StatusOffline})
+	eng.Heartbeat("qa-agent", time.Minute) // eng holds the lease
+	exchange(pm, eng)
+
+	agent, _ := pm.GetAgent("qa-agent")
+	if agent.LeaseHolder != "eng-agent" || agent.Status != StatusOnline {
+		t.Fatalf("Expected lease held by eng-agent, got %+v", agent)
+	}
+
+	later := time.Now().Add(2 * time.Minute)
+	if swept := pm.SweepExpired(later); len(swept) != 0 {
+		t.Errorf("Expected pm-agent to leave eng-agent's lease alone, swept %d", len(swept))
+	}
+	if swept := eng.SweepExpired(later); len(swept) != 1 {
+		t.Fatalf("Expected eng-agent to expire its lease, swept %d", len(swept))
+	}
+
+	// The offline transition reaches pm-agent by gossip
+	exchange(pm, eng)
+	if agent, _ := pm.GetAgent("qa-agent"); agent.Status != StatusOffline {
+		t.Errorf("Expected qa-agent offline on pm-agent, got %s", agent.Status)
+	}
+}
+
+func TestAgentRegistry_PruneTombstones(t *testing.T) {
+	r := newNode("pm-agent")
+	r.Register(&aoi.AgentIdentity{ID: "qa-agent"})
+	r.Unregister("qa-agent")
+
+	if n := r.PruneTombstones(time.Now().Add(-time.Hour)); n != 0 {
+		t.Errorf("Expected a fresh tombstone to be kept, pruned %d", n)
+	}
+	if n := r.PruneTombstones(time.Now().Add(time.Second)); n != 1 {
+		t.Errorf("Expected the tombstone to be pruned, pruned %d", n)
+	}
+	if _, ok := r.Digest()["qa-agent"]; ok {
+		t.Error("Expected pruned tombstone to leave the digest")
+	}
+}
@@ -1,55 +1,168 @@
 package identity
 
 import (
+	"crypto/ed25519"
 	"errors"
+	"fmt"
 	"sync"
+	"time"
 
 	"github.com/aoi-protocol/aoi/pkg/aoi"
 )
 
+// ErrAgentNotFound is returned for an agent ID that is not registered
+var ErrAgentNotFound = errors.New("agent not found")
+
+// ErrKeyMismatch is returned when a registration would replace an agent's public key
+var ErrKeyMismatch = errors.New("agent is registered with a different public key")
+
+// ErrNotOwner is returned when a caller changes an agent that is not its own
+var ErrNotOwner = errors.New("agent is registered by another caller")
+
 // AgentRegistry manages registered agents
 type AgentRegistry struct {
-	agents map[string]*aoi.AgentIdentity
-	mu     sync.RWMutex
+	agents     map[string]*aoi.AgentIdentity
+	tombstones map[string]Tombstone
+	store      Storage
+	nodeID     string
+	nodeKey    ed25519.PrivateKey
+	clock      uint64
+	leaseTTL   time.Duration
+	onChange   []func(StatusChange)
+	stopOnce   sync.Once
+	stop       chan struct{}
+	mu         sync.RWMutex
 }
 
 // NewAgentRegistry creates a new agent registry
 func NewAgentRegistry() *AgentRegistry {
 	return &AgentRegistry{
-		agents: make(map[string]*aoi.AgentIdentity),
+		agents:     make(map[string]*aoi.AgentIdentity),
+		tombstones: make(map[string]Tombstone),
+		leaseTTL:   DefaultLeaseTTL,
+		stop:       make(chan struct{}),
+	}
+}
+
+// NewAgentRegistryWithStorage creates a registry that writes every change
+// through to store, starting with the agents already stored
+func NewAgentRegistryWithStorage(store Storage) (*AgentRegistry, error) {
+	agents, err := store.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load agents: %w", err)
+	}
+
+	r := NewAgentRegistry()
+	r.store = store
+	for _, agent := range agents {
+		r.agents[agent.ID] = agent
+		if agent.Version > r.clock {
+			r.clock = agent.Version
+		}
+	}
+	return r, nil
+}
+
+// Close stops the lease sweeper and closes the registry's storage
+func (r *AgentRegistry) Close() error {
+	r.stopOnce.Do(func() { close(r.stop) })
+	if r.store == nil {
+		return nil
+	}
+	return r.store.Close()
+}
+
+// persist writes an agent to storage. Caller must hold r.mu.
+func (r *AgentRegistry) persist(agent *aoi.AgentIdentity) error {
+	if r.store == nil {
+		return nil
 	}
+	return r.store.Save(agent)
 }
 
-// Register adds an agent to the registry
+// Register adds an agent to the registry. An agent's public key is pinned by
+// its first registration: re-registering keeps it, and a different key is
+// refused with ErrKeyMismatch unless the agent is this node itself.
 func (r *AgentRegistry) Register(agent *aoi.AgentIdentity) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
+	return r.register(agent, nil)
+}
 
+// RegisterAs registers an agent on behalf of caller. An existing agent can
+// only be changed by itself or an admin, and only an admin can change its
+// role or Tailscale node.
+func (r *AgentRegistry) RegisterAs(agent *aoi.AgentIdentity, caller string, admin bool) error {
+	return r.register(agent, func(existing *aoi.AgentIdentity) error {
+		if admin {
+			return nil
+		}
+		if caller == "" || caller != existing.ID {
+			return ErrNotOwner
+		}
+		agent.Role = existing.Role
+		agent.TailscaleNodeID = existing.TailscaleNodeID
+		return nil
+	})
+}
+
+// register adds or replaces an agent; update, when set, vets the replacement
+// of an existing entry
+func (r *AgentRegistry) register(agent *aoi.AgentIdentity, update func(existing *aoi.AgentIdentity) error) error {
+	r.mu.Lock()
+	previous := ""
+	if existing, exists := r.agents[agent.ID]; exists {
+		previous = existing.Status
+		if update != nil {
+			if err := update(existing); err != nil {
+				r.mu.Unlock()
+				return err
+			}
+		}
+		if existing.PublicKey != "" {
+			if agent.PublicKey == "" {
+				agent.PublicKey = existing.PublicKey
+			} else if agent.PublicKey != existing.PublicKey && agent.ID != r.nodeID {
+				r.mu.Unlock()
+				return ErrKeyMismatch
+			}
+		}
+	}
+	r.stamp(agent)
+	if err := r.persist(agent); err != nil {
+		r.mu.Unlock()
+		return err
+	}
 	r.agents[agent.ID] = agent
+	delete(r.tombstones, agent.ID)
+	change := StatusChange{Agent: snapshot(agent), Previous: previous}
+	r.mu.Unlock()
+
+	if previous != agent.Status {
+		r.notify(change)
+	}
 	return nil
 }
 
-// GetAgent retrieves an agent by ID
+// GetAgent retrieves a copy of an agent by ID
 func (r *AgentRegistry) GetAgent(id string) (*aoi.AgentIdentity, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
 	agent, exists := r.agents[id]
 	if !exists {
-		return nil, errors.New("agent not found")
+		return nil, ErrAgentNotFound
 	}
 
-	return agent, nil
+	return snapshot(agent), nil
 }
 
-// Discover returns all registered agents
+// Discover returns copies of all registered agents
 func (r *AgentRegistry) Discover() []*aoi.AgentIdentity {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
 	agents := make([]*aoi.AgentIdentity, 0, len(r.agents))
 	for _, agent := range r.agents {
-		agents = append(agents, agent)
+		agents = append(agents, snapshot(agent))
 	}
 
 	return agents
@@ -58,15 +171,25 @@ func (r *AgentRegistry) Discover() []*aoi.AgentIdentity {
 // UpdateStatus updates an agent's status
 func (r *AgentRegistry) UpdateStatus(id string, status string) error {
 	r.mu.Lock()
-	defer r.mu.Unlock()
-
 	agent, exists := r.agents[id]
 	if !exists {
-		return errors.New("agent not found")
+		r.mu.Unlock()
+		return ErrAgentNotFound
 	}
 
+	previous := agent.Status
 	agent.Status = status
-	return nil
+	if previous != status {
+		r.stamp(agent)
+	}
+	err := r.persist(agent)
+	change := StatusChange{Agent: snapshot(agent), Previous: previous}
+	r.mu.Unlock()
+
+	if previous != status {
+		r.notify(change)
+	}
+	return err
 }
 
 // GetAgentByTailscaleNodeID retrieves an agent by its Tailscale node ID
@@ -76,7 +199,7 @@ func (r *AgentRegistry) GetAgentByTailscaleNodeID(nodeID string) (*aoi.AgentIden
 
 	for _, agent := range r.agents {
 		if agent.TailscaleNodeID == nodeID {
-			return agent, nil
+			return snapshot(agent), nil
 		}
 	}
 
@@ -90,11 +213,12 @@ func (r *AgentRegistry) UpdateTailscaleNodeID(id string, nodeID string) error {
 
 	agent, exists := r.agents[id]
 	if !exists {
-		return errors.New("agent not found")
+		return ErrAgentNotFound
 	}
 
 	agent.TailscaleNodeID = nodeID
-	return nil
+	r.stamp(agent)
+	return r.persist(agent)
 }
 
 // Unregister removes an agent from the registry
@@ -103,9 +227,18 @@ func (r *AgentRegistry) Unregister(id string) error {
 	defer r.mu.Unlock()
 
 	if _, exists := r.agents[id]; !exists {
-		return errors.New("agent not found")
+		return ErrAgentNotFound
 	}
 
+	if r.store != nil {
+		if err := r.store.Delete(id); err != nil {
+			return err
+		}
+	}
 	delete(r.agents, id)
+
+	// Peers learn of the removal from the tombstone instead of re-adding the agent
+	r.clock++
+	r.tombstones[id] = Tombstone{ID: id, Version: r.clock, Origin: r.nodeID, DeletedAt: time.Now()}
 	return nil
 }
@@ -358,3 +358,70 @@ func TestAgentRegistry_ConcurrentDiscover(t *testing.T) {
 
 	wg.Wait()
 }
+
+func TestAgentRegistry_PinsPublicKey(t *testing.T) {
+	registry := NewAgentRegistry()
+	registry.SetNodeID("pm-agent")
+	registry.Register(&aoi.AgentIdentity{ID: "eng-agent", PublicKey: "key-1"})
+
+	// Re-registering without a key keeps the pinned one
+	agent := &aoi.AgentIdentity{ID: "eng-agent", Endpoint: "http://eng:8080"}
+	if err := registry.Register(agent); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if agent.PublicKey != "key-1" {
+		t.Errorf("Expected pinned key to be kept, got %q", agent.PublicKey)
+	}
+
+	if err := registry.Register(&aoi.AgentIdentity{ID: "eng-agent", PublicKey: "key-2"}); err != ErrKeyMismatch {
+		t.Errorf("Expected ErrKeyMismatch, got %v", err)
+	}
+
+	// This node may replace its own key
+	registry.Register(&aoi.AgentIdentity{ID: "pm-agent", PublicKey: "key-1"})
+	if err := registry.Register(&aoi.AgentIdentity{ID: "pm-agent", PublicKey: "key-2"}); err != nil {
+		t.Errorf("Expected own key to be replaceable, got %v", err)
+	}
+
+	// Gossip cannot replace a pinned key either
+	forged := &aoi.AgentIdentity{ID: "eng-agent", PublicKey: "key-3", Version: 100, Origin: "qa-agent"}
+	if applied := registry.Merge(Delta{Agents: []*aoi.AgentIdentity{forged}}, "qa-agent"); applied != 0 {
+		t.Errorf("Expected forged key to be ignored, applied %d", applied)
+	}
+}
+
+func TestAgentRegistry_RegisterAs(t *testing.T) {
+	registry := NewAgentRegistry()
+	registry.Register(&aoi.AgentIdentity{ID: "eng-agent", Role: aoi.RoleEngineer, TailscaleNodeID: "node-1"})
+
+	takeover := &aoi.AgentIdentity{ID: "eng-agent", Role: aoi.RolePM, Endpoint: "http://evil:8080"}
+	if err := registry.RegisterAs(takeover, "qa-agent", false); err != ErrNotOwner {
+		t.Errorf("Expected ErrNotOwner for another caller, got %v", err)
+	}
+	if err := registry.RegisterAs(takeover, "", false); err != ErrNotOwner {
+		t.Errorf("Expected ErrNotOwner for an anonymous caller, got %v", err)
+	}
+
+	// The agent itself can update its entry but not its role or node
+	update := &aoi.AgentIdentity{ID: "eng-agent", Role: aoi.RolePM, TailscaleNodeID: "node-2", Endpoint: "http://eng:8080"}
+	if err := registry.RegisterAs(update, "eng-agent", false); err != nil {
+		t.Fatalf("Expected the agent to update itself, got %v", err)
+	}
+	agent, _ := registry.GetAgent("eng-agent")
+	if agent.Role != aoi.RoleEngineer || agent.TailscaleNodeID != "node-1" || agent.Endpoint != "http://eng:8080" {
+		t.Errorf("Expected role and node to be kept, got %+v", agent)
+	}
+
+	// An admin can change anything
+	if err := registry.RegisterAs(&aoi.AgentIdentity{ID: "eng-agent", Role: aoi.RolePM}, "pm-agent", true); err != nil {
+		t.Fatalf("Expected an admin to update the agent, got %v", err)
+	}
+	if agent, _ := registry.GetAgent("eng-agent"); agent.Role != aoi.RolePM {
+		t.Errorf("Expected the admin to change the role, got %s", agent.Role)
+	}
+
+	// Anyone can register a new agent
+	if err := registry.RegisterAs(&aoi.AgentIdentity{ID: "new-agent"}, "", false); err != nil {
+		t.Errorf("Expected a new agent to register, got %v", err)
+	}
+}
@@ -0,0 +1,156 @@
+package identity
+
+import (
+	"time"
+
+	"github.com/aoi-protocol/aoi/pkg/aoi"
+)
+
+// Agent statuses set by heartbeats and the lease sweeper
+const (
+	StatusOnline  = "online"
+	StatusOffline = "offline"
+)
+
+// Lease defaults
+const (
+	// DefaultLeaseTTL is how long a heartbeat keeps an agent online
+	DefaultLeaseTTL = 30 * time.Second
+	// MaxLeaseTTL caps the TTL an agent may ask for
+	MaxLeaseTTL = 10 * time.Minute
+	// DefaultSweepInterval is how often expired leases are checked
+	DefaultSweepInterval = 5 * time.Second
+)
+
+// StatusChange describes an agent's status transition
+type StatusChange struct {
+	// Agent is a copy of the agent after the change
+	Agent *aoi.AgentIdentity
+	// Previous is the status before the change; empty for a new agent
+	Previous string
+}
+
+// snapshot copies an agent so callers and callbacks can read it without holding
+// the registry lock
+func snapshot(agent *aoi.AgentIdentity) *aoi.AgentIdentity {
+	c := *agent
+	return &c
+}
+
+// OnStatusChange registers a callback invoked after an agent's status changes,
+// whether by registration, UpdateStatus, a heartbeat or lease expiry
+func (r *AgentRegistry) OnStatusChange(fn func(StatusChange)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onChange = append(r.onChange, fn)
+}
+
+func (r *AgentRegistry) notify(change StatusChange) {
+	r.mu.RLock()
+	callbacks := r.onChange
+	r.mu.RUnlock()
+
+	for _, fn := range callbacks {
+		fn(change)
+	}
+}
+
+// SetLeaseTTL sets the lease granted by heartbeats that do not ask for one
+func (r *AgentRegistry) SetLeaseTTL(ttl time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if ttl > 0 {
+		r.leaseTTL = ttl
+	}
+}
+
+// Heartbeat records that an agent is alive and extends its lease by ttl
+// (the registry default if zero, capped at MaxLeaseTTL). An offline agent
+// comes back online; other statuses such as "busy" are kept.
+func (r *AgentRegistry) Heartbeat(id string, ttl time.Duration) (*aoi.AgentIdentity, error) {
+	r.mu.Lock()
+	agent, exists := r.agents[id]
+	if !exists {
+		r.mu.Unlock()
+		return nil, ErrAgentNotFound
+	}
+
+	if ttl <= 0 {
+		ttl = r.leaseTTL
+	}
+	if ttl > MaxLeaseTTL {
+		ttl = MaxLeaseTTL
+	}
+	now := time.Now()
+	expires := now.Add(ttl)
+
+	previous := agent.Status
+	if previous == "" || previous == StatusOffline {
+		agent.Status = StatusOnline
+	}
+	// Renewals stay local; peers only need to hear about transitions and a new lease holder
+	if agent.Status != previous || agent.LeaseHolder != r.nodeID {
+		agent.LeaseHolder = r.nodeID
+		r.stamp(agent)
+	}
+	agent.LastSeen = &now
+	agent.LeaseExpiresAt = &expires
+	err := r.persist(agent)
+	change := StatusChange{Agent: snapshot(agent), Previous: previous}
+	r.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+	if previous != change.Agent.Status {
+		r.notify(change)
+	}
+	return change.Agent, nil
+}
+
+// SweepExpired marks agents whose lease expired before now as offline and
+// returns the resulting transitions. Leases granted by other nodes are left
+// to them, since this node does not see those heartbeats.
+func (r *AgentRegistry) SweepExpired(now time.Time) []StatusChange {
+	r.mu.Lock()
+	var changes []StatusChange
+	for _, agent := range r.agents {
+		if agent.LeaseExpiresAt == nil || agent.Status == StatusOffline || now.Before(*agent.LeaseExpiresAt) {
+			continue
+		}
+		if agent.LeaseHolder != r.nodeID {
+			continue
+		}
+		previous := agent.Status
+		agent.Status = StatusOffline
+		r.stamp(agent)
+		_ = r.persist(agent)
+		changes = append(changes, StatusChange{Agent: snapshot(agent), Previous: previous})
+	}
+	r.mu.Unlock()
+
+	for _, change := range changes {
+		r.notify(change)
+	}
+	return changes
+}
+
+// StartLeaseSweeper checks for expired leases every interval until Close
+func (r *AgentRegistry) StartLeaseSweeper(interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultSweepInterval
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				r.SweepExpired(now)
+			case <-r.stop:
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,225 @@
+package identity
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aoi-protocol/aoi/pkg/aoi"
+)
+
+// recordChanges collects the status changes reported by registry
+func recordChanges(registry *AgentRegistry) func() []StatusChange {
+	var mu sync.Mutex
+	var changes []StatusChange
+	registry.OnStatusChange(func(c StatusChange) {
+		mu.Lock()
+		defer mu.Unlock()
+		changes = append(changes, c)
+	})
+	return func() []StatusChange {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]StatusChange(nil), changes...)
+	}
+}
+
+func TestAgentRegistry_HeartbeatBringsAgentOnline(t *testing.T) {
+	registry := NewAgentRegistry()
+	registry.Register(&aoi.AgentIdentity{ID: "eng-agent", Status: StatusOffline})
+	changes := recordChanges(registry)
+
+	before := time.Now()
+	agent, err := registry.Heartbeat("eng-agent", time.Minute)
+	if err != nil {
+		t.Fatalf("Heartbeat failed: %v", err)
+	}
+
+	if agent.Status != StatusOnline {
+		t.Errorf("Expected status online, got %s", agent.Status)
+	}
+	if agent.LastSeen == nil || agent.LastSeen.Before(before) {
+		t.Errorf("Expected LastSeen to be set, got %v", agent.LastSeen)
+	}
+	if agent.LeaseExpiresAt == nil || agent.LeaseExpiresAt.Sub(*agent.LastSeen) != time.Minute {
+		t.Errorf("Expected a one minute lease, got %v", agent.LeaseExpiresAt)
+	}
+
+	got := changes()
+	if len(got) != 1 || got[0].Previous != StatusOffline || got[0].Agent.Status != StatusOnline {
+		t.Errorf("Expected one offline -> online change, got %+v", got)
+	}
+
+	// Renewing an online agent's lease is not a transition
+	registry.Heartbeat("eng-agent", 0)
+	if len(changes()) != 1 {
+		t.Errorf("Expected no change for a renewal, got %d changes", len(changes()))
+	}
+}
+
+func TestAgentRegistry_HeartbeatKeepsBusyStatus(t *testing.T) {
+	registry := NewAgentRegistry()
+	registry.Register(&aoi.AgentIdentity{ID: "eng-agent", Status: "busy"})
+
+	agent, err := registry.Heartbeat("eng-agent", 0)
+	if err != nil {
+		t.Fatalf("Heartbeat failed: %v", err)
+	}
+	if agent.Status != "busy" {
+		t.Errorf("Expected status busy to be kept, got %s", agent.Status)
+	}
+}
+
+func TestAgentRegistry_HeartbeatTTL(t *testing.T) {
+	registry := NewAgentRegistry()
+	registry.Register(&aoi.AgentIdentity{ID: "eng-agent"})
+	registry.SetLeaseTTL(time.Second)
+
+	agent, _ := registry.Heartbeat("eng-agent", 0)
+	if d := agent.LeaseExpiresAt.Sub(*agent.LastSeen); d != time.Second {
+		t.Errorf("Expected default lease of 1s, got %v", d)
+	}
+
+	agent, _ = registry.Heartbeat("eng-agent", 24*time.Hour)
+	if d := agent.LeaseExpiresAt.Sub(*agent.LastSeen); d != MaxLeaseTTL {
+		t.Errorf("Expected lease capped at %v, got %v", MaxLeaseTTL, d)
+	}
+}
+
+func TestAgentRegistry_HeartbeatUnknownAgent(t *testing.T) {
+	registry := NewAgentRegistry()
+
+	if _, err := registry.Heartbeat("ghost", 0); !errors.Is(err, ErrAgentNotFound) {
+		t.Errorf("Expected ErrAgentNotFound, got %v", err)
+	}
+}
+
+func TestAgentRegistry_SweepExpired(t *testing.T) {
+	registry := NewAgentRegistry()
+	registry.Register(&aoi.AgentIdentity{ID: "eng-agent", Status: StatusOnline})
+	registry.Register(&aoi.AgentIdentity{ID: "qa-agent", Status: StatusOnline})
+	registry.Register(&aoi.AgentIdentity{ID: "pm-agent", Status: StatusOnline}) // no lease
+	registry.Heartbeat("eng-agent", time.Minute)
+	registry.Heartbeat("qa-agent", time.Hour)
+	changes := recordChanges(registry)
+
+	swept := registry.SweepExpired(time.Now().Add(2 * time.Minute))
+	if len(swept) != 1 || swept[0].Agent.ID != "eng-agent" {
+		t.Fatalf("Expected only eng-agent to expire, got %+v", swept)
+	}
+
+	eng, _ := registry.GetAgent("eng-agent")
+	if eng.Status != StatusOffline {
+		t.Errorf("Expected eng-agent offline, got %s", eng.Status)
+	}
+	for _, id := range []string{"qa-agent", "pm-agent"} {
+		if agent, _ := registry.GetAgent(id); agent.Status != StatusOnline {
+			t.Errorf("Expected %s to stay online, got %s", id, agent.Status)
+		}
+	}
+
+	got := changes()
+	if len(got) != 1 || got[0].Previous != StatusOnline || got[0].Agent.Status != StatusOffline {
+		t.Errorf("Expected one online -> offline change, got %+v", got)
+	}
+
+	// An offline agent is not reported again
+	if swept := registry.SweepExpired(time.Now().Add(2 * time.Minute)); len(swept) != 0 {
+		t.Errorf("Expected no further transitions, got %+v", swept)
+	}
+}
+
+func TestAgentRegistry_StatusChangeOnRegisterAndUpdate(t *testing.T) {
+	registry := NewAgentRegistry()
+	changes := recordChanges(registry)
+
+	registry.Register(&aoi.AgentIdentity{ID: "eng-agent", Status: StatusOnline})
+	registry.UpdateStatus("eng-agent", StatusOnline) // unchanged
+	registry.UpdateStatus("eng-agent", "busy")
+
+	got := changes()
+	if len(got) != 2 {
+		t.Fatalf("Expected 2 changes, got %+v", got)
+	}
+	if got[0].Previous != "" || got[0].Agent.Status != StatusOnline {
+		t.Errorf("Expected registration as online, got %+v", got[0])
+	}
+	if got[1].Previous != StatusOnline || got[1].Agent.Status != "busy" {
+		t.Errorf("Expected online -> busy, got %+v", got[1])
+	}
+}
+
+func TestAgentRegistry_LeaseSweeper(t *testing.T) {
+	registry := NewAgentRegistry()
+	defer registry.Close()
+	registry.Register(&aoi.AgentIdentity{ID: "eng-agent", Status: StatusOnline})
+
+	offline := make(chan StatusChange, 1)
+	registry.OnStatusChange(func(c StatusChange) {
+		if c.Agent.Status == StatusOffline {
+			offline <- c
+		}
+	})
+
+	registry.SetLeaseTTL(20 * time.Millisecond)
+	registry.Heartbeat("eng-agent", 0)
+	registry.StartLeaseSweeper(10 * time.Millisecond)
+
+	select {
+	case c := <-offline:
+		if c.Agent.ID != "eng-agent" {
+			t.Errorf("Expected eng-agent to go offline, got %s", c.Agent.ID)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the sweeper to mark eng-agent offline")
+	}
+}
+
+func TestFileStorage_LeaseSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+
+	store, _ := NewFileStorage(dir)
+	registry, _ := NewAgentRegistryWithStorage(store)
+	registry.Register(&aoi.AgentIdentity{ID: "eng-agent"})
+	registry.Heartbeat("eng-agent", time.Minute)
+	registry.Close()
+
+	store, _ = NewFileStorage(dir)
+	registry, _ = NewAgentRegistryWithStorage(store)
+	defer registry.Close()
+
+	agent, err := registry.GetAgent("eng-agent")
+	if err != nil {
+		t.Fatalf("GetAgent failed: %v", err)
+	}
+	if agent.LastSeen == nil || agent.LeaseExpiresAt == nil || agent.Status != StatusOnline {
+		t.Errorf("Expected lease to survive restart, got %+v", agent)
+	}
+}
+
+func TestAgentRegistry_HeartbeatDuringReads(t *testing.T) {
+	r := NewAgentRegistry()
+	r.Register(&aoi.AgentIdentity{ID: "eng-agent", Status: StatusOnline})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			r.Heartbeat("eng-agent", time.Minute)
+		}
+	}()
+
+	// Read the lease fields of what the accessors return while it is renewed
+	for i := 0; i < 200; i++ {
+		agent, _ := r.GetAgent("eng-agent")
+		_, _ = agent.LastSeen, agent.LeaseExpiresAt
+		for _, agent := range r.Discover() {
+			_, _ = agent.LastSeen, agent.LeaseExpiresAt
+		}
+		for _, agent := range r.DiscoverMatching(DiscoverFilter{}) {
+			_, _ = agent.LastSeen, agent.LeaseExpiresAt
+		}
+	}
+	<-done
+}
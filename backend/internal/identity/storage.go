@@ -0,0 +1,386 @@
+package identity
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/aoi-protocol/aoi/pkg/aoi"
+)
+
+// Storage kinds selectable in config
+const (
+	StorageMemory = "memory"
+	StorageFile   = "file"
+)
+
+// File names used by FileStorage
+const (
+	snapshotFile = "agents.json"
+	journalFile  = "agents.journal"
+)
+
+// DefaultCompactEvery is how many journal entries FileStorage accumulates before
+// folding them into the snapshot
+const DefaultCompactEvery = 100
+
+// Storage persists the agents held by an AgentRegistry
+type Storage interface {
+	// Load returns all stored agents
+	Load() ([]*aoi.AgentIdentity, error)
+	// Save stores or replaces an agent
+	Save(agent *aoi.AgentIdentity) error
+	// Delete removes an agent; deleting an unknown ID is not an error
+	Delete(id string) error
+	// Close flushes and releases the storage
+	Close() error
+}
+
+// OpenStorage opens the storage of the given kind. path is the directory used
+// by file storage.
+func OpenStorage(kind, path string) (Storage, error) {
+	switch kind {
+	case "", StorageMemory:
+		return NewMemoryStorage(), nil
+	case StorageFile:
+		return NewFileStorage(path)
+	default:
+		return nil, fmt.Errorf("unknown registry storage %q", kind)
+	}
+}
+
+// cloneAgent copies an agent so stored state is not changed through the caller's pointer
+func cloneAgent(agent *aoi.AgentIdentity) (*aoi.AgentIdentity, error) {
+	data, err := json.Marshal(agent)
+	if err != nil {
+		return nil, err
+	}
+	var clone aoi.AgentIdentity
+	if err := json.Unmarshal(data, &clone); err != nil {
+		return nil, err
+	}
+	return &clone, nil
+}
+
+// MemoryStorage keeps agents in memory; nothing survives a restart
+type MemoryStorage struct {
+	agents map[string]*aoi.AgentIdentity
+	mu     sync.RWMutex
+}
+
+// NewMemoryStorage creates an empty in-memory storage
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		agents: make(map[string]*aoi.AgentIdentity),
+	}
+}
+
+// Load returns all stored agents
+func (s *MemoryStorage) Load() ([]*aoi.AgentIdentity, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	agents := make([]*aoi.AgentIdentity, 0, len(s.agents))
+	for _, agent := range s.agents {
+		clone, err := cloneAgent(agent)
+		if err != nil {
+			return nil, err
+		}
+		agents = append(agents, clone)
+	}
+	return agents, nil
+}
+
+// Save stores or replaces an agent
+func (s *MemoryStorage) Save(agent *aoi.AgentIdentity) error {
+	clone, err := cloneAgent(agent)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.agents[agent.ID] = clone
+	return nil
+}
+
+// Delete removes an agent
+func (s *MemoryStorage) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.agents, id)
+	return nil
+}
+
+// Close is a no-op for memory storage
+func (s *MemoryStorage) Close() error {
+	return nil
+}
+
+// journalEntry is one line of the FileStorage journal
+type journalEntry struct {
+	Op    string             `json:"op"` // "put" or "delete"
+	ID    string             `json:"id"`
+	Agent *aoi.AgentIdentity `json:"agent,omitempty"`
+}
+
+// FileStorage persists agents in a directory as a snapshot (agents.json) plus
+// an append-only journal (agents.journal). Every change is appended and synced
+// to the journal; the journal is periodically folded into the snapshot, which
+// is replaced atomically so a crash never leaves a partial file behind.
+type FileStorage struct {
+	dir          string
+	agents       map[string]*aoi.AgentIdentity
+	journal      *os.File
+	entries      int
+	compactEvery int
+	mu           sync.Mutex
+}
+
+// NewFileStorage opens (or creates) file storage in dir, replaying any journal
+// left by a previous run
+func NewFileStorage(dir string) (*FileStorage, error) {
+	if dir == "" {
+		return nil, errors.New("file storage requires a directory")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	s := &FileStorage{
+		dir:          dir,
+		agents:       make(map[string]*aoi.AgentIdentity),
+		compactEvery: DefaultCompactEvery,
+	}
+	if err := s.loadSnapshot(); err != nil {
+		return nil, err
+	}
+	if err := s.replayJournal(); err != nil {
+		return nil, err
+	}
+
+	// Start from a clean snapshot so the journal only holds this run's changes
+	if err := s.compactLocked(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// SetCompactEvery sets how many journal entries trigger a compaction
+func (s *FileStorage) SetCompactEvery(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if n > 0 {
+		s.compactEvery = n
+	}
+}
+
+// Load returns all stored agents
+func (s *FileStorage) Load() ([]*aoi.AgentIdentity, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	agents := make([]*aoi.AgentIdentity, 0, len(s.agents))
+	for _, agent := range s.agents {
+		clone, err := cloneAgent(agent)
+		if err != nil {
+			return nil, err
+		}
+		agents = append(agents, clone)
+	}
+	return agents, nil
+}
+
+// Save stores or replaces an agent
+func (s *FileStorage) Save(agent *aoi.AgentIdentity) error {
+	clone, err := cloneAgent(agent)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.appendLocked(journalEntry{Op: "put", ID: agent.ID, Agent: clone}); err != nil {
+		return err
+	}
+	s.agents[agent.ID] = clone
+	return s.maybeCompactLocked()
+}
+
+// Delete removes an agent
+func (s *FileStorage) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.agents[id]; !exists {
+		return nil
+	}
+	if err := s.appendLocked(journalEntry{Op: "delete", ID: id}); err != nil {
+		return err
+	}
+	delete(s.agents, id)
+	return s.maybeCompactLocked()
+}
+
+// Close folds the journal into the snapshot and closes the journal file
+func (s *FileStorage) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.journal == nil {
+		return nil
+	}
+	err := s.compactLocked()
+	if cerr := s.journal.Close(); err == nil {
+		err = cerr
+	}
+	s.journal = nil
+	return err
+}
+
+func (s *FileStorage) loadSnapshot() error {
+	data, err := os.ReadFile(filepath.Join(s.dir, snapshotFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read registry snapshot: %w", err)
+	}
+
+	var agents []*aoi.AgentIdentity
+	if err := json.Unmarshal(data, &agents); err != nil {
+		return fmt.Errorf("failed to parse registry snapshot: %w", err)
+	}
+	for _, agent := range agents {
+		s.agents[agent.ID] = agent
+	}
+	return nil
+}
+
+// replayJournal applies journal entries on top of the snapshot. A torn last
+// line (a crash mid-append) is ignored; corruption anywhere else is an error.
+func (s *FileStorage) replayJournal() error {
+	data, err := os.ReadFile(filepath.Join(s.dir, journalFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read registry journal: %w", err)
+	}
+
+	lines := bytes.Split(data, []byte("\n"))
+	for i, line := range lines {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var entry journalEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			if i == len(lines)-1 {
+				break
+			}
+			return fmt.Errorf("corrupt registry journal at line %d: %w", i+1, err)
+		}
+		switch entry.Op {
+		case "put":
+			if entry.Agent != nil {
+				s.agents[entry.ID] = entry.Agent
+			}
+		case "delete":
+			delete(s.agents, entry.ID)
+		}
+	}
+	return nil
+}
+
+func (s *FileStorage) appendLocked(entry journalEntry) error {
+	if s.journal == nil {
+		return errors.New("file storage is closed")
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(s.journal)
+	w.Write(line)
+	w.WriteByte('\n')
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to append to registry journal: %w", err)
+	}
+	if err := s.journal.Sync(); err != nil {
+		return fmt.Errorf("failed to sync registry journal: %w", err)
+	}
+	s.entries++
+	return nil
+}
+
+func (s *FileStorage) maybeCompactLocked() error {
+	if s.entries < s.compactEvery {
+		return nil
+	}
+	return s.compactLocked()
+}
+
+// compactLocked writes the current state as the snapshot and starts an empty journal
+func (s *FileStorage) compactLocked() error {
+	agents := make([]*aoi.AgentIdentity, 0, len(s.agents))
+	for _, agent := range s.agents {
+		agents = append(agents, agent)
+	}
+	sort.Slice(agents, func(i, j int) bool { return agents[i].ID < agents[j].ID })
+
+	data, err := json.MarshalIndent(agents, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filepath.Join(s.dir, snapshotFile), data); err != nil {
+		return fmt.Errorf("failed to write registry snapshot: %w", err)
+	}
+
+	// The snapshot now holds every journaled change, so the journal can restart empty
+	if s.journal != nil {
+		s.journal.Close()
+	}
+	journal, err := os.OpenFile(filepath.Join(s.dir, journalFile), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		s.journal = nil
+		return fmt.Errorf("failed to open registry journal: %w", err)
+	}
+	s.journal = journal
+	s.entries = 0
+	return nil
+}
+
+// writeFileAtomic writes data to a temp file in the same directory, syncs it and
+// renames it over path
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName)
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpName, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmpName, path)
+}
@@ -0,0 +1,178 @@
+package identity
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aoi-protocol/aoi/pkg/aoi"
+)
+
+func TestMemoryStorage_SaveLoadDelete(t *testing.T) {
+	store := NewMemoryStorage()
+
+	agent := &aoi.AgentIdentity{ID: "eng-agent", Role: aoi.RoleEngineer, Status: "online"}
+	if err := store.Save(agent); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	// Changes through the caller's pointer are not stored
+	agent.Status = "offline"
+
+	agents, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(agents) != 1 || agents[0].Status != "online" {
+		t.Errorf("Expected stored copy with status online, got %+v", agents)
+	}
+
+	store.Delete("eng-agent")
+	if agents, _ := store.Load(); len(agents) != 0 {
+		t.Errorf("Expected no agents after delete, got %d", len(agents))
+	}
+}
+
+func TestOpenStorage(t *testing.T) {
+	if s, err := OpenStorage("", ""); err != nil {
+		t.Errorf("Expected memory storage by default, got %v", err)
+	} else if _, ok := s.(*MemoryStorage); !ok {
+		t.Errorf("Expected *MemoryStorage, got %T", s)
+	}
+
+	if _, err := OpenStorage(StorageFile, ""); err == nil {
+		t.Error("Expected error for file storage without a path")
+	}
+
+	if _, err := OpenStorage("redis", ""); err == nil {
+		t.Error("Expected error for unknown storage")
+	}
+}
+
+func TestFileStorage_SurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := NewFileStorage(dir)
+	if err != nil {
+		t.Fatalf("NewFileStorage failed: %v", err)
+	}
+	registry, err := NewAgentRegistryWithStorage(store)
+	if err != nil {
+		t.Fatalf("NewAgentRegistryWithStorage failed: %v", err)
+	}
+
+	registry.Register(&aoi.AgentIdentity{ID: "eng-agent", Role: aoi.RoleEngineer, Endpoint: "http://eng:8080"})
+	registry.Register(&aoi.AgentIdentity{ID: "qa-agent", Role: aoi.RoleQA})
+	registry.UpdateTailscaleNodeID("eng-agent", "node-123")
+	registry.Unregister("qa-agent")
+	if err := registry.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	store, err = NewFileStorage(dir)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	registry, err = NewAgentRegistryWithStorage(store)
+	if err != nil {
+		t.Fatalf("NewAgentRegistryWithStorage failed: %v", err)
+	}
+	defer registry.Close()
+
+	agents := registry.Discover()
+	if len(agents) != 1 {
+		t.Fatalf("Expected 1 agent after restart, got %d", len(agents))
+	}
+	agent, err := registry.GetAgentByTailscaleNodeID("node-123")
+	if err != nil {
+		t.Fatalf("Expected Tailscale mapping to survive restart: %v", err)
+	}
+	if agent.ID != "eng-agent" || agent.Endpoint != "http://eng:8080" {
+		t.Errorf("Unexpected agent after restart: %+v", agent)
+	}
+}
+
+func TestFileStorage_ReplaysJournalAfterCrash(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := NewFileStorage(dir)
+	if err != nil {
+		t.Fatalf("NewFileStorage failed: %v", err)
+	}
+	store.Save(&aoi.AgentIdentity{ID: "eng-agent", Status: "online"})
+	store.Save(&aoi.AgentIdentity{ID: "pm-agent", Status: "online"})
+	store.Delete("pm-agent")
+	// No Close: simulate a crash with changes only in the journal, plus a torn final line
+	f, err := os.OpenFile(filepath.Join(dir, journalFile), os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("open journal: %v", err)
+	}
+	f.WriteString(`{"op":"put","id":"half`)
+	f.Close()
+
+	reopened, err := NewFileStorage(dir)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer reopened.Close()
+
+	agents, _ := reopened.Load()
+	if len(agents) != 1 || agents[0].ID != "eng-agent" {
+		t.Errorf("Expected only eng-agent after replay, got %+v", agents)
+	}
+}
+
+func TestFileStorage_CorruptJournal(t *testing.T) {
+	dir := t.TempDir()
+	journal := "not json\n" + `{"op":"put","id":"eng-agent","agent":{"id":"eng-agent"}}` + "\n"
+	if err := os.WriteFile(filepath.Join(dir, journalFile), []byte(journal), 0o600); err != nil {
+		t.Fatalf("write journal: %v", err)
+	}
+
+	if _, err := NewFileStorage(dir); err == nil {
+		t.Error("Expected error for corrupt journal")
+	}
+}
+
+func TestFileStorage_Compaction(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := NewFileStorage(dir)
+	if err != nil {
+		t.Fatalf("NewFileStorage failed: %v", err)
+	}
+	defer store.Close()
+	store.SetCompactEvery(3)
+
+	for _, id := range []string{"a", "b", "c"} {
+		if err := store.Save(&aoi.AgentIdentity{ID: id}); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+
+	// The third entry triggered a compaction: the snapshot holds everything and the journal is empty
+	info, err := os.Stat(filepath.Join(dir, journalFile))
+	if err != nil {
+		t.Fatalf("stat journal: %v", err)
+	}
+	if info.Size() != 0 {
+		t.Errorf("Expected empty journal after compaction, got %d bytes", info.Size())
+	}
+
+	snapshot, err := os.ReadFile(filepath.Join(dir, snapshotFile))
+	if err != nil {
+		t.Fatalf("read snapshot: %v", err)
+	}
+	for _, id := range []string{`"a"`, `"b"`, `"c"`} {
+		if !strings.Contains(string(snapshot), id) {
+			t.Errorf("Expected snapshot to contain %s", id)
+		}
+	}
+
+	// No temp files are left behind by the atomic write
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 {
+		t.Errorf("Expected only snapshot and journal in %s, got %d entries", dir, len(entries))
+	}
+}
@@ -0,0 +1,99 @@
+// Package llm provides the language models a secretary answers queries with:
+// any OpenAI-compatible chat completions API, including local servers, and a
+// deterministic stub for tests.
+package llm
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aoi-protocol/aoi/internal/config"
+)
+
+// DefaultTimeout bounds a single completion
+const DefaultTimeout = 60 * time.Second
+
+// Message roles
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+// Message is one message of a conversation
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// CompletionRequest asks a model to continue a conversation
+type CompletionRequest struct {
+	// System is the system prompt, sent before Messages
+	System   string
+	Messages []Message
+	// MaxTokens limits the reply; 0 uses the provider's default
+	MaxTokens int
+}
+
+// Completion is a model's reply
+type Completion struct {
+	Text  string
+	Model string
+	// Confidence estimates how sure the model was, from 0 to 1; it is 0 when
+	// the provider cannot tell
+	Confidence   float64
+	FinishReason string
+}
+
+// Classification is the label a model chose for a text
+type Classification struct {
+	Label      string
+	Confidence float64
+}
+
+// Provider is a language model
+type Provider interface {
+	// Complete continues a conversation
+	Complete(ctx context.Context, req CompletionRequest) (*Completion, error)
+	// Summarize condenses text to at most maxWords words
+	Summarize(ctx context.Context, text string, maxWords int) (string, error)
+	// Classify picks the one of labels that best describes text
+	Classify(ctx context.Context, text string, labels []string) (*Classification, error)
+}
+
+// New creates the provider selected by cfg, or nil if none is
+func New(cfg config.LLMConfig) (Provider, error) {
+	switch cfg.Provider {
+	case "":
+		return nil, nil
+	case "stub":
+		return NewStubProvider(), nil
+	case "openai":
+		timeout := DefaultTimeout
+		if cfg.Timeout != "" {
+			d, err := time.ParseDuration(cfg.Timeout)
+			if err != nil {
+				return nil, fmt.Errorf("llm.timeout: %w", err)
+			}
+			timeout = d
+		}
+		apiKey := ""
+		if cfg.APIKeyEnv != "" {
+			if apiKey = os.Getenv(cfg.APIKeyEnv); apiKey == "" {
+				return nil, fmt.Errorf("llm: environment variable %s is not set", cfg.APIKeyEnv)
+			}
+		}
+		return NewOpenAIProvider(OpenAIOptions{
+			BaseURL:     cfg.BaseURL,
+			Model:       cfg.Model,
+			APIKey:      apiKey,
+			Timeout:     timeout,
+			MaxTokens:   cfg.MaxTokens,
+			Temperature: cfg.Temperature,
+			Logprobs:    cfg.Logprobs,
+		}), nil
+	}
+	return nil, fmt.Errorf("llm: unknown provider %q", cfg.Provider)
+}
@@ -0,0 +1,175 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// OpenAIOptions configure an OpenAIProvider
+type OpenAIOptions struct {
+	// BaseURL is the API root, e.g. "http://localhost:11434/v1"
+	BaseURL string
+	Model   string
+	// APIKey is sent as a bearer token when set
+	APIKey      string
+	Timeout     time.Duration
+	MaxTokens   int
+	Temperature *float64
+	// Logprobs asks for token log probabilities to estimate confidence
+	Logprobs bool
+}
+
+// OpenAIProvider talks to an OpenAI-compatible chat completions API, which
+// OpenAI and local servers such as Ollama, llama.cpp and vLLM provide
+type OpenAIProvider struct {
+	opts       OpenAIOptions
+	httpClient *http.Client
+}
+
+// NewOpenAIProvider creates a provider for the API at opts.BaseURL
+func NewOpenAIProvider(opts OpenAIOptions) *OpenAIProvider {
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	opts.BaseURL = strings.TrimSuffix(opts.BaseURL, "/")
+	return &OpenAIProvider{
+		opts:       opts,
+		httpClient: &http.Client{Timeout: opts.Timeout},
+	}
+}
+
+type chatRequest struct {
+	Model       string    `json:"model"`
+	Messages    []Message `json:"messages"`
+	MaxTokens   int       `json:"max_tokens,omitempty"`
+	Temperature *float64  `json:"temperature,omitempty"`
+	Logprobs    bool      `json:"logprobs,omitempty"`
+}
+
+type chatResponse struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Message      Message `json:"message"`
+		FinishReason string  `json:"finish_reason"`
+		Logprobs     *struct {
+			Content []struct {
+				Logprob float64 `json:"logprob"`
+			} `json:"content"`
+		} `json:"logprobs"`
+	} `json:"choices"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// Complete sends the conversation to /chat/completions
+func (p *OpenAIProvider) Complete(ctx context.Context, req CompletionRequest) (*Completion, error) {
+	body := chatRequest{
+		Model:       p.opts.Model,
+		MaxTokens:   p.opts.MaxTokens,
+		Temperature: p.opts.Temperature,
+		Logprobs:    p.opts.Logprobs,
+	}
+	if req.MaxTokens > 0 {
+		body.MaxTokens = req.MaxTokens
+	}
+	if req.System != "" {
+		body.Messages = append(body.Messages, Message{Role: RoleSystem, Content: req.System})
+	}
+	body.Messages = append(body.Messages, req.Messages...)
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal completion request: %w", err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.opts.BaseURL+"/chat/completions", bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create completion request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if p.opts.APIKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+p.opts.APIKey)
+	}
+
+	resp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("completion request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read completion: %w", err)
+	}
+	var out chatResponse
+	if err := json.Unmarshal(raw, &out); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("completion request failed: %s", resp.Status)
+		}
+		return nil, fmt.Errorf("failed to unmarshal completion: %w", err)
+	}
+	if out.Error != nil {
+		return nil, fmt.Errorf("completion request failed: %s: %s", resp.Status, out.Error.Message)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("completion request failed: %s", resp.Status)
+	}
+	if len(out.Choices) == 0 {
+		return nil, fmt.Errorf("completion has no choices")
+	}
+
+	choice := out.Choices[0]
+	completion := &Completion{
+		Text:         strings.TrimSpace(choice.Message.Content),
+		Model:        out.Model,
+		FinishReason: choice.FinishReason,
+	}
+	// The geometric mean of the token probabilities
+	if choice.Logprobs != nil && len(choice.Logprobs.Content) > 0 {
+		sum := 0.0
+		for _, token := range choice.Logprobs.Content {
+			sum += token.Logprob
+		}
+		completion.Confidence = math.Exp(sum / float64(len(choice.Logprobs.Content)))
+	}
+	return completion, nil
+}
+
+// Summarize asks the model for a summary of text
+func (p *OpenAIProvider) Summarize(ctx context.Context, text string, maxWords int) (string, error) {
+	completion, err := p.Complete(ctx, CompletionRequest{
+		System:   fmt.Sprintf("Summarize the user's text in at most %d words. Reply with the summary only.", maxWords),
+		Messages: []Message{{Role: RoleUser, Content: text}},
+	})
+	if err != nil {
+		return "", err
+	}
+	return completion.Text, nil
+}
+
+// Classify asks the model to reply with one of labels
+func (p *OpenAIProvider) Classify(ctx context.Context, text string, labels []string) (*Classification, error) {
+	completion, err := p.Complete(ctx, CompletionRequest{
+		System: "Classify the user's text as exactly one of these labels: " + strings.Join(labels, ", ") +
+			". Reply with the label only.",
+		Messages: []Message{{Role: RoleUser, Content: text}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	reply := strings.Trim(strings.ToLower(completion.Text), " \t\n.\"'`")
+	for _, label := range labels {
+		if strings.ToLower(label) == reply {
+			return &Classification{Label: label, Confidence: completion.Confidence}, nil
+		}
+	}
+	return nil, fmt.Errorf("model replied %q, which is not one of %v", completion.Text, labels)
+}
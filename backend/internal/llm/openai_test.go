@@ -0,0 +1,124 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aoi-protocol/aoi/internal/config"
+)
+
+// newChatServer serves /chat/completions with reply, recording the last request
+func newChatServer(t *testing.T, reply string, got *chatRequest) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer sk-test" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":{"message":"invalid api key"}}`))
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(got); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"model": "local-model",
+			"choices": []map[string]interface{}{{
+				"message":       map[string]string{"role": "assistant", "content": " " + reply + "\n"},
+				"finish_reason": "stop",
+				"logprobs": map[string]interface{}{"content": []map[string]float64{
+					{"logprob": math.Log(0.9)}, {"logprob": math.Log(0.9)},
+				}},
+			}},
+		})
+	}))
+}
+
+func TestOpenAIProvider_Complete(t *testing.T) {
+	var got chatRequest
+	server := newChatServer(t, "The build is green.", &got)
+	defer server.Close()
+
+	p := NewOpenAIProvider(OpenAIOptions{BaseURL: server.URL + "/v1/", Model: "local-model", APIKey: "sk-test", Logprobs: true})
+	completion, err := p.Complete(context.Background(), CompletionRequest{
+		System:   "You are a secretary.",
+		Messages: []Message{{Role: RoleUser, Content: "Is the build green?"}},
+	})
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+
+	if completion.Text != "The build is green." || completion.Model != "local-model" {
+		t.Errorf("Unexpected completion: %+v", completion)
+	}
+	if math.Abs(completion.Confidence-0.9) > 1e-9 {
+		t.Errorf("Expected confidence 0.9 from logprobs, got %f", completion.Confidence)
+	}
+	if got.Model != "local-model" || !got.Logprobs || len(got.Messages) != 2 || got.Messages[0].Role != RoleSystem {
+		t.Errorf("Unexpected request: %+v", got)
+	}
+}
+
+func TestOpenAIProvider_Errors(t *testing.T) {
+	var got chatRequest
+	server := newChatServer(t, "", &got)
+	defer server.Close()
+
+	p := NewOpenAIProvider(OpenAIOptions{BaseURL: server.URL + "/v1", Model: "m", APIKey: "wrong"})
+	_, err := p.Complete(context.Background(), CompletionRequest{Messages: []Message{{Role: RoleUser, Content: "hi"}}})
+	if err == nil || !strings.Contains(err.Error(), "invalid api key") {
+		t.Errorf("Expected the API's error message, got %v", err)
+	}
+
+	p = NewOpenAIProvider(OpenAIOptions{BaseURL: server.URL, Model: "m", APIKey: "sk-test"})
+	if _, err := p.Complete(context.Background(), CompletionRequest{}); err == nil {
+		t.Error("Expected an error for a wrong path")
+	}
+}
+
+func TestOpenAIProvider_Classify(t *testing.T) {
+	var got chatRequest
+	server := newChatServer(t, "Urgent.", &got)
+	defer server.Close()
+
+	p := NewOpenAIProvider(OpenAIOptions{BaseURL: server.URL + "/v1", Model: "m", APIKey: "sk-test"})
+	c, err := p.Classify(context.Background(), "prod is down", []string{"routine", "urgent"})
+	if err != nil {
+		t.Fatalf("Classify: %v", err)
+	}
+	if c.Label != "urgent" {
+		t.Errorf("Expected urgent, got %q", c.Label)
+	}
+	if !strings.Contains(got.Messages[0].Content, "routine, urgent") {
+		t.Errorf("Expected the labels in the system prompt, got %q", got.Messages[0].Content)
+	}
+
+	if _, err := p.Classify(context.Background(), "prod is down", []string{"low", "high"}); err == nil {
+		t.Error("Expected an error for a reply that is not a label")
+	}
+}
+
+func TestNew(t *testing.T) {
+	if p, err := New(config.LLMConfig{}); p != nil || err != nil {
+		t.Errorf("Expected no provider, got %v, %v", p, err)
+	}
+	if p, err := New(config.LLMConfig{Provider: "stub"}); err != nil {
+		t.Errorf("New(stub): %v", err)
+	} else if _, ok := p.(*StubProvider); !ok {
+		t.Errorf("Expected a stub, got %T", p)
+	}
+	if _, err := New(config.LLMConfig{Provider: "openai", BaseURL: "http://localhost:1/v1", Model: "m", Timeout: "soon"}); err == nil {
+		t.Error("Expected an invalid timeout to be an error")
+	}
+	t.Setenv("AOI_TEST_LLM_KEY", "")
+	if _, err := New(config.LLMConfig{Provider: "openai", BaseURL: "http://localhost:1/v1", Model: "m", APIKeyEnv: "AOI_TEST_LLM_KEY"}); err == nil {
+		t.Error("Expected a missing API key to be an error")
+	}
+}
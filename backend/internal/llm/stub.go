@@ -0,0 +1,86 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// StubConfidence is the confidence of every stub completion
+const StubConfidence = 0.5
+
+// StubProvider answers deterministically without a model, for tests and for
+// trying out an agent offline. It records the requests it receives.
+type StubProvider struct {
+	// Reply, when set, produces the text of a completion
+	Reply func(req CompletionRequest) string
+
+	mu       sync.Mutex
+	requests []CompletionRequest
+}
+
+// NewStubProvider creates a stub that echoes the last user message
+func NewStubProvider() *StubProvider {
+	return &StubProvider{}
+}
+
+// Complete replies with Reply, or else "stub: " followed by the last user message
+func (p *StubProvider) Complete(ctx context.Context, req CompletionRequest) (*Completion, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	p.requests = append(p.requests, req)
+	p.mu.Unlock()
+
+	text := ""
+	if p.Reply != nil {
+		text = p.Reply(req)
+	} else {
+		for _, msg := range req.Messages {
+			if msg.Role == RoleUser {
+				text = "stub: " + msg.Content
+			}
+		}
+	}
+	return &Completion{Text: text, Model: "stub", Confidence: StubConfidence, FinishReason: "stop"}, nil
+}
+
+// Summarize returns the first maxWords words of text
+func (p *StubProvider) Summarize(ctx context.Context, text string, maxWords int) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	words := strings.Fields(text)
+	if maxWords > 0 && len(words) > maxWords {
+		words = words[:maxWords]
+	}
+	return strings.Join(words, " "), nil
+}
+
+// Classify picks the first label that occurs in text, or else the first label
+func (p *StubProvider) Classify(ctx context.Context, text string, labels []string) (*Classification, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if len(labels) == 0 {
+		return &Classification{}, nil
+	}
+	lower := strings.ToLower(text)
+	for _, label := range labels {
+		if strings.Contains(lower, strings.ToLower(label)) {
+			return &Classification{Label: label, Confidence: 1}, nil
+		}
+	}
+	return &Classification{Label: labels[0]}, nil
+}
+
+// Requests returns the completion requests received so far
+func (p *StubProvider) Requests() []CompletionRequest {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	requests := make([]CompletionRequest, len(p.requests))
+	copy(requests, p.requests)
+	return requests
+}
@@ -0,0 +1,37 @@
+package llm
+
+import (
+	"context"
+	"testing"
+)
+
+func TestStubProvider(t *testing.T) {
+	p := NewStubProvider()
+	ctx := context.Background()
+
+	completion, err := p.Complete(ctx, CompletionRequest{System: "sys", Messages: []Message{{Role: RoleUser, Content: "status?"}}})
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if completion.Text != "stub: status?" || completion.Confidence != StubConfidence {
+		t.Errorf("Unexpected completion: %+v", completion)
+	}
+	if reqs := p.Requests(); len(reqs) != 1 || reqs[0].System != "sys" {
+		t.Errorf("Expected the request to be recorded, got %+v", reqs)
+	}
+
+	p.Reply = func(CompletionRequest) string { return "fixed" }
+	if completion, _ := p.Complete(ctx, CompletionRequest{}); completion.Text != "fixed" {
+		t.Errorf("Expected the Reply func to answer, got %q", completion.Text)
+	}
+
+	if summary, _ := p.Summarize(ctx, "one two three four", 2); summary != "one two" {
+		t.Errorf("Expected the first two words, got %q", summary)
+	}
+	if c, _ := p.Classify(ctx, "Prod is DOWN", []string{"ok", "down"}); c.Label != "down" || c.Confidence != 1 {
+		t.Errorf("Expected down, got %+v", c)
+	}
+	if c, _ := p.Classify(ctx, "hello", []string{"ok", "down"}); c.Label != "ok" || c.Confidence != 0 {
+		t.Errorf("Expected the first label, got %+v", c)
+	}
+}
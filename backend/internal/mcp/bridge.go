@@ -5,11 +5,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	aoicontext "github.com/aoi-protocol/aoi/internal/context"
+	"github.com/aoi-protocol/aoi/internal/rpc"
 )
 
 // MCPBridge bridges AOI queries and MCP tool/resource interactions
@@ -20,6 +22,14 @@ type MCPBridge struct {
 	toolMappings  map[string]ToolMapping  // toolName -> mapping
 	resourceCache map[string]*CachedResource
 	cacheTimeout  time.Duration
+	outputFilter  OutputFilter
+}
+
+// OutputFilter rewrites what aoi.mcp.call and aoi.mcp.read return to the
+// caller in ctx, e.g. to withhold file contents from some requesters
+type OutputFilter interface {
+	FilterToolResult(ctx context.Context, serverName, toolName string, result *CallToolResult) *CallToolResult
+	FilterResource(ctx context.Context, serverName, uri string, contents []ResourceContent) []ResourceContent
 }
 
 // ToolMapping defines how an AOI query maps to an MCP tool call
@@ -76,6 +86,21 @@ func NewMCPBridge(contextStore *aoicontext.ContextStore) *MCPBridge {
 	}
 }
 
+// SetOutputFilter filters the results of aoi.mcp.call and aoi.mcp.read;
+// nil returns them unchanged
+func (b *MCPBridge) SetOutputFilter(filter OutputFilter) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.outputFilter = filter
+}
+
+// filter returns the output filter, if any
+func (b *MCPBridge) filter() OutputFilter {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.outputFilter
+}
+
 // Configure applies a configuration to the bridge
 func (b *MCPBridge) Configure(config *BridgeConfig) error {
 	b.mu.Lock()
@@ -421,6 +446,24 @@ func (b *MCPBridge) DiscoverServer(ctx context.Context, serverName string) (*Dis
 	return result, nil
 }
 
+// ToolNames discovers every configured server, connecting it if needed, and
+// returns its tools as "server/tool" names as used in capability manifests
+func (b *MCPBridge) ToolNames(ctx context.Context) []string {
+	names := make([]string, 0)
+	for _, server := range b.ListClients() {
+		result, err := b.DiscoverServer(ctx, server)
+		if err != nil {
+			log.Printf("[MCPBridge] Failed to discover %s: %v", server, err)
+			continue
+		}
+		for _, tool := range result.Tools {
+			names = append(names, server+"/"+tool.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
 // GetStatus returns the status of all connected MCP servers
 func (b *MCPBridge) GetStatus() map[string]any {
 	b.mu.RLock()
@@ -498,77 +541,157 @@ func (b *MCPBridge) RegisterToolMapping(pattern string, mapping ToolMapping) {
 // JSON-RPC Handler
 // ============================================================================
 
-// HandleJSONRPC handles JSON-RPC calls for MCP-related methods
-func (b *MCPBridge) HandleJSONRPC(ctx context.Context, method string, params json.RawMessage) (any, error) {
-	switch method {
-	case "aoi.mcp.status":
-		return b.GetStatus(), nil
+// RegisterMethods registers the aoi.mcp.* methods
+func (b *MCPBridge) RegisterMethods(reg *rpc.Registry) {
+	serverName := rpc.RequiredParam("server_name", "string", "Configured MCP server name")
+	reg.MustRegister(
+		rpc.Method{
+			Name:     "aoi.mcp.status",
+			Summary:  "Get the connection state of configured MCP servers",
+			Result:   rpc.Result("status", rpc.Type("object")),
+			Resource: "mcp/status",
+			Action:   rpc.ActionRead,
+			Handler: func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+				return b.GetStatus(), nil
+			},
+		},
+		rpc.Method{
+			Name:     "aoi.mcp.discover",
+			Summary:  "Connect to an MCP server and list its capabilities",
+			Params:   []rpc.ContentDescriptor{serverName},
+			Result:   rpc.Result("discovery", rpc.Type("object")),
+			Resource: "mcp/discover",
+			Action:   rpc.ActionRead,
+			Handler:  b.handleRPCDiscover,
+		},
+		rpc.Method{
+			Name:     "aoi.mcp.tools",
+			Summary:  "List the tools of an MCP server",
+			Params:   []rpc.ContentDescriptor{serverName},
+			Result:   rpc.Result("tools", rpc.ArrayOf(rpc.Type("object"))),
+			Resource: "mcp/tools",
+			Action:   rpc.ActionRead,
+			Handler:  b.handleRPCTools,
+		},
+		rpc.Method{
+			Name:    "aoi.mcp.call",
+			Summary: "Call a tool on an MCP server",
+			Params: []rpc.ContentDescriptor{
+				serverName,
+				rpc.RequiredParam("tool_name", "string", "Tool to call"),
+				rpc.Param("arguments", "object", "Tool arguments"),
+			},
+			Result:   rpc.Result("result", rpc.Type("object")),
+			Resource: "mcp/call",
+			Action:   rpc.ActionExecute,
+			Handler:  b.handleRPCCall,
+		},
+		rpc.Method{
+			Name:     "aoi.mcp.resources",
+			Summary:  "List the resources of an MCP server",
+			Params:   []rpc.ContentDescriptor{serverName},
+			Result:   rpc.Result("resources", rpc.ArrayOf(rpc.Type("object"))),
+			Resource: "mcp/resources",
+			Action:   rpc.ActionRead,
+			Handler:  b.handleRPCResources,
+		},
+		rpc.Method{
+			Name:    "aoi.mcp.read",
+			Summary: "Read a resource from an MCP server",
+			Params: []rpc.ContentDescriptor{
+				serverName,
+				rpc.RequiredParam("uri", "string", "Resource URI"),
+			},
+			Result:   rpc.Result("contents", rpc.ArrayOf(rpc.Type("object"))),
+			Resource: "mcp/read",
+			Action:   rpc.ActionRead,
+			Handler:  b.handleRPCRead,
+		},
+	)
+}
 
-	case "aoi.mcp.discover":
-		var p struct {
-			ServerName string `json:"server_name"`
-		}
-		if err := json.Unmarshal(params, &p); err != nil {
-			return nil, fmt.Errorf("invalid params: %w", err)
-		}
-		return b.DiscoverServer(ctx, p.ServerName)
+// rpcClient decodes the server_name param and returns its client
+func (b *MCPBridge) rpcClient(params json.RawMessage) (*MCPClient, error) {
+	var p struct {
+		ServerName string `json:"server_name"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, fmt.Errorf("invalid params: %w", err)
+	}
+	client, ok := b.GetClient(p.ServerName)
+	if !ok {
+		return nil, fmt.Errorf("client not found: %s", p.ServerName)
+	}
+	return client, nil
+}
 
-	case "aoi.mcp.tools":
-		var p struct {
-			ServerName string `json:"server_name"`
-		}
-		if err := json.Unmarshal(params, &p); err != nil {
-			return nil, fmt.Errorf("invalid params: %w", err)
-		}
-		client, ok := b.GetClient(p.ServerName)
-		if !ok {
-			return nil, fmt.Errorf("client not found: %s", p.ServerName)
-		}
-		return client.ListTools(ctx)
+func (b *MCPBridge) handleRPCDiscover(ctx context.Context, params json.RawMessage) (any, error) {
+	var p struct {
+		ServerName string `json:"server_name"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, fmt.Errorf("invalid params: %w", err)
+	}
+	return b.DiscoverServer(ctx, p.ServerName)
+}
 
-	case "aoi.mcp.call":
-		var p struct {
-			ServerName string         `json:"server_name"`
-			ToolName   string         `json:"tool_name"`
-			Arguments  map[string]any `json:"arguments"`
-		}
-		if err := json.Unmarshal(params, &p); err != nil {
-			return nil, fmt.Errorf("invalid params: %w", err)
-		}
-		client, ok := b.GetClient(p.ServerName)
-		if !ok {
-			return nil, fmt.Errorf("client not found: %s", p.ServerName)
-		}
-		return client.CallTool(ctx, p.ToolName, p.Arguments)
+func (b *MCPBridge) handleRPCTools(ctx context.Context, params json.RawMessage) (any, error) {
+	client, err := b.rpcClient(params)
+	if err != nil {
+		return nil, err
+	}
+	return client.ListTools(ctx)
+}
 
-	case "aoi.mcp.resources":
-		var p struct {
-			ServerName string `json:"server_name"`
-		}
-		if err := json.Unmarshal(params, &p); err != nil {
-			return nil, fmt.Errorf("invalid params: %w", err)
-		}
-		client, ok := b.GetClient(p.ServerName)
-		if !ok {
-			return nil, fmt.Errorf("client not found: %s", p.ServerName)
-		}
-		return client.ListResources(ctx)
+func (b *MCPBridge) handleRPCCall(ctx context.Context, params json.RawMessage) (any, error) {
+	var p struct {
+		ServerName string         `json:"server_name"`
+		ToolName   string         `json:"tool_name"`
+		Arguments  map[string]any `json:"arguments"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, fmt.Errorf("invalid params: %w", err)
+	}
+	client, ok := b.GetClient(p.ServerName)
+	if !ok {
+		return nil, fmt.Errorf("client not found: %s", p.ServerName)
+	}
+	result, err := client.CallTool(ctx, p.ToolName, p.Arguments)
+	if err != nil {
+		return nil, err
+	}
+	if filter := b.filter(); filter != nil {
+		result = filter.FilterToolResult(ctx, p.ServerName, p.ToolName, result)
+	}
+	return result, nil
+}
 
-	case "aoi.mcp.read":
-		var p struct {
-			ServerName string `json:"server_name"`
-			URI        string `json:"uri"`
-		}
-		if err := json.Unmarshal(params, &p); err != nil {
-			return nil, fmt.Errorf("invalid params: %w", err)
-		}
-		client, ok := b.GetClient(p.ServerName)
-		if !ok {
-			return nil, fmt.Errorf("client not found: %s", p.ServerName)
-		}
-		return client.ReadResource(ctx, p.URI)
+func (b *MCPBridge) handleRPCResources(ctx context.Context, params json.RawMessage) (any, error) {
+	client, err := b.rpcClient(params)
+	if err != nil {
+		return nil, err
+	}
+	return client.ListResources(ctx)
+}
 
-	default:
-		return nil, fmt.Errorf("unknown method: %s", method)
+func (b *MCPBridge) handleRPCRead(ctx context.Context, params json.RawMessage) (any, error) {
+	var p struct {
+		ServerName string `json:"server_name"`
+		URI        string `json:"uri"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, fmt.Errorf("invalid params: %w", err)
+	}
+	client, ok := b.GetClient(p.ServerName)
+	if !ok {
+		return nil, fmt.Errorf("client not found: %s", p.ServerName)
+	}
+	contents, err := client.ReadResource(ctx, p.URI)
+	if err != nil {
+		return nil, err
+	}
+	if filter := b.filter(); filter != nil {
+		contents = filter.FilterResource(ctx, p.ServerName, p.URI, contents)
 	}
+	return contents, nil
 }
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	aoicontext "github.com/aoi-protocol/aoi/internal/context"
+	"github.com/aoi-protocol/aoi/internal/rpc"
 )
 
 func setupTestBridge() (*MCPBridge, *aoicontext.ContextStore, func()) {
@@ -274,6 +275,51 @@ func TestMCPBridge_DiscoverServer(t *testing.T) {
 	}
 }
 
+func TestMCPBridge_ToolNames(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req JSONRPCRequest
+		json.NewDecoder(r.Body).Decode(&req)
+
+		var result interface{}
+		switch req.Method {
+		case "initialize":
+			result = InitializeResult{
+				ProtocolVersion: MCPVersion,
+				ServerInfo:      Implementation{Name: "test-server", Version: "1.0.0"},
+				Capabilities:    ServerCapabilities{Tools: &ToolsCapability{}},
+			}
+		case "notifications/initialized":
+			return
+		case "tools/list":
+			result = ListToolsResult{Tools: []Tool{{Name: "search"}, {Name: "read_file"}}}
+		}
+
+		resultJSON, _ := json.Marshal(result)
+		json.NewEncoder(w).Encode(JSONRPCResponse{JSONRPC: "2.0", ID: req.ID, Result: resultJSON})
+	}))
+	defer server.Close()
+
+	bridge, _, cleanup := setupTestBridge()
+	defer cleanup()
+
+	bridge.AddClient("files", NewMCPClient(&ClientConfig{
+		Transport:      TransportHTTP,
+		BaseURL:        server.URL,
+		RequestTimeout: 5 * time.Second,
+	}))
+	// A server that cannot be reached contributes no tools
+	bridge.AddClient("offline", NewMCPClient(&ClientConfig{
+		Transport:      TransportHTTP,
+		BaseURL:        "http://127.0.0.1:1",
+		RequestTimeout: time.Second,
+	}))
+
+	names := bridge.ToolNames(context.Background())
+	if len(names) != 2 || names[0] != "files/read_file" || names[1] != "files/search" {
+		t.Errorf("Expected [files/read_file files/search], got %v", names)
+	}
+}
+
 func TestMCPBridge_DiscoverServer_ClientNotFound(t *testing.T) {
 	bridge, _, cleanup := setupTestBridge()
 	defer cleanup()
@@ -349,14 +395,21 @@ func TestMCPBridge_FetchResourceAsContext(t *testing.T) {
 	}
 }
 
-func TestMCPBridge_HandleJSONRPC_Status(t *testing.T) {
+// registerTestMethods registers the bridge's methods with a new registry
+func registerTestMethods(bridge *MCPBridge) *rpc.Registry {
+	reg := rpc.NewRegistry()
+	bridge.RegisterMethods(reg)
+	return reg
+}
+
+func TestMCPBridge_RPCStatus(t *testing.T) {
 	bridge, _, cleanup := setupTestBridge()
 	defer cleanup()
 
 	ctx := context.Background()
-	result, err := bridge.HandleJSONRPC(ctx, "aoi.mcp.status", nil)
+	result, err := registerTestMethods(bridge).Call(ctx, "aoi.mcp.status", nil)
 	if err != nil {
-		t.Fatalf("HandleJSONRPC failed: %v", err)
+		t.Fatalf("aoi.mcp.status failed: %v", err)
 	}
 
 	status, ok := result.(map[string]any)
@@ -369,7 +422,7 @@ func TestMCPBridge_HandleJSONRPC_Status(t *testing.T) {
 	}
 }
 
-func TestMCPBridge_HandleJSONRPC_Discover(t *testing.T) {
+func TestMCPBridge_RPCDiscover(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var req JSONRPCRequest
 		json.NewDecoder(r.Body).Decode(&req)
@@ -409,9 +462,9 @@ func TestMCPBridge_HandleJSONRPC_Discover(t *testing.T) {
 
 	ctx := context.Background()
 	params, _ := json.Marshal(map[string]string{"server_name": "test-server"})
-	result, err := bridge.HandleJSONRPC(ctx, "aoi.mcp.discover", params)
+	result, err := registerTestMethods(bridge).Call(ctx, "aoi.mcp.discover", params)
 	if err != nil {
-		t.Fatalf("HandleJSONRPC failed: %v", err)
+		t.Fatalf("aoi.mcp.discover failed: %v", err)
 	}
 
 	discovery, ok := result.(*DiscoveryResult)
@@ -424,12 +477,12 @@ func TestMCPBridge_HandleJSONRPC_Discover(t *testing.T) {
 	}
 }
 
-func TestMCPBridge_HandleJSONRPC_UnknownMethod(t *testing.T) {
+func TestMCPBridge_RPCUnknownMethod(t *testing.T) {
 	bridge, _, cleanup := setupTestBridge()
 	defer cleanup()
 
 	ctx := context.Background()
-	_, err := bridge.HandleJSONRPC(ctx, "aoi.mcp.unknown", nil)
+	_, err := registerTestMethods(bridge).Call(ctx, "aoi.mcp.unknown", nil)
 	if err == nil {
 		t.Error("Expected error for unknown method")
 	}
@@ -0,0 +1,261 @@
+// Package pki issues and verifies the machine certificates agents use for
+// mutual TLS when Tailscale is not available.
+package pki
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// AgentURIPrefix prefixes the URI SAN that carries an agent ID, e.g. "aoi://agent/eng-suzuki"
+const AgentURIPrefix = "aoi://agent/"
+
+// Default certificate lifetimes
+const (
+	DefaultCAValidity    = 10 * 365 * 24 * time.Hour
+	DefaultAgentValidity = 365 * 24 * time.Hour
+)
+
+// File names used by a CA directory
+const (
+	CACertFile = "ca.crt"
+	CAKeyFile  = "ca.key"
+)
+
+// CA is a local certificate authority that issues agent certificates
+type CA struct {
+	Cert *x509.Certificate
+	Key  *ecdsa.PrivateKey
+}
+
+// NewCA creates a self-signed CA
+func NewCA(commonName string, validFor time.Duration) (*CA, error) {
+	if validFor <= 0 {
+		validFor = DefaultCAValidity
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate CA key: %w", err)
+	}
+	serial, err := newSerial()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: commonName, Organization: []string{"AOI"}},
+		NotBefore:             now.Add(-time.Minute),
+		NotAfter:              now.Add(validFor),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CA certificate: %w", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return &CA{Cert: cert, Key: key}, nil
+}
+
+// LoadCA reads a CA certificate and key from PEM files
+func LoadCA(certFile, keyFile string) (*CA, error) {
+	cert, err := readCertificate(certFile)
+	if err != nil {
+		return nil, err
+	}
+	if !cert.IsCA {
+		return nil, fmt.Errorf("%s is not a CA certificate", certFile)
+	}
+
+	keyPEM, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA key: %w", err)
+	}
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data", keyFile)
+	}
+	key, err := x509.ParseECPrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CA key: %w", err)
+	}
+	return &CA{Cert: cert, Key: key}, nil
+}
+
+// LoadCADir reads ca.crt and ca.key from dir
+func LoadCADir(dir string) (*CA, error) {
+	return LoadCA(filepath.Join(dir, CACertFile), filepath.Join(dir, CAKeyFile))
+}
+
+// Save writes ca.crt and ca.key to dir. Existing files are not overwritten.
+func (ca *CA) Save(dir string) error {
+	keyPEM, err := encodeKey(ca.Key)
+	if err != nil {
+		return err
+	}
+	return writePair(dir, CACertFile, CAKeyFile, encodeCert(ca.Cert.Raw), keyPEM)
+}
+
+// CertPEM returns the CA certificate in PEM form
+func (ca *CA) CertPEM() []byte {
+	return encodeCert(ca.Cert.Raw)
+}
+
+// IssueAgentCert issues a certificate for agentID usable for both serving and
+// client authentication. The agent ID is carried as the subject common name and
+// as an aoi://agent/<id> URI SAN; hosts become DNS or IP SANs.
+func (ca *CA) IssueAgentCert(agentID string, hosts []string, validFor time.Duration) (certPEM, keyPEM []byte, err error) {
+	if agentID == "" {
+		return nil, nil, errors.New("agent ID is required")
+	}
+	if validFor <= 0 {
+		validFor = DefaultAgentValidity
+	}
+
+	agentURI, err := url.Parse(AgentURIPrefix + url.PathEscape(agentID))
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid agent ID %q: %w", agentID, err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	serial, err := newSerial()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: agentID, Organization: []string{"AOI"}},
+		NotBefore:    now.Add(-time.Minute),
+		NotAfter:     now.Add(validFor),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		URIs:         []*url.URL{agentURI},
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else if h != "" {
+			tmpl.DNSNames = append(tmpl.DNSNames, h)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.Cert, &key.PublicKey, ca.Key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create certificate: %w", err)
+	}
+	keyPEM, err = encodeKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return encodeCert(der), keyPEM, nil
+}
+
+// IssueAgentFiles issues a certificate for agentID and writes <id>.crt and <id>.key to dir
+func (ca *CA) IssueAgentFiles(dir, agentID string, hosts []string, validFor time.Duration) (certFile, keyFile string, err error) {
+	certPEM, keyPEM, err := ca.IssueAgentCert(agentID, hosts, validFor)
+	if err != nil {
+		return "", "", err
+	}
+	certName, keyName := agentID+".crt", agentID+".key"
+	if err := writePair(dir, certName, keyName, certPEM, keyPEM); err != nil {
+		return "", "", err
+	}
+	return filepath.Join(dir, certName), filepath.Join(dir, keyName), nil
+}
+
+// AgentIDFromCertificate returns the agent ID a certificate was issued for:
+// the aoi://agent/ URI SAN if present, otherwise the subject common name.
+func AgentIDFromCertificate(cert *x509.Certificate) (string, bool) {
+	for _, u := range cert.URIs {
+		s := u.String()
+		if !strings.HasPrefix(s, AgentURIPrefix) {
+			continue
+		}
+		if id, err := url.PathUnescape(strings.TrimPrefix(s, AgentURIPrefix)); err == nil && id != "" {
+			return id, true
+		}
+	}
+	if cert.Subject.CommonName != "" {
+		return cert.Subject.CommonName, true
+	}
+	return "", false
+}
+
+func newSerial() (*big.Int, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial number: %w", err)
+	}
+	return serial, nil
+}
+
+func encodeCert(der []byte) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func encodeKey(key *ecdsa.PrivateKey) ([]byte, error) {
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal key: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), nil
+}
+
+func readCertificate(path string) (*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("%s: no PEM certificate", path)
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// writePair writes a certificate (0644) and its key (0600), refusing to overwrite either
+func writePair(dir, certName, keyName string, certPEM, keyPEM []byte) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	certPath, keyPath := filepath.Join(dir, certName), filepath.Join(dir, keyName)
+	for _, p := range []string{certPath, keyPath} {
+		if _, err := os.Stat(p); err == nil {
+			return fmt.Errorf("%s already exists", p)
+		}
+	}
+	if err := os.WriteFile(keyPath, keyPEM, 0o600); err != nil {
+		return fmt.Errorf("failed to write key: %w", err)
+	}
+	if err := os.WriteFile(certPath, certPEM, 0o644); err != nil {
+		return fmt.Errorf("failed to write certificate: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,263 @@
+package pki
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newTestPKI creates a CA and certificates for agentIDs in a temp dir
+func newTestPKI(t *testing.T, agentIDs ...string) (dir string, ca *CA) {
+	t.Helper()
+	dir = t.TempDir()
+
+	ca, err := NewCA("Test CA", 0)
+	if err != nil {
+		t.Fatalf("NewCA failed: %v", err)
+	}
+	if err := ca.Save(dir); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	for _, id := range agentIDs {
+		if _, _, err := ca.IssueAgentFiles(dir, id, []string{"localhost", "127.0.0.1"}, 0); err != nil {
+			t.Fatalf("IssueAgentFiles(%s) failed: %v", id, err)
+		}
+	}
+	return dir, ca
+}
+
+func parsePEM(t *testing.T, data []byte) *x509.Certificate {
+	t.Helper()
+	block, _ := pem.Decode(data)
+	if block == nil {
+		t.Fatal("no PEM block")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("ParseCertificate failed: %v", err)
+	}
+	return cert
+}
+
+func TestCA_IssueAgentCert(t *testing.T) {
+	ca, err := NewCA("Test CA", 0)
+	if err != nil {
+		t.Fatalf("NewCA failed: %v", err)
+	}
+
+	certPEM, _, err := ca.IssueAgentCert("eng-suzuki", []string{"eng-suzuki.local", "10.0.0.5"}, 0)
+	if err != nil {
+		t.Fatalf("IssueAgentCert failed: %v", err)
+	}
+	cert := parsePEM(t, certPEM)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Cert)
+	if _, err := cert.Verify(x509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}}); err != nil {
+		t.Errorf("Expected certificate to verify for client auth: %v", err)
+	}
+	if _, err := cert.Verify(x509.VerifyOptions{Roots: roots, DNSName: "eng-suzuki.local"}); err != nil {
+		t.Errorf("Expected certificate to verify for eng-suzuki.local: %v", err)
+	}
+	if len(cert.IPAddresses) != 1 || cert.IPAddresses[0].String() != "10.0.0.5" {
+		t.Errorf("Expected IP SAN 10.0.0.5, got %v", cert.IPAddresses)
+	}
+
+	if id, ok := AgentIDFromCertificate(cert); !ok || id != "eng-suzuki" {
+		t.Errorf("Expected agent ID eng-suzuki, got %q", id)
+	}
+}
+
+func TestCA_IssueRequiresAgentID(t *testing.T) {
+	ca, _ := NewCA("Test CA", 0)
+	if _, _, err := ca.IssueAgentCert("", nil, 0); err == nil {
+		t.Error("Expected error for empty agent ID")
+	}
+}
+
+func TestCA_SaveAndLoad(t *testing.T) {
+	dir, ca := newTestPKI(t)
+
+	loaded, err := LoadCADir(dir)
+	if err != nil {
+		t.Fatalf("LoadCADir failed: %v", err)
+	}
+	if !loaded.Cert.Equal(ca.Cert) {
+		t.Error("Loaded CA certificate differs")
+	}
+
+	info, err := os.Stat(filepath.Join(dir, CAKeyFile))
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("Expected CA key mode 0600, got %o", perm)
+	}
+
+	// Saving again must not overwrite the existing CA
+	if err := ca.Save(dir); err == nil {
+		t.Error("Expected error when CA files already exist")
+	}
+}
+
+func TestAgentIDFromCertificate_CommonNameFallback(t *testing.T) {
+	cert := &x509.Certificate{}
+	cert.Subject.CommonName = "qa-agent"
+
+	if id, ok := AgentIDFromCertificate(cert); !ok || id != "qa-agent" {
+		t.Errorf("Expected qa-agent from common name, got %q", id)
+	}
+	if _, ok := AgentIDFromCertificate(&x509.Certificate{}); ok {
+		t.Error("Expected no agent ID for empty certificate")
+	}
+}
+
+func TestNewServerTLSConfig_Modes(t *testing.T) {
+	dir, _ := newTestPKI(t, "pm-agent")
+	base := ServerConfig{
+		CertFile: filepath.Join(dir, "pm-agent.crt"),
+		KeyFile:  filepath.Join(dir, "pm-agent.key"),
+		CAFile:   filepath.Join(dir, CACertFile),
+	}
+
+	tests := []struct {
+		mode string
+		want tls.ClientAuthType
+	}{
+		{"", tls.NoClientCert},
+		{ClientAuthNone, tls.NoClientCert},
+		{ClientAuthRequest, tls.VerifyClientCertIfGiven},
+		{ClientAuthRequire, tls.RequireAndVerifyClientCert},
+	}
+	for _, tt := range tests {
+		cfg := base
+		cfg.ClientAuth = tt.mode
+		tlsCfg, err := NewServerTLSConfig(cfg)
+		if err != nil {
+			t.Fatalf("mode %q: %v", tt.mode, err)
+		}
+		if tlsCfg.ClientAuth != tt.want {
+			t.Errorf("mode %q: expected %v, got %v", tt.mode, tt.want, tlsCfg.ClientAuth)
+		}
+	}
+
+	bad := base
+	bad.ClientAuth = "sometimes"
+	if _, err := NewServerTLSConfig(bad); err == nil {
+		t.Error("Expected error for unknown client auth mode")
+	}
+
+	noCA := base
+	noCA.ClientAuth = ClientAuthRequire
+	noCA.CAFile = ""
+	if _, err := NewServerTLSConfig(noCA); err == nil {
+		t.Error("Expected error for mutual TLS without a CA")
+	}
+
+	if _, err := NewServerTLSConfig(ServerConfig{}); err == nil {
+		t.Error("Expected error without certificate files")
+	}
+}
+
+// newMTLSServer serves the agent ID seen by Middleware over mutual TLS
+func newMTLSServer(t *testing.T, dir string) *httptest.Server {
+	t.Helper()
+	tlsCfg, err := NewServerTLSConfig(ServerConfig{
+		CertFile:   filepath.Join(dir, "pm-agent.crt"),
+		KeyFile:    filepath.Join(dir, "pm-agent.key"),
+		CAFile:     filepath.Join(dir, CACertFile),
+		ClientAuth: ClientAuthRequire,
+	})
+	if err != nil {
+		t.Fatalf("NewServerTLSConfig failed: %v", err)
+	}
+
+	ts := httptest.NewUnstartedServer(Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, GetAgentIDFromContext(r.Context()))
+	})))
+	ts.TLS = tlsCfg
+	ts.StartTLS()
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func newAgentClient(t *testing.T, dir, agentID string) *http.Client {
+	t.Helper()
+	certFile, keyFile := "", ""
+	if agentID != "" {
+		certFile, keyFile = filepath.Join(dir, agentID+".crt"), filepath.Join(dir, agentID+".key")
+	}
+	tlsCfg, err := NewClientTLSConfig(certFile, keyFile, filepath.Join(dir, CACertFile))
+	if err != nil {
+		t.Fatalf("NewClientTLSConfig failed: %v", err)
+	}
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: tlsCfg}}
+}
+
+func TestMiddleware_MutualTLS(t *testing.T) {
+	dir, _ := newTestPKI(t, "pm-agent", "eng-agent")
+	ts := newMTLSServer(t, dir)
+
+	resp, err := newAgentClient(t, dir, "eng-agent").Get(ts.URL)
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "eng-agent" {
+		t.Errorf("Expected agent ID eng-agent, got %q", body)
+	}
+}
+
+func TestMiddleware_RejectsMismatchedClaim(t *testing.T) {
+	dir, _ := newTestPKI(t, "pm-agent", "eng-agent")
+	ts := newMTLSServer(t, dir)
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+	req.Header.Set("X-Agent-ID", "pm-agent")
+	resp, err := newAgentClient(t, dir, "eng-agent").Do(req)
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected 403, got %d", resp.StatusCode)
+	}
+}
+
+func TestMiddleware_RequireRejectsMissingCert(t *testing.T) {
+	dir, _ := newTestPKI(t, "pm-agent")
+	ts := newMTLSServer(t, dir)
+
+	resp, err := newAgentClient(t, dir, "").Get(ts.URL)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("Expected handshake failure without a client certificate")
+	}
+}
+
+func TestMiddleware_RejectsForeignCA(t *testing.T) {
+	dir, _ := newTestPKI(t, "pm-agent")
+	ts := newMTLSServer(t, dir)
+
+	// A certificate for the same agent ID from a different CA is not trusted
+	otherDir, _ := newTestPKI(t, "eng-agent")
+	certFile, keyFile := filepath.Join(otherDir, "eng-agent.crt"), filepath.Join(otherDir, "eng-agent.key")
+	tlsCfg, err := NewClientTLSConfig(certFile, keyFile, filepath.Join(dir, CACertFile))
+	if err != nil {
+		t.Fatalf("NewClientTLSConfig failed: %v", err)
+	}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsCfg}}
+
+	resp, err := client.Get(ts.URL)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("Expected handshake failure for a certificate from another CA")
+	}
+}
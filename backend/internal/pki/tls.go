@@ -0,0 +1,151 @@
+package pki
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+)
+
+// Client authentication modes for agent servers
+const (
+	// ClientAuthNone serves TLS without asking for client certificates
+	ClientAuthNone = "none"
+	// ClientAuthRequest verifies a client certificate when one is presented
+	ClientAuthRequest = "request"
+	// ClientAuthRequire rejects connections without a verified client certificate
+	ClientAuthRequire = "require"
+)
+
+// Errors returned when authenticating a request by its client certificate
+var (
+	ErrAgentMismatch = errors.New("claimed agent ID does not match client certificate")
+)
+
+// contextKey is used for context values
+type contextKey string
+
+// ContextKeyAgentID is the context key for the agent ID proven by a client certificate
+const ContextKeyAgentID contextKey = "pki_agent_id"
+
+// ServerConfig holds the files and mode used to serve TLS
+type ServerConfig struct {
+	CertFile string
+	KeyFile  string
+	// CAFile verifies client certificates; required unless ClientAuth is none
+	CAFile     string
+	ClientAuth string
+}
+
+// NewServerTLSConfig builds the TLS configuration for an agent server
+func NewServerTLSConfig(cfg ServerConfig) (*tls.Config, error) {
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return nil, errors.New("TLS certificate and key files are required")
+	}
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS key pair: %w", err)
+	}
+
+	tlsCfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	switch cfg.ClientAuth {
+	case "", ClientAuthNone:
+		return tlsCfg, nil
+	case ClientAuthRequest:
+		tlsCfg.ClientAuth = tls.VerifyClientCertIfGiven
+	case ClientAuthRequire:
+		tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+	default:
+		return nil, fmt.Errorf("unknown client auth mode %q", cfg.ClientAuth)
+	}
+
+	if cfg.CAFile == "" {
+		return nil, fmt.Errorf("a CA file is required for client auth mode %q", cfg.ClientAuth)
+	}
+	pool, err := LoadCertPool(cfg.CAFile)
+	if err != nil {
+		return nil, err
+	}
+	tlsCfg.ClientCAs = pool
+	return tlsCfg, nil
+}
+
+// NewClientTLSConfig builds the TLS configuration an agent uses to call other
+// agents: peers are verified against caFile (system roots when empty) and the
+// agent's own certificate, if given, is presented for mutual TLS.
+func NewClientTLSConfig(certFile, keyFile, caFile string) (*tls.Config, error) {
+	tlsCfg := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if caFile != "" {
+		pool, err := LoadCertPool(caFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsCfg.RootCAs = pool
+	}
+	if certFile != "" && keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS key pair: %w", err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+	return tlsCfg, nil
+}
+
+// LoadCertPool reads PEM certificates from path into a pool
+func LoadCertPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("%s: no PEM certificates", path)
+	}
+	return pool, nil
+}
+
+// AgentIDFromRequest returns the agent ID proven by the request's verified client certificate
+func AgentIDFromRequest(r *http.Request) (string, bool) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return "", false
+	}
+	return AgentIDFromCertificate(r.TLS.VerifiedChains[0][0])
+}
+
+// Middleware maps a verified client certificate to its agent ID and stores it in
+// the request context. A request whose X-Agent-ID header names a different agent
+// is rejected, so certificate holders cannot act as someone else.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		agentID, ok := AgentIDFromRequest(r)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if claimed := r.Header.Get("X-Agent-ID"); claimed != "" && claimed != agentID {
+			log.Printf("mTLS auth failed: %v (certificate: %s, claimed: %s)", ErrAgentMismatch, agentID, claimed)
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), ContextKeyAgentID, agentID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// GetAgentIDFromContext retrieves the certificate-proven agent ID from the request context
+func GetAgentIDFromContext(ctx context.Context) string {
+	agentID, _ := ctx.Value(ContextKeyAgentID).(string)
+	return agentID
+}
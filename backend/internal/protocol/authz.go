@@ -0,0 +1,242 @@
+package protocol
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/aoi-protocol/aoi/internal/pki"
+	"github.com/aoi-protocol/aoi/internal/rpc"
+	"github.com/aoi-protocol/aoi/internal/signing"
+	"github.com/aoi-protocol/aoi/internal/tailscale"
+)
+
+// Ways a caller can be authenticated, strongest first
+const (
+	AuthViaCertificate = "mtls"
+	AuthViaSignature   = "signature"
+	AuthViaTailscale   = "tailscale"
+	AuthViaToken       = "token"
+)
+
+// Caller is the sender of a request as far as it could be proven. Agent IDs a
+// caller merely claims, e.g. in params, are never used for authorization.
+type Caller struct {
+	// AgentID is "" for an unauthenticated caller
+	AgentID string
+	// Via is how AgentID was proven, one of the AuthVia constants
+	Via string
+	// Node is the caller's Tailscale node when the request came over Tailscale
+	Node *tailscale.NodeInfo
+}
+
+// routePermission is the ACL permission a REST route requires
+type routePermission struct {
+	Resource string
+	Action   string
+}
+
+// publicRoutes are served without a REST permission check: /health is public
+// and /api/v1/rpc is authorized per method
+var publicRoutes = map[string]bool{
+	"/health":     true,
+	"/api/v1/rpc": true,
+}
+
+// restPermissions declares the permission each REST route requires, by path
+// and HTTP method. With ACL enforcement on, routes and methods missing from
+// both lists are denied.
+var restPermissions = map[string]map[string]routePermission{
+	"/api/agents": {
+		http.MethodGet:  {"agents/discover", rpc.ActionRead},
+		http.MethodPost: {"agents/register", rpc.ActionWrite},
+	},
+	"/api/query":              {http.MethodPost: {"queries/send", rpc.ActionRead}},
+	"/api/v1/ws":              {http.MethodGet: {"events/subscribe", rpc.ActionRead}},
+	"/api/v1/context":         {http.MethodGet: {"context/summary", rpc.ActionRead}},
+	"/api/v1/context/history": {http.MethodGet: {"context/history", rpc.ActionRead}},
+	"/api/v1/context/stats":   {http.MethodGet: {"context/stats", rpc.ActionRead}},
+	"/api/v1/context/watch": {
+		http.MethodGet:    {"context/watch", rpc.ActionRead},
+		http.MethodPost:   {"context/watch", rpc.ActionWrite},
+		http.MethodDelete: {"context/watch", rpc.ActionWrite},
+	},
+	"/api/v1/context/activity": {http.MethodPost: {"context/activity", rpc.ActionWrite}},
+}
+
+// contextKey is used for context values
+type contextKey string
+
+// contextKeyTokenAgentID is the context key for the agent ID proven by a bearer token
+const contextKeyTokenAgentID contextKey = "token_agent_id"
+
+// SetACLEnforced makes every JSON-RPC method and REST route check the caller's
+// permission for the resource and action it declares before running
+func (s *Server) SetACLEnforced(enforced bool) {
+	s.enforceACL = enforced
+}
+
+// SetAuthTokens sets the bearer tokens that authenticate callers, mapping each
+// token to the agent ID it proves
+func (s *Server) SetAuthTokens(tokens map[string]string) {
+	s.tokens = tokens
+}
+
+// SetTailscale identifies callers by their Tailscale node and, when ACL
+// enforcement is on, also requires the node's tag permissions
+func (s *Server) SetTailscale(ts *tailscale.Integration) {
+	s.tsAuth = ts.Auth
+	s.tsACL = ts.ACL
+}
+
+// callerFrom returns the proven sender of a request: by client certificate,
+// request signature, Tailscale node or bearer token, in that order
+func callerFrom(ctx context.Context) Caller {
+	caller := Caller{Node: tailscale.GetNodeInfoFromContext(ctx)}
+	switch {
+	case pki.GetAgentIDFromContext(ctx) != "":
+		caller.AgentID, caller.Via = pki.GetAgentIDFromContext(ctx), AuthViaCertificate
+	case signing.GetAgentIDFromContext(ctx) != "":
+		caller.AgentID, caller.Via = signing.GetAgentIDFromContext(ctx), AuthViaSignature
+	case tailscale.GetAgentIDFromContext(ctx) != "":
+		caller.AgentID, caller.Via = tailscale.GetAgentIDFromContext(ctx), AuthViaTailscale
+	default:
+		if agentID, _ := ctx.Value(contextKeyTokenAgentID).(string); agentID != "" {
+			caller.AgentID, caller.Via = agentID, AuthViaToken
+		}
+	}
+	return caller
+}
+
+// AuthenticatedAgent returns the agent a request to the server proved it is,
+// or "", for subsystems whose methods need the caller
+func AuthenticatedAgent(ctx context.Context) string {
+	return authenticatedAgent(ctx)
+}
+
+// connectionContext carries the identity proven for a connection's upgrade
+// request over to the requests later sent on that connection
+func connectionContext(r *http.Request) context.Context {
+	ctx := context.Background()
+	for _, key := range []interface{}{pki.ContextKeyAgentID, tailscale.ContextKeyNodeInfo, tailscale.ContextKeyAgentID, contextKeyTokenAgentID} {
+		if v := r.Context().Value(key); v != nil {
+			ctx = context.WithValue(ctx, key, v)
+		}
+	}
+	return ctx
+}
+
+// checkPermission decides whether caller may perform action on resource.
+// It returns "" when allowed, else why not.
+func (s *Server) checkPermission(caller Caller, resource, action string) string {
+	if result := s.aclMgr.CheckPermission(caller.AgentID, resource, action); !result.Allowed {
+		return result.Reason
+	}
+	if caller.Node != nil && s.tsACL != nil {
+		if result := s.tsACL.CheckPermissionForTags(caller.Node.Tags, resource, action); !result.Allowed {
+			return fmt.Sprintf("Tailscale node %s: %s", caller.Node.Name, result.Reason)
+		}
+	}
+	return ""
+}
+
+// isAdmin reports whether an authenticated caller holds admin permission on resource
+func (s *Server) isAdmin(caller Caller, resource string) bool {
+	return caller.AgentID != "" && s.checkPermission(caller, resource, rpc.ActionAdmin) == ""
+}
+
+// taskScope is the task.Scope of aoi.task.*: the caller, who sees every task
+// with admin permission on tasks/all
+func (s *Server) taskScope(ctx context.Context) (string, bool) {
+	caller := callerFrom(ctx)
+	return caller.AgentID, s.isAdmin(caller, "tasks/all")
+}
+
+// authorize checks the caller's permission for the resource and action a
+// method declares. Methods without a resource, such as rpc.discover, are public.
+func (s *Server) authorize(ctx context.Context, method string) *JSONRPCError {
+	if !s.enforceACL {
+		return nil
+	}
+	m, ok := s.methods.Lookup(method)
+	if !ok || m.Resource == "" {
+		return nil
+	}
+	return s.authorizeResource(ctx, m.Resource, m.Action)
+}
+
+// authorizeResource checks the caller's permission for action on resource,
+// for what a method does beyond the resource it declares
+func (s *Server) authorizeResource(ctx context.Context, resource, action string) *JSONRPCError {
+	if !s.enforceACL {
+		return nil
+	}
+
+	caller := callerFrom(ctx)
+	reason := s.checkPermission(caller, resource, action)
+	if reason == "" {
+		return nil
+	}
+	data := map[string]string{"resource": resource, "action": action, "reason": reason}
+	if caller.AgentID == "" {
+		return &JSONRPCError{Code: JSONRPCUnauthenticated, Message: "Authentication required", Data: data}
+	}
+	data["agent_id"] = caller.AgentID
+	return &JSONRPCError{Code: JSONRPCACLDenied,
+		Message: fmt.Sprintf("agent '%s' is not allowed to %s %s", caller.AgentID, action, resource), Data: data}
+}
+
+// authenticateHTTP records the agent proven by a bearer token and, when ACL
+// enforcement is on, checks the permission of REST routes
+func (s *Server) authenticateHTTP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token, ok := bearerToken(r); ok && len(s.tokens) > 0 {
+			agentID := s.tokenAgent(token)
+			if agentID == "" {
+				http.Error(w, "Unauthorized: invalid token", http.StatusUnauthorized)
+				return
+			}
+			r = r.WithContext(context.WithValue(r.Context(), contextKeyTokenAgentID, agentID))
+		}
+
+		if s.enforceACL && !publicRoutes[r.URL.Path] {
+			perm, ok := restPermissions[r.URL.Path][r.Method]
+			if !ok {
+				http.Error(w, fmt.Sprintf("Forbidden: no permission is declared for %s %s", r.Method, r.URL.Path), http.StatusForbidden)
+				return
+			}
+			caller := callerFrom(r.Context())
+			if reason := s.checkPermission(caller, perm.Resource, perm.Action); reason != "" {
+				if caller.AgentID == "" {
+					http.Error(w, "Unauthorized: "+reason, http.StatusUnauthorized)
+				} else {
+					http.Error(w, "Forbidden: "+reason, http.StatusForbidden)
+				}
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// tokenAgent returns the agent a bearer token proves, or ""
+func (s *Server) tokenAgent(token string) string {
+	for known, agentID := range s.tokens {
+		if subtle.ConstantTimeCompare([]byte(known), []byte(token)) == 1 {
+			return agentID
+		}
+	}
+	return ""
+}
+
+// bearerToken extracts the token of an "Authorization: Bearer" header
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+	return token, true
+}
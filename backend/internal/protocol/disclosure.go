@@ -0,0 +1,241 @@
+package protocol
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aoi-protocol/aoi/internal/audit"
+	aoicontext "github.com/aoi-protocol/aoi/internal/context"
+	"github.com/aoi-protocol/aoi/internal/disclosure"
+	"github.com/aoi-protocol/aoi/internal/mcp"
+	"github.com/aoi-protocol/aoi/internal/rpc"
+	"github.com/aoi-protocol/aoi/internal/secretary"
+	"github.com/aoi-protocol/aoi/internal/task"
+)
+
+// MetaDisclosure is the metadata key naming the level a restricted answer was reduced to
+const MetaDisclosure = "disclosure"
+
+// SetDisclosure sets the level of requesters without read permission on
+// disclosure/full or disclosure/analysis
+func (s *Server) SetDisclosure(level disclosure.Level) {
+	s.disclosure = level
+}
+
+// disclosureLevel returns how much the caller in ctx may see: the most its
+// ACL permissions allow, and at least the default level
+func (s *Server) disclosureLevel(ctx context.Context) disclosure.Level {
+	if s.disclosure == disclosure.Full {
+		return disclosure.Full
+	}
+	caller := callerFrom(ctx)
+	if s.checkPermission(caller, disclosure.ResourceFull, rpc.ActionRead) == "" {
+		return disclosure.Full
+	}
+	if s.disclosure < disclosure.Analysis && s.checkPermission(caller, disclosure.ResourceAnalysis, rpc.ActionRead) == "" {
+		return disclosure.Analysis
+	}
+	return s.disclosure
+}
+
+// restrictAnswer reduces an answer to what the caller in ctx may see. At the
+// Boolean level the sources and metadata go too, as they name files.
+func (s *Server) restrictAnswer(ctx context.Context, from string, req secretary.QueryRequest, resp *secretary.QueryResponse) *secretary.QueryResponse {
+	level := s.disclosureLevel(ctx)
+	if level == disclosure.Full {
+		return resp
+	}
+
+	answer, report := disclosure.Restrict(ctx, level, s.secretary.Provider(), req.Query, resp.Answer)
+	restricted := &secretary.QueryResponse{Answer: answer, Confidence: resp.Confidence, Metadata: map[string]string{}}
+	if level != disclosure.Boolean {
+		restricted.Sources = resp.Sources
+		for k, v := range resp.Metadata {
+			restricted.Metadata[k] = v
+		}
+	} else if len(resp.Sources) > 0 || len(resp.Metadata) > 0 {
+		report.Changed = true
+	}
+	restricted.Metadata[MetaDisclosure] = level.String()
+
+	s.auditDisclosure(from, s.focusMgr.LocalAgent(), "aoi.query", req.Query, report)
+	return restricted
+}
+
+// restrictTask reduces a task's output and error to what the caller in ctx
+// may see: code is stripped at the Analysis level, and at the Boolean level
+// the output becomes whether the task succeeded.
+func (s *Server) restrictTask(ctx context.Context, info *task.Info) *task.Info {
+	level := s.disclosureLevel(ctx)
+	if level == disclosure.Full || (info.Output == "" && info.Error == "") {
+		return info
+	}
+
+	restricted := *info
+	report := disclosure.Report{Level: level}
+	if level == disclosure.Boolean {
+		restricted.Output, restricted.Error = taskVerdict(info.State), ""
+		report.Changed = restricted.Output != info.Output || info.Error != ""
+	} else {
+		var stripped disclosure.Report
+		restricted.Output, report = disclosure.StripCode(info.Output)
+		restricted.Error, stripped = disclosure.StripCode(info.Error)
+		report.CodeBlocks += stripped.CodeBlocks
+		report.CodeLines += stripped.CodeLines
+		report.Changed = report.Changed || stripped.Changed
+	}
+	if report.Changed {
+		s.auditDisclosure(callerFrom(ctx).AgentID, s.focusMgr.LocalAgent(), "aoi.task",
+			fmt.Sprintf("task %s (%s)", info.TaskID, info.Type), report)
+	}
+	return &restricted
+}
+
+// taskVerdict answers whether a task succeeded, or unknown while it runs
+func taskVerdict(state task.State) string {
+	switch state {
+	case task.StateSucceeded:
+		return disclosure.Yes
+	case task.StateFailed, task.StateCancelled, task.StateTimedOut:
+		return disclosure.No
+	}
+	return disclosure.Unknown
+}
+
+// filterHistory restricts and then redacts the context history served to
+// the caller in ctx
+func (s *Server) filterHistory(ctx context.Context, history *aoicontext.ContextHistory) {
+	s.restrictHistory(ctx, history)
+	s.redactHistory(history)
+}
+
+// restrictHistory strips code from the contents and summaries of context
+// entries at the Analysis level, or withholds the entries at the Boolean
+// level, leaving only whether there are any
+func (s *Server) restrictHistory(ctx context.Context, history *aoicontext.ContextHistory) {
+	level := s.disclosureLevel(ctx)
+	if level == disclosure.Full || len(history.Entries) == 0 {
+		return
+	}
+
+	report := disclosure.Report{Level: level}
+	if level == disclosure.Boolean {
+		report.Changed, report.Withheld = true, len(history.Entries)
+		history.Entries = []aoicontext.ContextEntry{}
+	} else {
+		for i := range history.Entries {
+			entry := &history.Entries[i]
+			for _, text := range []*string{&entry.Content, &entry.Summary} {
+				var stripped disclosure.Report
+				*text, stripped = disclosure.StripCode(*text)
+				report.CodeBlocks += stripped.CodeBlocks
+				report.CodeLines += stripped.CodeLines
+				report.Changed = report.Changed || stripped.Changed
+			}
+		}
+	}
+	if report.Changed {
+		s.auditDisclosure(callerFrom(ctx).AgentID, s.focusMgr.LocalAgent(), "aoi.context.history",
+			fmt.Sprintf("%d entries", report.Withheld+len(history.Entries)), report)
+	}
+}
+
+// auditDisclosure records that output on path was reduced to report.Level
+func (s *Server) auditDisclosure(from, to, path, summary string, report disclosure.Report) {
+	details := report.Details()
+	details["path"] = path
+	s.auditLogger.Log(audit.EventDisclosure, from, to,
+		fmt.Sprintf("%s output restricted to %s: %s", path, report.Level, summary), details, true, "")
+}
+
+// mcpOutputFilter applies the disclosure policy and redaction to
+// aoi.mcp.call and aoi.mcp.read
+type mcpOutputFilter struct {
+	s *Server
+}
+
+// FilterToolResult restricts and then redacts a tool's output
+func (f mcpOutputFilter) FilterToolResult(ctx context.Context, serverName, toolName string, result *mcp.CallToolResult) *mcp.CallToolResult {
+	if result == nil {
+		return nil
+	}
+	result = f.restrictToolResult(ctx, serverName, toolName, result)
+	if f.s.redactor == nil {
+		return result
+	}
+	return f.redactToolResult(ctx, serverName, toolName, result)
+}
+
+// FilterResource restricts and then redacts a resource's contents
+func (f mcpOutputFilter) FilterResource(ctx context.Context, serverName, uri string, contents []mcp.ResourceContent) []mcp.ResourceContent {
+	contents = f.restrictResource(ctx, serverName, uri, contents)
+	if f.s.redactor == nil {
+		return contents
+	}
+	return f.redactResource(ctx, serverName, uri, contents)
+}
+
+// restrictToolResult strips code from a tool's text output and withholds
+// other content at the Analysis level, or reduces it to whether the call
+// succeeded
+func (f mcpOutputFilter) restrictToolResult(ctx context.Context, serverName, toolName string, result *mcp.CallToolResult) *mcp.CallToolResult {
+	level := f.s.disclosureLevel(ctx)
+	if level == disclosure.Full {
+		return result
+	}
+
+	report := disclosure.Report{Level: level, Changed: true}
+	filtered := &mcp.CallToolResult{IsError: result.IsError}
+	if level == disclosure.Boolean {
+		verdict := disclosure.Yes
+		if result.IsError {
+			verdict = disclosure.No
+		}
+		filtered.Content = []mcp.ContentBlock{mcp.NewTextContent(verdict)}
+	} else {
+		report.Changed = false
+		for _, block := range result.Content {
+			if block.Type != "text" {
+				report.Withheld++
+				report.Changed = true
+				filtered.Content = append(filtered.Content, mcp.NewTextContent(disclosure.ContentWithheld))
+				continue
+			}
+			text, stripped := disclosure.StripCode(block.Text)
+			report.CodeBlocks += stripped.CodeBlocks
+			report.CodeLines += stripped.CodeLines
+			report.Changed = report.Changed || stripped.Changed
+			filtered.Content = append(filtered.Content, mcp.NewTextContent(text))
+		}
+	}
+
+	f.s.auditDisclosure(callerFrom(ctx).AgentID, serverName, "aoi.mcp.call", toolName, report)
+	return filtered
+}
+
+// restrictResource withholds a resource's contents at the Analysis level,
+// keeping what they are, or reduces them to the resource being readable
+func (f mcpOutputFilter) restrictResource(ctx context.Context, serverName, uri string, contents []mcp.ResourceContent) []mcp.ResourceContent {
+	level := f.s.disclosureLevel(ctx)
+	if level == disclosure.Full {
+		return contents
+	}
+
+	report := disclosure.Report{Level: level, Changed: len(contents) > 0, Withheld: len(contents)}
+	var filtered []mcp.ResourceContent
+	if level == disclosure.Boolean {
+		filtered = []mcp.ResourceContent{{URI: uri, Text: disclosure.Yes}}
+	} else {
+		for _, content := range contents {
+			size := len(content.Text) + len(content.Blob)
+			filtered = append(filtered, mcp.ResourceContent{
+				URI:      content.URI,
+				MimeType: content.MimeType,
+				Text:     fmt.Sprintf("%s (%d bytes)", disclosure.ContentWithheld, size),
+			})
+		}
+	}
+
+	f.s.auditDisclosure(callerFrom(ctx).AgentID, serverName, "aoi.mcp.read", uri, report)
+	return filtered
+}
@@ -0,0 +1,110 @@
+package protocol
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/aoi-protocol/aoi/internal/audit"
+	"github.com/aoi-protocol/aoi/internal/focus"
+	"github.com/aoi-protocol/aoi/internal/rpc"
+	"github.com/aoi-protocol/aoi/internal/secretary"
+)
+
+// Metadata keys of a query response that was queued or escalated rather than answered
+const (
+	MetaFocusAction = "focus_action"
+	MetaQueuedID    = "queued_id"
+	MetaFocusReason = "focus_reason"
+)
+
+// answerLocal answers a query addressed to this agent, unless its human is
+// focused and the focus policy queues or escalates the query. Answers below
+// the focus manager's minimum confidence are queued too, so the human answers
+// them later instead of the secretary guessing. Answers are restricted to the
+// sender's disclosure level and redacted before they are returned.
+func (s *Server) answerLocal(ctx context.Context, req secretary.QueryRequest) (*secretary.QueryResponse, error) {
+	agentID := s.focusMgr.LocalAgent()
+	q := focus.Query{
+		FromAgent:    req.FromAgent,
+		Priority:     req.Priority,
+		Query:        req.Query,
+		ContextScope: req.ContextScope,
+	}
+	// Rules on the sender's role only trust a proven sender
+	if caller := authenticatedAgent(ctx); caller != "" {
+		q.FromAgent = caller
+		if agent, err := s.registry.GetAgent(caller); err == nil {
+			q.SenderRole = string(agent.Role)
+		}
+	}
+
+	decision := s.focusMgr.Decide(agentID, q)
+	if decision.Action != focus.ActionAnswer {
+		return s.deferQuery(agentID, q, decision.Action, decision.Reason), nil
+	}
+	// Only callers who may delegate reach the worker in fallback mode
+	if s.authorizeResource(ctx, secretary.ResourceDelegate, rpc.ActionExecute) != nil {
+		ctx = secretary.WithoutDelegation(ctx)
+	}
+
+	resp, err := s.secretary.HandleQueryContext(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if !decision.Focused {
+		return s.redactAnswer(q.FromAgent, req, s.restrictAnswer(ctx, q.FromAgent, req, resp)), nil
+	}
+	if minConfidence := s.focusMgr.MinConfidence(); resp.Confidence < minConfidence {
+		reason := fmt.Sprintf("answer confidence %.2f is below %.2f", resp.Confidence, minConfidence)
+		return s.deferQuery(agentID, q, focus.ActionQueue, reason), nil
+	}
+
+	s.auditLogger.Log(audit.EventFocus, q.FromAgent, agentID, q.Query,
+		map[string]interface{}{"action": string(focus.ActionAnswer), "reason": decision.Reason, "confidence": resp.Confidence}, true, "")
+	return s.redactAnswer(q.FromAgent, req, s.restrictAnswer(ctx, q.FromAgent, req, resp)), nil
+}
+
+// deferQuery queues or escalates a query for agentID's human and tells the
+// sender so
+func (s *Server) deferQuery(agentID string, q focus.Query, action focus.Action, reason string) *secretary.QueryResponse {
+	var queued focus.QueuedQuery
+	var answer string
+	if action == focus.ActionEscalate {
+		queued = s.focusMgr.Escalate(agentID, q, reason)
+		answer = fmt.Sprintf("%s is focused; your query was escalated to them and will be answered shortly", agentID)
+	} else {
+		queued = s.focusMgr.Enqueue(agentID, q, reason)
+		answer = fmt.Sprintf("%s is focused; your query was queued and will be answered when they are available", agentID)
+	}
+
+	s.auditLogger.Log(audit.EventFocus, q.FromAgent, agentID, q.Query,
+		map[string]interface{}{"action": string(action), "reason": reason, "queued_id": queued.ID}, true, "")
+	return &secretary.QueryResponse{
+		Answer: answer,
+		Metadata: map[string]string{
+			MetaFocusAction: string(action),
+			MetaQueuedID:    queued.ID,
+			MetaFocusReason: reason,
+		},
+	}
+}
+
+// broadcastEscalation alerts the focused human's WebSocket connections to a query
+func (s *Server) broadcastEscalation(agentID string, q focus.QueuedQuery) {
+	log.Printf("[Focus] Escalated query %s from %s to %s: %s", q.ID, q.Query.FromAgent, agentID, q.Reason)
+	_ = s.wsHub.SendToAgent(agentID, MessageTypeEscalation, map[string]interface{}{
+		"agent_id": agentID,
+		"query":    q,
+	})
+}
+
+// broadcastFocusDigest sends the human's WebSocket connections the queries
+// queued while they were focused
+func (s *Server) broadcastFocusDigest(digest focus.Digest) {
+	log.Printf("[Focus] Focus of %s ended with %d queued queries", digest.AgentID, len(digest.Queries))
+	s.auditLogger.Log(audit.EventFocus, "", digest.AgentID,
+		fmt.Sprintf("focus ended: digest of %d queued queries", len(digest.Queries)),
+		map[string]interface{}{"focused_since": digest.FocusedSince, "queued": len(digest.Queries)}, true, "")
+	_ = s.wsHub.SendToAgent(digest.AgentID, MessageTypeFocusDigest, digest)
+}
@@ -0,0 +1,160 @@
+package protocol
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/aoi-protocol/aoi/internal/secretary"
+	"github.com/aoi-protocol/aoi/pkg/aoi"
+)
+
+// ForwardTimeoutMargin is how much longer than a delegation to a worker AI a
+// forwarded call may take, leaving time for the network and the remote agent.
+const ForwardTimeoutMargin = 30 * time.Second
+
+// DefaultForwardTimeout bounds a single agent-to-agent call. It outlasts the
+// default delegation timeout so a query is not abandoned while the remote
+// agent's worker is still answering it.
+const DefaultForwardTimeout = secretary.DefaultDelegationTimeout + ForwardTimeoutMargin
+
+// rpcPath is the JSON-RPC endpoint path exposed by every AOI agent.
+const rpcPath = "/api/v1/rpc"
+
+// AgentForwarder relays JSON-RPC calls to other agents via their advertised endpoint.
+type AgentForwarder struct {
+	httpClient *http.Client
+	requestID  int64
+
+	// agentID and key sign forwarded calls when set
+	agentID string
+	key     ed25519.PrivateKey
+}
+
+// NewAgentForwarder creates a forwarder whose calls time out after timeout.
+func NewAgentForwarder(timeout time.Duration) *AgentForwarder {
+	if timeout <= 0 {
+		timeout = DefaultForwardTimeout
+	}
+	return &AgentForwarder{
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+// SetTimeout sets how long a call may take; 0 uses DefaultForwardTimeout
+func (f *AgentForwarder) SetTimeout(timeout time.Duration) {
+	if timeout <= 0 {
+		timeout = DefaultForwardTimeout
+	}
+	f.httpClient.Timeout = timeout
+}
+
+// Timeout returns how long a call may take
+func (f *AgentForwarder) Timeout() time.Duration {
+	return f.httpClient.Timeout
+}
+
+// SetTLSConfig sets the TLS configuration for calls to https:// endpoints
+func (f *AgentForwarder) SetTLSConfig(tlsConfig *tls.Config) {
+	f.httpClient = &http.Client{
+		Timeout:   f.httpClient.Timeout,
+		Transport: &http.Transport{TLSClientConfig: tlsConfig},
+	}
+}
+
+// SetSigner makes the forwarder sign its calls as agentID with key
+func (f *AgentForwarder) SetSigner(agentID string, key ed25519.PrivateKey) {
+	f.agentID = agentID
+	f.key = key
+}
+
+// Query sends an aoi.query to the target agent and returns its secretary's response.
+// A signing forwarder sends the query as its own agent, since that is who the
+// target authenticates, and names the original sender in "forwarded_for".
+func (f *AgentForwarder) Query(ctx context.Context, target *aoi.AgentIdentity, req secretary.QueryRequest) (*secretary.QueryResponse, error) {
+	req.ToAgent = target.ID
+	if f.key != nil && req.FromAgent != f.agentID {
+		metadata := make(map[string]string, len(req.Metadata)+1)
+		for k, v := range req.Metadata {
+			metadata[k] = v
+		}
+		if req.FromAgent != "" {
+			metadata["forwarded_for"] = req.FromAgent
+		}
+		req.FromAgent, req.Metadata = f.agentID, metadata
+	}
+
+	var resp secretary.QueryResponse
+	if err := f.Call(ctx, target, "aoi.query", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Call invokes method on the target agent and decodes the result into result.
+// A JSON-RPC error returned by the remote agent is surfaced as *JSONRPCError.
+func (f *AgentForwarder) Call(ctx context.Context, target *aoi.AgentIdentity, method string, params interface{}, result interface{}) error {
+	if target.Endpoint == "" {
+		return fmt.Errorf("agent %s has no endpoint", target.ID)
+	}
+
+	paramsJSON, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("failed to marshal params: %w", err)
+	}
+
+	rpcReq := JSONRPCRequest{
+		JSONRPC: "2.0",
+		Method:  method,
+		Params:  paramsJSON,
+		ID:      atomic.AddInt64(&f.requestID, 1),
+	}
+	if f.key != nil {
+		if rpcReq.Auth, err = aoi.SignRequest(f.key, f.agentID, method, paramsJSON); err != nil {
+			return fmt.Errorf("failed to sign request: %w", err)
+		}
+	}
+	body, err := json.Marshal(rpcReq)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	url := strings.TrimRight(target.Endpoint, "/") + rpcPath
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	httpResp, err := f.httpClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("request to agent %s failed: %w", target.ID, err)
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode != http.StatusOK {
+		return fmt.Errorf("agent %s returned HTTP %d", target.ID, httpResp.StatusCode)
+	}
+
+	var rpcResp JSONRPCResponse
+	if err := json.NewDecoder(httpResp.Body).Decode(&rpcResp); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	if rpcResp.Error != nil {
+		return rpcResp.Error
+	}
+
+	if result != nil && rpcResp.Result != nil {
+		if err := json.Unmarshal(rpcResp.Result, result); err != nil {
+			return fmt.Errorf("failed to unmarshal result: %w", err)
+		}
+	}
+	return nil
+}
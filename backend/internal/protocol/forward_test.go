@@ -0,0 +1,271 @@
+package protocol
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aoi-protocol/aoi/internal/audit"
+	"github.com/aoi-protocol/aoi/internal/identity"
+	"github.com/aoi-protocol/aoi/internal/pki"
+	"github.com/aoi-protocol/aoi/internal/secretary"
+	"github.com/aoi-protocol/aoi/internal/signing"
+	"github.com/aoi-protocol/aoi/pkg/aoi"
+)
+
+// newRemoteAgent starts an AOI server for agentID and returns it with its endpoint.
+func newRemoteAgent(t *testing.T, agentID string, role aoi.AgentRole) (*Server, string) {
+	t.Helper()
+	server := NewServer(nil, nil)
+	server.SetSecretary(secretary.NewSecretary(&aoi.AgentIdentity{ID: agentID, Role: role}))
+	ts := httptest.NewServer(server.mux)
+	t.Cleanup(ts.Close)
+	return server, ts.URL
+}
+
+func TestRPCQuery_LocalSecretary(t *testing.T) {
+	server := NewServer(nil, nil)
+	server.SetSecretary(secretary.NewSecretary(&aoi.AgentIdentity{ID: "pm-agent", Role: aoi.RolePM}))
+
+	body := rpcRequest("aoi.query", map[string]string{
+		"query":      "What is the status?",
+		"from_agent": "eng-agent",
+		"to_agent":   "pm-agent",
+	})
+	w := httptest.NewRecorder()
+	server.handleJSONRPC(w, httptest.NewRequest("POST", "/api/v1/rpc", body))
+
+	resp := decodeRPC(t, w)
+	if resp.Error != nil {
+		t.Fatalf("unexpected RPC error: %+v", resp.Error)
+	}
+
+	var result secretary.QueryResponse
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		t.Fatalf("decode result: %v", err)
+	}
+	if !strings.Contains(result.Answer, "PM Summary") {
+		t.Errorf("expected PM secretary answer, got %q", result.Answer)
+	}
+}
+
+func TestRPCQuery_ForwardsToRemoteAgent(t *testing.T) {
+	remote, endpoint := newRemoteAgent(t, "eng-agent", aoi.RoleEngineer)
+
+	registry := identity.NewAgentRegistry()
+	registry.Register(&aoi.AgentIdentity{ID: "eng-agent", Role: aoi.RoleEngineer, Endpoint: endpoint})
+	server := NewServer(registry, nil)
+	server.SetSecretary(secretary.NewSecretary(&aoi.AgentIdentity{ID: "pm-agent", Role: aoi.RolePM}))
+
+	body := rpcRequest("aoi.query", map[string]string{
+		"query":      "How is the API going?",
+		"from_agent": "pm-agent",
+		"to_agent":   "eng-agent",
+	})
+	w := httptest.NewRecorder()
+	server.handleJSONRPC(w, httptest.NewRequest("POST", "/api/v1/rpc", body))
+
+	resp := decodeRPC(t, w)
+	if resp.Error != nil {
+		t.Fatalf("unexpected RPC error: %+v", resp.Error)
+	}
+
+	var result secretary.QueryResponse
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		t.Fatalf("decode result: %v", err)
+	}
+	if !strings.Contains(result.Answer, "Engineer Summary") {
+		t.Errorf("expected engineer secretary answer, got %q", result.Answer)
+	}
+
+	logs := remote.secretary.GetQueryLogs()
+	if len(logs) != 1 || logs[0].FromAgent != "pm-agent" {
+		t.Errorf("expected remote secretary to log one query from pm-agent, got %+v", logs)
+	}
+}
+
+func TestRPCQuery_UnknownTarget(t *testing.T) {
+	server := NewServer(nil, nil)
+
+	body := rpcRequest("aoi.query", map[string]string{
+		"query":    "hello?",
+		"to_agent": "ghost-agent",
+	})
+	w := httptest.NewRecorder()
+	server.handleJSONRPC(w, httptest.NewRequest("POST", "/api/v1/rpc", body))
+
+	resp := decodeRPC(t, w)
+	if resp.Error == nil {
+		t.Fatal("expected agent-not-found error")
+	}
+	if resp.Error.Code != JSONRPCAgentNotFound {
+		t.Errorf("expected code %d, got %d", JSONRPCAgentNotFound, resp.Error.Code)
+	}
+}
+
+func TestRPCQuery_EmptyQuery(t *testing.T) {
+	server := NewServer(nil, nil)
+
+	body := rpcRequest("aoi.query", map[string]string{"from_agent": "pm-agent"})
+	w := httptest.NewRecorder()
+	server.handleJSONRPC(w, httptest.NewRequest("POST", "/api/v1/rpc", body))
+
+	resp := decodeRPC(t, w)
+	if resp.Error == nil || resp.Error.Code != JSONRPCInvalidParams {
+		t.Fatalf("expected invalid params error, got %+v", resp.Error)
+	}
+}
+
+func TestAgentForwarder_RemoteErrorIsTyped(t *testing.T) {
+	_, endpoint := newRemoteAgent(t, "eng-agent", aoi.RoleEngineer)
+
+	f := NewAgentForwarder(0)
+	target := &aoi.AgentIdentity{ID: "eng-agent", Endpoint: endpoint}
+	err := f.Call(context.Background(), target, "aoi.unknown", map[string]string{}, nil)
+	if err == nil {
+		t.Fatal("expected error for unknown remote method")
+	}
+	rpcErr, ok := err.(*JSONRPCError)
+	if !ok {
+		t.Fatalf("expected *JSONRPCError, got %T", err)
+	}
+	if rpcErr.Code != JSONRPCMethodNotFound {
+		t.Errorf("expected code %d, got %d", JSONRPCMethodNotFound, rpcErr.Code)
+	}
+}
+
+func TestAgentForwarder_NoEndpoint(t *testing.T) {
+	f := NewAgentForwarder(0)
+	_, err := f.Query(context.Background(), &aoi.AgentIdentity{ID: "eng-agent"}, secretary.QueryRequest{Query: "hi"})
+	if err == nil {
+		t.Fatal("expected error for agent without endpoint")
+	}
+}
+
+func TestRPCQuery_ForwardsOverMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca, err := pki.NewCA("Test CA", 0)
+	if err != nil {
+		t.Fatalf("NewCA: %v", err)
+	}
+	if err := ca.Save(dir); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	for _, id := range []string{"pm-agent", "eng-agent"} {
+		if _, _, err := ca.IssueAgentFiles(dir, id, []string{"127.0.0.1"}, 0); err != nil {
+			t.Fatalf("IssueAgentFiles(%s): %v", id, err)
+		}
+	}
+
+	// The remote agent requires a client certificate.
+	remote := NewServer(nil, nil)
+	remote.SetSecretary(secretary.NewSecretary(&aoi.AgentIdentity{ID: "eng-agent", Role: aoi.RoleEngineer}))
+	serverTLS, err := pki.NewServerTLSConfig(pki.ServerConfig{
+		CertFile:   filepath.Join(dir, "eng-agent.crt"),
+		KeyFile:    filepath.Join(dir, "eng-agent.key"),
+		CAFile:     filepath.Join(dir, pki.CACertFile),
+		ClientAuth: pki.ClientAuthRequire,
+	})
+	if err != nil {
+		t.Fatalf("NewServerTLSConfig: %v", err)
+	}
+	ts := httptest.NewUnstartedServer(pki.Middleware(remote.mux))
+	ts.TLS = serverTLS
+	ts.StartTLS()
+	defer ts.Close()
+
+	registry := identity.NewAgentRegistry()
+	registry.Register(&aoi.AgentIdentity{ID: "eng-agent", Role: aoi.RoleEngineer, Endpoint: ts.URL})
+	server := NewServer(registry, nil)
+	server.SetSecretary(secretary.NewSecretary(&aoi.AgentIdentity{ID: "pm-agent", Role: aoi.RolePM}))
+
+	query := func() JSONRPCResponse {
+		body := rpcRequest("aoi.query", map[string]string{
+			"query":      "How is the API going?",
+			"from_agent": "pm-agent",
+			"to_agent":   "eng-agent",
+		})
+		w := httptest.NewRecorder()
+		server.handleJSONRPC(w, httptest.NewRequest("POST", "/api/v1/rpc", body))
+		return decodeRPC(t, w)
+	}
+
+	// Without this agent's certificate the remote agent refuses the connection.
+	if resp := query(); resp.Error == nil {
+		t.Fatal("expected forwarding without a client certificate to fail")
+	}
+
+	clientTLS, err := pki.NewClientTLSConfig(filepath.Join(dir, "pm-agent.crt"), filepath.Join(dir, "pm-agent.key"), filepath.Join(dir, pki.CACertFile))
+	if err != nil {
+		t.Fatalf("NewClientTLSConfig: %v", err)
+	}
+	server.SetForwardTLS(clientTLS)
+
+	resp := query()
+	if resp.Error != nil {
+		t.Fatalf("unexpected RPC error: %+v", resp.Error)
+	}
+	var result secretary.QueryResponse
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		t.Fatalf("decode result: %v", err)
+	}
+	if !strings.Contains(result.Answer, "Engineer Summary") {
+		t.Errorf("expected engineer secretary answer, got %q", result.Answer)
+	}
+}
+
+func TestRPCQuery_SenderIsCaller(t *testing.T) {
+	remote, endpoint := newRemoteAgent(t, "eng-agent", aoi.RoleEngineer)
+	registry := identity.NewAgentRegistry()
+	registry.Register(&aoi.AgentIdentity{ID: "eng-agent", Role: aoi.RoleEngineer, Endpoint: endpoint})
+	server := NewServer(registry, nil)
+	server.SetSecretary(secretary.NewSecretary(&aoi.AgentIdentity{ID: "pm-agent", Role: aoi.RolePM}))
+	ctx := context.WithValue(context.Background(), contextKeyTokenAgentID, "qa-agent")
+
+	// Claiming to be someone else is refused, locally and when forwarding
+	for _, to := range []string{"pm-agent", "eng-agent"} {
+		params := `{"query":"How is the API going?","from_agent":"lead-agent","to_agent":"` + to + `"}`
+		resp := server.dispatch(ctx, &JSONRPCRequest{JSONRPC: "2.0", Method: "aoi.query", Params: json.RawMessage(params), ID: 1})
+		if resp.Error == nil || resp.Error.Code != JSONRPCACLDenied {
+			t.Errorf("Expected a query to %s sent as another agent to be denied, got %+v", to, resp.Error)
+		}
+	}
+
+	params := `{"query":"How is the API going?","to_agent":"eng-agent"}`
+	resp := server.dispatch(ctx, &JSONRPCRequest{JSONRPC: "2.0", Method: "aoi.query", Params: json.RawMessage(params), ID: 2})
+	if resp.Error != nil {
+		t.Fatalf("unexpected RPC error: %+v", resp.Error)
+	}
+	if logs := remote.secretary.GetQueryLogs(); len(logs) != 1 || logs[0].FromAgent != "qa-agent" {
+		t.Errorf("Expected the forwarded query to be from the caller, got %+v", logs)
+	}
+	r := server.auditLogger.Search(audit.Query{EventType: audit.EventQuery})
+	if r.TotalCount != 1 || r.Entries[0].FromAgent != "qa-agent" {
+		t.Errorf("Expected the query to be audited as the caller's, got %+v", r.Entries)
+	}
+}
+
+func TestAgentForwarder_SignedQueryNamesOriginalSender(t *testing.T) {
+	pub, key, _ := ed25519.GenerateKey(nil)
+	remote, endpoint := newRemoteAgent(t, "eng-agent", aoi.RoleEngineer)
+	remote.registry.Register(&aoi.AgentIdentity{ID: "pm-agent", PublicKey: aoi.EncodePublicKey(pub)})
+	remote.SetSigning(signing.NewVerifier(remote.registry, signing.ModeRequired, 0), "", nil)
+
+	f := NewAgentForwarder(0)
+	f.SetSigner("pm-agent", key)
+	target := &aoi.AgentIdentity{ID: "eng-agent", Endpoint: endpoint}
+	resp, err := f.Query(context.Background(), target, secretary.QueryRequest{Query: "How is the API going?", FromAgent: "qa-agent"})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if logs := remote.secretary.GetQueryLogs(); len(logs) != 1 || logs[0].FromAgent != "pm-agent" {
+		t.Errorf("Expected the query to be sent as the signing agent, got %+v", logs)
+	}
+	if resp.Metadata["forwarded_for"] != "qa-agent" {
+		t.Errorf("Expected the original sender in forwarded_for, got %+v", resp.Metadata)
+	}
+}
@@ -0,0 +1,127 @@
+package protocol
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/aoi-protocol/aoi/internal/acl"
+	"github.com/aoi-protocol/aoi/internal/approval"
+	"github.com/aoi-protocol/aoi/internal/audit"
+	"github.com/aoi-protocol/aoi/internal/config"
+)
+
+// MaxGrantDuration caps how long a grant issued through an approval lasts
+const MaxGrantDuration = 24 * time.Hour
+
+// GrantParams are the params of an approval request of type
+// approval.TaskTypeACLGrant, e.g. {"agent_id": "pm-agent", "resource":
+// "context/project:billing", "permission": "read", "duration": "2h"}
+type GrantParams struct {
+	// AgentID is the agent, or "role:<name>", given access
+	AgentID    string `json:"agent_id"`
+	Resource   string `json:"resource"`
+	Permission string `json:"permission"`
+	// Duration is how long the grant lasts from approval, e.g. "2h"
+	Duration string `json:"duration"`
+}
+
+// parseGrantParams decodes and checks the params of a grant request
+func parseGrantParams(params map[string]interface{}) (*GrantParams, time.Duration, error) {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return nil, 0, err
+	}
+	var p GrantParams
+	if err := json.Unmarshal(raw, &p); err != nil {
+		return nil, 0, err
+	}
+
+	if p.AgentID == "" || p.Resource == "" {
+		return nil, 0, errors.New("agent_id and resource are required")
+	}
+	if strings.ContainsAny(p.AgentID, "*?[") {
+		return nil, 0, errors.New("agent_id must name one agent or role, not a pattern")
+	}
+	switch p.Permission {
+	case "read", "write", "admin":
+	default:
+		return nil, 0, fmt.Errorf("unknown permission %q", p.Permission)
+	}
+	ttl, err := time.ParseDuration(p.Duration)
+	if err != nil || ttl <= 0 {
+		return nil, 0, fmt.Errorf("duration must be a positive duration such as \"2h\", got %q", p.Duration)
+	}
+	if ttl > MaxGrantDuration {
+		return nil, 0, fmt.Errorf("duration %s exceeds the maximum of %s", ttl, MaxGrantDuration)
+	}
+	return &p, ttl, nil
+}
+
+// validateGrantParams rejects grant requests that could not be issued
+func validateGrantParams(params map[string]interface{}) error {
+	_, _, err := parseGrantParams(params)
+	return err
+}
+
+// checkGrantApprover lets only an authenticated approver who already holds the
+// granted permission on the granted resource approve a grant request
+func (s *Server) checkGrantApprover(req *approval.ApprovalRequest, approver string) error {
+	p, _, err := parseGrantParams(req.Params)
+	if err != nil {
+		return err
+	}
+	if approver == "" {
+		return errors.New("a grant must be approved by an authenticated caller")
+	}
+	if reason := s.checkPermission(Caller{AgentID: approver}, p.Resource, p.Permission); reason != "" {
+		return fmt.Errorf("'%s' cannot grant %s on %s: %s", approver, p.Permission, p.Resource, reason)
+	}
+	return nil
+}
+
+// issueGrant adds the temporary grant of an approved grant request to the
+// ACL. The grant is identified by the approval's ID and lasts from now.
+func (s *Server) issueGrant(req *approval.ApprovalRequest) {
+	if req.TaskType != approval.TaskTypeACLGrant {
+		return
+	}
+
+	p, ttl, err := parseGrantParams(req.Params)
+	if err != nil {
+		s.auditLogger.Log(audit.EventACLGrant, req.ApprovedBy, "", "grant not issued",
+			map[string]interface{}{"approval_id": req.ID}, false, err.Error())
+		return
+	}
+
+	rule := acl.NewRule(p.AgentID, p.Resource, acl.PermissionLevel(config.ParsePermission(p.Permission)))
+	rule.ID = req.ID
+	rule.ExpiresAt = time.Now().Add(ttl)
+	s.aclMgr.AddRule(rule)
+
+	log.Printf("[ACL] Grant %s: %s -> %s: %s until %s (approved by %s)",
+		rule.ID, p.AgentID, p.Resource, p.Permission, rule.ExpiresAt.Format(time.RFC3339), req.ApprovedBy)
+	s.auditLogger.Log(audit.EventACLGrant, req.ApprovedBy, p.AgentID,
+		fmt.Sprintf("granted %s on %s for %s", p.Permission, p.Resource, ttl),
+		grantDetails(*rule), true, "")
+}
+
+// auditRevokedGrant records a grant revoked on expiry
+func (s *Server) auditRevokedGrant(rule acl.AccessRule) {
+	log.Printf("[ACL] Grant %s expired: %s -> %s", rule.ID, rule.Subject(), rule.Resource)
+	s.auditLogger.Log(audit.EventACLRevoke, "", rule.Subject(),
+		fmt.Sprintf("revoked %s on %s: grant expired", rule.Permission, rule.Resource),
+		grantDetails(rule), true, "")
+}
+
+func grantDetails(rule acl.AccessRule) map[string]interface{} {
+	return map[string]interface{}{
+		"grant_id":   rule.ID,
+		"resource":   rule.Resource,
+		"permission": rule.Permission.String(),
+		"expires_at": rule.ExpiresAt,
+	}
+}
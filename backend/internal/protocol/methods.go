@@ -0,0 +1,170 @@
+package protocol
+
+import (
+	"github.com/aoi-protocol/aoi/internal/rpc"
+)
+
+// registerMethods wires the core aoi.* methods and every configured subsystem
+// into the server's method registry. Subsystems that are not configured simply
+// contribute no methods, so calls to them yield Method not found.
+func (s *Server) registerMethods() {
+	s.methods.MustRegister(
+		rpc.Method{
+			Name:    "aoi.discover",
+			Summary: "List the agents known to this agent, optionally filtered by their capability manifest",
+			Params: []rpc.ContentDescriptor{
+				rpc.Param("role", "string", "Only agents with this role"),
+				rpc.Param("capability", "string", "Only agents declaring this capability, action or MCP tool"),
+				rpc.Param("project", "string", "Only agents holding context for this repository"),
+				rpc.Param("issue", "integer", "Only agents holding context for this issue number"),
+				rpc.Param("online", "boolean", "Only agents that are (true) or are not (false) online"),
+			},
+			Result:   rpc.Result("agents", rpc.Type("object")),
+			Resource: "agents/discover",
+			Action:   rpc.ActionRead,
+			Handler:  s.handleDiscover,
+		},
+		rpc.Method{
+			Name:     "aoi.status",
+			Summary:  "Report whether this agent, or another known agent, is online",
+			Params:   []rpc.ContentDescriptor{rpc.Param("agent_id", "string", "Agent to report on; empty for this agent")},
+			Result:   rpc.Result("status", rpc.Type("object")),
+			Resource: "agents/status",
+			Action:   rpc.ActionRead,
+			Handler:  s.handleStatus,
+		},
+		rpc.Method{
+			Name:    "aoi.heartbeat",
+			Summary: "Renew an agent's lease; agents whose lease expires are marked offline",
+			Params: []rpc.ContentDescriptor{
+				rpc.Param("agent_id", "string", "Agent renewing its lease; defaults to the certificate's agent"),
+				rpc.Param("ttl", "integer", "Lease length in seconds"),
+			},
+			Result:   rpc.Result("lease", rpc.Type("object")),
+			Resource: "agents/heartbeat",
+			Action:   rpc.ActionWrite,
+			Handler:  s.handleHeartbeat,
+		},
+		rpc.Method{
+			Name:    "aoi.query",
+			Summary: "Ask a question of this agent's secretary or forward it to another agent",
+			Params: []rpc.ContentDescriptor{
+				rpc.RequiredParam("query", "string", "The question"),
+				rpc.Param("from_agent", "string", "Asking agent"),
+				rpc.Param("to_agent", "string", "Target agent; empty for this agent"),
+				rpc.Param("context_scope", "string", "Context to draw the answer from"),
+				rpc.Param("priority", "string", "e.g. low, normal, high or urgent; decides whether a focused human is interrupted"),
+				rpc.Param("delegate", "boolean", "Hand the query to the local worker AI and return its answer (needs execute on secretary/delegate)"),
+				rpc.Param("metadata", "object", "Free-form string metadata"),
+			},
+			Result:   rpc.Result("answer", rpc.Type("object")),
+			Resource: "queries/send",
+			Action:   rpc.ActionRead,
+			Handler:  s.handleRPCQuery,
+		},
+		rpc.Method{
+			Name:    "aoi.execute",
+			Summary: "Run a task through the registered executors",
+			Params: []rpc.ContentDescriptor{
+				rpc.RequiredParam("type", "string", "Task type, e.g. shell, mcp or h2a"),
+				rpc.Param("id", "string", "Caller-chosen task ID"),
+				rpc.Param("parameters", "object", "Executor-specific parameters"),
+				rpc.Param("async", "boolean", "Return immediately with the queued task"),
+				rpc.Param("timeout", "integer", "Task timeout in seconds"),
+			},
+			Result:   rpc.Result("task", rpc.Type("object")),
+			Resource: "tasks/execute",
+			Action:   rpc.ActionExecute,
+			Handler:  s.handleExecute,
+		},
+		rpc.Method{
+			Name:    "aoi.notify",
+			Summary: "Deliver a notification to this agent",
+			Params: []rpc.ContentDescriptor{
+				rpc.Param("type", "string", "Notification type"),
+				rpc.Param("from", "string", "Sending agent"),
+				rpc.Param("to", "string", "Receiving agent"),
+				rpc.Param("message", "string", "Notification text"),
+				rpc.Param("data", "object", "Structured payload"),
+			},
+			Result:   rpc.Result("status", rpc.Type("object")),
+			Resource: "notifications/send",
+			Action:   rpc.ActionWrite,
+			Handler:  s.handleNotify,
+		},
+		rpc.Method{
+			Name:    "aoi.h2a.register",
+			Summary: "Link an agent ID to a tmux session",
+			Params: []rpc.ContentDescriptor{
+				rpc.RequiredParam("agent_id", "string", "Agent to link"),
+				rpc.RequiredParam("session_name", "string", "tmux session name"),
+				rpc.Param("pane_name", "string", "tmux pane within the session"),
+			},
+			Result:   rpc.Result("status", rpc.Type("object")),
+			Resource: "h2a/register",
+			Action:   rpc.ActionWrite,
+			Handler:  s.handleH2ARegister,
+		},
+		rpc.Method{
+			Name:     "aoi.h2a.sessions",
+			Summary:  "List registered tmux sessions",
+			Result:   rpc.Result("sessions", rpc.Type("object")),
+			Resource: "h2a/sessions",
+			Action:   rpc.ActionRead,
+			Handler:  s.handleH2ASessions,
+		},
+		rpc.Method{
+			Name:    "aoi.h2a.send",
+			Summary: "Send a command to an agent's tmux pane",
+			Params: []rpc.ContentDescriptor{
+				rpc.RequiredParam("target_agent_id", "string", "Agent whose pane receives the command"),
+				rpc.RequiredParam("from_user", "string", "User sending the command"),
+				rpc.RequiredParam("command", "string", "Command line to send"),
+				rpc.Param("capture_output", "boolean", "Return the pane output"),
+			},
+			Result:   rpc.Result("result", rpc.Type("object")),
+			Resource: "h2a/send",
+			Action:   rpc.ActionExecute,
+			Handler:  s.handleH2ASend,
+		},
+		rpc.Method{
+			Name:    "aoi.h2a.stream",
+			Summary: "Send a command and stream the pane output over WebSocket",
+			Params: []rpc.ContentDescriptor{
+				rpc.RequiredParam("target_agent_id", "string", "Agent whose pane receives the command"),
+				rpc.RequiredParam("from_user", "string", "User sending the command"),
+				rpc.RequiredParam("command", "string", "Command line to send"),
+				rpc.Param("interval_ms", "integer", "Capture interval in milliseconds"),
+			},
+			Result:   rpc.Result("stream", rpc.Type("object")),
+			Resource: "h2a/stream",
+			Action:   rpc.ActionExecute,
+			Handler:  s.handleH2AStream,
+		},
+		rpc.Method{
+			Name:     "aoi.h2a.stop",
+			Summary:  "Stop an output stream",
+			Params:   []rpc.ContentDescriptor{rpc.RequiredParam("stream_id", "string", "Stream to stop")},
+			Result:   rpc.Result("status", rpc.Type("object")),
+			Resource: "h2a/stop",
+			Action:   rpc.ActionExecute,
+			Handler:  s.handleH2AStop,
+		},
+	)
+
+	s.aclMgr.RegisterMethods(s.methods)
+	s.taskMgr.RegisterMethods(s.methods)
+	s.approvalMgr.RegisterMethods(s.methods)
+	s.auditLogger.RegisterMethods(s.methods)
+	s.focusMgr.RegisterMethods(s.methods)
+	if s.contextAPI != nil {
+		s.contextAPI.RegisterMethods(s.methods)
+	}
+	if s.mcpBridge != nil {
+		s.mcpBridge.RegisterMethods(s.methods)
+	}
+
+	if err := s.methods.RegisterDiscover(s.discoverInfo); err != nil {
+		panic(err)
+	}
+}
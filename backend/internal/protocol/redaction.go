@@ -0,0 +1,120 @@
+package protocol
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/aoi-protocol/aoi/internal/audit"
+	aoicontext "github.com/aoi-protocol/aoi/internal/context"
+	"github.com/aoi-protocol/aoi/internal/mcp"
+	"github.com/aoi-protocol/aoi/internal/redact"
+	"github.com/aoi-protocol/aoi/internal/secretary"
+	"github.com/aoi-protocol/aoi/pkg/aoi"
+)
+
+// SetRedactor sets the redactor that removes secrets and personal data from
+// query answers, context history, MCP output, terminal captures and task
+// results before they leave the agent, and from audited delegations; nil
+// turns redaction off
+func (s *Server) SetRedactor(r *redact.Redactor) {
+	s.redactor = r
+}
+
+// auditRedaction records what was redacted from output on path
+func (s *Server) auditRedaction(from, to, path, summary string, counts redact.Counts) {
+	if counts.Total() == 0 {
+		return
+	}
+	log.Printf("[Redact] %s: %s", path, counts)
+	s.auditLogger.Log(audit.EventRedaction, from, to, fmt.Sprintf("%s output redacted: %s", path, summary),
+		map[string]interface{}{"path": path, "matches": counts.Total(), "counts": map[string]int(counts)}, true, "")
+}
+
+// redactAnswer redacts an answer to a query from the given agent
+func (s *Server) redactAnswer(from string, req secretary.QueryRequest, resp *secretary.QueryResponse) *secretary.QueryResponse {
+	answer, counts := s.redactor.Redact(resp.Answer)
+	if counts.Total() == 0 {
+		return resp
+	}
+	redacted := *resp
+	redacted.Answer = answer
+	s.auditRedaction(from, s.focusMgr.LocalAgent(), "aoi.query", req.Query, counts)
+	return &redacted
+}
+
+// redactHistory redacts the contents and summaries of served context entries
+func (s *Server) redactHistory(history *aoicontext.ContextHistory) {
+	total := redact.Counts{}
+	for i := range history.Entries {
+		var counts redact.Counts
+		entry := &history.Entries[i]
+		entry.Content, counts = s.redactor.Redact(entry.Content)
+		total.Add(counts)
+		entry.Summary, counts = s.redactor.Redact(entry.Summary)
+		total.Add(counts)
+	}
+	s.auditRedaction("", s.focusMgr.LocalAgent(), "aoi.context.history",
+		fmt.Sprintf("%d entries", len(history.Entries)), total)
+}
+
+// redactTerminal redacts output captured from an agent's tmux session
+func (s *Server) redactTerminal(agentID, output string) string {
+	output, counts := s.redactor.Redact(output)
+	s.auditRedaction(agentID, "", "h2a", "terminal output of "+agentID, counts)
+	return output
+}
+
+// redactTaskOutput redacts the output or error of a task run for requester
+func (s *Server) redactTaskOutput(requester string, t *aoi.Task, text string) string {
+	text, counts := s.redactor.Redact(text)
+	s.auditRedaction(requester, "", "aoi.execute", fmt.Sprintf("task %s (%s)", t.ID, t.Type), counts)
+	return text
+}
+
+// redactDelegation redacts the query, prompt, answer and error of an exchange
+// with the worker AI before it is audited
+func (s *Server) redactDelegation(d secretary.Delegation) secretary.Delegation {
+	total := redact.Counts{}
+	for _, text := range []*string{&d.Query.Query, &d.Prompt, &d.Answer, &d.Error} {
+		var counts redact.Counts
+		*text, counts = s.redactor.Redact(*text)
+		total.Add(counts)
+	}
+	s.auditRedaction(d.Query.FromAgent, d.Worker, "delegate", "delegation "+d.ID, total)
+	return d
+}
+
+// redactToolResult redacts the text of an MCP tool's output
+func (f mcpOutputFilter) redactToolResult(ctx context.Context, serverName, toolName string, result *mcp.CallToolResult) *mcp.CallToolResult {
+	total := redact.Counts{}
+	redacted := &mcp.CallToolResult{IsError: result.IsError, Content: make([]mcp.ContentBlock, len(result.Content))}
+	for i, block := range result.Content {
+		var counts redact.Counts
+		block.Text, counts = f.s.redactor.Redact(block.Text)
+		total.Add(counts)
+		if block.Resource != nil {
+			resource := *block.Resource
+			resource.Text, counts = f.s.redactor.Redact(resource.Text)
+			total.Add(counts)
+			block.Resource = &resource
+		}
+		redacted.Content[i] = block
+	}
+	f.s.auditRedaction(callerFrom(ctx).AgentID, serverName, "aoi.mcp.call", toolName, total)
+	return redacted
+}
+
+// redactResource redacts the text of an MCP resource
+func (f mcpOutputFilter) redactResource(ctx context.Context, serverName, uri string, contents []mcp.ResourceContent) []mcp.ResourceContent {
+	total := redact.Counts{}
+	redacted := make([]mcp.ResourceContent, len(contents))
+	for i, content := range contents {
+		var counts redact.Counts
+		content.Text, counts = f.s.redactor.Redact(content.Text)
+		total.Add(counts)
+		redacted[i] = content
+	}
+	f.s.auditRedaction(callerFrom(ctx).AgentID, serverName, "aoi.mcp.read", uri, total)
+	return redacted
+}
@@ -1,41 +1,103 @@
 package protocol
 
 import (
+	"bytes"
 	"context"
+	"crypto/ed25519"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/aoi-protocol/aoi/internal/acl"
 	"github.com/aoi-protocol/aoi/internal/approval"
 	"github.com/aoi-protocol/aoi/internal/audit"
 	aoicontext "github.com/aoi-protocol/aoi/internal/context"
+	"github.com/aoi-protocol/aoi/internal/disclosure"
+	"github.com/aoi-protocol/aoi/internal/focus"
 	"github.com/aoi-protocol/aoi/internal/h2a"
 	"github.com/aoi-protocol/aoi/internal/identity"
 	"github.com/aoi-protocol/aoi/internal/mcp"
 	"github.com/aoi-protocol/aoi/internal/notify"
+	"github.com/aoi-protocol/aoi/internal/pki"
+	"github.com/aoi-protocol/aoi/internal/redact"
+	"github.com/aoi-protocol/aoi/internal/rpc"
+	"github.com/aoi-protocol/aoi/internal/secretary"
+	"github.com/aoi-protocol/aoi/internal/signing"
+	"github.com/aoi-protocol/aoi/internal/tailscale"
+	"github.com/aoi-protocol/aoi/internal/task"
 	"github.com/aoi-protocol/aoi/pkg/aoi"
 )
 
 // JSON-RPC 2.0 error codes
 const (
-	JSONRPCParseError     = -32700
-	JSONRPCInvalidRequest = -32600
-	JSONRPCMethodNotFound = -32601
-	JSONRPCInvalidParams  = -32602
-	JSONRPCInternalError  = -32603
-	JSONRPCACLDenied      = -32000
-	JSONRPCAgentNotFound  = -32001
+	JSONRPCParseError      = rpc.CodeParseError
+	JSONRPCInvalidRequest  = rpc.CodeInvalidRequest
+	JSONRPCMethodNotFound  = rpc.CodeMethodNotFound
+	JSONRPCInvalidParams   = rpc.CodeInvalidParams
+	JSONRPCInternalError   = rpc.CodeInternalError
+	JSONRPCACLDenied       = rpc.CodeACLDenied
+	JSONRPCAgentNotFound   = rpc.CodeAgentNotFound
+	JSONRPCUnauthenticated = rpc.CodeUnauthenticated
 )
 
+// ProtocolVersion is the AOI protocol version reported in the OpenRPC document
+const ProtocolVersion = "1.0.0"
+
+// MaxBatchSize is the largest number of requests accepted in one batch
+const MaxBatchSize = 100
+
+// maxBatchConcurrency bounds how many requests of a batch run at once
+const maxBatchConcurrency = 8
+
 // JSONRPCRequest represents a JSON-RPC 2.0 request
 type JSONRPCRequest struct {
 	JSONRPC string          `json:"jsonrpc"`
 	Method  string          `json:"method"`
 	Params  json.RawMessage `json:"params,omitempty"`
 	ID      interface{}     `json:"id"`
+	// Auth is the sender's signature, checked when signatures are enabled
+	Auth *aoi.Signature `json:"auth,omitempty"`
+
+	// notification is set when the decoded request had no "id" member
+	notification bool
+}
+
+// UnmarshalJSON decodes a request and records whether the id member was present,
+// since a request without one is a notification and must not be answered.
+func (r *JSONRPCRequest) UnmarshalJSON(data []byte) error {
+	type plain JSONRPCRequest
+	var p plain
+	if err := json.Unmarshal(data, &p); err != nil {
+		return err
+	}
+	var presence struct {
+		ID json.RawMessage `json:"id"`
+	}
+	if err := json.Unmarshal(data, &presence); err != nil {
+		return err
+	}
+
+	*r = JSONRPCRequest(p)
+	r.notification = presence.ID == nil
+	return nil
+}
+
+// IsNotification reports whether the request was sent without an id
+func (r *JSONRPCRequest) IsNotification() bool {
+	return r.notification
+}
+
+// isBatch reports whether a request body is a JSON array
+func isBatch(body []byte) bool {
+	trimmed := bytes.TrimLeft(body, " \t\r\n")
+	return len(trimmed) > 0 && trimmed[0] == '['
 }
 
 // JSONRPCResponse represents a JSON-RPC 2.0 response
@@ -47,11 +109,7 @@ type JSONRPCResponse struct {
 }
 
 // JSONRPCError represents a JSON-RPC 2.0 error
-type JSONRPCError struct {
-	Code    int         `json:"code"`
-	Message string      `json:"message"`
-	Data    interface{} `json:"data,omitempty"`
-}
+type JSONRPCError = rpc.Error
 
 // Server represents the HTTP server
 type Server struct {
@@ -64,6 +122,19 @@ type Server struct {
 	approvalMgr *approval.ApprovalManager
 	auditLogger *audit.AuditLogger
 	h2aMgr      *h2a.H2AManager
+	secretary   *secretary.Secretary
+	forwarder   *AgentForwarder
+	taskMgr     *task.Manager
+	executors   *task.Registry
+	focusMgr    *focus.Manager
+	methods     *rpc.Registry
+	disclosure  disclosure.Level
+	redactor    *redact.Redactor
+	verifier    *signing.Verifier
+	enforceACL  bool
+	tokens      map[string]string
+	tsAuth      *tailscale.Auth
+	tsACL       *tailscale.ACL
 }
 
 // NewServer creates a new HTTP server
@@ -95,6 +166,12 @@ func NewServerFull(registry *identity.AgentRegistry, aclMgr *acl.AclManager, con
 
 	wsHub := NewWSHub(nil)
 
+	// Task types that can be served by the subsystems we already have.
+	executors := task.NewRegistry()
+	if mcpBridge != nil {
+		executors.Register(task.TypeMCP, task.NewMCPExecutor(mcpBridge))
+	}
+
 	// Wire up the WebSocket hub to the H2A manager for output broadcasting.
 	h2aMgr.SetWSHub(wsHub)
 
@@ -108,12 +185,74 @@ func NewServerFull(registry *identity.AgentRegistry, aclMgr *acl.AclManager, con
 		approvalMgr: approval.NewApprovalManager(),
 		auditLogger: audit.NewAuditLogger(),
 		h2aMgr:      h2aMgr,
+		forwarder:   NewAgentForwarder(DefaultForwardTimeout),
+		taskMgr:     task.NewManager(task.DefaultConfig(), executors),
+		executors:   executors,
+		focusMgr:    focus.NewManager(focus.DefaultOptions(), nil),
+		methods:     rpc.NewRegistry(),
+		disclosure:  disclosure.Full,
 	}
 
+	// Async tasks report completion over WebSocket; sync callers get the result directly.
+	s.taskMgr.OnComplete(s.broadcastTaskComplete)
+	// Callers only see and cancel their own tasks unless they administer tasks.
+	s.taskMgr.SetScope(s.taskScope)
+	// Agents going online or offline are pushed to dashboards and other agents.
+	registry.OnStatusChange(s.broadcastAgentUpdate)
+	// Approved grant requests issue temporary ACL grants, audited until they expire.
+	s.approvalMgr.SetValidator(approval.TaskTypeACLGrant, validateGrantParams)
+	s.approvalMgr.SetApproverCheck(approval.TaskTypeACLGrant, s.checkGrantApprover)
+	s.approvalMgr.SetCaller(authenticatedAgent)
+	s.approvalMgr.OnApprove(s.issueGrant)
+	aclMgr.OnRevoke(s.auditRevokedGrant)
+	// A focused human is alerted to escalated queries and sent the rest when focus ends.
+	s.focusMgr.OnEscalate(s.broadcastEscalation)
+	s.focusMgr.OnDigest(s.broadcastFocusDigest)
+	// MCP tool output and resources, task results and context history are
+	// reduced to what each requester may see and, like terminal captures, redacted.
+	if mcpBridge != nil {
+		mcpBridge.SetOutputFilter(mcpOutputFilter{s})
+	}
+	h2aMgr.SetOutputFilter(s.redactTerminal)
+	s.taskMgr.SetOutputFilter(s.redactTaskOutput)
+	s.taskMgr.SetReadFilter(s.restrictTask)
+	if contextAPI != nil {
+		contextAPI.SetHistoryFilter(s.filterHistory)
+	}
+
+	s.registerMethods()
 	s.setupRoutes()
 	return s
 }
 
+// SetSecretary sets the local secretary that answers queries addressed to this agent.
+func (s *Server) SetSecretary(sec *secretary.Secretary) {
+	s.secretary = sec
+	if sec != nil && sec.Identity != nil {
+		s.focusMgr.SetLocalAgent(sec.Identity.ID)
+	}
+	if sec != nil {
+		sec.OnDelegate(s.auditDelegation)
+	}
+}
+
+// Focus returns the manager of the local human's focus state, which decides
+// whether queries are answered, queued or escalated.
+func (s *Server) Focus() *focus.Manager {
+	return s.focusMgr
+}
+
+// TaskExecutors returns the registry used to dispatch aoi.execute tasks by type.
+func (s *Server) TaskExecutors() *task.Registry {
+	return s.executors
+}
+
+// Methods returns the registry of JSON-RPC methods served on /api/v1/rpc.
+// Methods registered here are also listed by rpc.discover.
+func (s *Server) Methods() *rpc.Registry {
+	return s.methods
+}
+
 func (s *Server) setupRoutes() {
 	// Keep existing REST endpoints for backward compatibility
 	s.mux.HandleFunc("/health", s.handleHealth)
@@ -152,8 +291,18 @@ func (s *Server) handleAgents(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		if err := s.registry.Register(&agent); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		// Only the agent itself or an admin can change an existing entry.
+		caller := callerFrom(r.Context())
+		admin := s.isAdmin(caller, "agents/register")
+		if err := s.registry.RegisterAs(&agent, caller.AgentID, admin); err != nil {
+			status := http.StatusInternalServerError
+			switch {
+			case errors.Is(err, identity.ErrKeyMismatch):
+				status = http.StatusConflict
+			case errors.Is(err, identity.ErrNotOwner):
+				status = http.StatusForbidden
+			}
+			http.Error(w, err.Error(), status)
 			return
 		}
 
@@ -179,122 +328,371 @@ func (s *Server) handleQuery(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Mock response for MVP
-	result := aoi.QueryResult{
-		Summary:   "Mock response: Work in progress",
-		Progress:  50,
-		Completed: true,
+	// Without a secretary there is nothing to answer from
+	if s.secretary == nil {
+		json.NewEncoder(w).Encode(aoi.QueryResult{
+			Summary:   "Mock response: Work in progress",
+			Progress:  50,
+			Completed: true,
+		})
+		return
 	}
 
-	json.NewEncoder(w).Encode(result)
+	from, err := querySender(r.Context(), query.From)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	resp, err := s.answerLocal(r.Context(), secretary.QueryRequest{
+		Query:        query.Query,
+		FromAgent:    from,
+		ToAgent:      query.To,
+		ContextScope: strings.Join(query.ContextScope, ","),
+		Priority:     query.Priority,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	metadata := map[string]interface{}{"confidence": resp.Confidence}
+	for k, v := range resp.Metadata {
+		metadata[k] = v
+	}
+	// A query queued or escalated for a focused human is answered later
+	_, deferred := resp.Metadata[MetaFocusAction]
+	json.NewEncoder(w).Encode(aoi.QueryResult{
+		Summary:     resp.Answer,
+		ContextRefs: resp.Sources,
+		Completed:   !deferred,
+		Metadata:    metadata,
+	})
 }
 
-// handleJSONRPC processes JSON-RPC 2.0 requests
+// handleJSONRPC processes JSON-RPC 2.0 requests, including batches and notifications
 func (s *Server) handleJSONRPC(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	if r.Method != http.MethodPost {
-		s.sendJSONRPCError(w, nil, JSONRPCInvalidRequest, "Method not allowed", nil)
+		s.writeJSONRPC(w, newErrorResponse(nil, JSONRPCInvalidRequest, "Method not allowed", nil))
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		s.writeJSONRPC(w, newErrorResponse(nil, JSONRPCParseError, "Parse error", err.Error()))
+		return
+	}
+
+	if isBatch(body) {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(body, &batch); err != nil {
+			s.writeJSONRPC(w, newErrorResponse(nil, JSONRPCParseError, "Parse error", err.Error()))
+			return
+		}
+		responses, rpcErr := s.dispatchBatch(r.Context(), batch)
+		if rpcErr != nil {
+			s.writeJSONRPC(w, &JSONRPCResponse{JSONRPC: "2.0", Error: rpcErr})
+			return
+		}
+		if len(responses) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		s.writeJSONRPC(w, responses)
 		return
 	}
 
 	var req JSONRPCRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		s.sendJSONRPCError(w, nil, JSONRPCParseError, "Parse error", err.Error())
+	if err := json.Unmarshal(body, &req); err != nil {
+		s.writeJSONRPC(w, newErrorResponse(nil, JSONRPCParseError, "Parse error", err.Error()))
+		return
+	}
+
+	resp := s.dispatch(r.Context(), &req)
+	if !needsResponse(&req, resp) {
+		w.WriteHeader(http.StatusNoContent)
 		return
 	}
+	s.writeJSONRPC(w, resp)
+}
+
+// dispatchBatch runs the requests of a batch concurrently and returns the
+// responses for those that are not notifications, in request order.
+func (s *Server) dispatchBatch(ctx context.Context, batch []json.RawMessage) ([]*JSONRPCResponse, *JSONRPCError) {
+	if len(batch) == 0 {
+		return nil, &JSONRPCError{Code: JSONRPCInvalidRequest, Message: "Invalid Request", Data: "empty batch"}
+	}
+	if len(batch) > MaxBatchSize {
+		return nil, &JSONRPCError{Code: JSONRPCInvalidRequest, Message: "Invalid Request",
+			Data: fmt.Sprintf("batch of %d requests exceeds limit of %d", len(batch), MaxBatchSize)}
+	}
+
+	responses := make([]*JSONRPCResponse, len(batch))
+	sem := make(chan struct{}, maxBatchConcurrency)
+	var wg sync.WaitGroup
+
+	for i, raw := range batch {
+		var req JSONRPCRequest
+		if err := json.Unmarshal(raw, &req); err != nil {
+			responses[i] = newErrorResponse(nil, JSONRPCInvalidRequest, "Invalid Request", err.Error())
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, req JSONRPCRequest) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			resp := s.dispatch(ctx, &req)
+			if needsResponse(&req, resp) {
+				responses[i] = resp
+			}
+		}(i, req)
+	}
+	wg.Wait()
 
+	result := make([]*JSONRPCResponse, 0, len(responses))
+	for _, resp := range responses {
+		if resp != nil {
+			result = append(result, resp)
+		}
+	}
+	return result, nil
+}
+
+// needsResponse reports whether resp must be sent back. Notifications are not
+// answered unless the request itself was invalid.
+func needsResponse(req *JSONRPCRequest, resp *JSONRPCResponse) bool {
+	return !req.IsNotification() || (resp.Error != nil && resp.Error.Code == JSONRPCInvalidRequest)
+}
+
+// dispatch validates a single request and calls the registered method
+func (s *Server) dispatch(ctx context.Context, req *JSONRPCRequest) *JSONRPCResponse {
 	// Validate JSON-RPC version
 	if req.JSONRPC != "2.0" {
-		s.sendJSONRPCError(w, req.ID, JSONRPCInvalidRequest, "Invalid JSON-RPC version", nil)
-		return
+		return newErrorResponse(req.ID, JSONRPCInvalidRequest, "Invalid JSON-RPC version", nil)
+	}
+	if req.Method == "" {
+		return newErrorResponse(req.ID, JSONRPCInvalidRequest, "Invalid Request", "method is required")
 	}
 
-	// Route to appropriate method handler
-	switch {
-	case req.Method == "aoi.discover":
-		s.handleDiscover(w, &req)
-	case req.Method == "aoi.query":
-		s.handleRPCQuery(w, &req)
-	case req.Method == "aoi.execute":
-		s.handleExecute(w, &req)
-	case req.Method == "aoi.notify":
-		s.handleNotify(w, &req)
-	case req.Method == "aoi.status":
-		s.handleStatus(w, &req)
-	case strings.HasPrefix(req.Method, "aoi.context"):
-		s.handleContextRPC(w, &req)
-	case strings.HasPrefix(req.Method, "aoi.mcp"):
-		s.handleMCPRPC(w, &req)
-	case strings.HasPrefix(req.Method, "aoi.approval"):
-		s.handleApprovalRPC(w, &req)
-	case strings.HasPrefix(req.Method, "aoi.audit"):
-		s.handleAuditRPC(w, &req)
-	case strings.HasPrefix(req.Method, "aoi.h2a"):
-		s.handleH2ARPC(w, &req)
-	default:
-		s.sendJSONRPCError(w, req.ID, JSONRPCMethodNotFound, "Method not found", req.Method)
+	ctx, authErr := s.authenticate(ctx, req)
+	if authErr != nil {
+		return &JSONRPCResponse{JSONRPC: "2.0", Error: authErr, ID: req.ID}
+	}
+	if aclErr := s.authorize(ctx, req.Method); aclErr != nil {
+		return &JSONRPCResponse{JSONRPC: "2.0", Error: aclErr, ID: req.ID}
+	}
+
+	result, err := s.methods.Call(ctx, req.Method, req.Params)
+	if err != nil {
+		var rpcErr *JSONRPCError
+		if !errors.As(err, &rpcErr) {
+			rpcErr = internalError(err)
+		}
+		return &JSONRPCResponse{JSONRPC: "2.0", Error: rpcErr, ID: req.ID}
+	}
+	return newResultResponse(req.ID, result)
+}
+
+// authenticate verifies the request signature and records the signing agent in
+// ctx. A signature by a different agent than the client certificate is rejected.
+func (s *Server) authenticate(ctx context.Context, req *JSONRPCRequest) (context.Context, *JSONRPCError) {
+	agentID, err := s.verifier.Verify(req.Method, req.Params, req.Auth)
+	if err != nil {
+		return ctx, &JSONRPCError{Code: JSONRPCUnauthenticated, Message: "Signature rejected", Data: err.Error()}
+	}
+	if agentID == "" {
+		return ctx, nil
+	}
+	if certAgentID := pki.GetAgentIDFromContext(ctx); certAgentID != "" && certAgentID != agentID {
+		return ctx, &JSONRPCError{Code: JSONRPCUnauthenticated, Message: "Signature rejected",
+			Data: fmt.Sprintf("signed by '%s' over a connection authenticated as '%s'", agentID, certAgentID)}
 	}
+	return signing.WithAgentID(ctx, agentID), nil
+}
+
+// authenticatedAgent returns the agent proven by client certificate, request
+// signature, Tailscale node or token, or "" when the caller is only identified
+// by what it claims
+func authenticatedAgent(ctx context.Context) string {
+	return callerFrom(ctx).AgentID
 }
 
 // handleDiscover implements aoi.discover method
-func (s *Server) handleDiscover(w http.ResponseWriter, req *JSONRPCRequest) {
-	agents := s.registry.Discover()
+func (s *Server) handleDiscover(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+	var filter identity.DiscoverFilter
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &filter); err != nil {
+			return nil, invalidParams(err.Error())
+		}
+	}
+
+	agents := s.registry.DiscoverMatching(filter)
 
-	result := map[string]interface{}{
+	return map[string]interface{}{
 		"agents": agents,
 		"count":  len(agents),
+	}, nil
+}
+
+// handleRPCQuery implements aoi.query method.
+// Queries addressed to this agent (or with no target) are answered by the local
+// secretary; anything else is forwarded to the target agent's endpoint.
+func (s *Server) handleRPCQuery(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+	var params secretary.QueryRequest
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, invalidParams(err.Error())
+	}
+	if params.Query == "" {
+		return nil, invalidParams("query is required")
+	}
+	from, err := querySender(ctx, params.FromAgent)
+	if err != nil {
+		return nil, err
+	}
+	params.FromAgent = from
+
+	if s.isLocalTarget(params.ToAgent) {
+		if s.secretary == nil {
+			return nil, &JSONRPCError{Code: JSONRPCInternalError, Message: "Secretary not available"}
+		}
+		// Delegating runs the worker AI, so it takes more than asking
+		if params.Delegate {
+			if err := s.authorizeResource(ctx, secretary.ResourceDelegate, rpc.ActionExecute); err != nil {
+				return nil, err
+			}
+		}
+		return s.answerLocal(ctx, params)
+	}
+
+	target, err := s.registry.GetAgent(params.ToAgent)
+	if err != nil {
+		return nil, &JSONRPCError{Code: JSONRPCAgentNotFound, Message: "Agent not found", Data: params.ToAgent}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.forwarder.Timeout())
+	defer cancel()
+
+	resp, via, err := s.queryAgent(ctx, target, params)
+	if err != nil {
+		s.auditLogger.Log(audit.EventQuery, params.FromAgent, target.ID, params.Query, nil, false, err.Error())
+		return nil, err
 	}
 
-	s.sendJSONRPCSuccess(w, req.ID, result)
+	s.auditLogger.Log(audit.EventQuery, params.FromAgent, target.ID, params.Query,
+		map[string]interface{}{"endpoint": target.Endpoint, "via": via}, true, "")
+	return resp, nil
 }
 
-// handleRPCQuery implements aoi.query method
-func (s *Server) handleRPCQuery(w http.ResponseWriter, req *JSONRPCRequest) {
-	var params struct {
-		Query        string            `json:"query"`
-		FromAgent    string            `json:"from_agent"`
-		ContextScope string            `json:"context_scope,omitempty"`
-		Metadata     map[string]string `json:"metadata,omitempty"`
+// querySender returns who a query is from: the authenticated caller when
+// there is one, which the claimed sender must then match, or else the claim.
+func querySender(ctx context.Context, claimed string) (string, error) {
+	caller := authenticatedAgent(ctx)
+	if caller == "" {
+		return claimed, nil
+	}
+	if claimed != "" && claimed != caller {
+		return "", &JSONRPCError{Code: JSONRPCACLDenied,
+			Message: fmt.Sprintf("caller '%s' cannot send as agent '%s'", caller, claimed)}
 	}
+	return caller, nil
+}
 
-	if err := json.Unmarshal(req.Params, &params); err != nil {
-		s.sendJSONRPCError(w, req.ID, JSONRPCInvalidParams, "Invalid params", err.Error())
-		return
+// queryAgent forwards a query to target over HTTP when it advertises an endpoint,
+// or else over its open WebSocket (agents behind NAT cannot be dialed).
+// It also reports which transport was used.
+func (s *Server) queryAgent(ctx context.Context, target *aoi.AgentIdentity, params secretary.QueryRequest) (*secretary.QueryResponse, string, error) {
+	if target.Endpoint != "" || !s.wsHub.IsConnected(target.ID) {
+		resp, err := s.forwarder.Query(ctx, target, params)
+		return resp, "http", err
 	}
 
-	// Mock response for now
-	result := map[string]interface{}{
-		"answer":     fmt.Sprintf("Response to: %s", params.Query),
-		"confidence": 0.85,
-		"sources":    []string{},
-		"metadata":   params.Metadata,
+	params.ToAgent = target.ID
+	raw, err := s.wsHub.Call(ctx, target.ID, "aoi.query", params)
+	if err != nil {
+		return nil, "websocket", err
 	}
+	var resp secretary.QueryResponse
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return nil, "websocket", fmt.Errorf("failed to unmarshal result: %w", err)
+	}
+	return &resp, "websocket", nil
+}
 
-	s.sendJSONRPCSuccess(w, req.ID, result)
+// isLocalTarget reports whether a query target refers to this agent.
+func (s *Server) isLocalTarget(agentID string) bool {
+	if agentID == "" {
+		return true
+	}
+	return s.secretary != nil && s.secretary.Identity != nil && s.secretary.Identity.ID == agentID
 }
 
-// handleExecute implements aoi.execute method
-func (s *Server) handleExecute(w http.ResponseWriter, req *JSONRPCRequest) {
+// handleExecute implements aoi.execute method.
+// Async tasks return immediately with their queued state; others block until finished.
+func (s *Server) handleExecute(ctx context.Context, raw json.RawMessage) (interface{}, error) {
 	var params aoi.Task
 
-	if err := json.Unmarshal(req.Params, &params); err != nil {
-		s.sendJSONRPCError(w, req.ID, JSONRPCInvalidParams, "Invalid params", err.Error())
-		return
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, invalidParams(err.Error())
+	}
+	// Task output is only stripped of code, not withheld like the non-text
+	// output of aoi.mcp.call, so MCP tools are only run for callers who may
+	// see everything.
+	if params.Type == task.TypeMCP {
+		if level := s.disclosureLevel(ctx); level != disclosure.Full {
+			return nil, &JSONRPCError{Code: JSONRPCACLDenied,
+				Message: fmt.Sprintf("mcp tasks need read on %s; caller may only see %s output", disclosure.ResourceFull, level)}
+		}
 	}
 
-	// Mock execution result
-	result := aoi.TaskResult{
-		TaskID: params.ID,
-		Status: "completed",
-		Output: "Task executed successfully",
+	info, err := s.taskMgr.SubmitFrom(authenticatedAgent(ctx), params)
+	if err != nil {
+		return nil, err
 	}
 
-	s.sendJSONRPCSuccess(w, req.ID, result)
+	if !params.Async {
+		// The caller going away stops the wait, not the task.
+		info, err = s.taskMgr.Wait(ctx, info.TaskID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return s.restrictTask(ctx, info).Result(), nil
+}
+
+// auditDelegation records a query handed to the local worker AI, with the
+// prompt it was given and its answer, both redacted
+func (s *Server) auditDelegation(d secretary.Delegation) {
+	d = s.redactDelegation(d)
+	s.auditLogger.Log(audit.EventDelegate, d.Query.FromAgent, d.Worker, d.Query.Query,
+		map[string]interface{}{
+			"delegation_id": d.ID,
+			"prompt":        d.Prompt,
+			"answer":        d.Answer,
+			"duration_ms":   d.Duration.Milliseconds(),
+		}, d.Error == "", d.Error)
+}
+
+// broadcastTaskComplete notifies the requester's WebSocket connections when an
+// async task finishes.
+func (s *Server) broadcastTaskComplete(info *task.Info) {
+	s.auditLogger.Log(audit.EventExecute, "", "", fmt.Sprintf("task %s (%s) %s", info.TaskID, info.Type, info.State),
+		map[string]interface{}{"task_id": info.TaskID, "state": string(info.State)},
+		info.State == task.StateSucceeded, info.Error)
+
+	if !info.Async || info.Requester == "" {
+		return
+	}
+	_ = s.wsHub.SendToAgent(info.Requester, MessageTypeTaskComplete, info.Result())
 }
 
 // handleNotify implements aoi.notify method (no response expected)
-func (s *Server) handleNotify(w http.ResponseWriter, req *JSONRPCRequest) {
+func (s *Server) handleNotify(ctx context.Context, raw json.RawMessage) (interface{}, error) {
 	var params struct {
 		Type    string                 `json:"type"`
 		From    string                 `json:"from"`
@@ -303,126 +701,139 @@ func (s *Server) handleNotify(w http.ResponseWriter, req *JSONRPCRequest) {
 		Data    map[string]interface{} `json:"data,omitempty"`
 	}
 
-	if err := json.Unmarshal(req.Params, &params); err != nil {
-		s.sendJSONRPCError(w, req.ID, JSONRPCInvalidParams, "Invalid params", err.Error())
-		return
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, invalidParams(err.Error())
 	}
 
 	// Notification accepted
-	result := map[string]interface{}{
+	return map[string]interface{}{
 		"status": "accepted",
-	}
-
-	s.sendJSONRPCSuccess(w, req.ID, result)
+	}, nil
 }
 
 // handleStatus implements aoi.status method
-func (s *Server) handleStatus(w http.ResponseWriter, req *JSONRPCRequest) {
+func (s *Server) handleStatus(ctx context.Context, raw json.RawMessage) (interface{}, error) {
 	var params struct {
 		AgentID string `json:"agent_id,omitempty"`
 	}
 
-	if req.Params != nil && len(req.Params) > 0 {
-		if err := json.Unmarshal(req.Params, &params); err != nil {
-			s.sendJSONRPCError(w, req.ID, JSONRPCInvalidParams, "Invalid params", err.Error())
-			return
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &params); err != nil {
+			return nil, invalidParams(err.Error())
+		}
+	}
+
+	// Another agent's liveness as seen by this agent's registry
+	if !s.isLocalTarget(params.AgentID) {
+		agent, err := s.registry.GetAgent(params.AgentID)
+		if err != nil {
+			return nil, &JSONRPCError{Code: JSONRPCAgentNotFound, Message: "Agent not found", Data: params.AgentID}
 		}
+		return map[string]interface{}{
+			"agent_id":         agent.ID,
+			"status":           agent.Status,
+			"last_seen":        agent.LastSeen,
+			"lease_expires_at": agent.LeaseExpiresAt,
+			"connected":        s.wsHub.IsConnected(agent.ID),
+		}, nil
 	}
 
-	result := map[string]interface{}{
+	status := map[string]interface{}{
 		"status": "online",
 		"agents": len(s.registry.Discover()),
 	}
-
-	s.sendJSONRPCSuccess(w, req.ID, result)
+	if version := s.aclMgr.PolicyVersion(); version != "" {
+		status["acl_policy_version"] = version
+	}
+	return status, nil
 }
 
-// handleContextRPC routes context-related JSON-RPC methods
-func (s *Server) handleContextRPC(w http.ResponseWriter, req *JSONRPCRequest) {
-	if s.contextAPI == nil {
-		s.sendJSONRPCError(w, req.ID, JSONRPCMethodNotFound, "Context API not available", nil)
-		return
+// handleHeartbeat implements aoi.heartbeat: it renews an agent's lease so the
+// agent stays online. An agent authenticated by certificate or signature may only renew its own.
+func (s *Server) handleHeartbeat(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+	var params struct {
+		AgentID string `json:"agent_id"`
+		TTL     int    `json:"ttl,omitempty"`
 	}
 
-	result, err := s.contextAPI.HandleJSONRPC(req.Method, req.Params)
-	if err != nil {
-		s.sendJSONRPCError(w, req.ID, JSONRPCInternalError, err.Error(), nil)
-		return
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &params); err != nil {
+			return nil, invalidParams(err.Error())
+		}
 	}
 
-	s.sendJSONRPCSuccess(w, req.ID, result)
-}
-
-// handleMCPRPC routes MCP-related JSON-RPC methods
-func (s *Server) handleMCPRPC(w http.ResponseWriter, req *JSONRPCRequest) {
-	if s.mcpBridge == nil {
-		s.sendJSONRPCError(w, req.ID, JSONRPCMethodNotFound, "MCP Bridge not available", nil)
-		return
+	if authenticated := authenticatedAgent(ctx); authenticated != "" {
+		if params.AgentID == "" {
+			params.AgentID = authenticated
+		} else if params.AgentID != authenticated {
+			return nil, &JSONRPCError{Code: JSONRPCACLDenied,
+				Message: fmt.Sprintf("agent '%s' cannot heartbeat for agent '%s'", authenticated, params.AgentID)}
+		}
 	}
-
-	ctx := context.Background()
-	result, err := s.mcpBridge.HandleJSONRPC(ctx, req.Method, req.Params)
-	if err != nil {
-		s.sendJSONRPCError(w, req.ID, JSONRPCInternalError, err.Error(), nil)
-		return
+	if params.AgentID == "" {
+		return nil, invalidParams("agent_id is required")
 	}
-
-	s.sendJSONRPCSuccess(w, req.ID, result)
-}
-
-// handleApprovalRPC routes approval-related JSON-RPC methods
-func (s *Server) handleApprovalRPC(w http.ResponseWriter, req *JSONRPCRequest) {
-	if s.approvalMgr == nil {
-		s.sendJSONRPCError(w, req.ID, JSONRPCMethodNotFound, "Approval Manager not available", nil)
-		return
+	if params.TTL < 0 {
+		return nil, invalidParams("ttl must not be negative")
 	}
 
-	result, err := s.approvalMgr.HandleJSONRPC(req.Method, req.Params)
+	agent, err := s.registry.Heartbeat(params.AgentID, time.Duration(params.TTL)*time.Second)
+	if errors.Is(err, identity.ErrAgentNotFound) {
+		return nil, &JSONRPCError{Code: JSONRPCAgentNotFound, Message: "Agent not found", Data: params.AgentID}
+	}
 	if err != nil {
-		s.sendJSONRPCError(w, req.ID, JSONRPCInternalError, err.Error(), nil)
-		return
+		return nil, err
 	}
 
-	s.sendJSONRPCSuccess(w, req.ID, result)
+	return map[string]interface{}{
+		"agent_id":         agent.ID,
+		"status":           agent.Status,
+		"last_seen":        agent.LastSeen,
+		"lease_expires_at": agent.LeaseExpiresAt,
+	}, nil
 }
 
-// handleAuditRPC routes audit-related JSON-RPC methods
-func (s *Server) handleAuditRPC(w http.ResponseWriter, req *JSONRPCRequest) {
-	if s.auditLogger == nil {
-		s.sendJSONRPCError(w, req.ID, JSONRPCMethodNotFound, "Audit Logger not available", nil)
-		return
-	}
+// broadcastAgentUpdate notifies WebSocket subscribers of an agent's status transition
+func (s *Server) broadcastAgentUpdate(change identity.StatusChange) {
+	_ = s.wsHub.BroadcastToTopic(MessageTypeAgentUpdate, MessageTypeAgentUpdate, map[string]interface{}{
+		"agent_id":        change.Agent.ID,
+		"status":          change.Agent.Status,
+		"previous_status": change.Previous,
+		"last_seen":       change.Agent.LastSeen,
+		"agent":           change.Agent,
+	})
+}
 
-	result, err := s.auditLogger.HandleJSONRPC(req.Method, req.Params)
-	if err != nil {
-		s.sendJSONRPCError(w, req.ID, JSONRPCInternalError, err.Error(), nil)
-		return
+// discoverInfo describes this agent in the OpenRPC document returned by rpc.discover
+func (s *Server) discoverInfo() rpc.Info {
+	info := rpc.Info{
+		Title:       "AOI Agent",
+		Description: "Agent Operational Interconnect JSON-RPC API",
+		Version:     ProtocolVersion,
 	}
-
-	s.sendJSONRPCSuccess(w, req.ID, result)
+	if s.secretary != nil && s.secretary.Identity != nil {
+		info.Title = fmt.Sprintf("AOI Agent %s (%s)", s.secretary.Identity.ID, s.secretary.Identity.Role)
+	}
+	return info
 }
 
-// sendJSONRPCSuccess sends a successful JSON-RPC response
-func (s *Server) sendJSONRPCSuccess(w http.ResponseWriter, id interface{}, result interface{}) {
+// newResultResponse builds a successful JSON-RPC response
+func newResultResponse(id interface{}, result interface{}) *JSONRPCResponse {
 	resultJSON, err := json.Marshal(result)
 	if err != nil {
-		s.sendJSONRPCError(w, id, JSONRPCInternalError, "Failed to marshal result", err.Error())
-		return
+		return newErrorResponse(id, JSONRPCInternalError, "Failed to marshal result", err.Error())
 	}
 
-	resp := JSONRPCResponse{
+	return &JSONRPCResponse{
 		JSONRPC: "2.0",
 		Result:  resultJSON,
 		ID:      id,
 	}
-
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(resp)
 }
 
-// sendJSONRPCError sends a JSON-RPC error response
-func (s *Server) sendJSONRPCError(w http.ResponseWriter, id interface{}, code int, message string, data interface{}) {
-	resp := JSONRPCResponse{
+// newErrorResponse builds a JSON-RPC error response
+func newErrorResponse(id interface{}, code int, message string, data interface{}) *JSONRPCResponse {
+	return &JSONRPCResponse{
 		JSONRPC: "2.0",
 		Error: &JSONRPCError{
 			Code:    code,
@@ -431,146 +842,141 @@ func (s *Server) sendJSONRPCError(w http.ResponseWriter, id interface{}, code in
 		},
 		ID: id,
 	}
+}
+
+// invalidParams builds an Invalid params error carrying detail as data
+func invalidParams(detail string) *JSONRPCError {
+	return &JSONRPCError{Code: JSONRPCInvalidParams, Message: "Invalid params", Data: detail}
+}
+
+// internalError wraps err as an Internal error
+func internalError(err error) *JSONRPCError {
+	return &JSONRPCError{Code: JSONRPCInternalError, Message: err.Error()}
+}
 
+// writeJSONRPC writes a single response or a batch of responses
+func (s *Server) writeJSONRPC(w http.ResponseWriter, payload interface{}) {
 	w.WriteHeader(http.StatusOK) // JSON-RPC errors are still HTTP 200
-	json.NewEncoder(w).Encode(resp)
-}
-
-// handleH2ARPC routes aoi.h2a.* JSON-RPC methods.
-func (s *Server) handleH2ARPC(w http.ResponseWriter, req *JSONRPCRequest) {
-	switch req.Method {
-	case "aoi.h2a.register":
-		s.handleH2ARegister(w, req)
-	case "aoi.h2a.sessions":
-		s.handleH2ASessions(w, req)
-	case "aoi.h2a.send":
-		s.handleH2ASend(w, req)
-	case "aoi.h2a.stream":
-		s.handleH2AStream(w, req)
-	case "aoi.h2a.stop":
-		s.handleH2AStop(w, req)
-	default:
-		s.sendJSONRPCError(w, req.ID, JSONRPCMethodNotFound, "Method not found", req.Method)
-	}
+	json.NewEncoder(w).Encode(payload)
 }
 
 // handleH2ARegister implements aoi.h2a.register — links an agent ID to a tmux session.
-func (s *Server) handleH2ARegister(w http.ResponseWriter, req *JSONRPCRequest) {
+func (s *Server) handleH2ARegister(ctx context.Context, raw json.RawMessage) (interface{}, error) {
 	var params struct {
 		AgentID     string `json:"agent_id"`
 		SessionName string `json:"session_name"`
 		PaneName    string `json:"pane_name,omitempty"`
 	}
-	if err := json.Unmarshal(req.Params, &params); err != nil {
-		s.sendJSONRPCError(w, req.ID, JSONRPCInvalidParams, "Invalid params", err.Error())
-		return
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, invalidParams(err.Error())
 	}
 	if err := s.h2aMgr.RegisterSession(params.AgentID, params.SessionName, params.PaneName); err != nil {
-		s.sendJSONRPCError(w, req.ID, JSONRPCInvalidParams, err.Error(), nil)
-		return
+		return nil, &JSONRPCError{Code: JSONRPCInvalidParams, Message: err.Error()}
 	}
-	s.sendJSONRPCSuccess(w, req.ID, map[string]interface{}{
+	return map[string]interface{}{
 		"status":   "registered",
 		"agent_id": params.AgentID,
-	})
+	}, nil
 }
 
 // handleH2ASessions implements aoi.h2a.sessions — returns all registered tmux sessions.
-func (s *Server) handleH2ASessions(w http.ResponseWriter, req *JSONRPCRequest) {
+func (s *Server) handleH2ASessions(ctx context.Context, raw json.RawMessage) (interface{}, error) {
 	sessions := s.h2aMgr.ListSessions()
-	s.sendJSONRPCSuccess(w, req.ID, map[string]interface{}{
+	return map[string]interface{}{
 		"sessions": sessions,
 		"count":    len(sessions),
-	})
+	}, nil
 }
 
 // handleH2ASend implements aoi.h2a.send — sends a command and optionally returns captured output.
-func (s *Server) handleH2ASend(w http.ResponseWriter, req *JSONRPCRequest) {
+func (s *Server) handleH2ASend(ctx context.Context, raw json.RawMessage) (interface{}, error) {
 	var params struct {
 		TargetAgentID string `json:"target_agent_id"`
 		FromUser      string `json:"from_user"`
 		Command       string `json:"command"`
 		CaptureOutput bool   `json:"capture_output"`
 	}
-	if err := json.Unmarshal(req.Params, &params); err != nil {
-		s.sendJSONRPCError(w, req.ID, JSONRPCInvalidParams, "Invalid params", err.Error())
-		return
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, invalidParams(err.Error())
 	}
 
-	// ACL check: can from_user send to target?
-	if !s.h2aMgr.CanSendTo(params.FromUser, params.TargetAgentID) {
-		s.sendJSONRPCError(w, req.ID, JSONRPCACLDenied,
-			fmt.Sprintf("user '%s' is not allowed to send to agent '%s'", params.FromUser, params.TargetAgentID),
-			nil)
-		return
+	sender, err := s.authorizeH2A(ctx, params.FromUser, params.TargetAgentID)
+	if err != nil {
+		return nil, err
 	}
 
 	result, err := s.h2aMgr.SendCommand(params.TargetAgentID, params.Command, params.CaptureOutput)
 	if err != nil {
-		s.sendJSONRPCError(w, req.ID, JSONRPCInternalError, err.Error(), nil)
-		return
+		return nil, err
 	}
 
-	log.Printf("[H2A] %s -> %s: %q", params.FromUser, params.TargetAgentID, params.Command)
-	s.sendJSONRPCSuccess(w, req.ID, result)
+	log.Printf("[H2A] %s -> %s: %q", sender, params.TargetAgentID, params.Command)
+	return result, nil
+}
+
+// authorizeH2A returns the proven caller if it may send to targetAgentID. The
+// from_user param is informational and, when given, must name the caller.
+func (s *Server) authorizeH2A(ctx context.Context, fromUser, targetAgentID string) (string, error) {
+	caller := authenticatedAgent(ctx)
+	if fromUser != "" && fromUser != caller {
+		return "", &JSONRPCError{Code: JSONRPCACLDenied,
+			Message: fmt.Sprintf("caller '%s' cannot send as user '%s'", caller, fromUser)}
+	}
+	if !s.h2aMgr.CanSendTo(caller, targetAgentID) {
+		return "", &JSONRPCError{Code: JSONRPCACLDenied,
+			Message: fmt.Sprintf("user '%s' is not allowed to send to agent '%s'", caller, targetAgentID)}
+	}
+	return caller, nil
 }
 
 // handleH2AStream implements aoi.h2a.stream — sends a command and begins output streaming via WebSocket.
-func (s *Server) handleH2AStream(w http.ResponseWriter, req *JSONRPCRequest) {
+func (s *Server) handleH2AStream(ctx context.Context, raw json.RawMessage) (interface{}, error) {
 	var params struct {
 		TargetAgentID string `json:"target_agent_id"`
 		FromUser      string `json:"from_user"`
 		Command       string `json:"command"`
 		IntervalMs    int    `json:"interval_ms,omitempty"`
 	}
-	if err := json.Unmarshal(req.Params, &params); err != nil {
-		s.sendJSONRPCError(w, req.ID, JSONRPCInvalidParams, "Invalid params", err.Error())
-		return
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, invalidParams(err.Error())
 	}
 
-	// ACL check
-	if !s.h2aMgr.CanSendTo(params.FromUser, params.TargetAgentID) {
-		s.sendJSONRPCError(w, req.ID, JSONRPCACLDenied,
-			fmt.Sprintf("user '%s' is not allowed to send to agent '%s'", params.FromUser, params.TargetAgentID),
-			nil)
-		return
+	sender, err := s.authorizeH2A(ctx, params.FromUser, params.TargetAgentID)
+	if err != nil {
+		return nil, err
 	}
 
 	// Send command first
 	if _, err := s.h2aMgr.SendCommand(params.TargetAgentID, params.Command, false); err != nil {
-		s.sendJSONRPCError(w, req.ID, JSONRPCInternalError, err.Error(), nil)
-		return
+		return nil, err
 	}
 
 	// Start streaming output via WebSocket
 	streamID, err := s.h2aMgr.StartStream(params.TargetAgentID, params.IntervalMs)
 	if err != nil {
-		s.sendJSONRPCError(w, req.ID, JSONRPCInternalError, err.Error(), nil)
-		return
+		return nil, err
 	}
 
-	log.Printf("[H2A] stream %s: %s -> %s: %q", streamID, params.FromUser, params.TargetAgentID, params.Command)
-	s.sendJSONRPCSuccess(w, req.ID, map[string]interface{}{
+	log.Printf("[H2A] stream %s: %s -> %s: %q", streamID, sender, params.TargetAgentID, params.Command)
+	return map[string]interface{}{
 		"status":    "streaming",
 		"stream_id": streamID,
 		"topic":     "h2a:" + params.TargetAgentID,
-	})
+	}, nil
 }
 
 // handleH2AStop implements aoi.h2a.stop — cancels an active stream.
-func (s *Server) handleH2AStop(w http.ResponseWriter, req *JSONRPCRequest) {
+func (s *Server) handleH2AStop(ctx context.Context, raw json.RawMessage) (interface{}, error) {
 	var params struct {
 		StreamID string `json:"stream_id"`
 	}
-	if err := json.Unmarshal(req.Params, &params); err != nil {
-		s.sendJSONRPCError(w, req.ID, JSONRPCInvalidParams, "Invalid params", err.Error())
-		return
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, invalidParams(err.Error())
 	}
 	if err := s.h2aMgr.StopStream(params.StreamID); err != nil {
-		s.sendJSONRPCError(w, req.ID, JSONRPCInternalError, err.Error(), nil)
-		return
+		return nil, err
 	}
-	s.sendJSONRPCSuccess(w, req.ID, map[string]string{"status": "stopped"})
+	return map[string]string{"status": "stopped"}, nil
 }
 
 // GetWSHub returns the WebSocket hub for external use
@@ -578,6 +984,16 @@ func (s *Server) GetWSHub() *WSHub {
 	return s.wsHub
 }
 
+// Handler returns the HTTP handler serving all REST, JSON-RPC and WebSocket routes.
+// The WebSocket hub must be running (see Start) for WebSocket clients to connect.
+func (s *Server) Handler() http.Handler {
+	handler := s.authenticateHTTP(s.mux)
+	if s.tsAuth != nil {
+		handler = s.tsAuth.Middleware(handler)
+	}
+	return handler
+}
+
 // Start starts the HTTP server
 func (s *Server) Start(addr string) error {
 	log.Printf("Starting server on %s", addr)
@@ -585,5 +1001,42 @@ func (s *Server) Start(addr string) error {
 	// Start WebSocket hub in background
 	go s.wsHub.Run()
 
-	return http.ListenAndServe(addr, s.mux)
+	return http.ListenAndServe(addr, s.Handler())
+}
+
+// StartTLS starts the HTTPS server. When tlsConfig verifies client certificates,
+// the agent ID each certificate was issued for identifies the caller (mutual TLS).
+func (s *Server) StartTLS(addr string, tlsConfig *tls.Config) error {
+	log.Printf("Starting TLS server on %s", addr)
+
+	// Start WebSocket hub in background
+	go s.wsHub.Run()
+
+	srv := &http.Server{
+		Addr:      addr,
+		Handler:   pki.Middleware(s.Handler()),
+		TLSConfig: tlsConfig,
+	}
+	return srv.ListenAndServeTLS("", "")
+}
+
+// SetSigning makes the server verify request signatures with v and sign the
+// calls it forwards to other agents as agentID with key (when key is non-nil)
+func (s *Server) SetSigning(v *signing.Verifier, agentID string, key ed25519.PrivateKey) {
+	s.verifier = v
+	if key != nil {
+		s.forwarder.SetSigner(agentID, key)
+	}
+}
+
+// SetForwardTimeout bounds calls to other agents. Keep it above the
+// delegation timeout of the agents called, e.g. by ForwardTimeoutMargin.
+func (s *Server) SetForwardTimeout(timeout time.Duration) {
+	s.forwarder.SetTimeout(timeout)
+}
+
+// SetForwardTLS sets the TLS configuration used when calling other agents,
+// e.g. to present this agent's certificate to peers requiring mutual TLS.
+func (s *Server) SetForwardTLS(tlsConfig *tls.Config) {
+	s.forwarder.SetTLSConfig(tlsConfig)
 }
@@ -1,10 +1,12 @@
 package protocol
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
@@ -23,8 +25,9 @@ const (
 	// Send pings to peer with this period. Must be less than pongWait.
 	pingPeriod = (pongWait * 9) / 10
 
-	// Maximum message size allowed from peer.
-	maxMessageSize = 512
+	// Maximum message size allowed from peer. Large enough for JSON-RPC
+	// requests and responses carried over the socket.
+	maxMessageSize = 1 << 20
 )
 
 // WebSocket message types
@@ -40,6 +43,13 @@ const (
 	MessageTypeError           = "error"
 	// H2A: Human-to-Agent output streaming
 	MessageTypeH2AOutput = "h2a_output"
+	// Completion of an async aoi.execute task
+	MessageTypeTaskComplete = "task_complete"
+	// Focus mode: queries escalated to a focused human, and the digest of
+	// queries queued while they were focused. Only the human's own
+	// authenticated connections receive them.
+	MessageTypeEscalation  = "escalation"
+	MessageTypeFocusDigest = "focus_digest"
 )
 
 // WSMessage represents a WebSocket message
@@ -102,13 +112,28 @@ var upgrader = websocket.Upgrader{
 
 // WSClient represents a WebSocket client connection
 type WSClient struct {
-	hub        *WSHub
-	conn       *websocket.Conn
-	send       chan []byte
-	agentID    string
-	topics     map[string]bool
-	topicsMu   sync.RWMutex
-	notifyChan chan notify.Notification
+	hub         *WSHub
+	conn        *websocket.Conn
+	send        chan []byte
+	agentID     string
+	proven      bool
+	topics      map[string]bool
+	topicsMu    sync.RWMutex
+	notifyChan  chan notify.Notification
+	connectedAt time.Time
+
+	// JSON-RPC over the socket, in both directions
+	server    *Server
+	ctx       context.Context
+	cancel    context.CancelFunc
+	inflight  chan struct{}
+	pending   map[string]chan *JSONRPCResponse
+	pendingMu sync.Mutex
+	nextID    int64
+
+	// closed is set by the hub when it closes send
+	sendMu sync.Mutex
+	closed bool
 }
 
 // WSHub manages all WebSocket connections
@@ -149,7 +174,10 @@ func (h *WSHub) Run() {
 			h.mu.Lock()
 			if _, ok := h.clients[client]; ok {
 				delete(h.clients, client)
+				client.sendMu.Lock()
+				client.closed = true
 				close(client.send)
+				client.sendMu.Unlock()
 				if client.notifyChan != nil && client.agentID != "" {
 					h.notifyMgr.Unsubscribe(client.agentID, client.notifyChan)
 				}
@@ -234,6 +262,41 @@ func (h *WSHub) BroadcastToTopic(topic string, msgType string, payload interface
 	return nil
 }
 
+// SendToAgent sends a message only to the connections proven to belong to
+// agentID, whatever topics they subscribed to
+func (h *WSHub) SendToAgent(agentID string, msgType string, payload interface{}) error {
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	msg := WSMessage{
+		Type:      msgType,
+		Payload:   payloadJSON,
+		Timestamp: time.Now(),
+	}
+
+	msgJSON, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for client := range h.clients {
+		if client.proven && client.agentID == agentID {
+			select {
+			case client.send <- msgJSON:
+			default:
+				// Client buffer full
+			}
+		}
+	}
+
+	return nil
+}
+
 // GetClientCount returns the number of connected clients
 func (h *WSHub) GetClientCount() int {
 	h.mu.RLock()
@@ -244,27 +307,46 @@ func (h *WSHub) GetClientCount() int {
 // HandleWebSocket handles WebSocket upgrade and connection
 func (s *Server) HandleWebSocket(hub *WSHub) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		conn, err := upgrader.Upgrade(w, r, nil)
-		if err != nil {
-			log.Printf("WebSocket upgrade failed: %v", err)
+		// A proven identity wins; otherwise take the query param or header,
+		// which names the connection but never routes calls to it
+		proven := authenticatedAgent(r.Context())
+		claimed := r.URL.Query().Get("agent_id")
+		if claimed == "" {
+			claimed = r.Header.Get("X-Agent-ID")
+		}
+		if proven != "" && claimed != "" && claimed != proven {
+			http.Error(w, fmt.Sprintf("authenticated as '%s', not '%s'", proven, claimed), http.StatusForbidden)
 			return
 		}
-
-		// Get agent ID from query param or header
-		agentID := r.URL.Query().Get("agent_id")
+		agentID := proven
 		if agentID == "" {
-			agentID = r.Header.Get("X-Agent-ID")
+			agentID = claimed
 		}
 		if agentID == "" {
 			agentID = "anonymous-" + generateID()
 		}
 
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			log.Printf("WebSocket upgrade failed: %v", err)
+			return
+		}
+
+		// RPCs over this connection carry the caller's proven identity like HTTP requests do
+		ctx, cancel := context.WithCancel(connectionContext(r))
 		client := &WSClient{
-			hub:     hub,
-			conn:    conn,
-			send:    make(chan []byte, 256),
-			agentID: agentID,
-			topics:  make(map[string]bool),
+			hub:         hub,
+			conn:        conn,
+			send:        make(chan []byte, 256),
+			agentID:     agentID,
+			proven:      proven != "",
+			topics:      make(map[string]bool),
+			connectedAt: time.Now(),
+			server:      s,
+			ctx:         ctx,
+			cancel:      cancel,
+			inflight:    make(chan struct{}, maxBatchConcurrency),
+			pending:     make(map[string]chan *JSONRPCResponse),
 		}
 
 		// Subscribe to all topics by default
@@ -274,7 +356,7 @@ func (s *Server) HandleWebSocket(hub *WSHub) http.HandlerFunc {
 		client.topics[MessageTypeApprovalRequest] = true
 
 		// Subscribe to notification manager if agent ID is provided
-		if agentID != "" && agentID[:10] != "anonymous-" {
+		if agentID != "" && !strings.HasPrefix(agentID, "anonymous-") {
 			client.notifyChan = hub.notifyMgr.Subscribe(agentID)
 			go client.forwardNotifications()
 		}
@@ -310,6 +392,8 @@ func (c *WSClient) forwardNotifications() {
 // readPump reads messages from the WebSocket connection
 func (c *WSClient) readPump() {
 	defer func() {
+		c.cancel()
+		c.failPending()
 		c.hub.unregister <- c
 		c.conn.Close()
 	}()
@@ -334,8 +418,14 @@ func (c *WSClient) readPump() {
 	}
 }
 
-// handleMessage processes incoming WebSocket messages
+// handleMessage processes incoming WebSocket messages. JSON-RPC requests,
+// responses and batches share the socket with hub messages.
 func (c *WSClient) handleMessage(data []byte) {
+	if isBatch(data) || isJSONRPC(data) {
+		c.handleRPC(data)
+		return
+	}
+
 	var msg WSMessage
 	if err := json.Unmarshal(data, &msg); err != nil {
 		c.sendError("Invalid message format")
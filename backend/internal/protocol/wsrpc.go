@@ -0,0 +1,221 @@
+package protocol
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// ErrAgentNotConnected is returned when a call targets an agent with no open WebSocket
+var ErrAgentNotConnected = errors.New("agent not connected")
+
+// errConnectionClosed fails calls that were pending when their socket closed
+var errConnectionClosed = errors.New("websocket connection closed")
+
+// isJSONRPC reports whether a WebSocket frame is a JSON-RPC message rather than a hub message
+func isJSONRPC(data []byte) bool {
+	var probe struct {
+		JSONRPC string `json:"jsonrpc"`
+	}
+	return json.Unmarshal(data, &probe) == nil && probe.JSONRPC != ""
+}
+
+// handleRPC processes a JSON-RPC frame: a batch or request from the client, or a
+// response to a request the server sent over this socket.
+func (c *WSClient) handleRPC(data []byte) {
+	if isBatch(data) {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(data, &batch); err != nil {
+			c.enqueueJSON(newErrorResponse(nil, JSONRPCParseError, "Parse error", err.Error()))
+			return
+		}
+		go c.serveBatch(batch)
+		return
+	}
+
+	var probe struct {
+		Method string `json:"method"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		c.enqueueJSON(newErrorResponse(nil, JSONRPCParseError, "Parse error", err.Error()))
+		return
+	}
+
+	if probe.Method == "" {
+		var resp JSONRPCResponse
+		if err := json.Unmarshal(data, &resp); err == nil {
+			c.resolve(&resp)
+		}
+		return
+	}
+
+	var req JSONRPCRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		c.enqueueJSON(newErrorResponse(nil, JSONRPCParseError, "Parse error", err.Error()))
+		return
+	}
+	// Requests run concurrently so a slow method does not stall the socket.
+	go c.serve(&req)
+}
+
+// serve dispatches a single request received over the socket
+func (c *WSClient) serve(req *JSONRPCRequest) {
+	select {
+	case c.inflight <- struct{}{}:
+		defer func() { <-c.inflight }()
+	case <-c.ctx.Done():
+		return
+	}
+
+	resp := c.server.dispatch(c.ctx, req)
+	if needsResponse(req, resp) {
+		c.enqueueJSON(resp)
+	}
+}
+
+// serveBatch dispatches a batch received over the socket
+func (c *WSClient) serveBatch(batch []json.RawMessage) {
+	responses, rpcErr := c.server.dispatchBatch(c.ctx, batch)
+	switch {
+	case rpcErr != nil:
+		c.enqueueJSON(&JSONRPCResponse{JSONRPC: "2.0", Error: rpcErr})
+	case len(responses) > 0:
+		c.enqueueJSON(responses)
+	}
+}
+
+// Call sends a JSON-RPC request to the client and waits for its response.
+// A JSON-RPC error returned by the client is surfaced as *JSONRPCError.
+func (c *WSClient) Call(ctx context.Context, method string, params interface{}) (json.RawMessage, error) {
+	paramsJSON, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal params: %w", err)
+	}
+
+	id := fmt.Sprintf("srv-%d", atomic.AddInt64(&c.nextID, 1))
+	ch := make(chan *JSONRPCResponse, 1)
+
+	c.pendingMu.Lock()
+	if c.pending == nil {
+		c.pendingMu.Unlock()
+		return nil, errConnectionClosed
+	}
+	c.pending[id] = ch
+	c.pendingMu.Unlock()
+
+	defer func() {
+		c.pendingMu.Lock()
+		if c.pending != nil {
+			delete(c.pending, id)
+		}
+		c.pendingMu.Unlock()
+	}()
+
+	req := JSONRPCRequest{JSONRPC: "2.0", Method: method, Params: paramsJSON, ID: id}
+	if !c.enqueueJSON(req) {
+		return nil, errConnectionClosed
+	}
+
+	select {
+	case resp, ok := <-ch:
+		if !ok {
+			return nil, errConnectionClosed
+		}
+		if resp.Error != nil {
+			return nil, resp.Error
+		}
+		return resp.Result, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// resolve delivers a response to the pending Call with the same ID
+func (c *WSClient) resolve(resp *JSONRPCResponse) {
+	id, ok := resp.ID.(string)
+	if !ok {
+		return
+	}
+
+	c.pendingMu.Lock()
+	ch, ok := c.pending[id]
+	if ok {
+		delete(c.pending, id)
+	}
+	c.pendingMu.Unlock()
+
+	if ok {
+		ch <- resp
+	}
+}
+
+// failPending ends all pending calls once the connection is gone
+func (c *WSClient) failPending() {
+	c.pendingMu.Lock()
+	defer c.pendingMu.Unlock()
+
+	for _, ch := range c.pending {
+		close(ch)
+	}
+	c.pending = nil
+}
+
+// enqueueJSON queues a JSON-RPC message for writePump. Unlike hub broadcasts,
+// RPC traffic is not dropped when the buffer is full; it waits up to writeWait.
+func (c *WSClient) enqueueJSON(v interface{}) bool {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return false
+	}
+
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	if c.closed {
+		return false
+	}
+
+	timer := time.NewTimer(writeWait)
+	defer timer.Stop()
+	select {
+	case c.send <- data:
+		return true
+	case <-c.ctx.Done():
+		return false
+	case <-timer.C:
+		return false
+	}
+}
+
+// Call sends a JSON-RPC request to a connected agent over its WebSocket and
+// returns the raw result. This reaches agents that cannot expose an endpoint.
+func (h *WSHub) Call(ctx context.Context, agentID, method string, params interface{}) (json.RawMessage, error) {
+	client := h.clientFor(agentID)
+	if client == nil {
+		return nil, fmt.Errorf("%w: %s", ErrAgentNotConnected, agentID)
+	}
+	return client.Call(ctx, method, params)
+}
+
+// IsConnected reports whether agentID has an open, authenticated WebSocket connection
+func (h *WSHub) IsConnected(agentID string) bool {
+	return h.clientFor(agentID) != nil
+}
+
+// clientFor returns a connection proven to belong to agentID, preferring the
+// most recent one. Connections that only claim an agent ID are never chosen,
+// so they cannot intercept calls meant for that agent.
+func (h *WSHub) clientFor(agentID string) *WSClient {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	var found *WSClient
+	for client := range h.clients {
+		if client.proven && client.agentID == agentID && (found == nil || client.connectedAt.After(found.connectedAt)) {
+			found = client
+		}
+	}
+	return found
+}
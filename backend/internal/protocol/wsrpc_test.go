@@ -0,0 +1,328 @@
+package protocol
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/aoi-protocol/aoi/internal/identity"
+	"github.com/aoi-protocol/aoi/internal/secretary"
+	"github.com/aoi-protocol/aoi/pkg/aoi"
+)
+
+// wsURL serves server over HTTP and returns the URL of its WebSocket endpoint.
+func wsURL(t *testing.T, server *Server) string {
+	t.Helper()
+	ts := httptest.NewServer(server.Handler())
+	t.Cleanup(ts.Close)
+	return "ws" + strings.TrimPrefix(ts.URL, "http") + "/api/v1/ws"
+}
+
+// dialWS connects agentID to server over WebSocket, proving its identity with a
+// bearer token, and waits until the hub has registered it.
+func dialWS(t *testing.T, server *Server, agentID string) *websocket.Conn {
+	t.Helper()
+	if server.tokens == nil {
+		server.tokens = map[string]string{}
+	}
+	token := "ws-token-" + agentID
+	server.tokens[token] = agentID
+	go server.wsHub.Run()
+
+	header := http.Header{"Authorization": {"Bearer " + token}}
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL(t, server)+"?agent_id="+agentID, header)
+	if err != nil {
+		t.Fatalf("WebSocket dial failed: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	deadline := time.Now().Add(time.Second)
+	for !server.wsHub.IsConnected(agentID) {
+		if time.Now().After(deadline) {
+			t.Fatalf("agent %s never registered with the hub", agentID)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return conn
+}
+
+// readRPCFrame reads frames until one holds a JSON-RPC message, skipping hub messages.
+func readRPCFrame(conn *websocket.Conn) ([]byte, error) {
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			if isJSONRPC([]byte(line)) || isBatch([]byte(line)) {
+				return []byte(line), nil
+			}
+		}
+	}
+}
+
+// answerNext answers the next server request on conn with reply(req)
+func answerNext(conn *websocket.Conn, reply func(req *JSONRPCRequest) interface{}) {
+	frame, err := readRPCFrame(conn)
+	if err != nil {
+		return
+	}
+	var req JSONRPCRequest
+	if err := json.Unmarshal(frame, &req); err != nil {
+		return
+	}
+	if resp := reply(&req); resp != nil {
+		data, _ := json.Marshal(resp)
+		conn.WriteMessage(websocket.TextMessage, data)
+	}
+}
+
+func mustReadRPCFrame(t *testing.T, conn *websocket.Conn) []byte {
+	t.Helper()
+	frame, err := readRPCFrame(conn)
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	return frame
+}
+
+func TestWSRPC_ClientRequest(t *testing.T) {
+	server := NewServer(nil, nil)
+	conn := dialWS(t, server, "eng-agent")
+
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","method":"aoi.status","id":7}`)); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+
+	var resp JSONRPCResponse
+	if err := json.Unmarshal(mustReadRPCFrame(t, conn), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %+v", resp.Error)
+	}
+	if id, _ := resp.ID.(float64); id != 7 {
+		t.Errorf("expected id 7, got %v", resp.ID)
+	}
+	if !strings.Contains(string(resp.Result), `"online"`) {
+		t.Errorf("unexpected result: %s", resp.Result)
+	}
+}
+
+func TestWSRPC_ClientBatch(t *testing.T) {
+	server := NewServer(nil, nil)
+	conn := dialWS(t, server, "eng-agent")
+
+	batch := `[{"jsonrpc":"2.0","method":"aoi.status","id":1},{"jsonrpc":"2.0","method":"aoi.nope","id":2}]`
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(batch)); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+
+	var resps []JSONRPCResponse
+	if err := json.Unmarshal(mustReadRPCFrame(t, conn), &resps); err != nil {
+		t.Fatalf("decode batch response: %v", err)
+	}
+	if len(resps) != 2 {
+		t.Fatalf("expected 2 responses, got %d", len(resps))
+	}
+	if resps[1].Error == nil || resps[1].Error.Code != JSONRPCMethodNotFound {
+		t.Errorf("expected method not found for aoi.nope, got %+v", resps[1])
+	}
+}
+
+func TestWSRPC_HubMessagesStillWork(t *testing.T) {
+	server := NewServer(nil, nil)
+	conn := dialWS(t, server, "eng-agent")
+
+	conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"ping"}`))
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	var msg WSMessage
+	if err := json.Unmarshal(data, &msg); err != nil || msg.Type != MessageTypePong {
+		t.Errorf("expected pong, got %s", data)
+	}
+}
+
+func TestWSHub_Call(t *testing.T) {
+	server := NewServer(nil, nil)
+	conn := dialWS(t, server, "laptop-agent")
+
+	// The agent answers the server's request over its socket.
+	go answerNext(conn, func(req *JSONRPCRequest) interface{} {
+		return map[string]interface{}{
+			"jsonrpc": "2.0",
+			"id":      req.ID,
+			"result":  map[string]string{"method": req.Method, "params": string(req.Params)},
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	raw, err := server.wsHub.Call(ctx, "laptop-agent", "agent.echo", map[string]int{"n": 1})
+	if err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+
+	var result map[string]string
+	json.Unmarshal(raw, &result)
+	if result["method"] != "agent.echo" || result["params"] != `{"n":1}` {
+		t.Errorf("unexpected result: %s", raw)
+	}
+}
+
+func TestWSHub_Call_ErrorResponse(t *testing.T) {
+	server := NewServer(nil, nil)
+	conn := dialWS(t, server, "laptop-agent")
+
+	go answerNext(conn, func(req *JSONRPCRequest) interface{} {
+		return JSONRPCResponse{JSONRPC: "2.0", ID: req.ID,
+			Error: &JSONRPCError{Code: JSONRPCMethodNotFound, Message: "Method not found"}}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_, err := server.wsHub.Call(ctx, "laptop-agent", "agent.missing", nil)
+
+	var rpcErr *JSONRPCError
+	if !errors.As(err, &rpcErr) || rpcErr.Code != JSONRPCMethodNotFound {
+		t.Errorf("expected method not found error, got %v", err)
+	}
+}
+
+func TestWSHub_Call_NotConnected(t *testing.T) {
+	hub := NewWSHub(nil)
+
+	_, err := hub.Call(context.Background(), "nobody", "agent.echo", nil)
+	if !errors.Is(err, ErrAgentNotConnected) {
+		t.Errorf("expected ErrAgentNotConnected, got %v", err)
+	}
+}
+
+func TestWSHub_Call_IgnoresClaimedIdentity(t *testing.T) {
+	server := NewServer(nil, nil)
+	conn := dialWS(t, server, "laptop-agent")
+
+	// A later, unauthenticated socket claiming the same agent ID must not take over.
+	spoof, _, err := websocket.DefaultDialer.Dial(wsURL(t, server)+"?agent_id=laptop-agent", nil)
+	if err != nil {
+		t.Fatalf("WebSocket dial failed: %v", err)
+	}
+	defer spoof.Close()
+	deadline := time.Now().Add(time.Second)
+	for server.wsHub.GetClientCount() < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("spoofed socket never registered with the hub")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	go answerNext(spoof, func(req *JSONRPCRequest) interface{} {
+		return map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": "spoofed"}
+	})
+	go answerNext(conn, func(req *JSONRPCRequest) interface{} {
+		return map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": "genuine"}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	raw, err := server.wsHub.Call(ctx, "laptop-agent", "agent.echo", nil)
+	if err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	if string(raw) != `"genuine"` {
+		t.Errorf("expected the authenticated socket to answer, got %s", raw)
+	}
+
+	// A claimed ID alone never makes an agent reachable.
+	victim, _, err := websocket.DefaultDialer.Dial(wsURL(t, server)+"?agent_id=pm-agent", nil)
+	if err != nil {
+		t.Fatalf("WebSocket dial failed: %v", err)
+	}
+	defer victim.Close()
+	time.Sleep(50 * time.Millisecond)
+	if server.wsHub.IsConnected("pm-agent") {
+		t.Error("expected an unauthenticated socket not to count as connected")
+	}
+}
+
+func TestHandleWebSocket_ClaimedIdentityMismatch(t *testing.T) {
+	server := NewServer(nil, nil)
+	server.SetAuthTokens(map[string]string{"laptop-token": "laptop-agent"})
+
+	header := http.Header{"Authorization": {"Bearer laptop-token"}}
+	_, resp, err := websocket.DefaultDialer.Dial(wsURL(t, server)+"?agent_id=pm-agent", header)
+	if err == nil {
+		t.Fatal("expected the upgrade to be rejected")
+	}
+	if resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected 403, got %v", resp)
+	}
+}
+
+func TestWSHub_Call_Disconnect(t *testing.T) {
+	server := NewServer(nil, nil)
+	conn := dialWS(t, server, "laptop-agent")
+
+	// The agent drops the connection instead of answering.
+	go answerNext(conn, func(req *JSONRPCRequest) interface{} {
+		conn.Close()
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_, err := server.wsHub.Call(ctx, "laptop-agent", "agent.echo", nil)
+	if err == nil || errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected connection closed error, got %v", err)
+	}
+}
+
+func TestRPCQuery_ReverseChannel(t *testing.T) {
+	registry := identity.NewAgentRegistry()
+	// No endpoint: the agent is only reachable through its WebSocket.
+	registry.Register(&aoi.AgentIdentity{ID: "laptop-agent", Role: aoi.RoleEngineer})
+	server := NewServer(registry, nil)
+	server.SetSecretary(secretary.NewSecretary(&aoi.AgentIdentity{ID: "pm-agent", Role: aoi.RolePM}))
+	conn := dialWS(t, server, "laptop-agent")
+
+	go answerNext(conn, func(req *JSONRPCRequest) interface{} {
+		if req.Method != "aoi.query" {
+			return nil
+		}
+		return map[string]interface{}{
+			"jsonrpc": "2.0",
+			"id":      req.ID,
+			"result":  secretary.QueryResponse{Answer: "answered over the socket"},
+		}
+	})
+
+	body := rpcRequest("aoi.query", map[string]string{
+		"query":      "How is it going?",
+		"from_agent": "pm-agent",
+		"to_agent":   "laptop-agent",
+	})
+	w := httptest.NewRecorder()
+	server.handleJSONRPC(w, httptest.NewRequest("POST", "/api/v1/rpc", body))
+
+	resp := decodeRPC(t, w)
+	if resp.Error != nil {
+		t.Fatalf("unexpected RPC error: %+v", resp.Error)
+	}
+	var result secretary.QueryResponse
+	json.Unmarshal(resp.Result, &result)
+	if result.Answer != "answered over the socket" {
+		t.Errorf("unexpected answer: %q", result.Answer)
+	}
+}
@@ -0,0 +1,168 @@
+// Package redact removes secrets and personal data from text before it leaves
+// the agent, replacing each match with a placeholder naming what was removed
+package redact
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Built-in detector names
+const (
+	PrivateKey = "private_key"
+	APIKey     = "api_key"
+	Token      = "token"
+	EnvSecret  = "env_secret"
+	Email      = "email"
+)
+
+// secretGroup names the part of a match to replace; without it, or when it
+// did not take part in the match, the whole match is replaced
+const secretGroup = "secret"
+
+// Detector finds one kind of sensitive data
+type Detector struct {
+	Name    string
+	Pattern *regexp.Regexp
+}
+
+// builtins run in this order, so that a key found by a specific detector is
+// not counted again by a generic one: placeholders match no detector
+var builtins = []Detector{
+	{PrivateKey, regexp.MustCompile(`(?s)-----BEGIN [A-Z0-9 ]*PRIVATE KEY-----.*?(?:-----END [A-Z0-9 ]*PRIVATE KEY-----|\z)`)},
+	{APIKey, regexp.MustCompile(`\b(?:sk-(?:proj-|ant-)?[A-Za-z0-9_-]{20,}|sk_(?:live|test)_[A-Za-z0-9]{16,}|AKIA[0-9A-Z]{16}|AIza[0-9A-Za-z_-]{35}|gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,}|xox[abposr]-[A-Za-z0-9-]{10,}|glpat-[A-Za-z0-9_-]{20,})`)},
+	{Token, regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}|(?i:\bbearer[ \t]+)(?P<secret>[A-Za-z0-9._~+/=-]{16,})`)},
+	{EnvSecret, regexp.MustCompile(`\b[A-Za-z0-9_]*(?:SECRET|TOKEN|PASSWORD|PASSWD|API_?KEY|ACCESS_?KEY|PRIVATE_?KEY|CREDENTIALS?|secret|token|password|passwd|api_?key|access_?key)[A-Za-z0-9_]*["']?[ \t]*[=:][ \t]*["']?(?P<secret>[^\s"'#,;\[]{4,})`)},
+	{Email, regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}\b`)},
+}
+
+// Builtins returns the built-in detectors: private keys, API keys of common
+// services, bearer and JSON web tokens, secret assignments as in .env files,
+// and email addresses
+func Builtins() []Detector {
+	return append([]Detector(nil), builtins...)
+}
+
+// Builtin returns the built-in detector with the given name
+func Builtin(name string) (Detector, bool) {
+	for _, d := range builtins {
+		if d.Name == name {
+			return d, true
+		}
+	}
+	return Detector{}, false
+}
+
+// BuiltinNames returns the names of the built-in detectors
+func BuiltinNames() []string {
+	names := make([]string, len(builtins))
+	for i, d := range builtins {
+		names[i] = d.Name
+	}
+	return names
+}
+
+// NewDetector compiles a custom detector. A group named "secret" in pattern
+// limits the replacement to that group, e.g. to keep a key's name.
+func NewDetector(name, pattern string) (Detector, error) {
+	if name == "" {
+		return Detector{}, fmt.Errorf("detector name is required")
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return Detector{}, fmt.Errorf("detector %q: %w", name, err)
+	}
+	return Detector{Name: name, Pattern: re}, nil
+}
+
+// Placeholder is what a match of the named detector is replaced with
+func Placeholder(name string) string {
+	return "[REDACTED:" + name + "]"
+}
+
+// Counts is the number of matches per detector name
+type Counts map[string]int
+
+// Total returns the number of matches of all detectors
+func (c Counts) Total() int {
+	total := 0
+	for _, n := range c {
+		total += n
+	}
+	return total
+}
+
+// Add adds other's matches to c
+func (c Counts) Add(other Counts) {
+	for name, n := range other {
+		c[name] += n
+	}
+}
+
+// String lists the counts by detector name, e.g. "api_key=1 email=2"
+func (c Counts) String() string {
+	parts := make([]string, 0, len(c))
+	for name, n := range c {
+		parts = append(parts, fmt.Sprintf("%s=%d", name, n))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, " ")
+}
+
+// Redactor runs detectors over text in order
+type Redactor struct {
+	detectors []Detector
+}
+
+// New creates a redactor running detectors in order
+func New(detectors []Detector) *Redactor {
+	return &Redactor{detectors: detectors}
+}
+
+// Detectors returns the names of the redactor's detectors
+func (r *Redactor) Detectors() []string {
+	if r == nil {
+		return nil
+	}
+	names := make([]string, len(r.detectors))
+	for i, d := range r.detectors {
+		names[i] = d.Name
+	}
+	return names
+}
+
+// Redact replaces every match in text with its detector's placeholder and
+// counts the matches. A nil redactor returns text unchanged.
+func (r *Redactor) Redact(text string) (string, Counts) {
+	counts := Counts{}
+	if r == nil {
+		return text, counts
+	}
+	for _, d := range r.detectors {
+		matches := d.Pattern.FindAllStringSubmatchIndex(text, -1)
+		if len(matches) == 0 {
+			continue
+		}
+		group := d.Pattern.SubexpIndex(secretGroup)
+		var b strings.Builder
+		last := 0
+		for _, m := range matches {
+			start, end := m[0], m[1]
+			if group > 0 && m[2*group] >= 0 {
+				start, end = m[2*group], m[2*group+1]
+			}
+			if start == end {
+				continue
+			}
+			b.WriteString(text[last:start])
+			b.WriteString(Placeholder(d.Name))
+			last = end
+			counts[d.Name]++
+		}
+		b.WriteString(text[last:])
+		text = b.String()
+	}
+	return text, counts
+}
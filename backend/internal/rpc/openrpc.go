@@ -0,0 +1,117 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// OpenRPCVersion is the OpenRPC specification version of generated documents
+const OpenRPCVersion = "1.2.6"
+
+// DiscoverMethod is the OpenRPC service discovery method name
+const DiscoverMethod = "rpc.discover"
+
+// Schema is a JSON Schema object
+type Schema map[string]interface{}
+
+// Type returns a schema for a single JSON type ("string", "object", ...)
+func Type(jsonType string) Schema {
+	return Schema{"type": jsonType}
+}
+
+// ArrayOf returns a schema for an array of items
+func ArrayOf(items Schema) Schema {
+	return Schema{"type": "array", "items": items}
+}
+
+// ContentDescriptor describes a param or result (OpenRPC Content Descriptor Object)
+type ContentDescriptor struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+	Schema      Schema `json:"schema"`
+}
+
+// Param describes an optional member of the params object
+func Param(name, jsonType, description string) ContentDescriptor {
+	return ContentDescriptor{Name: name, Description: description, Schema: Type(jsonType)}
+}
+
+// RequiredParam describes a required member of the params object
+func RequiredParam(name, jsonType, description string) ContentDescriptor {
+	return ContentDescriptor{Name: name, Description: description, Required: true, Schema: Type(jsonType)}
+}
+
+// Result describes a method result
+func Result(name string, schema Schema) *ContentDescriptor {
+	return &ContentDescriptor{Name: name, Schema: schema}
+}
+
+// Info is the OpenRPC Info Object
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// MethodObject is the OpenRPC Method Object, extended with the ACL permission
+// required to call the method.
+type MethodObject struct {
+	Name           string              `json:"name"`
+	Summary        string              `json:"summary,omitempty"`
+	ParamStructure string              `json:"paramStructure"`
+	Params         []ContentDescriptor `json:"params"`
+	Result         ContentDescriptor   `json:"result"`
+	Resource       string              `json:"x-aoi-resource,omitempty"`
+	Action         string              `json:"x-aoi-action,omitempty"`
+}
+
+// Document is an OpenRPC document describing an agent's methods
+type Document struct {
+	OpenRPC string         `json:"openrpc"`
+	Info    Info           `json:"info"`
+	Methods []MethodObject `json:"methods"`
+}
+
+// OpenRPC builds an OpenRPC document for the registered methods
+func (r *Registry) OpenRPC(info Info) *Document {
+	methods := r.Methods()
+	doc := &Document{
+		OpenRPC: OpenRPCVersion,
+		Info:    info,
+		Methods: make([]MethodObject, 0, len(methods)),
+	}
+
+	for _, m := range methods {
+		obj := MethodObject{
+			Name:           m.Name,
+			Summary:        m.Summary,
+			ParamStructure: "by-name",
+			Params:         m.Params,
+			Result:         ContentDescriptor{Name: "result", Schema: Schema{}},
+			Resource:       m.Resource,
+			Action:         m.Action,
+		}
+		if obj.Params == nil {
+			obj.Params = []ContentDescriptor{}
+		}
+		if m.Result != nil {
+			obj.Result = *m.Result
+		}
+		doc.Methods = append(doc.Methods, obj)
+	}
+	return doc
+}
+
+// RegisterDiscover registers rpc.discover, which returns the OpenRPC document.
+// info is called on every request so the document reflects the current agent.
+func (r *Registry) RegisterDiscover(info func() Info) error {
+	return r.Register(Method{
+		Name:    DiscoverMethod,
+		Summary: "Returns the OpenRPC document describing this agent's methods",
+		Result:  Result("OpenRPC Schema", Schema{"$ref": "https://raw.githubusercontent.com/open-rpc/meta-schema/master/schema.json"}),
+		Handler: func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+			return r.OpenRPC(info()), nil
+		},
+	})
+}
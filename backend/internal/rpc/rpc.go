@@ -0,0 +1,158 @@
+// Package rpc provides the JSON-RPC method registry shared by AOI subsystems.
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// JSON-RPC 2.0 error codes
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+	CodeACLDenied      = -32000
+	CodeAgentNotFound  = -32001
+	// CodeUnauthenticated rejects a request whose signature is missing or invalid
+	CodeUnauthenticated = -32002
+)
+
+// ACL actions a method can require on its resource
+const (
+	ActionRead    = "read"
+	ActionWrite   = "write"
+	ActionExecute = "execute"
+	ActionAdmin   = "admin"
+)
+
+// Error is a JSON-RPC 2.0 error. Handlers return it to control the error code;
+// any other error is reported as an internal error.
+type Error struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// Error implements the error interface so remote JSON-RPC errors can be returned as-is.
+func (e *Error) Error() string {
+	return fmt.Sprintf("JSON-RPC error %d: %s", e.Code, e.Message)
+}
+
+// NewError creates a JSON-RPC error
+func NewError(code int, message string, data interface{}) *Error {
+	return &Error{Code: code, Message: message, Data: data}
+}
+
+// InvalidParams creates an Invalid params error carrying detail as data
+func InvalidParams(detail string) *Error {
+	return &Error{Code: CodeInvalidParams, Message: "Invalid params", Data: detail}
+}
+
+// Handler executes a method with its raw params
+type Handler func(ctx context.Context, params json.RawMessage) (interface{}, error)
+
+// Method describes a registered JSON-RPC method
+type Method struct {
+	// Name is the full method name, e.g. "aoi.context.history"
+	Name    string
+	Summary string
+	// Params describes the members of the by-name params object
+	Params []ContentDescriptor
+	Result *ContentDescriptor
+	// Resource and Action are the ACL permission required to call the method
+	Resource string
+	Action   string
+	Handler  Handler
+}
+
+// Registry holds the methods served by an agent
+type Registry struct {
+	methods map[string]*Method
+	mu      sync.RWMutex
+}
+
+// NewRegistry creates an empty method registry
+func NewRegistry() *Registry {
+	return &Registry{
+		methods: make(map[string]*Method),
+	}
+}
+
+// Register adds a method. Registering a name twice is an error.
+func (r *Registry) Register(m Method) error {
+	if m.Name == "" {
+		return fmt.Errorf("method name is required")
+	}
+	if m.Handler == nil {
+		return fmt.Errorf("method %s has no handler", m.Name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.methods[m.Name]; exists {
+		return fmt.Errorf("method already registered: %s", m.Name)
+	}
+	r.methods[m.Name] = &m
+	return nil
+}
+
+// MustRegister registers methods and panics on error; intended for wiring at startup
+func (r *Registry) MustRegister(methods ...Method) {
+	for _, m := range methods {
+		if err := r.Register(m); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// Unregister removes a method
+func (r *Registry) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.methods, name)
+}
+
+// Lookup returns the method registered under name
+func (r *Registry) Lookup(name string) (*Method, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	m, ok := r.methods[name]
+	return m, ok
+}
+
+// Methods returns all registered methods sorted by name
+func (r *Registry) Methods() []*Method {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	methods := make([]*Method, 0, len(r.methods))
+	for _, m := range r.methods {
+		methods = append(methods, m)
+	}
+	sort.Slice(methods, func(i, j int) bool {
+		return methods[i].Name < methods[j].Name
+	})
+	return methods
+}
+
+// Call invokes a registered method. Unknown methods yield a Method not found error.
+func (r *Registry) Call(ctx context.Context, name string, params json.RawMessage) (interface{}, error) {
+	m, ok := r.Lookup(name)
+	if !ok {
+		return nil, NewError(CodeMethodNotFound, "Method not found", name)
+	}
+	return m.Handler(ctx, params)
+}
+
+// WithoutContext adapts a handler that does not need a context
+func WithoutContext(fn func(params json.RawMessage) (interface{}, error)) Handler {
+	return func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		return fn(params)
+	}
+}
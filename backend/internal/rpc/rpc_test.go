@@ -0,0 +1,155 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func echoHandler(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	return string(params), nil
+}
+
+func TestRegistry_RegisterAndCall(t *testing.T) {
+	reg := NewRegistry()
+	if err := reg.Register(Method{Name: "test.echo", Handler: echoHandler}); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	result, err := reg.Call(context.Background(), "test.echo", json.RawMessage(`{"a":1}`))
+	if err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	if result != `{"a":1}` {
+		t.Errorf("Expected params echoed, got %v", result)
+	}
+}
+
+func TestRegistry_RegisterErrors(t *testing.T) {
+	reg := NewRegistry()
+
+	if err := reg.Register(Method{Handler: echoHandler}); err == nil {
+		t.Error("Expected error for missing name")
+	}
+	if err := reg.Register(Method{Name: "test.nohandler"}); err == nil {
+		t.Error("Expected error for missing handler")
+	}
+
+	reg.MustRegister(Method{Name: "test.echo", Handler: echoHandler})
+	if err := reg.Register(Method{Name: "test.echo", Handler: echoHandler}); err == nil {
+		t.Error("Expected error for duplicate method")
+	}
+}
+
+func TestRegistry_CallUnknownMethod(t *testing.T) {
+	reg := NewRegistry()
+
+	_, err := reg.Call(context.Background(), "test.missing", nil)
+	var rpcErr *Error
+	if !errors.As(err, &rpcErr) {
+		t.Fatalf("Expected *Error, got %v", err)
+	}
+	if rpcErr.Code != CodeMethodNotFound {
+		t.Errorf("Expected code %d, got %d", CodeMethodNotFound, rpcErr.Code)
+	}
+}
+
+func TestRegistry_Unregister(t *testing.T) {
+	reg := NewRegistry()
+	reg.MustRegister(Method{Name: "test.echo", Handler: echoHandler})
+
+	reg.Unregister("test.echo")
+
+	if _, ok := reg.Lookup("test.echo"); ok {
+		t.Error("Expected method to be removed")
+	}
+}
+
+func TestRegistry_MethodsSorted(t *testing.T) {
+	reg := NewRegistry()
+	reg.MustRegister(
+		Method{Name: "b.second", Handler: echoHandler},
+		Method{Name: "c.third", Handler: echoHandler},
+		Method{Name: "a.first", Handler: echoHandler},
+	)
+
+	methods := reg.Methods()
+	if len(methods) != 3 {
+		t.Fatalf("Expected 3 methods, got %d", len(methods))
+	}
+	for i, name := range []string{"a.first", "b.second", "c.third"} {
+		if methods[i].Name != name {
+			t.Errorf("Expected methods[%d] = %s, got %s", i, name, methods[i].Name)
+		}
+	}
+}
+
+func TestRegistry_OpenRPC(t *testing.T) {
+	reg := NewRegistry()
+	reg.MustRegister(Method{
+		Name:     "test.get",
+		Summary:  "Get a thing",
+		Params:   []ContentDescriptor{RequiredParam("id", "string", "Thing ID")},
+		Result:   Result("thing", Type("object")),
+		Resource: "things/get",
+		Action:   ActionRead,
+		Handler:  echoHandler,
+	})
+	if err := reg.RegisterDiscover(func() Info { return Info{Title: "test", Version: "1.0.0"} }); err != nil {
+		t.Fatalf("RegisterDiscover failed: %v", err)
+	}
+
+	result, err := reg.Call(context.Background(), DiscoverMethod, nil)
+	if err != nil {
+		t.Fatalf("rpc.discover failed: %v", err)
+	}
+	doc, ok := result.(*Document)
+	if !ok {
+		t.Fatalf("Expected *Document, got %T", result)
+	}
+
+	if doc.OpenRPC != OpenRPCVersion {
+		t.Errorf("Expected openrpc %s, got %s", OpenRPCVersion, doc.OpenRPC)
+	}
+	if doc.Info.Title != "test" {
+		t.Errorf("Expected title 'test', got '%s'", doc.Info.Title)
+	}
+	if len(doc.Methods) != 2 {
+		t.Fatalf("Expected 2 methods, got %d", len(doc.Methods))
+	}
+
+	// rpc.discover sorts after test.get
+	get := doc.Methods[1]
+	if get.Name != "test.get" {
+		t.Fatalf("Expected test.get, got %s", get.Name)
+	}
+	if get.ParamStructure != "by-name" {
+		t.Errorf("Expected by-name params, got %s", get.ParamStructure)
+	}
+	if len(get.Params) != 1 || !get.Params[0].Required {
+		t.Errorf("Expected one required param, got %+v", get.Params)
+	}
+	if get.Result.Name != "thing" {
+		t.Errorf("Expected result 'thing', got '%s'", get.Result.Name)
+	}
+	if get.Resource != "things/get" || get.Action != ActionRead {
+		t.Errorf("Expected permission things/get:read, got %s:%s", get.Resource, get.Action)
+	}
+
+	// Extension fields are serialized with the x-aoi- prefix
+	data, err := json.Marshal(get)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var raw map[string]interface{}
+	json.Unmarshal(data, &raw)
+	if raw["x-aoi-resource"] != "things/get" || raw["x-aoi-action"] != "read" {
+		t.Errorf("Expected x-aoi-* extensions, got %s", data)
+	}
+
+	discover := doc.Methods[0]
+	if len(discover.Params) != 0 || discover.Params == nil {
+		t.Errorf("Expected empty params array for rpc.discover, got %v", discover.Params)
+	}
+}
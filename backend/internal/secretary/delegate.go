@@ -0,0 +1,246 @@
+package secretary
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+// Delegation modes
+const (
+	// DelegateExplicit delegates only queries that ask for it with "delegate"
+	DelegateExplicit = "explicit"
+	// DelegateFallback also delegates queries the context has no answer for
+	DelegateFallback = "fallback"
+)
+
+// Delegation defaults
+const (
+	// DefaultDelegationTimeout bounds how long a worker may take to answer
+	DefaultDelegationTimeout = 2 * time.Minute
+	// DelegatedConfidence is the confidence of a worker's answer; the worker
+	// looked into the workspace itself rather than the secretary's context
+	DelegatedConfidence = 0.9
+	// maxDelegatedQuery, in bytes, keeps a delegation prompt within one
+	// terminal command
+	maxDelegatedQuery = 3000
+)
+
+// ResourceDelegate is the ACL resource a caller needs execute on to have its
+// queries delegated to the worker AI
+const ResourceDelegate = "secretary/delegate"
+
+// ErrNoWorker is returned for a query asking to be delegated when the
+// secretary has no worker AI
+var ErrNoWorker = errors.New("no worker AI to delegate to")
+
+// WorkerTask is a query handed to a worker AI
+type WorkerTask struct {
+	// ID marks the worker's answer, see AnswerMarkers
+	ID     string
+	Prompt string
+}
+
+// Worker is the local worker AI, such as Claude Code or Cursor running in
+// tmux, that a secretary delegates queries to
+type Worker interface {
+	// Name identifies the worker in sources and audit entries, e.g. "h2a:eng-agent"
+	Name() string
+	// Delegate hands the task to the worker and returns its answer once it
+	// has finished, or an error when ctx ends first
+	Delegate(ctx context.Context, task WorkerTask) (string, error)
+}
+
+// Delegation records one exchange with a worker
+type Delegation struct {
+	ID       string        `json:"id"`
+	Worker   string        `json:"worker"`
+	Query    QueryRequest  `json:"query"`
+	Prompt   string        `json:"prompt"`
+	Answer   string        `json:"answer,omitempty"`
+	Started  time.Time     `json:"started"`
+	Duration time.Duration `json:"duration"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// SetWorker sets the worker AI queries are delegated to, and when: mode is
+// DelegateExplicit or DelegateFallback. A timeout of 0 uses
+// DefaultDelegationTimeout; a nil worker turns delegation off.
+func (s *Secretary) SetWorker(w Worker, mode string, timeout time.Duration) {
+	if timeout <= 0 {
+		timeout = DefaultDelegationTimeout
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.worker, s.delegateMode, s.delegateTimeout = w, mode, timeout
+}
+
+// OnDelegate registers a callback invoked with every exchange with the
+// worker, successful or not, e.g. to audit it
+func (s *Secretary) OnDelegate(fn func(Delegation)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onDelegate = append(s.onDelegate, fn)
+}
+
+// Delegate hands req to the worker AI, waits for its answer and returns it
+// as the query response
+func (s *Secretary) Delegate(ctx context.Context, req QueryRequest) (*QueryResponse, error) {
+	s.mu.RLock()
+	worker, timeout, callbacks := s.worker, s.delegateTimeout, s.onDelegate
+	s.mu.RUnlock()
+	if worker == nil {
+		return nil, ErrNoWorker
+	}
+
+	d := Delegation{ID: uuid.New().String()[:8], Worker: worker.Name(), Query: req, Started: time.Now()}
+	d.Prompt = delegationPrompt(d.ID, req)
+	log.Printf("[%s] Delegating query from %s to %s (%s)", s.Identity.Role, req.FromAgent, d.Worker, d.ID)
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	answer, err := worker.Delegate(ctx, WorkerTask{ID: d.ID, Prompt: d.Prompt})
+	d.Duration = time.Since(d.Started)
+	if err == nil && strings.TrimSpace(answer) == "" {
+		err = errors.New("worker returned an empty answer")
+	}
+	if err != nil {
+		d.Error = err.Error()
+	} else {
+		d.Answer = strings.TrimSpace(answer)
+	}
+	for _, fn := range callbacks {
+		fn(d)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("delegation to %s failed: %w", d.Worker, err)
+	}
+
+	metadata := make(map[string]string, len(req.Metadata)+2)
+	for k, v := range req.Metadata {
+		metadata[k] = v
+	}
+	metadata["delegated_to"] = d.Worker
+	metadata["delegation_id"] = d.ID
+	return &QueryResponse{
+		Answer:     d.Answer,
+		Confidence: DelegatedConfidence,
+		Sources:    []string{d.Worker},
+		Metadata:   metadata,
+	}, nil
+}
+
+type noDelegationKey struct{}
+
+// WithoutDelegation returns ctx for a query the worker must not answer, even
+// in fallback mode
+func WithoutDelegation(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noDelegationKey{}, true)
+}
+
+// delegationAllowed reports whether ctx allows falling back to the worker
+func delegationAllowed(ctx context.Context) bool {
+	denied, _ := ctx.Value(noDelegationKey{}).(bool)
+	return !denied
+}
+
+// delegatesUnanswered reports whether queries the context has no answer for
+// go to the worker
+func (s *Secretary) delegatesUnanswered() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.worker != nil && s.delegateMode == DelegateFallback
+}
+
+// AnswerMarkers returns the lines a worker prints before and after its
+// answer to the task with the given ID
+func AnswerMarkers(id string) (begin, end string) {
+	return "AOI-ANSWER-BEGIN " + id, "AOI-ANSWER-END " + id
+}
+
+// delegationPrompt asks the worker to answer req between the answer markers.
+// It is a single line so that it can be typed into a terminal as one command.
+func delegationPrompt(id string, req QueryRequest) string {
+	begin, end := AnswerMarkers(id)
+	query := terminalSafe(req.Query)
+	if len(query) > maxDelegatedQuery {
+		query = strings.ToValidUTF8(query[:maxDelegatedQuery], "") + "…"
+	}
+
+	var b strings.Builder
+	b.WriteString("[AOI] ")
+	if from := terminalSafe(req.FromAgent); from != "" {
+		fmt.Fprintf(&b, "Agent %s asks: ", from)
+	}
+	b.WriteString(query)
+	if scope := terminalSafe(req.ContextScope); scope != "" {
+		fmt.Fprintf(&b, " (context: %s)", scope)
+	}
+	fmt.Fprintf(&b, " -- Look into this in the current workspace and answer concisely for the asking agent."+
+		" Print a line with only %q, then your answer, then a line with only %q.", begin, end)
+	return b.String()
+}
+
+// terminalSafe folds s onto one line and escapes the control characters left,
+// so that text typed into the worker's terminal cannot end the command early
+// or send keys of its own
+func terminalSafe(s string) string {
+	var b strings.Builder
+	for _, r := range strings.Join(strings.Fields(s), " ") {
+		if unicode.IsControl(r) {
+			quoted := strconv.QuoteRuneToASCII(r)
+			b.WriteString(quoted[1 : len(quoted)-1])
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// ExtractAnswer finds the answer to the task with the given ID in a worker's
+// output: the lines between the last begin marker and the end marker after
+// it. Markers count only on a line of their own, ignoring decoration such as
+// bullets and borders, so the marker text in the echoed prompt is skipped.
+func ExtractAnswer(output, id string) (string, bool) {
+	begin, end := AnswerMarkers(id)
+	lines := strings.Split(output, "\n")
+	start := -1
+	for i, line := range lines {
+		if markerLine(line) == begin {
+			start = i
+		}
+	}
+	if start < 0 {
+		return "", false
+	}
+	for i := start + 1; i < len(lines); i++ {
+		if markerLine(lines[i]) == end {
+			answer := make([]string, 0, i-start-1)
+			for _, line := range lines[start+1 : i] {
+				answer = append(answer, strings.TrimFunc(line, isBorder))
+			}
+			return strings.TrimSpace(strings.Join(answer, "\n")), true
+		}
+	}
+	return "", false
+}
+
+// markerLine strips the decoration around a line a marker may be on
+func markerLine(line string) string {
+	return strings.TrimFunc(line, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// isBorder reports whether r is white space or a box-drawing character that
+// terminal UIs frame their output with
+func isBorder(r rune) bool {
+	return unicode.IsSpace(r) || (r >= '\u2500' && r <= '\u257f')
+}
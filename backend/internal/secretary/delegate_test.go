@@ -0,0 +1,285 @@
+package secretary
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode"
+
+	"github.com/aoi-protocol/aoi/internal/h2a"
+)
+
+// fakeWorker answers every task with reply, or fails with err
+type fakeWorker struct {
+	reply string
+	err   error
+	tasks []WorkerTask
+}
+
+func (w *fakeWorker) Name() string { return "fake" }
+
+func (w *fakeWorker) Delegate(ctx context.Context, task WorkerTask) (string, error) {
+	w.tasks = append(w.tasks, task)
+	return w.reply, w.err
+}
+
+func TestExtractAnswer(t *testing.T) {
+	prompt := delegationPrompt("1a2b3c4d", QueryRequest{Query: "Check the API spec", FromAgent: "pm-1"})
+	tests := []struct {
+		name   string
+		output string
+		want   string
+		found  bool
+	}{
+		{"echoed prompt only", "> " + prompt + "\n", "", false},
+		{"answer in progress", "> " + prompt + "\nAOI-ANSWER-BEGIN 1a2b3c4d\nThe spec is", "", false},
+		{"answer", "> " + prompt + "\n⏺ AOI-ANSWER-BEGIN 1a2b3c4d\n  The spec is in docs/api.md.\n  It is up to date.\n  AOI-ANSWER-END 1a2b3c4d\n> ",
+			"The spec is in docs/api.md.\nIt is up to date.", true},
+		{"bordered", "│ AOI-ANSWER-BEGIN 1a2b3c4d │\n│ Yes │\n│ AOI-ANSWER-END 1a2b3c4d │", "Yes", true},
+		{"other task", "AOI-ANSWER-BEGIN ffffffff\nNo\nAOI-ANSWER-END ffffffff", "", false},
+	}
+	for _, tt := range tests {
+		answer, found := ExtractAnswer(tt.output, "1a2b3c4d")
+		if answer != tt.want || found != tt.found {
+			t.Errorf("%s: got %q, %v; want %q, %v", tt.name, answer, found, tt.want, tt.found)
+		}
+	}
+}
+
+func TestDelegationPrompt(t *testing.T) {
+	prompt := delegationPrompt("1a2b3c4d", QueryRequest{Query: "Check\nthe API spec", FromAgent: "pm-1", ContextScope: "project:api"})
+	if strings.Contains(prompt, "\n") {
+		t.Errorf("Expected a single-line prompt, got %q", prompt)
+	}
+	for _, want := range []string{"pm-1", "Check the API spec", "project:api", "AOI-ANSWER-BEGIN 1a2b3c4d", "AOI-ANSWER-END 1a2b3c4d"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("Expected the prompt to contain %q, got %q", want, prompt)
+		}
+	}
+
+	// Control characters cannot end the command or send keys of their own
+	prompt = delegationPrompt("1a2b3c4d", QueryRequest{Query: "Check\r\x1b[2J the spec\x03", FromAgent: "pm\n-1", ContextScope: "api\x04"})
+	for _, r := range prompt {
+		if unicode.IsControl(r) {
+			t.Fatalf("Expected control characters to be escaped, got %q", prompt)
+		}
+	}
+	for _, want := range []string{`\x1b[2J the spec\x03`, "pm -1", `api\x04`} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("Expected the prompt to contain %q, got %q", want, prompt)
+		}
+	}
+
+	long := delegationPrompt("1a2b3c4d", QueryRequest{Query: strings.Repeat("あ", 2000)})
+	if err := h2a.ValidateCommand(long); err != nil {
+		t.Errorf("Expected a long query to fit in one command: %v", err)
+	}
+}
+
+func TestSecretary_Delegate(t *testing.T) {
+	sec, _ := newGroundedSecretary(t)
+	worker := &fakeWorker{reply: "The API spec matches the handlers.\n"}
+	sec.SetWorker(worker, DelegateExplicit, time.Second)
+	var delegations []Delegation
+	sec.OnDelegate(func(d Delegation) { delegations = append(delegations, d) })
+
+	resp, err := sec.HandleQuery(QueryRequest{Query: "Check the API spec", FromAgent: "pm-1", Delegate: true,
+		Metadata: map[string]string{"ticket": "API-7"}})
+	if err != nil {
+		t.Fatalf("HandleQuery: %v", err)
+	}
+	if resp.Answer != "The API spec matches the handlers." || resp.Confidence != DelegatedConfidence {
+		t.Errorf("Expected the worker's answer, got %+v", resp)
+	}
+	if resp.Metadata["delegated_to"] != "fake" || resp.Metadata["ticket"] != "API-7" || len(resp.Sources) != 1 || resp.Sources[0] != "fake" {
+		t.Errorf("Expected the worker as source and in metadata, got %+v", resp)
+	}
+	if len(worker.tasks) != 1 || !strings.Contains(worker.tasks[0].Prompt, worker.tasks[0].ID) {
+		t.Fatalf("Expected one task marked with its ID, got %+v", worker.tasks)
+	}
+	if len(delegations) != 1 || delegations[0].Answer != resp.Answer || delegations[0].Prompt != worker.tasks[0].Prompt {
+		t.Errorf("Expected the exchange to be reported, got %+v", delegations)
+	}
+
+	// Explicit mode leaves unanswerable queries to the context
+	resp, err = sec.HandleQuery(QueryRequest{Query: "Who owns the roadmap?", FromAgent: "pm-1"})
+	if err != nil || !strings.HasPrefix(resp.Answer, "No context found") || len(worker.tasks) != 1 {
+		t.Errorf("Expected no delegation in explicit mode, got %+v, %v", resp, err)
+	}
+}
+
+func TestSecretary_DelegateFallback(t *testing.T) {
+	sec, _ := newGroundedSecretary(t)
+	worker := &fakeWorker{reply: "Alice owns the roadmap."}
+	sec.SetWorker(worker, DelegateFallback, time.Second)
+
+	resp, err := sec.HandleQuery(QueryRequest{Query: "Who owns the roadmap?", FromAgent: "pm-1"})
+	if err != nil || resp.Answer != "Alice owns the roadmap." {
+		t.Errorf("Expected the unanswerable query to be delegated, got %+v, %v", resp, err)
+	}
+	resp, err = sec.HandleQuery(QueryRequest{Query: "Is invoice rounding fixed?", FromAgent: "pm-1"})
+	if err != nil || len(worker.tasks) != 1 || resp.Sources[0] != "e1" {
+		t.Errorf("Expected answerable queries to be answered from context, got %+v, %v", resp, err)
+	}
+
+	// Senders who may not delegate get the context's answer
+	resp, err = sec.HandleQueryContext(WithoutDelegation(context.Background()), QueryRequest{Query: "Who owns the roadmap?", FromAgent: "pm-1"})
+	if err != nil || len(worker.tasks) != 1 || !strings.HasPrefix(resp.Answer, "No context found") {
+		t.Errorf("Expected no delegation without permission, got %+v, %v", resp, err)
+	}
+
+	// A failing worker leaves the context's answer
+	worker.err = errors.New("session gone")
+	resp, err = sec.HandleQuery(QueryRequest{Query: "Who owns the roadmap?", FromAgent: "pm-1"})
+	if err != nil || !strings.HasPrefix(resp.Answer, "No context found") {
+		t.Errorf("Expected the context answer when the worker fails, got %+v, %v", resp, err)
+	}
+}
+
+func TestSecretary_DelegateErrors(t *testing.T) {
+	sec, _ := newGroundedSecretary(t)
+	if _, err := sec.HandleQuery(QueryRequest{Query: "Check the API spec", Delegate: true}); !errors.Is(err, ErrNoWorker) {
+		t.Errorf("Expected ErrNoWorker, got %v", err)
+	}
+
+	var delegations []Delegation
+	sec.OnDelegate(func(d Delegation) { delegations = append(delegations, d) })
+	sec.SetWorker(&fakeWorker{reply: "  "}, DelegateExplicit, time.Second)
+	if _, err := sec.HandleQuery(QueryRequest{Query: "Check the API spec", Delegate: true}); err == nil {
+		t.Error("Expected an empty answer to fail")
+	}
+	if len(delegations) != 1 || delegations[0].Error == "" {
+		t.Errorf("Expected the failed exchange to be reported, got %+v", delegations)
+	}
+}
+
+// fakeSessions is a tmux session whose worker answers task id on the third capture
+type fakeSessions struct {
+	mu       sync.Mutex
+	id       string
+	sent     string
+	captures int
+}
+
+func (f *fakeSessions) SendCommand(agentID, command string, captureOutput bool) (*h2a.SendResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sent = command
+	return &h2a.SendResult{Status: "sent"}, nil
+}
+
+func (f *fakeSessions) CaptureOutput(agentID string, lines int) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.captures++
+	output := "> " + f.sent + "\n"
+	if f.captures >= 3 {
+		output += "AOI-ANSWER-BEGIN " + f.id + "\nAll endpoints are documented.\nAOI-ANSWER-END " + f.id + "\n"
+	}
+	return output, nil
+}
+
+func TestH2AWorker_Delegate(t *testing.T) {
+	sessions := &fakeSessions{id: "1a2b3c4d"}
+	worker := NewH2AWorker(sessions, "eng-1")
+	worker.SetPollInterval(time.Millisecond)
+	if worker.Name() != "h2a:eng-1" {
+		t.Errorf("Unexpected name %q", worker.Name())
+	}
+
+	prompt := delegationPrompt("1a2b3c4d", QueryRequest{Query: "Check the API spec"})
+	answer, err := worker.Delegate(context.Background(), WorkerTask{ID: "1a2b3c4d", Prompt: prompt})
+	if err != nil {
+		t.Fatalf("Delegate: %v", err)
+	}
+	if answer != "All endpoints are documented." || sessions.captures != 3 {
+		t.Errorf("Expected the answer on the third capture, got %q after %d", answer, sessions.captures)
+	}
+}
+
+func TestH2AWorker_Timeout(t *testing.T) {
+	worker := NewH2AWorker(&fakeSessions{id: "1a2b3c4d", captures: -1000}, "eng-1")
+	worker.SetPollInterval(time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := worker.Delegate(ctx, WorkerTask{ID: "1a2b3c4d", Prompt: "ping"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected a deadline error, got %v", err)
+	}
+}
+
+// queueSessions is a tmux session whose worker answers the last task typed
+// into it, noting when a task is typed before the previous one was answered
+type queueSessions struct {
+	mu       sync.Mutex
+	pending  string
+	answered []string
+	overlap  bool
+}
+
+func (q *queueSessions) SendCommand(agentID, command string, captureOutput bool) (*h2a.SendResult, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.pending != "" {
+		q.overlap = true
+	}
+	q.pending = command
+	return &h2a.SendResult{Status: "sent"}, nil
+}
+
+func (q *queueSessions) CaptureOutput(agentID string, lines int) (string, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.pending == "" {
+		return "", nil
+	}
+	id := q.pending
+	q.pending = ""
+	q.answered = append(q.answered, id)
+	return "AOI-ANSWER-BEGIN " + id + "\nanswer to " + id + "\nAOI-ANSWER-END " + id + "\n", nil
+}
+
+func TestH2AWorker_OneTaskAtATime(t *testing.T) {
+	sessions := &queueSessions{}
+	worker := NewH2AWorker(sessions, "eng-1")
+	worker.SetPollInterval(time.Millisecond)
+
+	ids := []string{"1a2b3c4d", "2b3c4d5e", "3c4d5e6f", "4d5e6f70"}
+	errs := make(chan error, len(ids))
+	for _, id := range ids {
+		go func(id string) {
+			answer, err := worker.Delegate(context.Background(), WorkerTask{ID: id, Prompt: id})
+			if err == nil && answer != "answer to "+id {
+				err = errors.New("got " + answer + " for " + id)
+			}
+			errs <- err
+		}(id)
+	}
+	for range ids {
+		if err := <-errs; err != nil {
+			t.Errorf("Delegate: %v", err)
+		}
+	}
+
+	sessions.mu.Lock()
+	defer sessions.mu.Unlock()
+	if sessions.overlap || len(sessions.answered) != len(ids) {
+		t.Errorf("Expected each task to be answered before the next was sent, got %v (overlap %v)", sessions.answered, sessions.overlap)
+	}
+}
+
+func TestH2AWorker_BusyTimeout(t *testing.T) {
+	worker := NewH2AWorker(&fakeSessions{id: "1a2b3c4d", captures: -1000}, "eng-1")
+	worker.SetPollInterval(time.Millisecond)
+	go worker.Delegate(context.Background(), WorkerTask{ID: "1a2b3c4d", Prompt: "ping"})
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := worker.Delegate(ctx, WorkerTask{ID: "2b3c4d5e", Prompt: "ping"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected a deadline error while the session is busy, got %v", err)
+	}
+}
@@ -0,0 +1,235 @@
+package secretary
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	aoicontext "github.com/aoi-protocol/aoi/internal/context"
+)
+
+// Retrieval limits
+const (
+	// MaxContextEntries is how many entries an answer is built from
+	MaxContextEntries = 5
+	// maxCandidates is how many of the most recent scoped entries are scored
+	maxCandidates = 1000
+	// maxEntryChars is how much of an entry's content is shown to a language model
+	maxEntryChars = 1000
+)
+
+// stopWords are left out of the terms a query is matched by
+var stopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "are": true, "was": true, "were": true,
+	"what": true, "whats": true, "which": true, "who": true, "how": true, "when": true,
+	"where": true, "why": true, "does": true, "did": true, "has": true, "have": true,
+	"with": true, "this": true, "that": true, "from": true, "about": true, "any": true,
+	"can": true, "you": true, "your": true, "our": true, "there": true, "its": true,
+	"status": true, "current": true, "currently": true, "please": true, "tell": true,
+}
+
+// retrieval is the context an answer is built from
+type retrieval struct {
+	Entries []aoicontext.ContextEntry
+	// Confidence is how well the entries cover the query, from 0 to 1
+	Confidence float64
+	// Scope describes the scope filters applied, e.g. "project:billing"
+	Scope string
+}
+
+// Refs returns the IDs and files of the entries, for Sources and ContextRefs
+func (r *retrieval) Refs() []string {
+	var refs []string
+	seen := make(map[string]bool)
+	for _, entry := range r.Entries {
+		for _, ref := range []string{entry.ID, entry.File} {
+			if ref != "" && !seen[ref] {
+				seen[ref] = true
+				refs = append(refs, ref)
+			}
+		}
+	}
+	return refs
+}
+
+// retrieve finds the context entries relevant to req. ContextScope is a
+// comma-separated list of "project:<name>", "topic:<name>", "file:<path>" or
+// "type:<type>" filters; other scope words are matched like query terms.
+// Entries are ranked by how many of the query's terms their topics, project,
+// file, summary and content match, more recent entries first on ties. The
+// confidence is how much of the query, scope included, the entries cover.
+func retrieve(store *aoicontext.ContextStore, req QueryRequest) (*retrieval, error) {
+	q := aoicontext.ContextQuery{Limit: maxCandidates}
+	var filters []string
+	text := req.Query
+	for _, scope := range strings.Split(req.ContextScope, ",") {
+		scope = strings.TrimSpace(scope)
+		kind, value, ok := strings.Cut(scope, ":")
+		if !ok || value == "" {
+			text += " " + scope
+			continue
+		}
+		switch kind {
+		case "project":
+			q.Project = value
+		case "topic":
+			q.Topic = value
+		case "file":
+			q.File = value
+		case "type":
+			q.Type = aoicontext.ContextEntryType(value)
+		default:
+			text += " " + scope
+			continue
+		}
+		filters = append(filters, scope)
+	}
+
+	history, err := store.Query(q)
+	if err != nil {
+		return nil, err
+	}
+	result := &retrieval{Scope: strings.Join(filters, ", ")}
+	terms := queryTerms(text)
+	// Scope filters count as terms every scoped entry matches
+	total := len(terms) + len(filters)
+	if total == 0 {
+		return result, nil
+	}
+
+	type scored struct {
+		entry   aoicontext.ContextEntry
+		matched map[string]bool
+	}
+	var candidates []scored
+	for _, entry := range history.Entries {
+		matched := matchTerms(entry, terms)
+		for _, filter := range filters {
+			matched[filter] = true
+		}
+		if len(matched) > 0 {
+			candidates = append(candidates, scored{entry, matched})
+		}
+	}
+	// Entries come most recent first, so a stable sort keeps recency on ties
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return len(candidates[i].matched) > len(candidates[j].matched)
+	})
+	if len(candidates) > MaxContextEntries {
+		candidates = candidates[:MaxContextEntries]
+	}
+	if len(candidates) == 0 {
+		return result, nil
+	}
+
+	// Confidence averages how much of the query the best entry covers and how
+	// much all retrieved entries cover together
+	covered := make(map[string]bool)
+	for _, c := range candidates {
+		result.Entries = append(result.Entries, c.entry)
+		for term := range c.matched {
+			covered[term] = true
+		}
+	}
+	best := float64(len(candidates[0].matched)) / float64(total)
+	union := float64(len(covered)) / float64(total)
+	result.Confidence = math.Round((best+union)/2*100) / 100
+	return result, nil
+}
+
+// queryTerms splits text into lower-case words of three or more characters,
+// leaving out stop words
+func queryTerms(text string) []string {
+	var terms []string
+	seen := make(map[string]bool)
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '_' && r != '.' && r != '/'
+	})
+	for _, word := range words {
+		word = strings.Trim(word, "-_./")
+		if len([]rune(word)) < 3 || stopWords[word] || seen[word] {
+			continue
+		}
+		seen[word] = true
+		terms = append(terms, word)
+	}
+	return terms
+}
+
+// matchTerms returns the terms that occur in an entry's topics, project,
+// file, summary or content
+func matchTerms(entry aoicontext.ContextEntry, terms []string) map[string]bool {
+	fields := append([]string{entry.Project, entry.File, entry.Summary, entry.Content}, entry.Topics...)
+	text := strings.ToLower(strings.Join(fields, "\n"))
+
+	matched := make(map[string]bool)
+	for _, term := range terms {
+		if strings.Contains(text, term) {
+			matched[term] = true
+		}
+	}
+	return matched
+}
+
+// describeEntry is a one-line description of an entry
+func describeEntry(entry aoicontext.ContextEntry) string {
+	text := entry.Summary
+	if text == "" {
+		text = truncate(entry.Content, 200)
+	}
+	var where []string
+	if entry.File != "" {
+		where = append(where, entry.File)
+	}
+	if entry.Project != "" {
+		where = append(where, "project "+entry.Project)
+	}
+	if len(where) > 0 {
+		text += " (" + strings.Join(where, ", ") + ")"
+	}
+	return fmt.Sprintf("%s, %s", text, entry.Timestamp.Format(time.RFC3339))
+}
+
+// contextAnswer builds an answer from the retrieved entries alone
+func contextAnswer(r *retrieval) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Based on %d context entries", len(r.Entries))
+	if r.Scope != "" {
+		fmt.Fprintf(&b, " (%s)", r.Scope)
+	}
+	b.WriteString(":")
+	for _, entry := range r.Entries {
+		b.WriteString("\n- ")
+		b.WriteString(describeEntry(entry))
+	}
+	return b.String()
+}
+
+// contextPrompt is the user message asking a language model to answer req
+// from the retrieved entries
+func contextPrompt(req QueryRequest, r *retrieval) string {
+	var b strings.Builder
+	b.WriteString("Context entries:\n")
+	for i, entry := range r.Entries {
+		fmt.Fprintf(&b, "[%d] %s\n", i+1, describeEntry(entry))
+		if content := truncate(entry.Content, maxEntryChars); content != "" && content != entry.Summary {
+			b.WriteString(content)
+			b.WriteString("\n")
+		}
+	}
+	b.WriteString("\n")
+	b.WriteString(queryPrompt(req))
+	return b.String()
+}
+
+// truncate shortens s to at most n runes
+func truncate(s string, n int) string {
+	runes := []rune(strings.TrimSpace(s))
+	if len(runes) <= n {
+		return string(runes)
+	}
+	return string(runes[:n]) + "…"
+}
@@ -0,0 +1,123 @@
+package secretary
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	aoicontext "github.com/aoi-protocol/aoi/internal/context"
+	"github.com/aoi-protocol/aoi/internal/llm"
+	"github.com/aoi-protocol/aoi/pkg/aoi"
+)
+
+// newGroundedSecretary returns an engineer's secretary over a store holding
+// billing and auth entries
+func newGroundedSecretary(t *testing.T) (*Secretary, *aoicontext.ContextStore) {
+	t.Helper()
+	store := aoicontext.NewContextStore(time.Hour)
+	t.Cleanup(store.Stop)
+
+	now := time.Now()
+	entries := []*aoicontext.ContextEntry{
+		{ID: "e1", Type: aoicontext.ContextTypeFile, Project: "billing", File: "billing/invoice.go",
+			Summary: "Invoice rounding fixed", Content: "func roundInvoice() uses banker's rounding", Topics: []string{"invoice"}, Timestamp: now.Add(-time.Hour)},
+		{ID: "e2", Type: aoicontext.ContextTypeActivity, Project: "billing",
+			Summary: "Invoice PDF export in review", Topics: []string{"invoice", "pdf"}, Timestamp: now},
+		{ID: "e3", Type: aoicontext.ContextTypeFile, Project: "auth", File: "auth/login.go",
+			Summary: "Login rate limiting added", Topics: []string{"security"}, Timestamp: now},
+	}
+	for _, entry := range entries {
+		if err := store.Store(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sec := NewSecretary(&aoi.AgentIdentity{ID: "eng-1", Role: aoi.RoleEngineer})
+	sec.SetContextStore(store)
+	return sec, store
+}
+
+func TestSecretary_AnswersFromContext(t *testing.T) {
+	sec, _ := newGroundedSecretary(t)
+
+	resp, err := sec.HandleQuery(QueryRequest{Query: "Is invoice rounding fixed?", FromAgent: "pm-1"})
+	if err != nil {
+		t.Fatalf("HandleQuery: %v", err)
+	}
+	// e1 matches every term, e2 only "invoice"
+	if len(resp.Sources) < 2 || resp.Sources[0] != "e1" || resp.Sources[1] != "billing/invoice.go" {
+		t.Errorf("Expected e1 and its file first in the sources, got %v", resp.Sources)
+	}
+	for _, source := range resp.Sources {
+		if source == "e3" {
+			t.Error("Expected the unrelated auth entry to be left out")
+		}
+	}
+	if !strings.Contains(resp.Answer, "Invoice rounding fixed") {
+		t.Errorf("Expected the answer to be built from the entries, got %q", resp.Answer)
+	}
+	if resp.Confidence != 1 {
+		t.Errorf("Expected full confidence when one entry covers the query, got %.2f", resp.Confidence)
+	}
+}
+
+func TestSecretary_ContextScope(t *testing.T) {
+	sec, _ := newGroundedSecretary(t)
+
+	resp, _ := sec.HandleQuery(QueryRequest{Query: "what changed?", ContextScope: "project:auth"})
+	if len(resp.Sources) != 2 || resp.Sources[0] != "e3" {
+		t.Errorf("Expected only the auth entry, got %v", resp.Sources)
+	}
+	if resp.Confidence != 0.5 || !strings.Contains(resp.Answer, "project:auth") {
+		t.Errorf("Expected a scope-only answer, got %q (%.2f)", resp.Answer, resp.Confidence)
+	}
+
+	resp, _ = sec.HandleQuery(QueryRequest{Query: "pdf export and rounding", ContextScope: "topic:pdf"})
+	if len(resp.Sources) != 1 || resp.Sources[0] != "e2" {
+		t.Errorf("Expected only the pdf entry, got %v", resp.Sources)
+	}
+	if resp.Confidence != 0.75 {
+		t.Errorf("Expected partial confidence, got %.2f", resp.Confidence)
+	}
+}
+
+func TestSecretary_NoContext(t *testing.T) {
+	sec, _ := newGroundedSecretary(t)
+
+	resp, err := sec.HandleQuery(QueryRequest{Query: "kubernetes upgrade"})
+	if err != nil {
+		t.Fatalf("HandleQuery: %v", err)
+	}
+	if resp.Confidence != 0 || len(resp.Sources) != 0 || !strings.HasPrefix(resp.Answer, "No context found") {
+		t.Errorf("Expected no answer without context, got %+v", resp)
+	}
+}
+
+func TestSecretary_ContextWithProvider(t *testing.T) {
+	sec, _ := newGroundedSecretary(t)
+	provider := llm.NewStubProvider()
+	provider.Reply = func(llm.CompletionRequest) string { return "Yes, rounding is fixed [1]." }
+	sec.SetProvider(provider)
+
+	resp, _ := sec.HandleQuery(QueryRequest{Query: "Is invoice rounding fixed?"})
+	if resp.Answer != "Yes, rounding is fixed [1]." || resp.Confidence != 1 || resp.Sources[0] != "e1" {
+		t.Errorf("Expected the model's answer with retrieval sources and confidence, got %+v", resp)
+	}
+	prompt := provider.Requests()[0].Messages[0].Content
+	if !strings.Contains(prompt, "[1] Invoice rounding fixed") || !strings.Contains(prompt, "banker's rounding") {
+		t.Errorf("Expected the entries in the prompt, got %q", prompt)
+	}
+
+	// Nothing to ground an answer in: the model is not asked
+	sec.HandleQuery(QueryRequest{Query: "kubernetes upgrade"})
+	if len(provider.Requests()) != 1 {
+		t.Error("Expected no completion without context")
+	}
+}
+
+func TestQueryTerms(t *testing.T) {
+	got := strings.Join(queryTerms("What's the status of billing/invoice.go and the PDF export?"), " ")
+	if got != "billing/invoice.go pdf export" {
+		t.Errorf("Unexpected terms: %q", got)
+	}
+}
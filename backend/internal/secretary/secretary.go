@@ -1,11 +1,16 @@
 package secretary
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
+	aoicontext "github.com/aoi-protocol/aoi/internal/context"
+	"github.com/aoi-protocol/aoi/internal/llm"
 	"github.com/aoi-protocol/aoi/pkg/aoi"
 )
 
@@ -13,7 +18,10 @@ import (
 type QueryRequest struct {
 	Query        string            `json:"query"`
 	FromAgent    string            `json:"from_agent"`
+	ToAgent      string            `json:"to_agent,omitempty"`
 	ContextScope string            `json:"context_scope,omitempty"`
+	Priority     string            `json:"priority,omitempty"`
+	Delegate     bool              `json:"delegate,omitempty"`
 	Metadata     map[string]string `json:"metadata,omitempty"`
 }
 
@@ -33,61 +41,213 @@ type QueryLog struct {
 	Response  string
 }
 
+// Handler answers a query in the manner of a role, returning the answer, a
+// confidence score and the sources it drew on
+type Handler func(s *Secretary, req QueryRequest) (answer string, confidence float64, sources []string)
+
+// Built-in handler names
+const (
+	HandlerPM       = "pm"
+	HandlerEngineer = "engineer"
+	HandlerQA       = "qa"
+	HandlerDesign   = "design"
+	// HandlerGeneric answers for roles without a specialised handler
+	HandlerGeneric = "generic"
+)
+
+var handlers = map[string]Handler{
+	HandlerPM: func(s *Secretary, req QueryRequest) (string, float64, []string) {
+		return s.handlePMQuery(req), 0.85, []string{"project_status.md", "roadmap.md"}
+	},
+	HandlerEngineer: func(s *Secretary, req QueryRequest) (string, float64, []string) {
+		return s.handleEngineerQuery(req), 0.90, []string{"codebase", "technical_docs"}
+	},
+	HandlerQA: func(s *Secretary, req QueryRequest) (string, float64, []string) {
+		return s.handleQAQuery(req), 0.88, []string{"test_results", "bug_reports"}
+	},
+	HandlerDesign: func(s *Secretary, req QueryRequest) (string, float64, []string) {
+		return s.handleDesignQuery(req), 0.87, []string{"design_specs", "ui_mockups"}
+	},
+	HandlerGeneric: func(s *Secretary, req QueryRequest) (string, float64, []string) {
+		return fmt.Sprintf("Query processed by %s: %s", s.Identity.ID, req.Query), 0.75, []string{}
+	},
+}
+
+// defaultSystemPrompts tell a language model how each handler answers
+var defaultSystemPrompts = map[string]string{
+	HandlerPM: "You are the secretary of a project manager. Answer questions from other agents " +
+		"about project status, schedule, priorities and risks, concisely and factually.",
+	HandlerEngineer: "You are the secretary of a software engineer. Answer questions from other agents " +
+		"about the codebase, implementation status and technical decisions, concisely and precisely.",
+	HandlerQA: "You are the secretary of a QA engineer. Answer questions from other agents " +
+		"about test coverage, test results and known bugs, concisely and factually.",
+	HandlerDesign: "You are the secretary of a designer. Answer questions from other agents " +
+		"about designs, UI components and the design system, concisely.",
+	HandlerGeneric: "You are the secretary of a team member. Answer questions from other agents " +
+		"on their behalf, concisely. Say so when you do not know.",
+}
+
+// HasHandler reports whether name is a known handler
+func HasHandler(name string) bool {
+	_, ok := handlers[name]
+	return ok
+}
+
+// HandlerNames returns the known handler names, sorted
+func HandlerNames() []string {
+	names := make([]string, 0, len(handlers))
+	for name := range handlers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // Secretary represents an AI secretary agent
 type Secretary struct {
-	Identity  *aoi.AgentIdentity
-	status    string
-	shutdown  chan struct{}
-	wg        sync.WaitGroup
-	queryLogs []QueryLog
-	mu        sync.RWMutex
+	Identity *aoi.AgentIdentity
+	handler  string
+	provider llm.Provider
+	prompt   string
+	store    *aoicontext.ContextStore
+	status   string
+	// worker, when set, answers the queries delegated to it
+	worker          Worker
+	delegateMode    string
+	delegateTimeout time.Duration
+	onDelegate      []func(Delegation)
+	shutdown        chan struct{}
+	wg              sync.WaitGroup
+	queryLogs       []QueryLog
+	mu              sync.RWMutex
 }
 
-// NewSecretary creates a new secretary agent
+// NewSecretary creates a new secretary agent. Queries are answered by the
+// handler named after the agent's role, or the generic one if there is none.
 func NewSecretary(agentID *aoi.AgentIdentity) *Secretary {
+	handler := HandlerGeneric
+	if agentID != nil && HasHandler(string(agentID.Role)) {
+		handler = string(agentID.Role)
+	}
 	return &Secretary{
 		Identity:  agentID,
+		handler:   handler,
 		status:    "idle",
 		shutdown:  make(chan struct{}),
 		queryLogs: make([]QueryLog, 0),
 	}
 }
 
-// HandleQuery processes an incoming query with role-based routing
-func (s *Secretary) HandleQuery(req QueryRequest) (*QueryResponse, error) {
+// SetHandler selects the handler that answers queries, e.g. to let a custom
+// role answer like one of the built-in roles
+func (s *Secretary) SetHandler(name string) error {
+	if !HasHandler(name) {
+		return fmt.Errorf("unknown secretary handler %q (known: %s)", name, strings.Join(HandlerNames(), ", "))
+	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	s.handler = name
+	return nil
+}
+
+// Handler returns the name of the handler that answers queries
+func (s *Secretary) Handler() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.handler
+}
+
+// SetProvider makes a language model answer queries instead of the
+// handler's built-in templates; nil restores the templates
+func (s *Secretary) SetProvider(provider llm.Provider) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.provider = provider
+}
+
+// Provider returns the language model answering queries, or nil
+func (s *Secretary) Provider() llm.Provider {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.provider
+}
 
-	// Route based on agent role
-	var answer string
-	var confidence float64
-	var sources []string
+// SetSystemPrompt replaces the handler's default system prompt; "" restores it
+func (s *Secretary) SetSystemPrompt(prompt string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prompt = prompt
+}
 
-	switch s.Identity.Role {
-	case aoi.RolePM:
-		answer = s.handlePMQuery(req)
-		confidence = 0.85
-		sources = []string{"project_status.md", "roadmap.md"}
+// SetContextStore grounds answers in the entries of store: queries are
+// answered from the relevant entries, which are returned as sources
+func (s *Secretary) SetContextStore(store *aoicontext.ContextStore) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.store = store
+}
 
-	case aoi.RoleEngineer:
-		answer = s.handleEngineerQuery(req)
-		confidence = 0.90
-		sources = []string{"codebase", "technical_docs"}
+// SystemPrompt returns the system prompt the language model answers with
+func (s *Secretary) SystemPrompt() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.prompt != "" {
+		return s.prompt
+	}
+	return defaultSystemPrompts[s.handler]
+}
 
-	case aoi.RoleQA:
-		answer = s.handleQAQuery(req)
-		confidence = 0.88
-		sources = []string{"test_results", "bug_reports"}
+// HandleQuery processes an incoming query with the handler of the agent's role
+func (s *Secretary) HandleQuery(req QueryRequest) (*QueryResponse, error) {
+	return s.HandleQueryContext(context.Background(), req)
+}
 
-	case aoi.RoleDesign:
-		answer = s.handleDesignQuery(req)
-		confidence = 0.87
-		sources = []string{"design_specs", "ui_mockups"}
+// HandleQueryContext processes an incoming query. With a context store the
+// answer is built from the relevant context entries, by the language model if
+// there is one, and the confidence is how well they cover the query. Without
+// a store a language model answers from the role's system prompt, or else the
+// handler of the agent's role answers from its templates. Queries asking to
+// be delegated, and in fallback mode those the context has no answer for, are
+// answered by the worker AI.
+func (s *Secretary) HandleQueryContext(ctx context.Context, req QueryRequest) (*QueryResponse, error) {
+	s.mu.RLock()
+	handler, provider, store := s.handler, s.provider, s.store
+	s.mu.RUnlock()
 
+	var (
+		answer     string
+		confidence float64
+		sources    []string
+		metadata   = req.Metadata
+		err        error
+	)
+	switch {
+	case req.Delegate:
+		var resp *QueryResponse
+		if resp, err = s.Delegate(ctx, req); err == nil {
+			answer, confidence, sources, metadata = resp.Answer, resp.Confidence, resp.Sources, resp.Metadata
+		}
+	case store != nil:
+		answer, confidence, sources, err = s.answerFromContext(ctx, provider, store, req)
+	case provider != nil:
+		var completion *llm.Completion
+		if completion, err = s.complete(ctx, provider, s.SystemPrompt(), queryPrompt(req)); err == nil {
+			answer, confidence = completion.Text, completion.Confidence
+		}
 	default:
-		answer = fmt.Sprintf("Query processed by %s: %s", s.Identity.ID, req.Query)
-		confidence = 0.75
-		sources = []string{}
+		answer, confidence, sources = handlers[handler](s, req)
+	}
+	if err != nil {
+		log.Printf("[%s] Query from %s failed: %v", s.Identity.Role, req.FromAgent, err)
+		return nil, fmt.Errorf("failed to answer query: %w", err)
+	}
+	// Nothing in context: the worker may find the answer in the workspace
+	if !req.Delegate && store != nil && len(sources) == 0 && s.delegatesUnanswered() && delegationAllowed(ctx) {
+		if resp, err := s.Delegate(ctx, req); err != nil {
+			log.Printf("[%s] Falling back to the context answer: %v", s.Identity.Role, err)
+		} else {
+			answer, confidence, sources, metadata = resp.Answer, resp.Confidence, resp.Sources, resp.Metadata
+		}
 	}
 
 	// Log the query for audit trail
@@ -97,7 +257,9 @@ func (s *Secretary) HandleQuery(req QueryRequest) (*QueryResponse, error) {
 		Query:     req.Query,
 		Response:  answer,
 	}
+	s.mu.Lock()
 	s.queryLogs = append(s.queryLogs, queryLog)
+	s.mu.Unlock()
 
 	// Log to stdout
 	log.Printf("[%s] Query from %s: %s", s.Identity.Role, req.FromAgent, req.Query)
@@ -106,10 +268,56 @@ func (s *Secretary) HandleQuery(req QueryRequest) (*QueryResponse, error) {
 		Answer:     answer,
 		Confidence: confidence,
 		Sources:    sources,
-		Metadata:   req.Metadata,
+		Metadata:   metadata,
 	}, nil
 }
 
+// answerFromContext answers req from the entries of store relevant to it
+func (s *Secretary) answerFromContext(ctx context.Context, provider llm.Provider, store *aoicontext.ContextStore, req QueryRequest) (string, float64, []string, error) {
+	r, err := retrieve(store, req)
+	if err != nil {
+		return "", 0, nil, err
+	}
+	if len(r.Entries) == 0 {
+		return "No context found for: " + req.Query, 0, nil, nil
+	}
+	if provider == nil {
+		return contextAnswer(r), r.Confidence, r.Refs(), nil
+	}
+
+	system := s.SystemPrompt() + " Answer only from the context entries given, citing them as [n]. " +
+		"If they do not answer the question, say so."
+	completion, err := s.complete(ctx, provider, system, contextPrompt(req, r))
+	if err != nil {
+		return "", 0, nil, err
+	}
+	return completion.Text, r.Confidence, r.Refs(), nil
+}
+
+// complete asks the language model for an answer
+func (s *Secretary) complete(ctx context.Context, provider llm.Provider, system, prompt string) (*llm.Completion, error) {
+	return provider.Complete(ctx, llm.CompletionRequest{
+		System:   system,
+		Messages: []llm.Message{{Role: llm.RoleUser, Content: prompt}},
+	})
+}
+
+// queryPrompt is the user message asking a language model to answer req
+func queryPrompt(req QueryRequest) string {
+	var b strings.Builder
+	if req.FromAgent != "" {
+		fmt.Fprintf(&b, "Question from agent %s", req.FromAgent)
+	} else {
+		b.WriteString("Question")
+	}
+	if req.ContextScope != "" {
+		fmt.Fprintf(&b, " about %s", req.ContextScope)
+	}
+	b.WriteString(":\n")
+	b.WriteString(req.Query)
+	return b.String()
+}
+
 // handlePMQuery returns project status summaries
 func (s *Secretary) handlePMQuery(req QueryRequest) string {
 	return fmt.Sprintf("PM Summary: Project is on track. Query: %s. Context: %s",
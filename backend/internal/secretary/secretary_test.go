@@ -1,10 +1,13 @@
 package secretary
 
 import (
+	"context"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/aoi-protocol/aoi/internal/llm"
 	"github.com/aoi-protocol/aoi/pkg/aoi"
 )
 
@@ -576,3 +579,83 @@ func TestSecretary_QueryLogging(t *testing.T) {
 		t.Errorf("Expected query 'Test query', got %s", logs[0].Query)
 	}
 }
+
+func TestSecretary_HandlerForCustomRole(t *testing.T) {
+	sec := NewSecretary(&aoi.AgentIdentity{ID: "sre-agent", Role: "sre"})
+	if sec.Handler() != HandlerGeneric {
+		t.Errorf("Expected generic handler for a custom role, got %s", sec.Handler())
+	}
+
+	if err := sec.SetHandler(HandlerEngineer); err != nil {
+		t.Fatalf("SetHandler failed: %v", err)
+	}
+	resp, err := sec.HandleQuery(QueryRequest{Query: "deploy status?", FromAgent: "pm-agent"})
+	if err != nil {
+		t.Fatalf("HandleQuery failed: %v", err)
+	}
+	if !strings.HasPrefix(resp.Answer, "Engineer Summary") || resp.Confidence != 0.90 {
+		t.Errorf("Expected an engineer answer, got %q (%.2f)", resp.Answer, resp.Confidence)
+	}
+
+	if err := sec.SetHandler("oncall"); err == nil {
+		t.Error("Expected an unknown handler to be rejected")
+	}
+	if sec.Handler() != HandlerEngineer {
+		t.Errorf("Expected handler to stay engineer, got %s", sec.Handler())
+	}
+}
+
+func TestNewSecretary_BuiltinRoleHandlers(t *testing.T) {
+	for _, role := range []aoi.AgentRole{aoi.RolePM, aoi.RoleEngineer, aoi.RoleQA, aoi.RoleDesign} {
+		if got := NewSecretary(&aoi.AgentIdentity{ID: "a", Role: role}).Handler(); got != string(role) {
+			t.Errorf("Expected handler %s for role %s, got %s", role, role, got)
+		}
+	}
+}
+
+func TestSecretary_HandleQueryWithProvider(t *testing.T) {
+	sec := NewSecretary(&aoi.AgentIdentity{ID: "qa-1", Role: aoi.RoleQA})
+	provider := llm.NewStubProvider()
+	provider.Reply = func(req llm.CompletionRequest) string { return "All 42 tests pass." }
+	sec.SetProvider(provider)
+
+	resp, err := sec.HandleQuery(QueryRequest{Query: "Do the tests pass?", FromAgent: "pm-1", ContextScope: "project:billing"})
+	if err != nil {
+		t.Fatalf("HandleQuery: %v", err)
+	}
+	if resp.Answer != "All 42 tests pass." || resp.Confidence != llm.StubConfidence {
+		t.Errorf("Expected the provider's answer, got %q (%.2f)", resp.Answer, resp.Confidence)
+	}
+
+	reqs := provider.Requests()
+	if len(reqs) != 1 {
+		t.Fatalf("Expected one completion request, got %d", len(reqs))
+	}
+	if reqs[0].System != defaultSystemPrompts[HandlerQA] {
+		t.Errorf("Expected the QA system prompt, got %q", reqs[0].System)
+	}
+	prompt := reqs[0].Messages[0].Content
+	if !strings.Contains(prompt, "pm-1") || !strings.Contains(prompt, "project:billing") || !strings.Contains(prompt, "Do the tests pass?") {
+		t.Errorf("Expected the prompt to carry sender, scope and query, got %q", prompt)
+	}
+
+	sec.SetSystemPrompt("You speak for the release team.")
+	sec.HandleQuery(QueryRequest{Query: "Ship it?"})
+	if reqs := provider.Requests(); reqs[1].System != "You speak for the release team." {
+		t.Errorf("Expected the configured system prompt, got %q", reqs[1].System)
+	}
+}
+
+func TestSecretary_HandleQueryProviderError(t *testing.T) {
+	sec := NewSecretary(&aoi.AgentIdentity{ID: "eng-1", Role: aoi.RoleEngineer})
+	sec.SetProvider(llm.NewStubProvider())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := sec.HandleQueryContext(ctx, QueryRequest{Query: "status?"}); err == nil {
+		t.Error("Expected the provider's error")
+	}
+	if len(sec.GetQueryLogs()) != 0 {
+		t.Error("Expected a failed query not to be logged as answered")
+	}
+}
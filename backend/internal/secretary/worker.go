@@ -0,0 +1,133 @@
+package secretary
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aoi-protocol/aoi/internal/h2a"
+	"github.com/aoi-protocol/aoi/internal/mcp"
+)
+
+// Worker defaults
+const (
+	// DefaultWorkerPollInterval is how often a worker's terminal is read
+	DefaultWorkerPollInterval = 2 * time.Second
+	// DefaultWorkerCaptureLines is how much of a worker's terminal is read
+	DefaultWorkerCaptureLines = 500
+)
+
+// H2ASessions is the subset of h2a.H2AManager an H2AWorker drives
+type H2ASessions interface {
+	SendCommand(agentID, command string, captureOutput bool) (*h2a.SendResult, error)
+	CaptureOutput(agentID string, lines int) (string, error)
+}
+
+// H2AWorker is a worker AI running in the tmux session registered with H2A
+// for an agent. Tasks are typed into the session and the answer is read back
+// from the terminal between the answer markers, one task at a time.
+type H2AWorker struct {
+	sessions     H2ASessions
+	agentID      string
+	pollInterval time.Duration
+	lines        int
+
+	// busy is held from sending a task until its answer is read, so
+	// concurrent tasks are not typed into the session on top of each other
+	busy chan struct{}
+}
+
+// NewH2AWorker creates a worker for the tmux session of agentID
+func NewH2AWorker(sessions H2ASessions, agentID string) *H2AWorker {
+	return &H2AWorker{
+		sessions:     sessions,
+		agentID:      agentID,
+		pollInterval: DefaultWorkerPollInterval,
+		lines:        DefaultWorkerCaptureLines,
+		busy:         make(chan struct{}, 1),
+	}
+}
+
+// SetPollInterval sets how often the terminal is read while waiting
+func (w *H2AWorker) SetPollInterval(interval time.Duration) {
+	if interval > 0 {
+		w.pollInterval = interval
+	}
+}
+
+// Name returns "h2a:<agent>"
+func (w *H2AWorker) Name() string {
+	return "h2a:" + w.agentID
+}
+
+// Delegate types the prompt into the session and waits for the answer
+// markers. Tasks delegated meanwhile wait for the session to be free.
+func (w *H2AWorker) Delegate(ctx context.Context, task WorkerTask) (string, error) {
+	select {
+	case w.busy <- struct{}{}:
+		defer func() { <-w.busy }()
+	case <-ctx.Done():
+		return "", fmt.Errorf("%s is busy: %w", w.Name(), ctx.Err())
+	}
+
+	if _, err := w.sessions.SendCommand(w.agentID, task.Prompt, false); err != nil {
+		return "", err
+	}
+
+	ticker := time.NewTicker(w.pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("no answer from %s: %w", w.Name(), ctx.Err())
+		case <-ticker.C:
+			output, err := w.sessions.CaptureOutput(w.agentID, w.lines)
+			if err != nil {
+				return "", err
+			}
+			if answer, ok := ExtractAnswer(output, task.ID); ok {
+				return answer, nil
+			}
+		}
+	}
+}
+
+// MCPWorker is a worker AI exposed as an MCP tool that takes the prompt as
+// its "prompt" argument and replies with the answer
+type MCPWorker struct {
+	bridge     *mcp.MCPBridge
+	serverName string
+	toolName   string
+}
+
+// NewMCPWorker creates a worker calling toolName on the MCP server serverName
+func NewMCPWorker(bridge *mcp.MCPBridge, serverName, toolName string) *MCPWorker {
+	return &MCPWorker{bridge: bridge, serverName: serverName, toolName: toolName}
+}
+
+// Name returns "mcp:<server>/<tool>"
+func (w *MCPWorker) Name() string {
+	return "mcp:" + w.serverName + "/" + w.toolName
+}
+
+// Delegate calls the tool and returns its answer, taken from between the
+// answer markers when the tool printed them
+func (w *MCPWorker) Delegate(ctx context.Context, task WorkerTask) (string, error) {
+	resp, err := w.bridge.ExecuteToolCall(ctx, &mcp.ToolCallRequest{
+		ServerName: w.serverName,
+		ToolName:   w.toolName,
+		Arguments:  map[string]any{"prompt": task.Prompt},
+		Mapping:    mcp.ToolMapping{ServerName: w.serverName, ToolName: w.toolName},
+	})
+	if err != nil {
+		return "", err
+	}
+	if isErr, _ := resp.Metadata["error"].(bool); isErr {
+		return "", fmt.Errorf("tool %s reported an error: %s", w.Name(), strings.TrimSpace(resp.Answer))
+	}
+	if answer, ok := ExtractAnswer(resp.Answer, task.ID); ok {
+		return answer, nil
+	}
+	return resp.Answer, nil
+}
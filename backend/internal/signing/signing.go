@@ -0,0 +1,226 @@
+// Package signing authenticates JSON-RPC requests by the Ed25519 signature
+// of the sending agent, checked against the public key in the agent registry.
+package signing
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/aoi-protocol/aoi/internal/identity"
+	"github.com/aoi-protocol/aoi/pkg/aoi"
+)
+
+// Signature modes
+const (
+	// ModeOff neither signs nor verifies requests
+	ModeOff = "off"
+	// ModeOptional signs outgoing requests and verifies incoming ones that are
+	// signed, while still accepting unsigned requests. Use it while migrating.
+	ModeOptional = "optional"
+	// ModeRequired rejects unsigned requests
+	ModeRequired = "required"
+)
+
+// DefaultMaxSkew is how far a request's timestamp may be from the local clock
+const DefaultMaxSkew = 5 * time.Minute
+
+// Errors returned by Verifier.Verify
+var (
+	ErrUnsigned   = errors.New("request is not signed")
+	ErrUnknownKey = errors.New("no public key registered for agent")
+	ErrStale      = errors.New("request timestamp is outside the allowed window")
+	ErrReplay     = errors.New("request nonce was already used")
+)
+
+// contextKey is used for context values
+type contextKey string
+
+// ContextKeyAgentID is the context key for the agent ID proven by a request signature
+const ContextKeyAgentID contextKey = "signing_agent_id"
+
+// WithAgentID returns ctx carrying the agent ID proven by a signature
+func WithAgentID(ctx context.Context, agentID string) context.Context {
+	return context.WithValue(ctx, ContextKeyAgentID, agentID)
+}
+
+// GetAgentIDFromContext retrieves the signature-proven agent ID from ctx
+func GetAgentIDFromContext(ctx context.Context) string {
+	agentID, _ := ctx.Value(ContextKeyAgentID).(string)
+	return agentID
+}
+
+// LoadOrGenerateKey reads the agent's private key from a PEM (PKCS #8) file,
+// generating and saving a new key if the file does not exist yet
+func LoadOrGenerateKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		return parsePrivateKey(data)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read signing key: %w", err)
+	}
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate signing key: %w", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode signing key: %w", err)
+	}
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return nil, fmt.Errorf("failed to create key directory: %w", err)
+		}
+	}
+	pemData := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(path, pemData, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write signing key: %w", err)
+	}
+	return key, nil
+}
+
+func parsePrivateKey(data []byte) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, errors.New("signing key: no PRIVATE KEY PEM block")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("signing key: %w", err)
+	}
+	key, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.New("signing key: not an Ed25519 key")
+	}
+	return key, nil
+}
+
+// ReplayCache remembers the nonces of recently verified requests. Entries are
+// kept until their request would be rejected as stale anyway.
+type ReplayCache struct {
+	mu        sync.Mutex
+	seen      map[string]time.Time
+	window    time.Duration
+	lastPrune time.Time
+}
+
+// NewReplayCache creates a cache for requests accepted within window of now
+func NewReplayCache(window time.Duration) *ReplayCache {
+	return &ReplayCache{
+		seen:   make(map[string]time.Time),
+		window: window,
+	}
+}
+
+// Check records the nonce of a request signed at ts and reports whether it
+// was already recorded
+func (c *ReplayCache) Check(agentID, nonce string, ts, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if now.Sub(c.lastPrune) > c.window {
+		for key, expires := range c.seen {
+			if now.After(expires) {
+				delete(c.seen, key)
+			}
+		}
+		c.lastPrune = now
+	}
+
+	key := agentID + "\x00" + nonce
+	if _, ok := c.seen[key]; ok {
+		return true
+	}
+	c.seen[key] = ts.Add(c.window)
+	return false
+}
+
+// Len returns the number of remembered nonces
+func (c *ReplayCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.seen)
+}
+
+// Verifier checks request signatures against the keys in an agent registry
+type Verifier struct {
+	registry *identity.AgentRegistry
+	mode     string
+	maxSkew  time.Duration
+	replay   *ReplayCache
+	now      func() time.Time
+}
+
+// NewVerifier creates a verifier for mode; a non-positive maxSkew uses DefaultMaxSkew
+func NewVerifier(registry *identity.AgentRegistry, mode string, maxSkew time.Duration) *Verifier {
+	if maxSkew <= 0 {
+		maxSkew = DefaultMaxSkew
+	}
+	return &Verifier{
+		registry: registry,
+		mode:     mode,
+		maxSkew:  maxSkew,
+		replay:   NewReplayCache(maxSkew),
+		now:      time.Now,
+	}
+}
+
+// Mode returns the verifier's signature mode
+func (v *Verifier) Mode() string {
+	return v.mode
+}
+
+// Verify authenticates a request and returns the signing agent's ID. An
+// unsigned request returns "" and no error unless signatures are required.
+// In optional mode a signature from an agent with no registered key is
+// treated like an unsigned request, so agents can start signing before every
+// peer knows their key.
+func (v *Verifier) Verify(method string, params json.RawMessage, sig *aoi.Signature) (string, error) {
+	if v == nil || v.mode == ModeOff || v.mode == "" {
+		return "", nil
+	}
+	if sig == nil {
+		if v.mode == ModeRequired {
+			return "", ErrUnsigned
+		}
+		return "", nil
+	}
+
+	var pub ed25519.PublicKey
+	if agent, err := v.registry.GetAgent(sig.AgentID); err == nil && agent.PublicKey != "" {
+		if pub, err = aoi.ParsePublicKey(agent.PublicKey); err != nil {
+			return "", err
+		}
+	}
+	if pub == nil {
+		if v.mode == ModeRequired {
+			return "", ErrUnknownKey
+		}
+		return "", nil
+	}
+
+	now := v.now()
+	ts := sig.Time()
+	if ts.Before(now.Add(-v.maxSkew)) || ts.After(now.Add(v.maxSkew)) {
+		return "", ErrStale
+	}
+	if err := aoi.VerifyRequest(pub, method, params, sig); err != nil {
+		return "", err
+	}
+	// Only verified requests take up room in the cache
+	if v.replay.Check(sig.AgentID, sig.Nonce, ts, now) {
+		return "", ErrReplay
+	}
+	return sig.AgentID, nil
+}
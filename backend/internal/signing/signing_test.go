@@ -0,0 +1,139 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aoi-protocol/aoi/internal/identity"
+	"github.com/aoi-protocol/aoi/pkg/aoi"
+)
+
+func newSignedRegistry(t *testing.T) (*identity.AgentRegistry, ed25519.PrivateKey) {
+	t.Helper()
+	pub, key, _ := ed25519.GenerateKey(nil)
+	registry := identity.NewAgentRegistry()
+	registry.Register(&aoi.AgentIdentity{ID: "eng-agent", PublicKey: aoi.EncodePublicKey(pub)})
+	registry.Register(&aoi.AgentIdentity{ID: "legacy-agent"})
+	return registry, key
+}
+
+func TestVerifier_Modes(t *testing.T) {
+	registry, key := newSignedRegistry(t)
+	params := json.RawMessage(`{"query":"status?"}`)
+	_, strangerKey, _ := ed25519.GenerateKey(nil)
+
+	tests := []struct {
+		name    string
+		mode    string
+		sign    func() *aoi.Signature
+		agentID string
+		err     error
+	}{
+		{"off ignores signatures", ModeOff, func() *aoi.Signature { return nil }, "", nil},
+		{"optional accepts unsigned", ModeOptional, func() *aoi.Signature { return nil }, "", nil},
+		{"required rejects unsigned", ModeRequired, func() *aoi.Signature { return nil }, "", ErrUnsigned},
+		{"optional verifies", ModeOptional, func() *aoi.Signature {
+			sig, _ := aoi.SignRequest(key, "eng-agent", "aoi.query", params)
+			return sig
+		}, "eng-agent", nil},
+		{"required verifies", ModeRequired, func() *aoi.Signature {
+			sig, _ := aoi.SignRequest(key, "eng-agent", "aoi.query", params)
+			return sig
+		}, "eng-agent", nil},
+		{"wrong key", ModeOptional, func() *aoi.Signature {
+			sig, _ := aoi.SignRequest(strangerKey, "eng-agent", "aoi.query", params)
+			return sig
+		}, "", aoi.ErrBadSignature},
+		{"optional treats unknown key as unsigned", ModeOptional, func() *aoi.Signature {
+			sig, _ := aoi.SignRequest(strangerKey, "legacy-agent", "aoi.query", params)
+			return sig
+		}, "", nil},
+		{"required rejects unknown key", ModeRequired, func() *aoi.Signature {
+			sig, _ := aoi.SignRequest(strangerKey, "ghost", "aoi.query", params)
+			return sig
+		}, "", ErrUnknownKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := NewVerifier(registry, tt.mode, 0)
+			agentID, err := v.Verify("aoi.query", params, tt.sign())
+			if err != tt.err || agentID != tt.agentID {
+				t.Errorf("expected (%q, %v), got (%q, %v)", tt.agentID, tt.err, agentID, err)
+			}
+		})
+	}
+}
+
+func TestVerifier_RejectsReplayAndStale(t *testing.T) {
+	registry, key := newSignedRegistry(t)
+	v := NewVerifier(registry, ModeRequired, time.Minute)
+
+	sig, _ := aoi.SignRequest(key, "eng-agent", "aoi.status", nil)
+	if _, err := v.Verify("aoi.status", nil, sig); err != nil {
+		t.Fatalf("expected first use to verify, got %v", err)
+	}
+	if _, err := v.Verify("aoi.status", nil, sig); err != ErrReplay {
+		t.Errorf("expected ErrReplay, got %v", err)
+	}
+
+	// Signed two minutes ago, outside the one-minute window
+	v.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	sig, _ = aoi.SignRequest(key, "eng-agent", "aoi.status", nil)
+	if _, err := v.Verify("aoi.status", nil, sig); err != ErrStale {
+		t.Errorf("expected ErrStale, got %v", err)
+	}
+}
+
+func TestReplayCache_Prunes(t *testing.T) {
+	c := NewReplayCache(time.Minute)
+	now := time.Now()
+
+	if c.Check("eng-agent", "n1", now, now) {
+		t.Fatal("expected a new nonce")
+	}
+	if !c.Check("eng-agent", "n1", now, now) {
+		t.Error("expected a seen nonce")
+	}
+	if c.Check("qa-agent", "n1", now, now) {
+		t.Error("expected nonces to be per agent")
+	}
+
+	later := now.Add(2 * time.Minute)
+	c.Check("eng-agent", "n2", later, later)
+	if c.Len() != 1 {
+		t.Errorf("expected expired nonces to be pruned, have %d", c.Len())
+	}
+}
+
+func TestLoadOrGenerateKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys", "agent.key")
+
+	key, err := LoadOrGenerateKey(path)
+	if err != nil {
+		t.Fatalf("generate failed: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("expected key file: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected mode 0600, got %v", info.Mode().Perm())
+	}
+
+	again, err := LoadOrGenerateKey(path)
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if !again.Equal(key) {
+		t.Error("expected the saved key to be loaded")
+	}
+
+	os.WriteFile(path, []byte("not a key"), 0600)
+	if _, err := LoadOrGenerateKey(path); err == nil {
+		t.Error("expected a corrupt key file to fail")
+	}
+}
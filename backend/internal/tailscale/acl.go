@@ -238,35 +238,7 @@ func actionToPermission(action string) acl.PermissionLevel {
 
 // matchResource checks if a resource pattern matches a target resource
 func matchResource(pattern, target string) bool {
-	// Exact match
-	if pattern == target {
-		return true
-	}
-
-	// Global wildcard
-	if pattern == "*" {
-		return true
-	}
-
-	// Wildcard match (e.g., "agents/*" matches "agents/agent-1" but not "agents/agent-1/tasks")
-	if strings.HasSuffix(pattern, "/*") {
-		prefix := strings.TrimSuffix(pattern, "/*")
-		if !strings.HasPrefix(target, prefix+"/") {
-			return false
-		}
-		// Check if target has more path segments after the prefix
-		remainder := strings.TrimPrefix(target, prefix+"/")
-		// If remainder contains another slash, it's a deeper path - don't match
-		return !strings.Contains(remainder, "/")
-	}
-
-	// Double wildcard match (e.g., "agents/**" matches "agents/agent-1/tasks")
-	if strings.HasSuffix(pattern, "/**") {
-		prefix := strings.TrimSuffix(pattern, "/**")
-		return strings.HasPrefix(target, prefix+"/")
-	}
-
-	return false
+	return acl.MatchResource(pattern, target)
 }
 
 // tagsEqual checks if two tag slices are equal
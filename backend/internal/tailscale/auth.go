@@ -71,12 +71,22 @@ func (a *Auth) MapNodeToAgent(nodeID, agentID string) {
 	a.nodeToAgent[nodeID] = agentID
 }
 
-// GetAgentIDForNode returns the agent ID mapped to a Tailscale node
+// GetAgentIDForNode returns the agent ID mapped to a Tailscale node. Mappings
+// not made in this process are recovered from the agents' stored TailscaleNodeID.
 func (a *Auth) GetAgentIDForNode(nodeID string) (string, bool) {
 	a.mu.RLock()
-	defer a.mu.RUnlock()
 	agentID, ok := a.nodeToAgent[nodeID]
-	return agentID, ok
+	a.mu.RUnlock()
+	if ok || a.registry == nil {
+		return agentID, ok
+	}
+
+	agent, err := a.registry.GetAgentByTailscaleNodeID(nodeID)
+	if err != nil {
+		return "", false
+	}
+	a.MapNodeToAgent(nodeID, agent.ID)
+	return agent.ID, true
 }
 
 // RemoveNodeMapping removes the mapping for a node
@@ -190,11 +200,12 @@ func (a *Auth) autoRegisterAgent(ctx context.Context, nodeInfo *NodeInfo) string
 
 	// Create new agent identity
 	agent := &aoi.AgentIdentity{
-		ID:       agentID,
-		Role:     aoi.RoleEngineer, // Default role
-		Owner:    nodeInfo.UserID,
-		Status:   "online",
-		Endpoint: nodeInfo.IPs[0] + ":8080", // Default endpoint
+		ID:              agentID,
+		Role:            aoi.RoleEngineer, // Default role
+		Owner:           nodeInfo.UserID,
+		Status:          "online",
+		TailscaleNodeID: nodeInfo.ID,
+		Endpoint:        nodeInfo.IPs[0] + ":8080", // Default endpoint
 		Metadata: map[string]interface{}{
 			"tailscale_node_id": nodeInfo.ID,
 			"tailscale_name":    nodeInfo.Name,
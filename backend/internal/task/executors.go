@@ -0,0 +1,261 @@
+package task
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aoi-protocol/aoi/internal/h2a"
+	"github.com/aoi-protocol/aoi/internal/mcp"
+	"github.com/aoi-protocol/aoi/pkg/aoi"
+)
+
+// Built-in task types
+const (
+	TypeShell = "shell"
+	TypeMCP   = "mcp"
+	TypeH2A   = "h2a"
+)
+
+// Registry dispatches tasks to the executor registered for their Type
+type Registry struct {
+	executors map[string]Executor
+	mu        sync.RWMutex
+}
+
+// NewRegistry creates an empty executor registry
+func NewRegistry() *Registry {
+	return &Registry{
+		executors: make(map[string]Executor),
+	}
+}
+
+// Register associates an executor with a task type, replacing any previous one
+func (r *Registry) Register(taskType string, executor Executor) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.executors[taskType] = executor
+}
+
+// Types returns the registered task types in sorted order
+func (r *Registry) Types() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	types := make([]string, 0, len(r.executors))
+	for t := range r.executors {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Execute runs the task with the executor registered for its type
+func (r *Registry) Execute(ctx context.Context, t *aoi.Task) (string, error) {
+	r.mu.RLock()
+	executor, ok := r.executors[t.Type]
+	r.mu.RUnlock()
+
+	if !ok {
+		return "", fmt.Errorf("no executor registered for task type %q", t.Type)
+	}
+	return executor.Execute(ctx, t)
+}
+
+// ============================================================================
+// Shell executor
+// ============================================================================
+
+// ShellExecutor runs allow-listed local commands without a shell.
+//
+// Parameters: "command" (string, required), "args" ([]string), "dir" (string,
+// relative to WorkDir).
+type ShellExecutor struct {
+	allowed map[string]bool
+	workDir string
+}
+
+// NewShellExecutor creates a shell executor that only runs the given commands.
+// Commands must match an allow-list entry exactly; bare names are resolved via PATH.
+func NewShellExecutor(allowedCommands []string, workDir string) *ShellExecutor {
+	allowed := make(map[string]bool, len(allowedCommands))
+	for _, cmd := range allowedCommands {
+		allowed[cmd] = true
+	}
+	return &ShellExecutor{
+		allowed: allowed,
+		workDir: workDir,
+	}
+}
+
+// Execute runs the command and returns its combined output
+func (e *ShellExecutor) Execute(ctx context.Context, t *aoi.Task) (string, error) {
+	command, _ := t.Parameters["command"].(string)
+	if command == "" {
+		return "", fmt.Errorf("shell task requires a command parameter")
+	}
+	if !e.allowed[command] {
+		return "", fmt.Errorf("command %q is not in the allow-list", command)
+	}
+
+	args, err := stringSlice(t.Parameters["args"])
+	if err != nil {
+		return "", fmt.Errorf("invalid args parameter: %w", err)
+	}
+
+	dir := e.workDir
+	if sub, _ := t.Parameters["dir"].(string); sub != "" {
+		if filepath.IsAbs(sub) || strings.HasPrefix(filepath.Clean(sub), "..") {
+			return "", fmt.Errorf("dir must be relative to the work directory: %s", sub)
+		}
+		dir = filepath.Join(e.workDir, sub)
+	}
+
+	cmd := exec.CommandContext(ctx, command, args...)
+	cmd.Dir = dir
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+
+	if err := cmd.Run(); err != nil {
+		return out.String(), fmt.Errorf("command failed: %w", err)
+	}
+	return out.String(), nil
+}
+
+// ============================================================================
+// MCP tool executor
+// ============================================================================
+
+// MCPExecutor turns a task into an MCP tool call through the bridge.
+//
+// Parameters: "server_name" (string), "tool_name" (string), "arguments" (object).
+type MCPExecutor struct {
+	bridge *mcp.MCPBridge
+}
+
+// NewMCPExecutor creates an executor backed by the given MCP bridge
+func NewMCPExecutor(bridge *mcp.MCPBridge) *MCPExecutor {
+	return &MCPExecutor{bridge: bridge}
+}
+
+// Execute calls the MCP tool and returns its text answer
+func (e *MCPExecutor) Execute(ctx context.Context, t *aoi.Task) (string, error) {
+	serverName, _ := t.Parameters["server_name"].(string)
+	toolName, _ := t.Parameters["tool_name"].(string)
+	if serverName == "" || toolName == "" {
+		return "", fmt.Errorf("mcp task requires server_name and tool_name parameters")
+	}
+
+	arguments, _ := t.Parameters["arguments"].(map[string]interface{})
+	if arguments == nil {
+		arguments = map[string]interface{}{}
+	}
+
+	resp, err := e.bridge.ExecuteToolCall(ctx, &mcp.ToolCallRequest{
+		ServerName: serverName,
+		ToolName:   toolName,
+		Arguments:  arguments,
+		Mapping: mcp.ToolMapping{
+			ServerName: serverName,
+			ToolName:   toolName,
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	if isErr, _ := resp.Metadata["error"].(bool); isErr {
+		return resp.Answer, fmt.Errorf("tool %s/%s reported an error", serverName, toolName)
+	}
+	return resp.Answer, nil
+}
+
+// ============================================================================
+// H2A session executor
+// ============================================================================
+
+// H2ASender is the subset of H2AManager used by H2AExecutor
+type H2ASender interface {
+	CanSendTo(fromUser, targetAgentID string) bool
+	SendCommand(agentID, command string, captureOutput bool) (*h2a.SendResult, error)
+	CaptureOutput(agentID string, lines int) (string, error)
+}
+
+// H2AExecutor forwards a task to an agent's tmux session and collects the output.
+// The task's requester must be allowed to send to the session, as with aoi.h2a.send.
+//
+// Parameters: "agent_id" (string), "command" (string), "wait_ms" (number, how
+// long to let the command run before capturing), "lines" (number of pane lines).
+type H2AExecutor struct {
+	sender      H2ASender
+	defaultWait time.Duration
+}
+
+// NewH2AExecutor creates an executor that drives tmux sessions via sender
+func NewH2AExecutor(sender H2ASender) *H2AExecutor {
+	return &H2AExecutor{
+		sender:      sender,
+		defaultWait: time.Second,
+	}
+}
+
+// Execute sends the command, waits, and returns the captured pane output
+func (e *H2AExecutor) Execute(ctx context.Context, t *aoi.Task) (string, error) {
+	agentID, _ := t.Parameters["agent_id"].(string)
+	command, _ := t.Parameters["command"].(string)
+	if agentID == "" || command == "" {
+		return "", fmt.Errorf("h2a task requires agent_id and command parameters")
+	}
+	if requester := Requester(ctx); !e.sender.CanSendTo(requester, agentID) {
+		return "", fmt.Errorf("%w: requester %q may not send to agent %q", ErrDenied, requester, agentID)
+	}
+
+	wait := e.defaultWait
+	if ms, ok := t.Parameters["wait_ms"].(float64); ok && ms > 0 {
+		wait = time.Duration(ms) * time.Millisecond
+	}
+	lines := 50
+	if n, ok := t.Parameters["lines"].(float64); ok && n > 0 {
+		lines = int(n)
+	}
+
+	if _, err := e.sender.SendCommand(agentID, command, false); err != nil {
+		return "", err
+	}
+
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case <-time.After(wait):
+	}
+
+	return e.sender.CaptureOutput(agentID, lines)
+}
+
+// stringSlice converts a decoded JSON array into a []string
+func stringSlice(v interface{}) ([]string, error) {
+	switch vals := v.(type) {
+	case nil:
+		return nil, nil
+	case []string:
+		return vals, nil
+	case []interface{}:
+		result := make([]string, 0, len(vals))
+		for _, item := range vals {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("expected string, got %T", item)
+			}
+			result = append(result, s)
+		}
+		return result, nil
+	default:
+		return nil, fmt.Errorf("expected array of strings, got %T", v)
+	}
+}
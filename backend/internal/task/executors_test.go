@@ -0,0 +1,203 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aoi-protocol/aoi/internal/h2a"
+	"github.com/aoi-protocol/aoi/internal/mcp"
+	"github.com/aoi-protocol/aoi/pkg/aoi"
+)
+
+func TestRegistry_DispatchByType(t *testing.T) {
+	r := NewRegistry()
+	r.Register("echo", echoExecutor())
+
+	out, err := r.Execute(context.Background(), &aoi.Task{Type: "echo"})
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if out != "ran echo" {
+		t.Errorf("unexpected output %q", out)
+	}
+
+	if _, err := r.Execute(context.Background(), &aoi.Task{Type: "unknown"}); err == nil {
+		t.Error("expected error for unregistered task type")
+	}
+}
+
+func TestRegistry_Types(t *testing.T) {
+	r := NewRegistry()
+	r.Register(TypeShell, echoExecutor())
+	r.Register(TypeH2A, echoExecutor())
+
+	types := r.Types()
+	if len(types) != 2 || types[0] != TypeH2A || types[1] != TypeShell {
+		t.Errorf("expected sorted [h2a shell], got %v", types)
+	}
+}
+
+func TestShellExecutor_AllowedCommand(t *testing.T) {
+	e := NewShellExecutor([]string{"echo"}, t.TempDir())
+
+	out, err := e.Execute(context.Background(), &aoi.Task{
+		Type:       TypeShell,
+		Parameters: map[string]interface{}{"command": "echo", "args": []interface{}{"hello", "aoi"}},
+	})
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if strings.TrimSpace(out) != "hello aoi" {
+		t.Errorf("unexpected output %q", out)
+	}
+}
+
+func TestShellExecutor_RejectsUnlistedCommand(t *testing.T) {
+	e := NewShellExecutor([]string{"echo"}, t.TempDir())
+
+	for _, cmd := range []string{"rm", "/bin/echo", ""} {
+		_, err := e.Execute(context.Background(), &aoi.Task{
+			Parameters: map[string]interface{}{"command": cmd},
+		})
+		if err == nil {
+			t.Errorf("expected command %q to be rejected", cmd)
+		}
+	}
+}
+
+func TestShellExecutor_RejectsDirEscape(t *testing.T) {
+	e := NewShellExecutor([]string{"echo"}, t.TempDir())
+
+	for _, dir := range []string{"../outside", "/etc"} {
+		_, err := e.Execute(context.Background(), &aoi.Task{
+			Parameters: map[string]interface{}{"command": "echo", "dir": dir},
+		})
+		if err == nil {
+			t.Errorf("expected dir %q to be rejected", dir)
+		}
+	}
+}
+
+func TestShellExecutor_InvalidArgs(t *testing.T) {
+	e := NewShellExecutor([]string{"echo"}, t.TempDir())
+
+	_, err := e.Execute(context.Background(), &aoi.Task{
+		Parameters: map[string]interface{}{"command": "echo", "args": []interface{}{1, 2}},
+	})
+	if err == nil {
+		t.Error("expected error for non-string args")
+	}
+}
+
+func TestMCPExecutor_MissingParams(t *testing.T) {
+	e := NewMCPExecutor(mcp.NewMCPBridge(nil))
+
+	_, err := e.Execute(context.Background(), &aoi.Task{
+		Parameters: map[string]interface{}{"server_name": "fs"},
+	})
+	if err == nil {
+		t.Error("expected error without tool_name")
+	}
+}
+
+func TestMCPExecutor_UnknownServer(t *testing.T) {
+	e := NewMCPExecutor(mcp.NewMCPBridge(nil))
+
+	_, err := e.Execute(context.Background(), &aoi.Task{
+		Parameters: map[string]interface{}{"server_name": "missing", "tool_name": "read_file"},
+	})
+	if err == nil {
+		t.Error("expected error for unknown MCP server")
+	}
+}
+
+type fakeSender struct {
+	sent    []string
+	output  string
+	sendErr error
+}
+
+// CanSendTo lets agents send to themselves, like a non-PM user of H2AManager
+func (f *fakeSender) CanSendTo(fromUser, targetAgentID string) bool {
+	return fromUser != "" && fromUser == targetAgentID
+}
+
+func (f *fakeSender) SendCommand(agentID, command string, captureOutput bool) (*h2a.SendResult, error) {
+	if f.sendErr != nil {
+		return nil, f.sendErr
+	}
+	f.sent = append(f.sent, agentID+":"+command)
+	return &h2a.SendResult{Status: "sent"}, nil
+}
+
+func (f *fakeSender) CaptureOutput(agentID string, lines int) (string, error) {
+	return f.output, nil
+}
+
+func TestH2AExecutor_SendAndCapture(t *testing.T) {
+	sender := &fakeSender{output: "PASS\nok  ./...\n"}
+	e := NewH2AExecutor(sender)
+
+	out, err := e.Execute(WithRequester(context.Background(), "eng-suzuki"), &aoi.Task{
+		Type: TypeH2A,
+		Parameters: map[string]interface{}{
+			"agent_id": "eng-suzuki",
+			"command":  "go test ./...",
+			"wait_ms":  float64(10),
+		},
+	})
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if out != sender.output {
+		t.Errorf("expected captured output, got %q", out)
+	}
+	if len(sender.sent) != 1 || sender.sent[0] != "eng-suzuki:go test ./..." {
+		t.Errorf("unexpected sent commands: %v", sender.sent)
+	}
+}
+
+func TestH2AExecutor_SendError(t *testing.T) {
+	e := NewH2AExecutor(&fakeSender{sendErr: errors.New("no session")})
+
+	_, err := e.Execute(WithRequester(context.Background(), "eng"), &aoi.Task{
+		Parameters: map[string]interface{}{"agent_id": "eng", "command": "ls"},
+	})
+	if err == nil || errors.Is(err, ErrDenied) {
+		t.Errorf("expected send error to propagate, got %v", err)
+	}
+}
+
+func TestH2AExecutor_Denied(t *testing.T) {
+	sender := &fakeSender{}
+	e := NewH2AExecutor(sender)
+
+	for _, requester := range []string{"", "qa-tanaka"} {
+		_, err := e.Execute(WithRequester(context.Background(), requester), &aoi.Task{
+			Parameters: map[string]interface{}{"agent_id": "eng-suzuki", "command": "cat .env"},
+		})
+		if !errors.Is(err, ErrDenied) {
+			t.Errorf("requester %q: expected ErrDenied, got %v", requester, err)
+		}
+	}
+	if len(sender.sent) != 0 {
+		t.Errorf("expected nothing to be sent, got %v", sender.sent)
+	}
+}
+
+func TestH2AExecutor_RespectsContext(t *testing.T) {
+	e := NewH2AExecutor(&fakeSender{})
+
+	ctx, cancel := context.WithTimeout(WithRequester(context.Background(), "eng"), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := e.Execute(ctx, &aoi.Task{
+		Parameters: map[string]interface{}{"agent_id": "eng", "command": "sleep 10", "wait_ms": float64(5000)},
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+}
@@ -0,0 +1,658 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/aoi-protocol/aoi/internal/rpc"
+	"github.com/aoi-protocol/aoi/pkg/aoi"
+)
+
+// State represents the lifecycle state of a task
+type State string
+
+const (
+	StateQueued    State = "queued"
+	StateRunning   State = "running"
+	StateSucceeded State = "succeeded"
+	StateFailed    State = "failed"
+	StateCancelled State = "cancelled"
+	StateTimedOut  State = "timed_out"
+)
+
+// IsTerminal reports whether the task will not change state again
+func (s State) IsTerminal() bool {
+	switch s {
+	case StateSucceeded, StateFailed, StateCancelled, StateTimedOut:
+		return true
+	default:
+		return false
+	}
+}
+
+// Task manager errors
+var (
+	ErrTaskNotFound   = errors.New("task not found")
+	ErrQueueFull      = errors.New("task queue is full")
+	ErrDuplicateTask  = errors.New("task ID already exists")
+	ErrManagerStopped = errors.New("task manager is stopped")
+	ErrNotCancellable = errors.New("task is already finished")
+	ErrDenied         = errors.New("task not permitted for requester")
+)
+
+// requesterKey is the context key for the agent that submitted a task
+type requesterKey struct{}
+
+// WithRequester returns a context carrying the agent that submitted a task
+func WithRequester(ctx context.Context, agentID string) context.Context {
+	return context.WithValue(ctx, requesterKey{}, agentID)
+}
+
+// Requester returns the proven agent that submitted the task being executed,
+// or "" when it is unknown
+func Requester(ctx context.Context) string {
+	agentID, _ := ctx.Value(requesterKey{}).(string)
+	return agentID
+}
+
+// Executor runs a single task and returns its output
+type Executor interface {
+	Execute(ctx context.Context, t *aoi.Task) (string, error)
+}
+
+// ExecutorFunc adapts a function to the Executor interface
+type ExecutorFunc func(ctx context.Context, t *aoi.Task) (string, error)
+
+// Execute calls f(ctx, t)
+func (f ExecutorFunc) Execute(ctx context.Context, t *aoi.Task) (string, error) {
+	return f(ctx, t)
+}
+
+// Info is a point-in-time snapshot of a task's state
+type Info struct {
+	TaskID     string                 `json:"task_id"`
+	Type       string                 `json:"type"`
+	State      State                  `json:"state"`
+	Async      bool                   `json:"async"`
+	Requester  string                 `json:"requester,omitempty"`
+	Timeout    int                    `json:"timeout,omitempty"`
+	Output     string                 `json:"output,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+	CreatedAt  time.Time              `json:"created_at"`
+	StartedAt  *time.Time             `json:"started_at,omitempty"`
+	FinishedAt *time.Time             `json:"finished_at,omitempty"`
+}
+
+// Result converts the snapshot into the wire-level TaskResult
+func (i *Info) Result() aoi.TaskResult {
+	result := aoi.TaskResult{
+		TaskID: i.TaskID,
+		Status: string(i.State),
+		Output: i.Output,
+		Error:  i.Error,
+		Metadata: map[string]interface{}{
+			"type":       i.Type,
+			"created_at": i.CreatedAt,
+		},
+	}
+	if i.StartedAt != nil {
+		result.Metadata["started_at"] = *i.StartedAt
+	}
+	if i.FinishedAt != nil {
+		result.Metadata["finished_at"] = *i.FinishedAt
+		if i.StartedAt != nil {
+			result.Metadata["duration_ms"] = i.FinishedAt.Sub(*i.StartedAt).Milliseconds()
+		}
+	}
+	return result
+}
+
+// record is the manager's internal view of a task
+type record struct {
+	task       aoi.Task
+	requester  string
+	state      State
+	output     string
+	err        string
+	createdAt  time.Time
+	startedAt  time.Time
+	finishedAt time.Time
+	cancel     context.CancelFunc
+	done       chan struct{}
+}
+
+func (r *record) snapshot() *Info {
+	info := &Info{
+		TaskID:     r.task.ID,
+		Type:       r.task.Type,
+		State:      r.state,
+		Async:      r.task.Async,
+		Requester:  r.requester,
+		Timeout:    r.task.Timeout,
+		Output:     r.output,
+		Error:      r.err,
+		Parameters: r.task.Parameters,
+		CreatedAt:  r.createdAt,
+	}
+	if !r.startedAt.IsZero() {
+		t := r.startedAt
+		info.StartedAt = &t
+	}
+	if !r.finishedAt.IsZero() {
+		t := r.finishedAt
+		info.FinishedAt = &t
+	}
+	return info
+}
+
+// Config holds task manager configuration
+type Config struct {
+	// Workers is the number of tasks executed concurrently
+	Workers int
+	// QueueSize is the maximum number of tasks waiting for a worker
+	QueueSize int
+	// DefaultTimeout applies to tasks that do not set Timeout (0 = no limit)
+	DefaultTimeout time.Duration
+	// Retention is how long finished tasks are kept for aoi.task.get/list
+	Retention time.Duration
+}
+
+// DefaultConfig returns sensible defaults for the task manager
+func DefaultConfig() Config {
+	return Config{
+		Workers:   4,
+		QueueSize: 100,
+		Retention: 24 * time.Hour,
+	}
+}
+
+// OutputFilter rewrites the output or error of a task before it is stored,
+// e.g. to redact secrets
+type OutputFilter func(requester string, t *aoi.Task, text string) string
+
+// Scope returns the agent calling a JSON-RPC method and whether it may see
+// the tasks of every requester
+type Scope func(ctx context.Context) (agentID string, all bool)
+
+// ReadFilter rewrites a task snapshot before it is returned to the JSON-RPC
+// caller in ctx, e.g. to reduce its output to what the caller may see
+type ReadFilter func(ctx context.Context, info *Info) *Info
+
+// Manager queues tasks and runs them on a fixed pool of workers
+type Manager struct {
+	config     Config
+	executor   Executor
+	filter     OutputFilter
+	scope      Scope
+	read       ReadFilter
+	tasks      map[string]*record
+	queue      chan *record
+	onComplete []func(*Info)
+	stopped    bool
+	stop       chan struct{}
+	wg         sync.WaitGroup
+	mu         sync.RWMutex
+}
+
+// NewManager creates a task manager and starts its workers.
+// A nil executor fails every task until one is set with SetExecutor.
+func NewManager(config Config, executor Executor) *Manager {
+	defaults := DefaultConfig()
+	if config.Workers <= 0 {
+		config.Workers = defaults.Workers
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = defaults.QueueSize
+	}
+	if config.Retention <= 0 {
+		config.Retention = defaults.Retention
+	}
+
+	m := &Manager{
+		config:   config,
+		executor: executor,
+		tasks:    make(map[string]*record),
+		queue:    make(chan *record, config.QueueSize),
+		stop:     make(chan struct{}),
+	}
+
+	for i := 0; i < config.Workers; i++ {
+		m.wg.Add(1)
+		go m.worker()
+	}
+	go m.cleanupLoop()
+
+	return m
+}
+
+// SetExecutor replaces the executor used for tasks that have not started yet
+func (m *Manager) SetExecutor(executor Executor) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.executor = executor
+}
+
+// SetOutputFilter filters what executed tasks output and fail with before it
+// is kept for aoi.task.get/list and passed to OnComplete callbacks; nil keeps
+// it unchanged
+func (m *Manager) SetOutputFilter(filter OutputFilter) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.filter = filter
+}
+
+// SetScope limits aoi.task.get, list and cancel to the tasks submitted by
+// the caller that scope returns, unless it may see all. Other tasks are
+// reported as not found. Without a scope every caller sees every task.
+func (m *Manager) SetScope(scope Scope) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.scope = scope
+}
+
+// SetReadFilter sets the filter applied to the tasks returned by
+// aoi.task.get, list and cancel; nil returns them unchanged
+func (m *Manager) SetReadFilter(filter ReadFilter) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.read = filter
+}
+
+// readAs applies the read filter for the JSON-RPC caller in ctx
+func (m *Manager) readAs(ctx context.Context, info *Info) *Info {
+	m.mu.RLock()
+	filter := m.read
+	m.mu.RUnlock()
+	if filter == nil {
+		return info
+	}
+	return filter(ctx, info)
+}
+
+// visibleTo returns whether the JSON-RPC caller in ctx may see a task
+func (m *Manager) visibleTo(ctx context.Context, info *Info) bool {
+	m.mu.RLock()
+	scope := m.scope
+	m.mu.RUnlock()
+	if scope == nil {
+		return true
+	}
+	agentID, all := scope(ctx)
+	return all || info.Requester == agentID
+}
+
+// OnComplete registers a callback invoked after a task reaches a terminal state
+func (m *Manager) OnComplete(fn func(*Info)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onComplete = append(m.onComplete, fn)
+}
+
+// Submit queues a task for execution. An ID is generated if the task has none.
+func (m *Manager) Submit(t aoi.Task) (*Info, error) {
+	return m.SubmitFrom("", t)
+}
+
+// SubmitFrom queues a task submitted by the proven agent requester, which
+// executors can check with Requester
+func (m *Manager) SubmitFrom(requester string, t aoi.Task) (*Info, error) {
+	if t.ID == "" {
+		t.ID = uuid.New().String()
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.stopped {
+		return nil, ErrManagerStopped
+	}
+	if _, exists := m.tasks[t.ID]; exists {
+		return nil, fmt.Errorf("%w: %s", ErrDuplicateTask, t.ID)
+	}
+
+	rec := &record{
+		task:      t,
+		requester: requester,
+		state:     StateQueued,
+		createdAt: time.Now(),
+		done:      make(chan struct{}),
+	}
+
+	select {
+	case m.queue <- rec:
+	default:
+		return nil, ErrQueueFull
+	}
+
+	m.tasks[t.ID] = rec
+	return rec.snapshot(), nil
+}
+
+// Wait blocks until the task finishes or ctx is done
+func (m *Manager) Wait(ctx context.Context, id string) (*Info, error) {
+	m.mu.RLock()
+	rec, ok := m.tasks[id]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrTaskNotFound, id)
+	}
+
+	select {
+	case <-rec.done:
+		return m.Get(id)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Get returns a snapshot of a task
+func (m *Manager) Get(id string) (*Info, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	rec, ok := m.tasks[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrTaskNotFound, id)
+	}
+	return rec.snapshot(), nil
+}
+
+// List returns snapshots of all tasks, oldest first, optionally filtered by state
+func (m *Manager) List(state State) []*Info {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make([]*Info, 0, len(m.tasks))
+	for _, rec := range m.tasks {
+		if state == "" || rec.state == state {
+			result = append(result, rec.snapshot())
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+	return result
+}
+
+// Cancel stops a queued or running task
+func (m *Manager) Cancel(id string) (*Info, error) {
+	m.mu.Lock()
+	rec, ok := m.tasks[id]
+	if !ok {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("%w: %s", ErrTaskNotFound, id)
+	}
+
+	switch rec.state {
+	case StateQueued:
+		// The worker that dequeues it will see the terminal state and skip it.
+		m.finishLocked(rec, StateCancelled, "", "cancelled before start")
+		info := rec.snapshot()
+		m.mu.Unlock()
+		m.notifyComplete(info)
+		return info, nil
+	case StateRunning:
+		rec.cancel()
+		m.mu.Unlock()
+		return m.Wait(context.Background(), id)
+	default:
+		m.mu.Unlock()
+		return nil, ErrNotCancellable
+	}
+}
+
+// Stop cancels queued and running tasks and shuts down the workers
+func (m *Manager) Stop() {
+	m.mu.Lock()
+	if m.stopped {
+		m.mu.Unlock()
+		return
+	}
+	m.stopped = true
+	var cancelled []*Info
+	for _, rec := range m.tasks {
+		switch rec.state {
+		case StateRunning:
+			rec.cancel()
+		case StateQueued:
+			// No worker will dequeue it, so finish it here to release waiters.
+			m.finishLocked(rec, StateCancelled, "", "cancelled: task manager stopped")
+			cancelled = append(cancelled, rec.snapshot())
+		}
+	}
+	close(m.stop)
+	m.mu.Unlock()
+
+	for _, info := range cancelled {
+		m.notifyComplete(info)
+	}
+	m.wg.Wait()
+}
+
+// worker runs queued tasks until the manager stops
+func (m *Manager) worker() {
+	defer m.wg.Done()
+	for {
+		select {
+		case <-m.stop:
+			return
+		case rec := <-m.queue:
+			m.run(rec)
+		}
+	}
+}
+
+// run executes a single task, enforcing its timeout
+func (m *Manager) run(rec *record) {
+	m.mu.Lock()
+	if rec.state != StateQueued {
+		m.mu.Unlock()
+		return
+	}
+	if m.stopped {
+		m.finishLocked(rec, StateCancelled, "", "task manager stopped")
+		m.mu.Unlock()
+		return
+	}
+
+	timeout := m.config.DefaultTimeout
+	if rec.task.Timeout > 0 {
+		timeout = time.Duration(rec.task.Timeout) * time.Second
+	}
+
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), timeout)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+	defer cancel()
+	ctx = WithRequester(ctx, rec.requester)
+
+	rec.state = StateRunning
+	rec.startedAt = time.Now()
+	rec.cancel = cancel
+	executor, filter := m.executor, m.filter
+	t := rec.task
+	m.mu.Unlock()
+
+	var output, errMsg string
+	var err error
+	if executor == nil {
+		err = fmt.Errorf("no executor configured for task type %q", t.Type)
+	} else {
+		output, err = executor.Execute(ctx, &t)
+	}
+	if err != nil {
+		errMsg = err.Error()
+	}
+	if filter != nil {
+		output = filter(rec.requester, &t, output)
+		errMsg = filter(rec.requester, &t, errMsg)
+	}
+
+	m.mu.Lock()
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		m.finishLocked(rec, StateTimedOut, output, fmt.Sprintf("task exceeded timeout of %s", timeout))
+	case errors.Is(ctx.Err(), context.Canceled):
+		m.finishLocked(rec, StateCancelled, output, "cancelled while running")
+	case err != nil:
+		m.finishLocked(rec, StateFailed, output, errMsg)
+	default:
+		m.finishLocked(rec, StateSucceeded, output, "")
+	}
+	info := rec.snapshot()
+	m.mu.Unlock()
+
+	m.notifyComplete(info)
+}
+
+// finishLocked moves a task into a terminal state. Caller must hold m.mu.
+func (m *Manager) finishLocked(rec *record, state State, output, errMsg string) {
+	rec.state = state
+	rec.output = output
+	rec.err = errMsg
+	rec.finishedAt = time.Now()
+	close(rec.done)
+}
+
+func (m *Manager) notifyComplete(info *Info) {
+	m.mu.RLock()
+	callbacks := make([]func(*Info), len(m.onComplete))
+	copy(callbacks, m.onComplete)
+	m.mu.RUnlock()
+
+	for _, fn := range callbacks {
+		fn(info)
+	}
+}
+
+// cleanupLoop periodically drops finished tasks older than the retention period
+func (m *Manager) cleanupLoop() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+			m.mu.Lock()
+			cutoff := time.Now().Add(-m.config.Retention)
+			for id, rec := range m.tasks {
+				if rec.state.IsTerminal() && rec.finishedAt.Before(cutoff) {
+					delete(m.tasks, id)
+				}
+			}
+			m.mu.Unlock()
+		}
+	}
+}
+
+// RegisterMethods registers the aoi.task.* methods
+func (m *Manager) RegisterMethods(reg *rpc.Registry) {
+	taskID := rpc.RequiredParam("task_id", "string", "Task ID")
+	info := rpc.Result("task", rpc.Type("object"))
+	reg.MustRegister(
+		rpc.Method{
+			Name:     "aoi.task.get",
+			Summary:  "Get the current state of a task",
+			Params:   []rpc.ContentDescriptor{taskID},
+			Result:   info,
+			Resource: "tasks/get",
+			Action:   rpc.ActionRead,
+			Handler:  m.handleGet,
+		},
+		rpc.Method{
+			Name:     "aoi.task.list",
+			Summary:  "List tracked tasks",
+			Params:   []rpc.ContentDescriptor{rpc.Param("state", "string", "Filter by state")},
+			Result:   rpc.Result("tasks", rpc.Type("object")),
+			Resource: "tasks/list",
+			Action:   rpc.ActionRead,
+			Handler:  m.handleList,
+		},
+		rpc.Method{
+			Name:     "aoi.task.cancel",
+			Summary:  "Cancel a queued or running task",
+			Params:   []rpc.ContentDescriptor{taskID},
+			Result:   info,
+			Resource: "tasks/cancel",
+			Action:   rpc.ActionExecute,
+			Handler:  m.handleCancel,
+		},
+	)
+}
+
+// getFor returns a task the caller in ctx may see
+func (m *Manager) getFor(ctx context.Context, id string) (*Info, error) {
+	info, err := m.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if !m.visibleTo(ctx, info) {
+		return nil, fmt.Errorf("%w: %s", ErrTaskNotFound, id)
+	}
+	return info, nil
+}
+
+func (m *Manager) handleGet(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p struct {
+		TaskID string `json:"task_id"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, fmt.Errorf("invalid params: %w", err)
+	}
+	info, err := m.getFor(ctx, p.TaskID)
+	if err != nil {
+		return nil, err
+	}
+	return m.readAs(ctx, info), nil
+}
+
+func (m *Manager) handleList(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p struct {
+		State string `json:"state,omitempty"`
+	}
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, fmt.Errorf("invalid params: %w", err)
+		}
+	}
+	tasks := []*Info{}
+	for _, info := range m.List(State(p.State)) {
+		if m.visibleTo(ctx, info) {
+			tasks = append(tasks, m.readAs(ctx, info))
+		}
+	}
+	return map[string]interface{}{
+		"tasks": tasks,
+		"count": len(tasks),
+	}, nil
+}
+
+func (m *Manager) handleCancel(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p struct {
+		TaskID string `json:"task_id"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, fmt.Errorf("invalid params: %w", err)
+	}
+	if _, err := m.getFor(ctx, p.TaskID); err != nil {
+		return nil, err
+	}
+	info, err := m.Cancel(p.TaskID)
+	if err != nil {
+		return nil, err
+	}
+	return m.readAs(ctx, info), nil
+}
@@ -0,0 +1,415 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aoi-protocol/aoi/internal/rpc"
+	"github.com/aoi-protocol/aoi/pkg/aoi"
+)
+
+func echoExecutor() Executor {
+	return ExecutorFunc(func(ctx context.Context, t *aoi.Task) (string, error) {
+		return "ran " + t.Type, nil
+	})
+}
+
+// blockingExecutor runs until its context is cancelled or release is closed.
+func blockingExecutor(started chan<- string, release <-chan struct{}) Executor {
+	return ExecutorFunc(func(ctx context.Context, t *aoi.Task) (string, error) {
+		started <- t.ID
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-release:
+			return "released", nil
+		}
+	})
+}
+
+func waitFor(t *testing.T, m *Manager, id string) *Info {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	info, err := m.Wait(ctx, id)
+	if err != nil {
+		t.Fatalf("wait for %s: %v", id, err)
+	}
+	return info
+}
+
+func TestManager_SubmitAndSucceed(t *testing.T) {
+	m := NewManager(Config{}, echoExecutor())
+	defer m.Stop()
+
+	info, err := m.Submit(aoi.Task{ID: "task-1", Type: "analyze"})
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	if info.State != StateQueued {
+		t.Errorf("expected queued state on submit, got %s", info.State)
+	}
+
+	info = waitFor(t, m, "task-1")
+	if info.State != StateSucceeded {
+		t.Errorf("expected succeeded, got %s (%s)", info.State, info.Error)
+	}
+	if info.Output != "ran analyze" {
+		t.Errorf("unexpected output %q", info.Output)
+	}
+	if info.StartedAt == nil || info.FinishedAt == nil {
+		t.Error("expected start and finish timestamps")
+	}
+}
+
+func TestManager_GeneratesID(t *testing.T) {
+	m := NewManager(Config{}, echoExecutor())
+	defer m.Stop()
+
+	info, err := m.Submit(aoi.Task{Type: "analyze"})
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	if info.TaskID == "" {
+		t.Error("expected a generated task ID")
+	}
+}
+
+func TestManager_DuplicateID(t *testing.T) {
+	m := NewManager(Config{}, echoExecutor())
+	defer m.Stop()
+
+	if _, err := m.Submit(aoi.Task{ID: "dup"}); err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	if _, err := m.Submit(aoi.Task{ID: "dup"}); !errors.Is(err, ErrDuplicateTask) {
+		t.Errorf("expected ErrDuplicateTask, got %v", err)
+	}
+}
+
+func TestManager_Failure(t *testing.T) {
+	m := NewManager(Config{}, ExecutorFunc(func(ctx context.Context, t *aoi.Task) (string, error) {
+		return "partial", errors.New("boom")
+	}))
+	defer m.Stop()
+
+	m.Submit(aoi.Task{ID: "task-1"})
+	info := waitFor(t, m, "task-1")
+	if info.State != StateFailed {
+		t.Errorf("expected failed, got %s", info.State)
+	}
+	if info.Error != "boom" || info.Output != "partial" {
+		t.Errorf("unexpected error/output: %q / %q", info.Error, info.Output)
+	}
+}
+
+func TestManager_OutputFilter(t *testing.T) {
+	m := NewManager(Config{}, ExecutorFunc(func(ctx context.Context, t *aoi.Task) (string, error) {
+		return "token=secret", errors.New("login as secret failed")
+	}))
+	defer m.Stop()
+	var requesters []string
+	m.SetOutputFilter(func(requester string, t *aoi.Task, text string) string {
+		requesters = append(requesters, requester)
+		return strings.ReplaceAll(text, "secret", "***")
+	})
+	done := make(chan *Info, 1)
+	m.OnComplete(func(info *Info) { done <- info })
+
+	m.SubmitFrom("pm-agent", aoi.Task{ID: "task-1"})
+	var completed *Info
+	select {
+	case completed = <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("completion callback not called")
+	}
+	info, _ := m.Get("task-1")
+	if info.Output != "token=***" || info.Error != "login as *** failed" {
+		t.Errorf("expected filtered output and error, got %q / %q", info.Output, info.Error)
+	}
+	if completed.Output != info.Output || completed.Error != info.Error {
+		t.Errorf("expected callbacks to see the filtered task, got %+v", completed)
+	}
+	if len(requesters) != 2 || requesters[0] != "pm-agent" {
+		t.Errorf("expected the filter to get the requester, got %v", requesters)
+	}
+}
+
+func TestManager_NoExecutor(t *testing.T) {
+	m := NewManager(Config{}, nil)
+	defer m.Stop()
+
+	m.Submit(aoi.Task{ID: "task-1", Type: "shell"})
+	info := waitFor(t, m, "task-1")
+	if info.State != StateFailed {
+		t.Errorf("expected failed without executor, got %s", info.State)
+	}
+}
+
+func TestManager_Timeout(t *testing.T) {
+	started := make(chan string, 1)
+	m := NewManager(Config{DefaultTimeout: 50 * time.Millisecond}, blockingExecutor(started, nil))
+	defer m.Stop()
+
+	m.Submit(aoi.Task{ID: "slow"})
+	info := waitFor(t, m, "slow")
+	if info.State != StateTimedOut {
+		t.Errorf("expected timed_out, got %s", info.State)
+	}
+}
+
+func TestManager_CancelRunning(t *testing.T) {
+	started := make(chan string, 1)
+	m := NewManager(Config{Workers: 1}, blockingExecutor(started, nil))
+	defer m.Stop()
+
+	m.Submit(aoi.Task{ID: "long"})
+	<-started
+
+	info, err := m.Cancel("long")
+	if err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	if info.State != StateCancelled {
+		t.Errorf("expected cancelled, got %s", info.State)
+	}
+
+	if _, err := m.Cancel("long"); !errors.Is(err, ErrNotCancellable) {
+		t.Errorf("expected ErrNotCancellable on second cancel, got %v", err)
+	}
+}
+
+func TestManager_CancelQueued(t *testing.T) {
+	started := make(chan string, 2)
+	release := make(chan struct{})
+	m := NewManager(Config{Workers: 1}, blockingExecutor(started, release))
+	defer m.Stop()
+
+	m.Submit(aoi.Task{ID: "first"})
+	<-started
+	m.Submit(aoi.Task{ID: "second"})
+
+	info, err := m.Cancel("second")
+	if err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	if info.State != StateCancelled {
+		t.Errorf("expected cancelled, got %s", info.State)
+	}
+
+	close(release)
+	if got := waitFor(t, m, "first").State; got != StateSucceeded {
+		t.Errorf("expected first task to succeed, got %s", got)
+	}
+	select {
+	case id := <-started:
+		t.Errorf("cancelled task %s should never start", id)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestManager_StopFinishesQueued(t *testing.T) {
+	started := make(chan string, 2)
+	m := NewManager(Config{Workers: 1}, blockingExecutor(started, nil))
+
+	m.Submit(aoi.Task{ID: "running"})
+	<-started
+	m.Submit(aoi.Task{ID: "queued"})
+	m.Stop()
+
+	for _, id := range []string{"running", "queued"} {
+		if got := waitFor(t, m, id).State; got != StateCancelled {
+			t.Errorf("expected %s to be cancelled on stop, got %s", id, got)
+		}
+	}
+}
+
+func TestManager_QueueFull(t *testing.T) {
+	started := make(chan string, 2)
+	release := make(chan struct{})
+	defer close(release)
+	m := NewManager(Config{Workers: 1, QueueSize: 1}, blockingExecutor(started, release))
+	defer m.Stop()
+
+	m.Submit(aoi.Task{ID: "running"})
+	<-started
+	if _, err := m.Submit(aoi.Task{ID: "queued"}); err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	if _, err := m.Submit(aoi.Task{ID: "overflow"}); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("expected ErrQueueFull, got %v", err)
+	}
+}
+
+func TestManager_OnComplete(t *testing.T) {
+	m := NewManager(Config{}, echoExecutor())
+	defer m.Stop()
+
+	var mu sync.Mutex
+	var completed []*Info
+	done := make(chan struct{})
+	m.OnComplete(func(info *Info) {
+		mu.Lock()
+		completed = append(completed, info)
+		mu.Unlock()
+		close(done)
+	})
+
+	m.Submit(aoi.Task{ID: "task-1", Async: true})
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("completion callback not called")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if completed[0].TaskID != "task-1" || !completed[0].Async {
+		t.Errorf("unexpected completion info: %+v", completed[0])
+	}
+}
+
+func TestManager_ListFilter(t *testing.T) {
+	m := NewManager(Config{}, echoExecutor())
+	defer m.Stop()
+
+	m.Submit(aoi.Task{ID: "a"})
+	m.Submit(aoi.Task{ID: "b"})
+	waitFor(t, m, "a")
+	waitFor(t, m, "b")
+
+	if got := len(m.List("")); got != 2 {
+		t.Errorf("expected 2 tasks, got %d", got)
+	}
+	if got := len(m.List(StateSucceeded)); got != 2 {
+		t.Errorf("expected 2 succeeded tasks, got %d", got)
+	}
+	if got := len(m.List(StateRunning)); got != 0 {
+		t.Errorf("expected 0 running tasks, got %d", got)
+	}
+}
+
+func TestManager_RegisterMethods(t *testing.T) {
+	m := NewManager(Config{}, echoExecutor())
+	defer m.Stop()
+	reg := rpc.NewRegistry()
+	m.RegisterMethods(reg)
+	ctx := context.Background()
+
+	m.Submit(aoi.Task{ID: "task-1", Type: "analyze"})
+	waitFor(t, m, "task-1")
+
+	result, err := reg.Call(ctx, "aoi.task.get", json.RawMessage(`{"task_id":"task-1"}`))
+	if err != nil {
+		t.Fatalf("aoi.task.get: %v", err)
+	}
+	if info := result.(*Info); info.State != StateSucceeded {
+		t.Errorf("expected succeeded, got %s", info.State)
+	}
+
+	if _, err := reg.Call(ctx, "aoi.task.list", nil); err != nil {
+		t.Errorf("aoi.task.list: %v", err)
+	}
+	if _, err := reg.Call(ctx, "aoi.task.get", json.RawMessage(`{"task_id":"missing"}`)); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("expected ErrTaskNotFound, got %v", err)
+	}
+	if _, err := reg.Call(ctx, "aoi.task.unknown", nil); err == nil {
+		t.Error("expected error for unknown method")
+	}
+}
+
+type callerKey struct{}
+
+func TestManager_Scope(t *testing.T) {
+	m := NewManager(Config{}, echoExecutor())
+	defer m.Stop()
+	m.SetScope(func(ctx context.Context) (string, bool) {
+		agentID, _ := ctx.Value(callerKey{}).(string)
+		return agentID, agentID == "admin"
+	})
+	reg := rpc.NewRegistry()
+	m.RegisterMethods(reg)
+	as := func(agentID string) context.Context {
+		return context.WithValue(context.Background(), callerKey{}, agentID)
+	}
+
+	m.SubmitFrom("pm-agent", aoi.Task{ID: "task-1"})
+	waitFor(t, m, "task-1")
+
+	if _, err := reg.Call(as("pm-agent"), "aoi.task.get", json.RawMessage(`{"task_id":"task-1"}`)); err != nil {
+		t.Errorf("expected the requester to get its task, got %v", err)
+	}
+	if _, err := reg.Call(as("admin"), "aoi.task.get", json.RawMessage(`{"task_id":"task-1"}`)); err != nil {
+		t.Errorf("expected an admin to get any task, got %v", err)
+	}
+	for _, method := range []string{"aoi.task.get", "aoi.task.cancel"} {
+		if _, err := reg.Call(as("qa-agent"), method, json.RawMessage(`{"task_id":"task-1"}`)); !errors.Is(err, ErrTaskNotFound) {
+			t.Errorf("%s: expected ErrTaskNotFound for another caller, got %v", method, err)
+		}
+	}
+
+	count := func(agentID string) int {
+		result, err := reg.Call(as(agentID), "aoi.task.list", nil)
+		if err != nil {
+			t.Fatalf("aoi.task.list: %v", err)
+		}
+		return result.(map[string]interface{})["count"].(int)
+	}
+	if n := count("pm-agent"); n != 1 {
+		t.Errorf("expected the requester to list 1 task, got %d", n)
+	}
+	if n := count("qa-agent"); n != 0 {
+		t.Errorf("expected another caller to list no tasks, got %d", n)
+	}
+}
+
+func TestManager_ReadFilter(t *testing.T) {
+	m := NewManager(Config{}, echoExecutor())
+	defer m.Stop()
+	m.SetReadFilter(func(ctx context.Context, info *Info) *Info {
+		if agentID, _ := ctx.Value(callerKey{}).(string); agentID == "pm-agent" {
+			filtered := *info
+			filtered.Output = "withheld"
+			return &filtered
+		}
+		return info
+	})
+	reg := rpc.NewRegistry()
+	m.RegisterMethods(reg)
+	ctx := context.WithValue(context.Background(), callerKey{}, "pm-agent")
+
+	m.Submit(aoi.Task{ID: "task-1", Type: "echo"})
+	waitFor(t, m, "task-1")
+
+	result, err := reg.Call(ctx, "aoi.task.get", json.RawMessage(`{"task_id":"task-1"}`))
+	if err != nil || result.(*Info).Output != "withheld" {
+		t.Errorf("expected aoi.task.get to be filtered, got %+v (%v)", result, err)
+	}
+	result, err = reg.Call(ctx, "aoi.task.list", nil)
+	if err != nil || result.(map[string]interface{})["tasks"].([]*Info)[0].Output != "withheld" {
+		t.Errorf("expected aoi.task.list to be filtered, got %+v (%v)", result, err)
+	}
+	if info, _ := m.Get("task-1"); info.Output == "withheld" {
+		t.Error("expected the stored task to stay intact")
+	}
+}
+
+func TestInfo_Result(t *testing.T) {
+	start := time.Now()
+	finish := start.Add(1500 * time.Millisecond)
+	info := &Info{TaskID: "t", Type: "shell", State: StateSucceeded, Output: "ok", StartedAt: &start, FinishedAt: &finish}
+
+	result := info.Result()
+	if result.TaskID != "t" || result.Status != "succeeded" || result.Output != "ok" {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if result.Metadata["duration_ms"] != int64(1500) {
+		t.Errorf("expected duration_ms 1500, got %v", result.Metadata["duration_ms"])
+	}
+}
@@ -0,0 +1,194 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	"github.com/aoi-protocol/aoi/pkg/aoi"
+)
+
+// QueryRequest is the params of aoi.query
+type QueryRequest struct {
+	Query        string            `json:"query"`
+	FromAgent    string            `json:"from_agent"`
+	ToAgent      string            `json:"to_agent,omitempty"`
+	ContextScope string            `json:"context_scope,omitempty"`
+	Priority     string            `json:"priority,omitempty"`
+	Delegate     bool              `json:"delegate,omitempty"`
+	Metadata     map[string]string `json:"metadata,omitempty"`
+}
+
+// QueryResponse is the answer returned by an agent's secretary
+type QueryResponse struct {
+	Answer     string            `json:"answer"`
+	Confidence float64           `json:"confidence"`
+	Sources    []string          `json:"sources,omitempty"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
+}
+
+// DiscoverResult is the result of aoi.discover
+type DiscoverResult struct {
+	Agents []aoi.AgentIdentity `json:"agents"`
+	Count  int                 `json:"count"`
+}
+
+// DiscoverFilter is the params of aoi.discover. Zero fields match every agent.
+type DiscoverFilter struct {
+	Role aoi.AgentRole `json:"role,omitempty"`
+	// Capability matches a capability tag, a manifest action or an MCP tool
+	Capability string `json:"capability,omitempty"`
+	// Project matches a repository by full name ("aoi-protocol/aoi") or last segment ("aoi")
+	Project string `json:"project,omitempty"`
+	Issue   int    `json:"issue,omitempty"`
+	// Online selects agents that are (true) or are not (false) online
+	Online *bool `json:"online,omitempty"`
+}
+
+// StatusResult is the result of aoi.status. Agents is set for the agent itself;
+// the other fields are set when asking about another agent.
+type StatusResult struct {
+	Status         string     `json:"status"`
+	Agents         int        `json:"agents,omitempty"`
+	AgentID        string     `json:"agent_id,omitempty"`
+	LastSeen       *time.Time `json:"last_seen,omitempty"`
+	LeaseExpiresAt *time.Time `json:"lease_expires_at,omitempty"`
+	Connected      bool       `json:"connected,omitempty"`
+}
+
+// HeartbeatResult is the result of aoi.heartbeat
+type HeartbeatResult struct {
+	AgentID        string    `json:"agent_id"`
+	Status         string    `json:"status"`
+	LastSeen       time.Time `json:"last_seen"`
+	LeaseExpiresAt time.Time `json:"lease_expires_at"`
+}
+
+// AgentUpdate is the payload of an agent_update WebSocket message
+type AgentUpdate struct {
+	AgentID        string            `json:"agent_id"`
+	Status         string            `json:"status"`
+	PreviousStatus string            `json:"previous_status"`
+	LastSeen       *time.Time        `json:"last_seen,omitempty"`
+	Agent          aoi.AgentIdentity `json:"agent"`
+}
+
+// TaskInfo is a snapshot of a task tracked by the agent's task engine
+type TaskInfo struct {
+	TaskID     string                 `json:"task_id"`
+	Type       string                 `json:"type"`
+	State      string                 `json:"state"`
+	Async      bool                   `json:"async"`
+	Timeout    int                    `json:"timeout,omitempty"`
+	Output     string                 `json:"output,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+	CreatedAt  time.Time              `json:"created_at"`
+	StartedAt  *time.Time             `json:"started_at,omitempty"`
+	FinishedAt *time.Time             `json:"finished_at,omitempty"`
+}
+
+// TaskList is the result of aoi.task.list
+type TaskList struct {
+	Tasks []TaskInfo `json:"tasks"`
+	Count int        `json:"count"`
+}
+
+// Discover lists the agents known to the target agent (aoi.discover)
+func (c *Client) Discover(ctx context.Context) (*DiscoverResult, error) {
+	var result DiscoverResult
+	if err := c.Call(ctx, "aoi.discover", nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// FindAgents lists the agents matching filter (aoi.discover), e.g. the online
+// engineer agents that hold context for a repository
+func (c *Client) FindAgents(ctx context.Context, filter DiscoverFilter) (*DiscoverResult, error) {
+	var result DiscoverResult
+	if err := c.Call(ctx, "aoi.discover", filter, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Query asks a question (aoi.query). Leave ToAgent empty to query the agent itself.
+func (c *Client) Query(ctx context.Context, req QueryRequest) (*QueryResponse, error) {
+	var result QueryResponse
+	if err := c.Call(ctx, "aoi.query", req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Execute runs a task (aoi.execute). Async tasks return immediately in the queued state.
+func (c *Client) Execute(ctx context.Context, task aoi.Task) (*aoi.TaskResult, error) {
+	var result aoi.TaskResult
+	if err := c.Call(ctx, "aoi.execute", task, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Status returns the agent status (aoi.status)
+func (c *Client) Status(ctx context.Context) (*StatusResult, error) {
+	var result StatusResult
+	if err := c.Call(ctx, "aoi.status", nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// AgentStatus reports another agent's liveness as seen by the target agent (aoi.status)
+func (c *Client) AgentStatus(ctx context.Context, agentID string) (*StatusResult, error) {
+	var result StatusResult
+	if err := c.Call(ctx, "aoi.status", map[string]string{"agent_id": agentID}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Heartbeat renews agentID's lease on the target agent (aoi.heartbeat).
+// A zero ttl uses the target's default lease.
+func (c *Client) Heartbeat(ctx context.Context, agentID string, ttl time.Duration) (*HeartbeatResult, error) {
+	params := map[string]interface{}{"agent_id": agentID}
+	if ttl > 0 {
+		params["ttl"] = int(ttl / time.Second)
+	}
+	var result HeartbeatResult
+	if err := c.Call(ctx, "aoi.heartbeat", params, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetTask returns the current state of a task (aoi.task.get)
+func (c *Client) GetTask(ctx context.Context, taskID string) (*TaskInfo, error) {
+	var result TaskInfo
+	if err := c.Call(ctx, "aoi.task.get", map[string]string{"task_id": taskID}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ListTasks lists tracked tasks, optionally filtered by state (aoi.task.list)
+func (c *Client) ListTasks(ctx context.Context, state string) (*TaskList, error) {
+	var result TaskList
+	params := map[string]string{}
+	if state != "" {
+		params["state"] = state
+	}
+	if err := c.Call(ctx, "aoi.task.list", params, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// CancelTask cancels a queued or running task (aoi.task.cancel)
+func (c *Client) CancelTask(ctx context.Context, taskID string) (*TaskInfo, error) {
+	var result TaskInfo
+	if err := c.Call(ctx, "aoi.task.cancel", map[string]string{"task_id": taskID}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
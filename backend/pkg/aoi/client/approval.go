@@ -0,0 +1,96 @@
+package client
+
+import (
+	"context"
+	"time"
+)
+
+// Approval request statuses
+const (
+	ApprovalPending  = "pending"
+	ApprovalApproved = "approved"
+	ApprovalDenied   = "denied"
+	ApprovalExpired  = "expired"
+)
+
+// ApprovalRequest is a Human-in-the-Loop approval request
+type ApprovalRequest struct {
+	ID          string                 `json:"id"`
+	Requester   string                 `json:"requester"`
+	TaskType    string                 `json:"taskType"`
+	Description string                 `json:"description"`
+	Params      map[string]interface{} `json:"params"`
+	Status      string                 `json:"status"`
+	CreatedAt   time.Time              `json:"createdAt"`
+	UpdatedAt   time.Time              `json:"updatedAt"`
+	ExpiresAt   time.Time              `json:"expiresAt"`
+	ApprovedBy  string                 `json:"approvedBy,omitempty"`
+	DeniedBy    string                 `json:"deniedBy,omitempty"`
+	DenyReason  string                 `json:"denyReason,omitempty"`
+}
+
+// CreateApproval opens a new approval request (aoi.approval.create). An
+// authenticated server takes the requester from the token; empty means the caller.
+func (c *Client) CreateApproval(ctx context.Context, requester, taskType, description string, params map[string]interface{}) (*ApprovalRequest, error) {
+	p := map[string]interface{}{
+		"requester":   requester,
+		"taskType":    taskType,
+		"description": description,
+		"params":      params,
+	}
+	var result ApprovalRequest
+	if err := c.Call(ctx, "aoi.approval.create", p, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetApproval returns an approval request by ID (aoi.approval.get)
+func (c *Client) GetApproval(ctx context.Context, id string) (*ApprovalRequest, error) {
+	var result ApprovalRequest
+	if err := c.Call(ctx, "aoi.approval.get", map[string]string{"id": id}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ListApprovals lists approval requests with the given status; empty means pending (aoi.approval.list)
+func (c *Client) ListApprovals(ctx context.Context, status string) ([]ApprovalRequest, error) {
+	params := map[string]string{}
+	if status != "" {
+		params["status"] = status
+	}
+	var result []ApprovalRequest
+	if err := c.Call(ctx, "aoi.approval.list", params, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Approve approves a pending request (aoi.approval.approve). An authenticated
+// server takes the approver from the token; empty means the caller.
+func (c *Client) Approve(ctx context.Context, id, approvedBy string) (*ApprovalRequest, error) {
+	params := map[string]string{
+		"id":         id,
+		"approvedBy": approvedBy,
+	}
+	var result ApprovalRequest
+	if err := c.Call(ctx, "aoi.approval.approve", params, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Deny denies a pending request (aoi.approval.deny)
+func (c *Client) Deny(ctx context.Context, id, deniedBy, reason string) (*ApprovalRequest, error) {
+	params := map[string]string{
+		"id":       id,
+		"deniedBy": deniedBy,
+		"reason":   reason,
+	}
+	var result ApprovalRequest
+	if err := c.Call(ctx, "aoi.approval.deny", params, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
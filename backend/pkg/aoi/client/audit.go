@@ -0,0 +1,96 @@
+package client
+
+import (
+	"context"
+	"time"
+)
+
+// AuditEntry is an entry of the agent's audit log
+type AuditEntry struct {
+	ID        string                 `json:"id"`
+	Timestamp time.Time              `json:"timestamp"`
+	EventType string                 `json:"eventType"`
+	FromAgent string                 `json:"fromAgent"`
+	ToAgent   string                 `json:"toAgent"`
+	Summary   string                 `json:"summary"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+	Success   bool                   `json:"success"`
+	ErrorMsg  string                 `json:"errorMsg,omitempty"`
+}
+
+// AuditQuery filters aoi.audit.search
+type AuditQuery struct {
+	FromAgent      string     `json:"fromAgent,omitempty"`
+	ToAgent        string     `json:"toAgent,omitempty"`
+	EventType      string     `json:"eventType,omitempty"`
+	SearchTerm     string     `json:"searchTerm,omitempty"`
+	StartTime      *time.Time `json:"startTime,omitempty"`
+	EndTime        *time.Time `json:"endTime,omitempty"`
+	SuccessOnly    *bool      `json:"successOnly,omitempty"`
+	Limit          int        `json:"limit,omitempty"`
+	Offset         int        `json:"offset,omitempty"`
+	SortDescending bool       `json:"sortDescending,omitempty"`
+}
+
+// AuditSearchResult is a page of audit entries
+type AuditSearchResult struct {
+	Entries    []AuditEntry `json:"entries"`
+	TotalCount int          `json:"totalCount"`
+	Offset     int          `json:"offset"`
+	Limit      int          `json:"limit"`
+}
+
+// AuditStats is the result of aoi.audit.stats
+type AuditStats struct {
+	TotalEntries    int            `json:"totalEntries"`
+	MaxEntries      int            `json:"maxEntries"`
+	EventTypeCounts map[string]int `json:"eventTypeCounts"`
+	SuccessCount    int            `json:"successCount"`
+	FailureCount    int            `json:"failureCount"`
+}
+
+// LogAudit records an entry in the agent's audit log (aoi.audit.log).
+// ID and Timestamp are assigned by the agent.
+func (c *Client) LogAudit(ctx context.Context, entry AuditEntry) (*AuditEntry, error) {
+	var result AuditEntry
+	if err := c.Call(ctx, "aoi.audit.log", entry, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetAuditEntry returns an audit entry by ID (aoi.audit.get)
+func (c *Client) GetAuditEntry(ctx context.Context, id string) (*AuditEntry, error) {
+	var result AuditEntry
+	if err := c.Call(ctx, "aoi.audit.get", map[string]string{"id": id}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// SearchAudit searches the audit log (aoi.audit.search)
+func (c *Client) SearchAudit(ctx context.Context, q AuditQuery) (*AuditSearchResult, error) {
+	var result AuditSearchResult
+	if err := c.Call(ctx, "aoi.audit.search", q, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// RecentAudit returns the most recent audit entries (aoi.audit.recent)
+func (c *Client) RecentAudit(ctx context.Context, count int) ([]AuditEntry, error) {
+	var result []AuditEntry
+	if err := c.Call(ctx, "aoi.audit.recent", map[string]int{"count": count}, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// AuditStats returns audit log statistics (aoi.audit.stats)
+func (c *Client) AuditStats(ctx context.Context) (*AuditStats, error) {
+	var result AuditStats
+	if err := c.Call(ctx, "aoi.audit.stats", nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
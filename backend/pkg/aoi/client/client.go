@@ -0,0 +1,323 @@
+// Package client is a Go client for talking to AOI agents over JSON-RPC and WebSocket.
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/aoi-protocol/aoi/pkg/aoi"
+)
+
+// Endpoint paths exposed by every AOI agent
+const (
+	RPCPath       = "/api/v1/rpc"
+	WebSocketPath = "/api/v1/ws"
+)
+
+// DefaultTimeout is applied to calls whose context has no deadline
+const DefaultTimeout = 30 * time.Second
+
+// JSON-RPC 2.0 error codes returned by AOI agents
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+	CodeACLDenied      = -32000
+	CodeAgentNotFound  = -32001
+	// CodeUnauthenticated is returned when the agent rejects a request signature
+	CodeUnauthenticated = -32002
+)
+
+// Error is a JSON-RPC error returned by an agent
+type Error struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+// Error implements the error interface
+func (e *Error) Error() string {
+	return fmt.Sprintf("JSON-RPC error %d: %s", e.Code, e.Message)
+}
+
+// IsCode reports whether err is a JSON-RPC error with the given code
+func IsCode(err error, code int) bool {
+	var rpcErr *Error
+	return errors.As(err, &rpcErr) && rpcErr.Code == code
+}
+
+type rpcRequest struct {
+	JSONRPC string         `json:"jsonrpc"`
+	Method  string         `json:"method"`
+	Params  interface{}    `json:"params,omitempty"`
+	ID      interface{}    `json:"id,omitempty"`
+	Auth    *aoi.Signature `json:"auth,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+	ID      interface{}     `json:"id"`
+}
+
+// id returns the numeric request ID echoed by the agent
+func (r *rpcResponse) id() (int64, bool) {
+	n, ok := r.ID.(float64)
+	return int64(n), ok
+}
+
+// Client calls a single AOI agent
+type Client struct {
+	baseURL    string
+	agentID    string
+	httpClient *http.Client
+	timeout    time.Duration
+	requestID  int64
+	signingKey ed25519.PrivateKey
+	token      string
+}
+
+// Option is a functional option for configuring Client
+type Option func(*Client)
+
+// WithHTTPClient sets the HTTP client used for JSON-RPC calls
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = hc
+	}
+}
+
+// WithTimeout sets the timeout applied to calls whose context has no deadline
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = timeout
+	}
+}
+
+// WithAgentID identifies the caller to the agent (sent as X-Agent-ID)
+func WithAgentID(agentID string) Option {
+	return func(c *Client) {
+		c.agentID = agentID
+	}
+}
+
+// WithSigningKey signs every request with key as the agent set by WithAgentID,
+// for agents that require signed requests
+func WithSigningKey(key ed25519.PrivateKey) Option {
+	return func(c *Client) {
+		c.signingKey = key
+	}
+}
+
+// WithToken proves the caller's identity with a bearer token from the agent's
+// acl.tokens, on JSON-RPC calls and WebSocket subscriptions alike. Agents only
+// route calls to a subscription whose identity is proven.
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// New creates a client for the agent at baseURL (e.g. "http://eng-01:8080")
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: http.DefaultClient,
+		timeout:    DefaultTimeout,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// BaseURL returns the agent URL this client talks to
+func (c *Client) BaseURL() string {
+	return c.baseURL
+}
+
+// Call invokes method with params and decodes the result into result.
+// A JSON-RPC error returned by the agent is surfaced as *Error.
+func (c *Client) Call(ctx context.Context, method string, params interface{}, result interface{}) error {
+	req, err := c.newRequest(method, params, atomic.AddInt64(&c.requestID, 1))
+	if err != nil {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+
+	var rpcResp rpcResponse
+	if err := c.post(ctx, req, &rpcResp); err != nil {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+	return decodeResult(method, &rpcResp, result)
+}
+
+// Notify sends a JSON-RPC notification. The agent runs the method but sends
+// no response, so neither its result nor its errors are reported.
+func (c *Client) Notify(ctx context.Context, method string, params interface{}) error {
+	req, err := c.newRequest(method, params, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+	if err := c.post(ctx, req, nil); err != nil {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+	return nil
+}
+
+// BatchCall is one call of a batch. Batch fills in Result and Err.
+type BatchCall struct {
+	Method string
+	Params interface{}
+	Result interface{}
+	Err    error
+}
+
+// Batch sends calls in a single JSON-RPC batch request. The returned error
+// reports transport failures; per-call errors are set on each BatchCall.
+func (c *Client) Batch(ctx context.Context, calls []*BatchCall) error {
+	if len(calls) == 0 {
+		return nil
+	}
+
+	reqs := make([]rpcRequest, len(calls))
+	byID := make(map[int64]*BatchCall, len(calls))
+	for i, call := range calls {
+		id := atomic.AddInt64(&c.requestID, 1)
+		req, err := c.newRequest(call.Method, call.Params, id)
+		if err != nil {
+			return fmt.Errorf("batch: %s: %w", call.Method, err)
+		}
+		reqs[i] = req
+		byID[id] = call
+	}
+
+	var raw json.RawMessage
+	if err := c.post(ctx, reqs, &raw); err != nil {
+		return fmt.Errorf("batch: %w", err)
+	}
+
+	// A rejected batch (e.g. too large) is answered with a single error object.
+	var responses []rpcResponse
+	if err := json.Unmarshal(raw, &responses); err != nil {
+		var single rpcResponse
+		if json.Unmarshal(raw, &single) == nil && single.Error != nil {
+			return single.Error
+		}
+		return fmt.Errorf("batch: failed to decode response: %w", err)
+	}
+
+	for i := range responses {
+		id, ok := responses[i].id()
+		call := byID[id]
+		if !ok || call == nil {
+			continue
+		}
+		call.Err = decodeResult(call.Method, &responses[i], call.Result)
+		delete(byID, id)
+	}
+	for _, call := range byID {
+		call.Err = fmt.Errorf("%s: no response in batch", call.Method)
+	}
+	return nil
+}
+
+// newRequest builds a request, signing it when the client has a signing key.
+// A nil id makes a notification.
+func (c *Client) newRequest(method string, params interface{}, id interface{}) (rpcRequest, error) {
+	req := rpcRequest{JSONRPC: "2.0", Method: method, Params: params}
+	if id != nil {
+		req.ID = id
+	}
+	if c.signingKey == nil {
+		return req, nil
+	}
+
+	var raw json.RawMessage
+	if params != nil {
+		var err error
+		if raw, err = json.Marshal(params); err != nil {
+			return req, fmt.Errorf("failed to marshal params: %w", err)
+		}
+		req.Params = raw
+	}
+	sig, err := aoi.SignRequest(c.signingKey, c.agentID, method, raw)
+	if err != nil {
+		return req, err
+	}
+	req.Auth = sig
+	return req, nil
+}
+
+// post sends a JSON-RPC payload and decodes the response body into out.
+// A nil out expects no response body (notifications).
+func (c *Client) post(ctx context.Context, payload interface{}, out interface{}) error {
+	if _, ok := ctx.Deadline(); !ok && c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+RPCPath, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if c.agentID != "" {
+		httpReq.Header.Set("X-Agent-ID", c.agentID)
+	}
+	if c.token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	httpResp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+
+	switch {
+	case httpResp.StatusCode == http.StatusNoContent && out == nil:
+		return nil
+	case httpResp.StatusCode != http.StatusOK:
+		return fmt.Errorf("unexpected HTTP status %d", httpResp.StatusCode)
+	case out == nil:
+		return nil
+	}
+
+	if err := json.NewDecoder(httpResp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// decodeResult returns the response error or unmarshals its result into result
+func decodeResult(method string, resp *rpcResponse, result interface{}) error {
+	if resp.Error != nil {
+		return resp.Error
+	}
+
+	if result != nil && resp.Result != nil {
+		if err := json.Unmarshal(resp.Result, result); err != nil {
+			return fmt.Errorf("%s: failed to unmarshal result: %w", method, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,582 @@
+package client
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aoi-protocol/aoi/internal/identity"
+	"github.com/aoi-protocol/aoi/internal/protocol"
+	"github.com/aoi-protocol/aoi/internal/secretary"
+	"github.com/aoi-protocol/aoi/internal/signing"
+	"github.com/aoi-protocol/aoi/internal/task"
+	"github.com/aoi-protocol/aoi/pkg/aoi"
+)
+
+// newTestAgent starts a real AOI server with a secretary and an "echo" task type.
+func newTestAgent(t *testing.T) (*protocol.Server, *Client) {
+	t.Helper()
+	server := protocol.NewServer(nil, nil)
+	server.SetSecretary(secretary.NewSecretary(&aoi.AgentIdentity{ID: "eng-agent", Role: aoi.RoleEngineer}))
+	server.TaskExecutors().Register("echo", task.ExecutorFunc(func(ctx context.Context, t *aoi.Task) (string, error) {
+		msg, _ := t.Parameters["message"].(string)
+		return msg, nil
+	}))
+	go server.GetWSHub().Run()
+
+	ts := httptest.NewServer(server.Handler())
+	t.Cleanup(ts.Close)
+	return server, New(ts.URL, WithAgentID("sdk-test-client"))
+}
+
+func TestClient_CoreMethods(t *testing.T) {
+	_, c := newTestAgent(t)
+	ctx := context.Background()
+
+	status, err := c.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if status.Status != "online" {
+		t.Errorf("expected online, got %s", status.Status)
+	}
+
+	if _, err := c.Discover(ctx); err != nil {
+		t.Fatalf("Discover: %v", err)
+	}
+
+	answer, err := c.Query(ctx, QueryRequest{Query: "What is the status?", FromAgent: "pm-agent"})
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if answer.Answer == "" {
+		t.Error("expected a non-empty answer")
+	}
+
+	result, err := c.Execute(ctx, aoi.Task{ID: "t1", Type: "echo", Parameters: map[string]interface{}{"message": "hi"}})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if result.Status != "succeeded" || result.Output != "hi" {
+		t.Errorf("unexpected task result: %+v", result)
+	}
+
+	info, err := c.GetTask(ctx, "t1")
+	if err != nil {
+		t.Fatalf("GetTask: %v", err)
+	}
+	if info.State != "succeeded" {
+		t.Errorf("expected succeeded, got %s", info.State)
+	}
+	list, err := c.ListTasks(ctx, "succeeded")
+	if err != nil {
+		t.Fatalf("ListTasks: %v", err)
+	}
+	if list.Count != 1 {
+		t.Errorf("expected 1 succeeded task, got %d", list.Count)
+	}
+}
+
+func TestClient_ApprovalAndAudit(t *testing.T) {
+	server, c := newTestAgent(t)
+	ctx := context.Background()
+
+	// The requester and approver are the callers their tokens prove
+	server.SetAuthTokens(map[string]string{"eng-token": "eng-agent", "pm-token": "pm-tanaka"})
+	approver := New(c.baseURL, WithToken("pm-token"))
+	c = New(c.baseURL, WithAgentID("sdk-test-client"), WithToken("eng-token"))
+
+	req, err := c.CreateApproval(ctx, "eng-agent", "deploy", "Deploy to staging", nil)
+	if err != nil {
+		t.Fatalf("CreateApproval: %v", err)
+	}
+	pending, err := c.ListApprovals(ctx, "")
+	if err != nil {
+		t.Fatalf("ListApprovals: %v", err)
+	}
+	if len(pending) != 1 || pending[0].ID != req.ID {
+		t.Errorf("expected the new request to be pending, got %+v", pending)
+	}
+	if _, err := c.Approve(ctx, req.ID, "eng-agent"); !IsCode(err, CodeACLDenied) {
+		t.Errorf("expected self-approval to be denied, got %v", err)
+	}
+	approved, err := approver.Approve(ctx, req.ID, "pm-tanaka")
+	if err != nil {
+		t.Fatalf("Approve: %v", err)
+	}
+	if approved.Status != ApprovalApproved || approved.ApprovedBy != "pm-tanaka" {
+		t.Errorf("unexpected approval: %+v", approved)
+	}
+
+	entry, err := c.LogAudit(ctx, AuditEntry{EventType: "query", FromAgent: "a", ToAgent: "b", Summary: "hello", Success: true})
+	if err != nil {
+		t.Fatalf("LogAudit: %v", err)
+	}
+	got, err := c.GetAuditEntry(ctx, entry.ID)
+	if err != nil {
+		t.Fatalf("GetAuditEntry: %v", err)
+	}
+	if got.Summary != "hello" {
+		t.Errorf("unexpected audit entry: %+v", got)
+	}
+	found, err := c.SearchAudit(ctx, AuditQuery{SearchTerm: "hello"})
+	if err != nil {
+		t.Fatalf("SearchAudit: %v", err)
+	}
+	if found.TotalCount != 1 {
+		t.Errorf("expected 1 match, got %d", found.TotalCount)
+	}
+	stats, err := c.AuditStats(ctx)
+	if err != nil {
+		t.Fatalf("AuditStats: %v", err)
+	}
+	if stats.TotalEntries == 0 {
+		t.Error("expected audit entries in stats")
+	}
+}
+
+func TestClient_H2ASessions(t *testing.T) {
+	_, c := newTestAgent(t)
+	ctx := context.Background()
+
+	if err := c.H2ARegister(ctx, "eng-suzuki", "suzuki-dev", ""); err != nil {
+		t.Fatalf("H2ARegister: %v", err)
+	}
+	sessions, err := c.H2ASessions(ctx)
+	if err != nil {
+		t.Fatalf("H2ASessions: %v", err)
+	}
+	if sessions.Count != 1 || sessions.Sessions[0].SessionName != "suzuki-dev" {
+		t.Errorf("unexpected sessions: %+v", sessions)
+	}
+
+	_, err = c.H2ASend(ctx, H2ASendRequest{TargetAgentID: "eng-suzuki", FromUser: "stranger", Command: "ls"})
+	if !IsCode(err, CodeACLDenied) {
+		t.Errorf("expected ACL denied error, got %v", err)
+	}
+}
+
+func TestClient_TypedErrors(t *testing.T) {
+	_, c := newTestAgent(t)
+
+	// No context API is configured on the test agent.
+	_, err := c.Context(context.Background())
+	var rpcErr *Error
+	if !errors.As(err, &rpcErr) {
+		t.Fatalf("expected *Error, got %T: %v", err, err)
+	}
+	if rpcErr.Code != CodeMethodNotFound {
+		t.Errorf("expected method not found, got %d", rpcErr.Code)
+	}
+
+	_, err = c.Query(context.Background(), QueryRequest{Query: "hi", ToAgent: "ghost"})
+	if !IsCode(err, CodeAgentNotFound) {
+		t.Errorf("expected agent not found, got %v", err)
+	}
+}
+
+func TestClient_ContextTimeout(t *testing.T) {
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer ts.Close()
+	defer close(release)
+
+	c := New(ts.URL)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if _, err := c.Status(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+
+	c = New(ts.URL, WithTimeout(20*time.Millisecond))
+	if _, err := c.Status(context.Background()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected client timeout to apply, got %v", err)
+	}
+}
+
+func TestClient_RequestShape(t *testing.T) {
+	var got struct {
+		JSONRPC string          `json:"jsonrpc"`
+		Method  string          `json:"method"`
+		Params  json.RawMessage `json:"params"`
+	}
+	var agentHeader string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		agentHeader = r.Header.Get("X-Agent-ID")
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Write([]byte(`{"jsonrpc":"2.0","result":{"status":"stopped"},"id":1}`))
+	}))
+	defer ts.Close()
+
+	c := New(ts.URL+"/", WithAgentID("pm-01"))
+	if err := c.H2AStop(context.Background(), "stream-1"); err != nil {
+		t.Fatalf("H2AStop: %v", err)
+	}
+	if got.JSONRPC != "2.0" || got.Method != "aoi.h2a.stop" || string(got.Params) != `{"stream_id":"stream-1"}` {
+		t.Errorf("unexpected request: %+v (params %s)", got, got.Params)
+	}
+	if agentHeader != "pm-01" {
+		t.Errorf("expected X-Agent-ID pm-01, got %q", agentHeader)
+	}
+}
+
+func TestClient_HTTPError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad gateway", http.StatusBadGateway)
+	}))
+	defer ts.Close()
+
+	_, err := New(ts.URL).Status(context.Background())
+	if err == nil {
+		t.Fatal("expected error for non-200 response")
+	}
+	var rpcErr *Error
+	if errors.As(err, &rpcErr) {
+		t.Error("HTTP failures should not be reported as JSON-RPC errors")
+	}
+}
+
+func TestSubscribe_TaskComplete(t *testing.T) {
+	server, c := newTestAgent(t)
+	// task_complete only goes to the connections of the agent that submitted the task
+	server.SetAuthTokens(map[string]string{"sdk-token": "sdk-test-client"})
+	c = New(c.baseURL, WithAgentID("sdk-test-client"), WithToken("sdk-token"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sub, err := c.Subscribe(ctx)
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	defer sub.Close()
+
+	// Give the hub a moment to register the client before the task completes.
+	time.Sleep(50 * time.Millisecond)
+
+	if _, err := c.Execute(ctx, aoi.Task{ID: "async-1", Type: "echo", Async: true, Parameters: map[string]interface{}{"message": "done"}}); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+
+	for {
+		select {
+		case msg, ok := <-sub.Messages():
+			if !ok {
+				t.Fatalf("subscription closed: %v", sub.Err())
+			}
+			if msg.Type != MessageTypeTaskComplete {
+				continue
+			}
+			var result aoi.TaskResult
+			if err := msg.Decode(&result); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if result.TaskID != "async-1" || result.Output != "done" {
+				t.Errorf("unexpected task result: %+v", result)
+			}
+			return
+		case <-ctx.Done():
+			t.Fatal("timed out waiting for task_complete")
+		}
+	}
+}
+
+func TestSubscribe_ClosesWithContext(t *testing.T) {
+	_, c := newTestAgent(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sub, err := c.Subscribe(ctx, "h2a:eng-suzuki")
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	cancel()
+
+	select {
+	case _, ok := <-sub.Messages():
+		for ok {
+			_, ok = <-sub.Messages()
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("subscription did not close after context cancellation")
+	}
+}
+
+func TestWebSocketURL(t *testing.T) {
+	tests := []struct {
+		base string
+		want string
+	}{
+		{"http://localhost:8080", "ws://localhost:8080/api/v1/ws?agent_id=pm-01"},
+		{"https://pm.tailnet.ts.net/", "wss://pm.tailnet.ts.net/api/v1/ws?agent_id=pm-01"},
+	}
+	for _, tt := range tests {
+		got, err := New(tt.base, WithAgentID("pm-01")).webSocketURL()
+		if err != nil {
+			t.Fatalf("webSocketURL(%s): %v", tt.base, err)
+		}
+		if got != tt.want {
+			t.Errorf("webSocketURL(%s) = %s, want %s", tt.base, got, tt.want)
+		}
+	}
+}
+
+func TestClient_Batch(t *testing.T) {
+	_, c := newTestAgent(t)
+
+	var status StatusResult
+	var discover DiscoverResult
+	calls := []*BatchCall{
+		{Method: "aoi.status", Result: &status},
+		{Method: "aoi.unknown"},
+		{Method: "aoi.discover", Result: &discover},
+	}
+	if err := c.Batch(context.Background(), calls); err != nil {
+		t.Fatalf("Batch: %v", err)
+	}
+
+	if calls[0].Err != nil || status.Status != "online" {
+		t.Errorf("unexpected status call: err=%v result=%+v", calls[0].Err, status)
+	}
+	if !IsCode(calls[1].Err, CodeMethodNotFound) {
+		t.Errorf("expected method not found, got %v", calls[1].Err)
+	}
+	if calls[2].Err != nil {
+		t.Errorf("unexpected discover error: %v", calls[2].Err)
+	}
+}
+
+func TestClient_Notify(t *testing.T) {
+	_, c := newTestAgent(t)
+	ctx := context.Background()
+
+	if err := c.Notify(ctx, "aoi.approval.create", map[string]string{"taskType": "deploy"}); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	pending, err := c.ListApprovals(ctx, "")
+	if err != nil {
+		t.Fatalf("ListApprovals: %v", err)
+	}
+	if len(pending) != 1 {
+		t.Errorf("expected the notification to create 1 approval, got %d", len(pending))
+	}
+}
+
+func TestSubscription_Call(t *testing.T) {
+	_, c := newTestAgent(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sub, err := c.Subscribe(ctx)
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	defer sub.Close()
+
+	var status StatusResult
+	if err := sub.Call(ctx, "aoi.status", nil, &status); err != nil {
+		t.Fatalf("Call: %v", err)
+	}
+	if status.Status != "online" {
+		t.Errorf("expected online, got %s", status.Status)
+	}
+
+	err = sub.Call(ctx, "aoi.nope", nil, nil)
+	if !IsCode(err, CodeMethodNotFound) {
+		t.Errorf("expected method not found, got %v", err)
+	}
+}
+
+func TestSubscription_Handle(t *testing.T) {
+	server, c := newTestAgent(t)
+	// The agent only routes calls to a subscription whose identity is proven.
+	server.SetAuthTokens(map[string]string{"sdk-token": "sdk-test-client"})
+	c = New(c.baseURL, WithAgentID("sdk-test-client"), WithToken("sdk-token"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sub, err := c.Subscribe(ctx)
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	defer sub.Close()
+
+	sub.Handle("agent.greet", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var p struct {
+			Name string `json:"name"`
+		}
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, &Error{Code: CodeInvalidParams, Message: "Invalid params"}
+		}
+		return map[string]string{"greeting": "hello " + p.Name}, nil
+	})
+
+	// Wait for the hub to register the connection.
+	for !server.GetWSHub().IsConnected("sdk-test-client") {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	raw, err := server.GetWSHub().Call(ctx, "sdk-test-client", "agent.greet", map[string]string{"name": "pm"})
+	if err != nil {
+		t.Fatalf("server Call: %v", err)
+	}
+	if string(raw) != `{"greeting":"hello pm"}` {
+		t.Errorf("unexpected result: %s", raw)
+	}
+
+	if _, err := server.GetWSHub().Call(ctx, "sdk-test-client", "agent.unknown", nil); err == nil {
+		t.Error("expected error for unhandled method")
+	}
+}
+
+func TestClient_HeartbeatAndAgentUpdate(t *testing.T) {
+	_, c := newTestAgent(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sub, err := c.Subscribe(ctx, MessageTypeAgentUpdate)
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	defer sub.Close()
+
+	if _, err := c.Heartbeat(ctx, "qa-agent", 0); !IsCode(err, CodeAgentNotFound) {
+		t.Fatalf("expected agent not found before registration, got %v", err)
+	}
+
+	body := `{"id":"qa-agent","role":"qa","status":"offline"}`
+	resp, err := http.Post(c.BaseURL()+"/api/agents", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	resp.Body.Close()
+
+	lease, err := c.Heartbeat(ctx, "qa-agent", time.Minute)
+	if err != nil {
+		t.Fatalf("Heartbeat: %v", err)
+	}
+	if lease.Status != "online" || lease.LeaseExpiresAt.Sub(lease.LastSeen) != time.Minute {
+		t.Errorf("unexpected lease: %+v", lease)
+	}
+
+	status, err := c.AgentStatus(ctx, "qa-agent")
+	if err != nil {
+		t.Fatalf("AgentStatus: %v", err)
+	}
+	if status.AgentID != "qa-agent" || status.Status != "online" || status.LastSeen == nil {
+		t.Errorf("unexpected agent status: %+v", status)
+	}
+
+	for {
+		select {
+		case msg, ok := <-sub.Messages():
+			if !ok {
+				t.Fatalf("subscription closed: %v", sub.Err())
+			}
+			var update AgentUpdate
+			if err := msg.Decode(&update); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if update.Status != "online" {
+				continue
+			}
+			if update.AgentID != "qa-agent" || update.PreviousStatus != "offline" {
+				t.Errorf("unexpected agent update: %+v", update)
+			}
+			return
+		case <-ctx.Done():
+			t.Fatal("timed out waiting for agent_update")
+		}
+	}
+}
+
+func TestClient_FindAgents(t *testing.T) {
+	_, c := newTestAgent(t)
+	ctx := context.Background()
+
+	for _, body := range []string{
+		`{"id":"eng-agent","role":"engineer","status":"online","manifest":{"repositories":[{"name":"aoi-protocol/aoi","issues":[42]}],"actions":["read","execute"]}}`,
+		`{"id":"pm-agent","role":"pm","status":"online"}`,
+	} {
+		resp, err := http.Post(c.BaseURL()+"/api/agents", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("register: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	online := true
+	result, err := c.FindAgents(ctx, DiscoverFilter{Project: "aoi-protocol/aoi", Issue: 42, Capability: "execute", Online: &online})
+	if err != nil {
+		t.Fatalf("FindAgents: %v", err)
+	}
+	if result.Count != 1 || result.Agents[0].ID != "eng-agent" {
+		t.Errorf("expected eng-agent, got %+v", result.Agents)
+	}
+
+	result, err = c.FindAgents(ctx, DiscoverFilter{Role: aoi.RoleDesign})
+	if err != nil {
+		t.Fatalf("FindAgents: %v", err)
+	}
+	if result.Count != 0 {
+		t.Errorf("expected no design agents, got %+v", result.Agents)
+	}
+}
+
+func TestClient_SigningKey(t *testing.T) {
+	pub, key, _ := ed25519.GenerateKey(nil)
+	registry := identity.NewAgentRegistry()
+	registry.Register(&aoi.AgentIdentity{ID: "qa-agent", Role: aoi.RoleQA, PublicKey: aoi.EncodePublicKey(pub)})
+
+	server := protocol.NewServer(registry, nil)
+	server.SetSigning(signing.NewVerifier(registry, signing.ModeRequired, 0), "", nil)
+	go server.GetWSHub().Run()
+	ts := httptest.NewServer(server.Handler())
+	t.Cleanup(ts.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := New(ts.URL, WithAgentID("qa-agent")).Status(ctx); !IsCode(err, CodeUnauthenticated) {
+		t.Fatalf("expected unsigned call to be rejected, got %v", err)
+	}
+
+	c := New(ts.URL, WithAgentID("qa-agent"), WithSigningKey(key))
+	lease, err := c.Heartbeat(ctx, "", time.Minute)
+	if err != nil {
+		t.Fatalf("signed Heartbeat: %v", err)
+	}
+	if lease.AgentID != "qa-agent" {
+		t.Errorf("expected the signer's lease, got %+v", lease)
+	}
+
+	calls := []*BatchCall{{Method: "aoi.status"}, {Method: "aoi.discover", Params: map[string]string{"role": "qa"}}}
+	if err := c.Batch(ctx, calls); err != nil {
+		t.Fatalf("signed Batch: %v", err)
+	}
+	for _, call := range calls {
+		if call.Err != nil {
+			t.Errorf("%s: %v", call.Method, call.Err)
+		}
+	}
+
+	sub, err := c.Subscribe(ctx)
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	defer sub.Close()
+	if err := sub.Call(ctx, "aoi.status", nil, nil); err != nil {
+		t.Errorf("signed WebSocket call: %v", err)
+	}
+}
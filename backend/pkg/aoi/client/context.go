@@ -0,0 +1,117 @@
+package client
+
+import (
+	"context"
+	"time"
+)
+
+// ContextEntry is a single recorded piece of agent context
+type ContextEntry struct {
+	ID        string                 `json:"id"`
+	Type      string                 `json:"type"`
+	Source    string                 `json:"source"`
+	Content   string                 `json:"content"`
+	Summary   string                 `json:"summary"`
+	Project   string                 `json:"project,omitempty"`
+	File      string                 `json:"file,omitempty"`
+	Topics    []string               `json:"topics,omitempty"`
+	Timestamp time.Time              `json:"timestamp"`
+	ExpiresAt time.Time              `json:"expires_at,omitempty"`
+	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// ActivitySummary describes a recent activity in a context summary
+type ActivitySummary struct {
+	Description string    `json:"description"`
+	Type        string    `json:"type"`
+	Timestamp   time.Time `json:"timestamp"`
+	File        string    `json:"file,omitempty"`
+	Project     string    `json:"project,omitempty"`
+}
+
+// ContextSummary is the result of aoi.context
+type ContextSummary struct {
+	ActiveProject  string            `json:"active_project,omitempty"`
+	ActiveFiles    []string          `json:"active_files,omitempty"`
+	RecentActivity []ActivitySummary `json:"recent_activity,omitempty"`
+	Topics         []string          `json:"topics,omitempty"`
+	TotalEntries   int               `json:"total_entries"`
+	LastUpdated    time.Time         `json:"last_updated"`
+	WatchedDirs    []string          `json:"watched_dirs,omitempty"`
+}
+
+// ContextQuery filters aoi.context.history
+type ContextQuery struct {
+	Project string     `json:"project,omitempty"`
+	File    string     `json:"file,omitempty"`
+	Topic   string     `json:"topic,omitempty"`
+	Type    string     `json:"type,omitempty"`
+	Since   *time.Time `json:"since,omitempty"`
+	Until   *time.Time `json:"until,omitempty"`
+	Limit   int        `json:"limit,omitempty"`
+	Offset  int        `json:"offset,omitempty"`
+}
+
+// ContextHistory is a page of context entries
+type ContextHistory struct {
+	Entries    []ContextEntry `json:"entries"`
+	TotalCount int            `json:"total_count"`
+	Offset     int            `json:"offset"`
+	Limit      int            `json:"limit"`
+	HasMore    bool           `json:"has_more"`
+}
+
+// WatchRequest is the params of aoi.context.watch
+type WatchRequest struct {
+	Path         string   `json:"path"`
+	Recursive    bool     `json:"recursive"`
+	Patterns     []string `json:"patterns,omitempty"`
+	IgnoreHidden bool     `json:"ignore_hidden"`
+}
+
+// WatchResponse is the result of aoi.context.watch
+type WatchResponse struct {
+	Path     string    `json:"path"`
+	Watching bool      `json:"watching"`
+	Message  string    `json:"message,omitempty"`
+	AddedAt  time.Time `json:"added_at"`
+}
+
+// Context returns the agent's context summary (aoi.context)
+func (c *Client) Context(ctx context.Context) (*ContextSummary, error) {
+	var result ContextSummary
+	if err := c.Call(ctx, "aoi.context", nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ContextHistory queries recorded context entries (aoi.context.history)
+func (c *Client) ContextHistory(ctx context.Context, q ContextQuery) (*ContextHistory, error) {
+	var result ContextHistory
+	if err := c.Call(ctx, "aoi.context.history", q, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ContextWatch adds a directory to the agent's file watcher (aoi.context.watch)
+func (c *Client) ContextWatch(ctx context.Context, req WatchRequest) (*WatchResponse, error) {
+	var result WatchResponse
+	if err := c.Call(ctx, "aoi.context.watch", req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// RecordActivity records an activity in the agent's context (aoi.context.activity)
+func (c *Client) RecordActivity(ctx context.Context, activityType, description string, metadata map[string]interface{}) error {
+	params := map[string]interface{}{
+		"type":        activityType,
+		"description": description,
+	}
+	if metadata != nil {
+		params["metadata"] = metadata
+	}
+	return c.Call(ctx, "aoi.context.activity", params, nil)
+}
@@ -0,0 +1,95 @@
+package client
+
+import (
+	"context"
+	"time"
+)
+
+// H2ASession links an agent ID to a tmux session
+type H2ASession struct {
+	AgentID      string    `json:"agent_id"`
+	SessionName  string    `json:"session_name"`
+	PaneName     string    `json:"pane_name,omitempty"`
+	RegisteredAt time.Time `json:"registered_at"`
+}
+
+// H2ASessionList is the result of aoi.h2a.sessions
+type H2ASessionList struct {
+	Sessions []H2ASession `json:"sessions"`
+	Count    int          `json:"count"`
+}
+
+// H2ASendRequest is the params of aoi.h2a.send
+type H2ASendRequest struct {
+	TargetAgentID string `json:"target_agent_id"`
+	FromUser      string `json:"from_user"`
+	Command       string `json:"command"`
+	CaptureOutput bool   `json:"capture_output"`
+}
+
+// H2ASendResult is the result of aoi.h2a.send
+type H2ASendResult struct {
+	Status   string `json:"status"`
+	Output   string `json:"output,omitempty"`
+	StreamID string `json:"stream_id,omitempty"`
+}
+
+// H2AStreamRequest is the params of aoi.h2a.stream
+type H2AStreamRequest struct {
+	TargetAgentID string `json:"target_agent_id"`
+	FromUser      string `json:"from_user"`
+	Command       string `json:"command"`
+	IntervalMs    int    `json:"interval_ms,omitempty"`
+}
+
+// H2AStreamResult is the result of aoi.h2a.stream.
+// Output is published on the WebSocket topic Topic.
+type H2AStreamResult struct {
+	Status   string `json:"status"`
+	StreamID string `json:"stream_id"`
+	Topic    string `json:"topic"`
+}
+
+// H2ARegister links an agent ID to a tmux session (aoi.h2a.register)
+func (c *Client) H2ARegister(ctx context.Context, agentID, sessionName, paneName string) error {
+	params := map[string]string{
+		"agent_id":     agentID,
+		"session_name": sessionName,
+	}
+	if paneName != "" {
+		params["pane_name"] = paneName
+	}
+	return c.Call(ctx, "aoi.h2a.register", params, nil)
+}
+
+// H2ASessions lists registered tmux sessions (aoi.h2a.sessions)
+func (c *Client) H2ASessions(ctx context.Context) (*H2ASessionList, error) {
+	var result H2ASessionList
+	if err := c.Call(ctx, "aoi.h2a.sessions", nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// H2ASend sends a command to an agent's tmux session (aoi.h2a.send)
+func (c *Client) H2ASend(ctx context.Context, req H2ASendRequest) (*H2ASendResult, error) {
+	var result H2ASendResult
+	if err := c.Call(ctx, "aoi.h2a.send", req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// H2AStream sends a command and starts streaming its output over WebSocket (aoi.h2a.stream)
+func (c *Client) H2AStream(ctx context.Context, req H2AStreamRequest) (*H2AStreamResult, error) {
+	var result H2AStreamResult
+	if err := c.Call(ctx, "aoi.h2a.stream", req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// H2AStop stops an active output stream (aoi.h2a.stop)
+func (c *Client) H2AStop(ctx context.Context, streamID string) error {
+	return c.Call(ctx, "aoi.h2a.stop", map[string]string{"stream_id": streamID}, nil)
+}
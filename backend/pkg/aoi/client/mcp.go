@@ -0,0 +1,138 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// MCPImplementation identifies an MCP server implementation
+type MCPImplementation struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// MCPServerStatus is the connection state of one configured MCP server
+type MCPServerStatus struct {
+	Name       string             `json:"name"`
+	Connected  bool               `json:"connected"`
+	ServerInfo *MCPImplementation `json:"server_info,omitempty"`
+}
+
+// MCPStatus is the result of aoi.mcp.status
+type MCPStatus struct {
+	Servers         []MCPServerStatus `json:"servers"`
+	ServerCount     int               `json:"server_count"`
+	CachedResources int               `json:"cached_resources"`
+	ToolMappings    int               `json:"tool_mappings"`
+}
+
+// MCPTool describes a tool exposed by an MCP server
+type MCPTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"inputSchema"`
+}
+
+// MCPResource describes a resource exposed by an MCP server
+type MCPResource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+// MCPResourceContent is the content of a read resource
+type MCPResourceContent struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text,omitempty"`
+	Blob     string `json:"blob,omitempty"`
+}
+
+// MCPContentBlock is one block of a tool call result
+type MCPContentBlock struct {
+	Type     string              `json:"type"`
+	Text     string              `json:"text,omitempty"`
+	MimeType string              `json:"mimeType,omitempty"`
+	Data     string              `json:"data,omitempty"`
+	Resource *MCPResourceContent `json:"resource,omitempty"`
+}
+
+// MCPToolResult is the result of aoi.mcp.call
+type MCPToolResult struct {
+	Content []MCPContentBlock `json:"content"`
+	IsError bool              `json:"isError,omitempty"`
+}
+
+// MCPDiscovery is the result of aoi.mcp.discover
+type MCPDiscovery struct {
+	ServerName   string             `json:"server_name"`
+	ServerInfo   *MCPImplementation `json:"server_info"`
+	Tools        []MCPTool          `json:"tools"`
+	Resources    []MCPResource      `json:"resources"`
+	Prompts      json.RawMessage    `json:"prompts,omitempty"`
+	Capabilities json.RawMessage    `json:"capabilities,omitempty"`
+}
+
+// MCPStatus returns the state of the agent's MCP servers (aoi.mcp.status)
+func (c *Client) MCPStatus(ctx context.Context) (*MCPStatus, error) {
+	var result MCPStatus
+	if err := c.Call(ctx, "aoi.mcp.status", nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// MCPDiscover connects to an MCP server and lists its capabilities (aoi.mcp.discover)
+func (c *Client) MCPDiscover(ctx context.Context, serverName string) (*MCPDiscovery, error) {
+	var result MCPDiscovery
+	if err := c.Call(ctx, "aoi.mcp.discover", map[string]string{"server_name": serverName}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// MCPTools lists the tools of an MCP server (aoi.mcp.tools)
+func (c *Client) MCPTools(ctx context.Context, serverName string) ([]MCPTool, error) {
+	var result []MCPTool
+	if err := c.Call(ctx, "aoi.mcp.tools", map[string]string{"server_name": serverName}, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// MCPCall calls a tool on an MCP server (aoi.mcp.call)
+func (c *Client) MCPCall(ctx context.Context, serverName, toolName string, arguments map[string]interface{}) (*MCPToolResult, error) {
+	params := map[string]interface{}{
+		"server_name": serverName,
+		"tool_name":   toolName,
+		"arguments":   arguments,
+	}
+	var result MCPToolResult
+	if err := c.Call(ctx, "aoi.mcp.call", params, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// MCPResources lists the resources of an MCP server (aoi.mcp.resources)
+func (c *Client) MCPResources(ctx context.Context, serverName string) ([]MCPResource, error) {
+	var result []MCPResource
+	if err := c.Call(ctx, "aoi.mcp.resources", map[string]string{"server_name": serverName}, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// MCPRead reads a resource from an MCP server (aoi.mcp.read)
+func (c *Client) MCPRead(ctx context.Context, serverName, uri string) ([]MCPResourceContent, error) {
+	params := map[string]string{
+		"server_name": serverName,
+		"uri":         uri,
+	}
+	var result []MCPResourceContent
+	if err := c.Call(ctx, "aoi.mcp.read", params, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,379 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// WebSocket message types pushed by AOI agents
+const (
+	MessageTypeAgentUpdate     = "agent_update"
+	MessageTypeAuditEntry      = "audit_entry"
+	MessageTypeNotification    = "notification"
+	MessageTypeApprovalRequest = "approval_request"
+	MessageTypeTaskComplete    = "task_complete"
+	MessageTypeH2AOutput       = "h2a_output"
+	MessageTypeError           = "error"
+	MessageTypePong            = "pong"
+)
+
+// Message is a WebSocket message received from an agent
+type Message struct {
+	Type      string          `json:"type"`
+	Payload   json.RawMessage `json:"payload,omitempty"`
+	Timestamp time.Time       `json:"timestamp"`
+	ID        string          `json:"id,omitempty"`
+}
+
+// Decode unmarshals the message payload into v
+func (m *Message) Decode(v interface{}) error {
+	return json.Unmarshal(m.Payload, v)
+}
+
+// RequestHandler answers a JSON-RPC request the agent sends over the WebSocket.
+// Returning *Error controls the error code; other errors are reported as internal errors.
+type RequestHandler func(ctx context.Context, params json.RawMessage) (interface{}, error)
+
+// Subscription is a live WebSocket connection to an agent. Besides pushed
+// messages it carries JSON-RPC calls in both directions.
+type Subscription struct {
+	client    *Client
+	conn      *websocket.Conn
+	messages  chan Message
+	writeMu   sync.Mutex
+	closeOnce sync.Once
+	closing   chan struct{}
+	done      chan struct{}
+	err       error
+
+	// ctx is cancelled when the connection ends; it bounds request handlers
+	ctx        context.Context
+	cancel     context.CancelFunc
+	requestID  int64
+	pending    map[int64]chan rpcResponse
+	pendingMu  sync.Mutex
+	handlers   map[string]RequestHandler
+	handlersMu sync.RWMutex
+}
+
+// Subscribe opens a WebSocket connection to the agent and subscribes to topics
+// in addition to the agent's default topics. The subscription is closed when
+// ctx is cancelled or Close is called.
+func (c *Client) Subscribe(ctx context.Context, topics ...string) (*Subscription, error) {
+	wsURL, err := c.webSocketURL()
+	if err != nil {
+		return nil, err
+	}
+
+	header := http.Header{}
+	if c.agentID != "" {
+		header.Set("X-Agent-ID", c.agentID)
+	}
+	if c.token != "" {
+		header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, wsURL, header)
+	if err != nil {
+		return nil, fmt.Errorf("websocket dial %s: %w", wsURL, err)
+	}
+
+	subCtx, cancel := context.WithCancel(context.Background())
+	s := &Subscription{
+		client:   c,
+		conn:     conn,
+		messages: make(chan Message, 64),
+		closing:  make(chan struct{}),
+		done:     make(chan struct{}),
+		ctx:      subCtx,
+		cancel:   cancel,
+		pending:  make(map[int64]chan rpcResponse),
+		handlers: make(map[string]RequestHandler),
+	}
+
+	if len(topics) > 0 {
+		if err := s.Subscribe(topics...); err != nil {
+			cancel()
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	go s.readLoop()
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.Close()
+		case <-s.done:
+		}
+	}()
+
+	return s, nil
+}
+
+// Messages returns the channel of received messages. It is closed when the
+// subscription ends; Err reports why.
+func (s *Subscription) Messages() <-chan Message {
+	return s.messages
+}
+
+// Err returns the error that ended the subscription, or nil while it is active
+// or after a clean Close.
+func (s *Subscription) Err() error {
+	select {
+	case <-s.done:
+		return s.err
+	default:
+		return nil
+	}
+}
+
+// Subscribe adds topics to the subscription (e.g. "h2a:<agent_id>")
+func (s *Subscription) Subscribe(topics ...string) error {
+	return s.send("subscribe", topics)
+}
+
+// Unsubscribe removes topics from the subscription
+func (s *Subscription) Unsubscribe(topics ...string) error {
+	return s.send("unsubscribe", topics)
+}
+
+// Close ends the subscription
+func (s *Subscription) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.closing)
+		s.writeMu.Lock()
+		s.conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+		s.writeMu.Unlock()
+		err = s.conn.Close()
+	})
+	return err
+}
+
+// Call invokes method over the WebSocket and decodes the result into result.
+// A JSON-RPC error returned by the agent is surfaced as *Error.
+func (s *Subscription) Call(ctx context.Context, method string, params interface{}, result interface{}) error {
+	id := atomic.AddInt64(&s.requestID, 1)
+	ch := make(chan rpcResponse, 1)
+
+	s.pendingMu.Lock()
+	if s.pending == nil {
+		s.pendingMu.Unlock()
+		return fmt.Errorf("%s: subscription closed", method)
+	}
+	s.pending[id] = ch
+	s.pendingMu.Unlock()
+
+	defer func() {
+		s.pendingMu.Lock()
+		if s.pending != nil {
+			delete(s.pending, id)
+		}
+		s.pendingMu.Unlock()
+	}()
+
+	req, err := s.client.newRequest(method, params, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+	if err := s.writeJSON(req); err != nil {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+
+	select {
+	case resp, ok := <-ch:
+		if !ok {
+			return fmt.Errorf("%s: subscription closed", method)
+		}
+		return decodeResult(method, &resp, result)
+	case <-ctx.Done():
+		return fmt.Errorf("%s: %w", method, ctx.Err())
+	}
+}
+
+// Handle registers h to answer JSON-RPC requests for method sent by the agent.
+// This lets an agent that cannot accept connections serve calls over its socket.
+func (s *Subscription) Handle(method string, h RequestHandler) {
+	s.handlersMu.Lock()
+	defer s.handlersMu.Unlock()
+	s.handlers[method] = h
+}
+
+func (s *Subscription) send(msgType string, topics []string) error {
+	payload, err := json.Marshal(map[string][]string{"topics": topics})
+	if err != nil {
+		return err
+	}
+	return s.writeJSON(Message{Type: msgType, Payload: payload, Timestamp: time.Now()})
+}
+
+func (s *Subscription) writeJSON(v interface{}) error {
+	msg, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	return s.conn.WriteMessage(websocket.TextMessage, msg)
+}
+
+// readLoop decodes incoming frames. Agents may batch several newline-separated
+// messages into one frame.
+func (s *Subscription) readLoop() {
+	defer func() {
+		s.cancel()
+		s.failPending()
+		close(s.messages)
+		close(s.done)
+	}()
+
+	for {
+		_, data, err := s.conn.ReadMessage()
+		if err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure) && !errors.Is(err, net.ErrClosed) {
+				s.err = err
+			}
+			return
+		}
+
+		for _, line := range bytes.Split(data, []byte("\n")) {
+			if len(bytes.TrimSpace(line)) == 0 {
+				continue
+			}
+			if s.handleRPC(line) {
+				continue
+			}
+			var msg Message
+			if err := json.Unmarshal(line, &msg); err != nil {
+				continue
+			}
+			select {
+			case s.messages <- msg:
+			case <-s.closing:
+				return
+			}
+		}
+	}
+}
+
+// wsEnvelope holds the members used to tell JSON-RPC frames apart from messages
+type wsEnvelope struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// handleRPC handles a JSON-RPC request or response and reports whether line was one
+func (s *Subscription) handleRPC(line []byte) bool {
+	var env wsEnvelope
+	if err := json.Unmarshal(line, &env); err != nil || env.JSONRPC == "" {
+		return false
+	}
+
+	if env.Method != "" {
+		go s.serve(env)
+		return true
+	}
+
+	var resp rpcResponse
+	if err := json.Unmarshal(line, &resp); err != nil {
+		return true
+	}
+	id, ok := resp.id()
+	if !ok {
+		return true
+	}
+	s.pendingMu.Lock()
+	ch, ok := s.pending[id]
+	if ok {
+		delete(s.pending, id)
+	}
+	s.pendingMu.Unlock()
+	if ok {
+		ch <- resp
+	}
+	return true
+}
+
+// serve runs the handler for a request from the agent and writes its response
+func (s *Subscription) serve(req wsEnvelope) {
+	s.handlersMu.RLock()
+	h := s.handlers[req.Method]
+	s.handlersMu.RUnlock()
+
+	var result interface{}
+	var err error
+	if h == nil {
+		err = &Error{Code: CodeMethodNotFound, Message: "Method not found"}
+	} else {
+		result, err = h(s.ctx, req.Params)
+	}
+
+	// Notifications get no response
+	if req.ID == nil {
+		return
+	}
+
+	resp := struct {
+		JSONRPC string          `json:"jsonrpc"`
+		Result  interface{}     `json:"result,omitempty"`
+		Error   *Error          `json:"error,omitempty"`
+		ID      json.RawMessage `json:"id"`
+	}{JSONRPC: "2.0", Result: result, ID: req.ID}
+	if err != nil {
+		var rpcErr *Error
+		if !errors.As(err, &rpcErr) {
+			rpcErr = &Error{Code: CodeInternalError, Message: err.Error()}
+		}
+		resp.Result = nil
+		resp.Error = rpcErr
+	}
+	s.writeJSON(resp)
+}
+
+// failPending ends pending calls once the connection is gone
+func (s *Subscription) failPending() {
+	s.pendingMu.Lock()
+	defer s.pendingMu.Unlock()
+	for _, ch := range s.pending {
+		close(ch)
+	}
+	s.pending = nil
+}
+
+// webSocketURL derives the ws:// or wss:// URL from the client's base URL
+func (c *Client) webSocketURL() (string, error) {
+	u, err := url.Parse(c.baseURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid base URL: %w", err)
+	}
+	switch u.Scheme {
+	case "https":
+		u.Scheme = "wss"
+	case "http", "":
+		u.Scheme = "ws"
+	}
+	u.Path = strings.TrimRight(u.Path, "/") + WebSocketPath
+	if c.agentID != "" {
+		q := u.Query()
+		q.Set("agent_id", c.agentID)
+		u.RawQuery = q.Encode()
+	}
+	return u.String(), nil
+}
@@ -0,0 +1,174 @@
+package aoi
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// signatureContext prefixes every signed payload so AOI signatures cannot be
+// replayed as signatures over anything else
+const signatureContext = "aoi-rpc-sig-v1"
+
+// Signature authenticates a JSON-RPC request as sent by AgentID. It travels
+// in the request's "auth" member alongside method and params.
+type Signature struct {
+	AgentID string `json:"agent_id"`
+	// Timestamp is when the request was signed, in Unix milliseconds
+	Timestamp int64 `json:"ts"`
+	// Nonce is unique per request so a captured request cannot be replayed
+	Nonce string `json:"nonce"`
+	// Sig is the base64 Ed25519 signature over SignedPayload
+	Sig string `json:"sig"`
+}
+
+// Time returns the signing time
+func (s *Signature) Time() time.Time {
+	return time.UnixMilli(s.Timestamp)
+}
+
+// SignedPayload returns the bytes signed for a request: the signer, time,
+// nonce, method and a hash of the compacted params, one per line
+func SignedPayload(agentID string, timestamp int64, nonce, method string, params json.RawMessage) ([]byte, error) {
+	var compact bytes.Buffer
+	if len(params) > 0 {
+		if err := json.Compact(&compact, params); err != nil {
+			return nil, fmt.Errorf("invalid params: %w", err)
+		}
+	}
+	sum := sha256.Sum256(compact.Bytes())
+
+	var b bytes.Buffer
+	for _, field := range []string{signatureContext, agentID, strconv.FormatInt(timestamp, 10), nonce, method} {
+		b.WriteString(field)
+		b.WriteByte('\n')
+	}
+	b.WriteString(hex.EncodeToString(sum[:]))
+	return b.Bytes(), nil
+}
+
+// SignRequest signs a request from agentID with key
+func SignRequest(key ed25519.PrivateKey, agentID, method string, params json.RawMessage) (*Signature, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	sig := &Signature{
+		AgentID:   agentID,
+		Timestamp: time.Now().UnixMilli(),
+		Nonce:     base64.RawURLEncoding.EncodeToString(nonce),
+	}
+	payload, err := SignedPayload(sig.AgentID, sig.Timestamp, sig.Nonce, method, params)
+	if err != nil {
+		return nil, err
+	}
+	sig.Sig = base64.StdEncoding.EncodeToString(ed25519.Sign(key, payload))
+	return sig, nil
+}
+
+// ErrBadSignature is returned when a signature does not verify
+var ErrBadSignature = errors.New("signature does not verify")
+
+// VerifyRequest checks that sig was made over method and params by the holder of pub.
+// Freshness and replay are left to the caller.
+func VerifyRequest(pub ed25519.PublicKey, method string, params json.RawMessage, sig *Signature) error {
+	raw, err := base64.StdEncoding.DecodeString(sig.Sig)
+	if err != nil || len(raw) != ed25519.SignatureSize {
+		return ErrBadSignature
+	}
+	payload, err := SignedPayload(sig.AgentID, sig.Timestamp, sig.Nonce, method, params)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(pub, payload, raw) {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+// EncodePublicKey encodes a key for AgentIdentity.PublicKey
+func EncodePublicKey(pub ed25519.PublicKey) string {
+	return base64.StdEncoding.EncodeToString(pub)
+}
+
+// ParsePublicKey decodes AgentIdentity.PublicKey
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+	if len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid public key: %d bytes, want %d", len(raw), ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+// identityMethod is the method name an identity signature is made over
+const identityMethod = "aoi.identity"
+
+// signedIdentity is the part of an AgentIdentity its signature covers. Status,
+// leases and gossip versions change without the agent and are not signed.
+type signedIdentity struct {
+	ID              string                 `json:"id"`
+	Role            AgentRole              `json:"role"`
+	Owner           string                 `json:"owner"`
+	Capabilities    []string               `json:"capabilities"`
+	Endpoint        string                 `json:"endpoint"`
+	TailscaleNodeID string                 `json:"tailscale_node_id"`
+	Metadata        map[string]interface{} `json:"metadata"`
+	RoleDisplayName string                 `json:"role_display_name"`
+	PublicKey       string                 `json:"public_key"`
+	Manifest        *CapabilityManifest    `json:"manifest"`
+}
+
+func identityPayload(agent *AgentIdentity) (json.RawMessage, error) {
+	return json.Marshal(signedIdentity{
+		ID:              agent.ID,
+		Role:            agent.Role,
+		Owner:           agent.Owner,
+		Capabilities:    agent.Capabilities,
+		Endpoint:        agent.Endpoint,
+		TailscaleNodeID: agent.TailscaleNodeID,
+		Metadata:        agent.Metadata,
+		RoleDisplayName: agent.RoleDisplayName,
+		PublicKey:       agent.PublicKey,
+		Manifest:        agent.Manifest,
+	})
+}
+
+// SignIdentity signs agent's identity with its own key and sets agent.Signature.
+// Sign again after changing any signed field.
+func SignIdentity(key ed25519.PrivateKey, agent *AgentIdentity) error {
+	payload, err := identityPayload(agent)
+	if err != nil {
+		return err
+	}
+	sig, err := SignRequest(key, agent.ID, identityMethod, payload)
+	if err != nil {
+		return err
+	}
+	agent.Signature = sig
+	return nil
+}
+
+// VerifyIdentity checks that agent carries a signature made over its current
+// identity by the agent itself with the key pub
+func VerifyIdentity(pub ed25519.PublicKey, agent *AgentIdentity) error {
+	if agent.Signature == nil || agent.Signature.AgentID != agent.ID {
+		return ErrBadSignature
+	}
+	payload, err := identityPayload(agent)
+	if err != nil {
+		return err
+	}
+	return VerifyRequest(pub, identityMethod, payload, agent.Signature)
+}
@@ -0,0 +1,107 @@
+package aoi
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"testing"
+)
+
+func TestSignRequest_Verify(t *testing.T) {
+	pub, key, _ := ed25519.GenerateKey(nil)
+	params := json.RawMessage(`{"agent_id":"eng-agent","ttl":60}`)
+
+	sig, err := SignRequest(key, "eng-agent", "aoi.heartbeat", params)
+	if err != nil {
+		t.Fatalf("SignRequest failed: %v", err)
+	}
+	if sig.AgentID != "eng-agent" || sig.Nonce == "" || sig.Timestamp == 0 {
+		t.Errorf("incomplete signature: %+v", sig)
+	}
+	if err := VerifyRequest(pub, "aoi.heartbeat", params, sig); err != nil {
+		t.Errorf("expected signature to verify, got %v", err)
+	}
+
+	// Whitespace in params does not matter
+	spaced := json.RawMessage(`{ "agent_id": "eng-agent", "ttl": 60 }`)
+	if err := VerifyRequest(pub, "aoi.heartbeat", spaced, sig); err != nil {
+		t.Errorf("expected signature to verify over reformatted params, got %v", err)
+	}
+
+	tampered := []struct {
+		name   string
+		method string
+		params json.RawMessage
+		mutate func(s *Signature)
+	}{
+		{"method", "aoi.execute", params, nil},
+		{"params", "aoi.heartbeat", json.RawMessage(`{"agent_id":"qa-agent","ttl":60}`), nil},
+		{"agent", "aoi.heartbeat", params, func(s *Signature) { s.AgentID = "qa-agent" }},
+		{"timestamp", "aoi.heartbeat", params, func(s *Signature) { s.Timestamp++ }},
+		{"nonce", "aoi.heartbeat", params, func(s *Signature) { s.Nonce = "other" }},
+		{"garbage", "aoi.heartbeat", params, func(s *Signature) { s.Sig = "not base64!" }},
+	}
+	for _, tt := range tampered {
+		s := *sig
+		if tt.mutate != nil {
+			tt.mutate(&s)
+		}
+		if err := VerifyRequest(pub, tt.method, tt.params, &s); err == nil {
+			t.Errorf("%s: expected tampered request to fail", tt.name)
+		}
+	}
+
+	otherPub, _, _ := ed25519.GenerateKey(nil)
+	if err := VerifyRequest(otherPub, "aoi.heartbeat", params, sig); err != ErrBadSignature {
+		t.Errorf("expected ErrBadSignature for another key, got %v", err)
+	}
+}
+
+func TestSignRequest_NoParams(t *testing.T) {
+	pub, key, _ := ed25519.GenerateKey(nil)
+	sig, err := SignRequest(key, "pm-agent", "aoi.status", nil)
+	if err != nil {
+		t.Fatalf("SignRequest failed: %v", err)
+	}
+	if err := VerifyRequest(pub, "aoi.status", nil, sig); err != nil {
+		t.Errorf("expected signature without params to verify, got %v", err)
+	}
+}
+
+func TestParsePublicKey(t *testing.T) {
+	pub, _, _ := ed25519.GenerateKey(nil)
+	parsed, err := ParsePublicKey(EncodePublicKey(pub))
+	if err != nil || !parsed.Equal(pub) {
+		t.Errorf("expected round trip, got %v (err %v)", parsed, err)
+	}
+
+	for _, bad := range []string{"", "not base64!", "c2hvcnQ="} {
+		if _, err := ParsePublicKey(bad); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestSignIdentity(t *testing.T) {
+	pub, key, _ := ed25519.GenerateKey(nil)
+	agent := &AgentIdentity{ID: "eng-agent", Role: RoleEngineer, Endpoint: "http://eng:8080", Metadata: map[string]interface{}{"team": "core"}}
+	if err := SignIdentity(key, agent); err != nil {
+		t.Fatalf("SignIdentity failed: %v", err)
+	}
+
+	// Liveness and gossip versions are not signed, and a JSON round trip keeps the signature
+	agent.Status, agent.Version, agent.Origin = "offline", 7, "pm-agent"
+	raw, _ := json.Marshal(agent)
+	var relayed AgentIdentity
+	json.Unmarshal(raw, &relayed)
+	if err := VerifyIdentity(pub, &relayed); err != nil {
+		t.Errorf("expected the relayed identity to verify, got %v", err)
+	}
+
+	relayed.Role = RolePM
+	if err := VerifyIdentity(pub, &relayed); err != ErrBadSignature {
+		t.Errorf("expected a changed role to fail, got %v", err)
+	}
+	if err := VerifyIdentity(pub, &AgentIdentity{ID: "eng-agent"}); err != ErrBadSignature {
+		t.Errorf("expected an unsigned identity to fail, got %v", err)
+	}
+}
@@ -1,8 +1,12 @@
 package aoi
 
-// AgentRole represents the role of an agent
+import "time"
+
+// AgentRole represents the role of an agent. Besides the built-in roles below,
+// agents may use roles defined in their configuration.
 type AgentRole string
 
+// Built-in roles
 const (
 	RolePM       AgentRole = "pm"
 	RoleEngineer AgentRole = "engineer"
@@ -20,6 +24,49 @@ type AgentIdentity struct {
 	Status          string                 `json:"status"`
 	TailscaleNodeID string                 `json:"tailscale_node_id,omitempty"`
 	Metadata        map[string]interface{} `json:"metadata,omitempty"`
+	// RoleDisplayName is the role's human-readable name, e.g. "Site Reliability Engineer"
+	RoleDisplayName string `json:"role_display_name,omitempty"`
+	// PublicKey is the agent's base64 Ed25519 key that its signed requests verify against
+	PublicKey string `json:"public_key,omitempty"`
+	// Manifest declares what the agent holds context for and can do
+	Manifest *CapabilityManifest `json:"manifest,omitempty"`
+	// LastSeen is when the agent last sent a heartbeat
+	LastSeen *time.Time `json:"last_seen,omitempty"`
+	// LeaseExpiresAt is when the agent is marked offline unless it heartbeats again.
+	// Agents without a lease are never expired.
+	LeaseExpiresAt *time.Time `json:"lease_expires_at,omitempty"`
+	// LeaseHolder is the node that granted the lease; only it marks the agent offline
+	LeaseHolder string `json:"lease_holder,omitempty"`
+	// Version and Origin order changes to this entry when registries gossip:
+	// the higher Version wins and ties go to the greater Origin (the node that made the change)
+	Version uint64 `json:"version,omitempty"`
+	Origin  string `json:"origin,omitempty"`
+	// Signature is the agent's own signature over its identity (see SignIdentity),
+	// which lets registries accept the entry from any peer that relays it
+	Signature *Signature `json:"signature,omitempty"`
+}
+
+// Actions an agent can expose in its capability manifest
+const (
+	ActionRead    = "read"
+	ActionWrite   = "write"
+	ActionExecute = "execute"
+)
+
+// CapabilityManifest is an agent's capability declaration: the context it
+// holds, the actions it exposes and the MCP tools it can proxy
+type CapabilityManifest struct {
+	Repositories []RepositoryContext `json:"repositories,omitempty"`
+	Actions      []string            `json:"actions,omitempty"`
+	MCPTools     []string            `json:"mcp_tools,omitempty"`
+}
+
+// RepositoryContext is a repository an agent holds context for
+type RepositoryContext struct {
+	// Name is the repository, e.g. "aoi-protocol/aoi"
+	Name string `json:"name"`
+	// Issues are the issue numbers the agent is working on
+	Issues []int `json:"issues,omitempty"`
 }
 
 // Query represents a query message